@@ -1,7 +1,9 @@
 package api
 
 import (
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -9,6 +11,9 @@ import (
 	"nofx/config"
 	"nofx/decision"
 	"nofx/manager"
+	"nofx/market"
+	"nofx/mcp"
+	"nofx/replay"
 	"strconv"
 	"strings"
 	"time"
@@ -109,6 +114,31 @@ func (s *Server) setupRoutes() {
 			protected.POST("/traders/:id/start", s.handleStartTrader)
 			protected.POST("/traders/:id/stop", s.handleStopTrader)
 			protected.PUT("/traders/:id/prompt", s.handleUpdateTraderPrompt)
+			protected.PUT("/traders/:id/prompt-template", s.handleUpdateTraderPromptTemplate)
+			protected.PUT("/traders/:id/prompt-experiment", s.handleUpdateTraderPromptExperiment)
+			protected.PUT("/traders/:id/signal-analyzers", s.handleUpdateTraderSignalAnalyzers)
+			protected.PUT("/traders/:id/decision-mode", s.handleUpdateTraderDecisionMode)
+			protected.PUT("/traders/:id/locale", s.handleUpdateTraderLocale)
+			protected.PUT("/traders/:id/change-detector", s.handleUpdateTraderChangeDetector)
+			protected.PUT("/traders/:id/universe-filter", s.handleUpdateTraderUniverseFilter)
+			protected.PUT("/traders/:id/data-profile", s.handleUpdateTraderDataProfile)
+			protected.PUT("/traders/:id/alert-trigger", s.handleUpdateTraderAlertTrigger)
+			protected.POST("/traders/:id/replay", s.handleReplayDecisions)
+
+			// 可用的信号分析器（名称、说明及默认参数）
+			protected.GET("/signal-analyzers", s.handleGetSignalAnalyzers)
+
+			// 系统提示词模板重新加载（返回每个模板的渲染错误）
+			protected.POST("/prompt-templates/reload", s.handleReloadPromptTemplates)
+
+			// 用户提示词模板（数据库存储，支持版本历史、差异对比和回滚）
+			protected.GET("/user/prompt-templates", s.handleGetUserPromptTemplates)
+			protected.POST("/user/prompt-templates", s.handleCreateUserPromptTemplate)
+			protected.PUT("/user/prompt-templates/:id", s.handleUpdateUserPromptTemplate)
+			protected.DELETE("/user/prompt-templates/:id", s.handleDeleteUserPromptTemplate)
+			protected.GET("/user/prompt-templates/:id/versions", s.handleGetUserPromptTemplateVersions)
+			protected.GET("/user/prompt-templates/:id/diff", s.handleDiffUserPromptTemplate)
+			protected.POST("/user/prompt-templates/:id/rollback", s.handleRollbackUserPromptTemplate)
 
 			// AI模型配置
 			protected.GET("/models", s.handleGetModelConfigs)
@@ -124,12 +154,16 @@ func (s *Server) setupRoutes() {
 
 			// 指定trader的数据（使用query参数 ?trader_id=xxx）
 			protected.GET("/status", s.handleStatus)
+			protected.GET("/market/health", s.handleMarketHealth)
+			protected.GET("/market/alerts", s.handleMarketAlerts)
 			protected.GET("/account", s.handleAccount)
 			protected.GET("/positions", s.handlePositions)
 			protected.GET("/decisions", s.handleDecisions)
 			protected.GET("/decisions/latest", s.handleLatestDecisions)
+			protected.GET("/decisions/snapshot", s.handleDecisionSnapshot)
 			protected.GET("/statistics", s.handleStatistics)
 			protected.GET("/performance", s.handlePerformance)
+			protected.GET("/experiment", s.handleExperimentReport)
 		}
 	}
 }
@@ -228,6 +262,9 @@ type CreateTraderRequest struct {
 	IsCrossMargin        *bool   `json:"is_cross_margin"`        // 指针类型，nil表示使用默认值true
 	UseCoinPool          bool    `json:"use_coin_pool"`
 	UseOITop             bool    `json:"use_oi_top"`
+	AIRepairRounds       *int    `json:"ai_repair_rounds"`  // 决策修复轮数，nil表示使用默认值1
+	BlockStaleOpens      bool    `json:"block_stale_opens"` // 行情数据过时时禁止开新仓
+	MaxSlippagePct       float64 `json:"max_slippage_pct"`  // 市价开仓允许的最大预估滑点（%，0=不限制）
 }
 
 type ModelConfig struct {
@@ -255,6 +292,9 @@ type UpdateModelConfigRequest struct {
 		APIKey          string `json:"api_key"`
 		CustomAPIURL    string `json:"custom_api_url"`
 		CustomModelName string `json:"custom_model_name"`
+		OutputMode      string `json:"output_mode"`     // 输出模式: text/json_object/json_schema，为空表示text
+		ContextWindow   int    `json:"context_window"`  // 上下文窗口（token），0表示按模型自动识别
+		TimeoutSeconds  int    `json:"timeout_seconds"` // 请求超时（秒），0表示使用默认值
 	} `json:"models"`
 }
 
@@ -347,6 +387,21 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		scanIntervalMinutes = 3 // 默认3分钟
 	}
 
+	// 设置决策修复轮数默认值
+	aiRepairRounds := 1 // 默认修复1轮
+	if req.AIRepairRounds != nil {
+		if *req.AIRepairRounds < 0 || *req.AIRepairRounds > 3 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "决策修复轮数必须在0-3之间"})
+			return
+		}
+		aiRepairRounds = *req.AIRepairRounds
+	}
+
+	if req.MaxSlippagePct < 0 || req.MaxSlippagePct > 5 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "最大滑点必须在0-5%之间"})
+		return
+	}
+
 	// 创建交易员配置（数据库实体）
 	trader := &config.TraderRecord{
 		ID:                   traderID,
@@ -364,6 +419,9 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 		OverrideBasePrompt:   req.OverrideBasePrompt,
 		SystemPromptTemplate: systemPromptTemplate,
 		IsCrossMargin:        isCrossMargin,
+		AIRepairRounds:       aiRepairRounds,
+		BlockStaleOpens:      req.BlockStaleOpens,
+		MaxSlippagePct:       req.MaxSlippagePct,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            false,
 	}
@@ -394,17 +452,20 @@ func (s *Server) handleCreateTrader(c *gin.Context) {
 
 // UpdateTraderRequest 更新交易员请求
 type UpdateTraderRequest struct {
-	Name                string  `json:"name" binding:"required"`
-	AIModelID           string  `json:"ai_model_id" binding:"required"`
-	ExchangeID          string  `json:"exchange_id" binding:"required"`
-	InitialBalance      float64 `json:"initial_balance"`
-	ScanIntervalMinutes int     `json:"scan_interval_minutes"`
-	BTCETHLeverage      int     `json:"btc_eth_leverage"`
-	AltcoinLeverage     int     `json:"altcoin_leverage"`
-	TradingSymbols      string  `json:"trading_symbols"`
-	CustomPrompt        string  `json:"custom_prompt"`
-	OverrideBasePrompt  bool    `json:"override_base_prompt"`
-	IsCrossMargin       *bool   `json:"is_cross_margin"`
+	Name                string   `json:"name" binding:"required"`
+	AIModelID           string   `json:"ai_model_id" binding:"required"`
+	ExchangeID          string   `json:"exchange_id" binding:"required"`
+	InitialBalance      float64  `json:"initial_balance"`
+	ScanIntervalMinutes int      `json:"scan_interval_minutes"`
+	BTCETHLeverage      int      `json:"btc_eth_leverage"`
+	AltcoinLeverage     int      `json:"altcoin_leverage"`
+	TradingSymbols      string   `json:"trading_symbols"`
+	CustomPrompt        string   `json:"custom_prompt"`
+	OverrideBasePrompt  bool     `json:"override_base_prompt"`
+	IsCrossMargin       *bool    `json:"is_cross_margin"`
+	AIRepairRounds      *int     `json:"ai_repair_rounds"`
+	BlockStaleOpens     *bool    `json:"block_stale_opens"`
+	MaxSlippagePct      *float64 `json:"max_slippage_pct"`
 }
 
 // handleUpdateTrader 更新交易员配置
@@ -460,6 +521,32 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		scanIntervalMinutes = existingTrader.ScanIntervalMinutes // 保持原值
 	}
 
+	// 设置决策修复轮数
+	aiRepairRounds := existingTrader.AIRepairRounds // 保持原值
+	if req.AIRepairRounds != nil {
+		if *req.AIRepairRounds < 0 || *req.AIRepairRounds > 3 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "决策修复轮数必须在0-3之间"})
+			return
+		}
+		aiRepairRounds = *req.AIRepairRounds
+	}
+
+	// 设置行情过时禁止开仓
+	blockStaleOpens := existingTrader.BlockStaleOpens // 保持原值
+	if req.BlockStaleOpens != nil {
+		blockStaleOpens = *req.BlockStaleOpens
+	}
+
+	// 设置最大滑点
+	maxSlippagePct := existingTrader.MaxSlippagePct // 保持原值
+	if req.MaxSlippagePct != nil {
+		if *req.MaxSlippagePct < 0 || *req.MaxSlippagePct > 5 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "最大滑点必须在0-5%之间"})
+			return
+		}
+		maxSlippagePct = *req.MaxSlippagePct
+	}
+
 	// 更新交易员配置
 	trader := &config.TraderRecord{
 		ID:                   traderID,
@@ -475,6 +562,9 @@ func (s *Server) handleUpdateTrader(c *gin.Context) {
 		OverrideBasePrompt:   req.OverrideBasePrompt,
 		SystemPromptTemplate: existingTrader.SystemPromptTemplate, // 保持原值
 		IsCrossMargin:        isCrossMargin,
+		AIRepairRounds:       aiRepairRounds,
+		BlockStaleOpens:      blockStaleOpens,
+		MaxSlippagePct:       maxSlippagePct,
 		ScanIntervalMinutes:  scanIntervalMinutes,
 		IsRunning:            existingTrader.IsRunning, // 保持原值
 	}
@@ -665,9 +755,31 @@ func (s *Server) handleUpdateModelConfigs(c *gin.Context) {
 		return
 	}
 
+	// 校验输出模式
+	for modelID, modelData := range req.Models {
+		switch modelData.OutputMode {
+		case "", mcp.OutputModeText, mcp.OutputModeJSONObject, mcp.OutputModeJSONSchema:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("模型 %s 的输出模式无效: %s（可选: text, json_object, json_schema）", modelID, modelData.OutputMode)})
+			return
+		}
+		if modelData.ContextWindow != 0 && modelData.ContextWindow < mcp.MinContextWindow {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("模型 %s 的上下文窗口过小: %d（至少 %d，0表示自动识别）", modelID, modelData.ContextWindow, mcp.MinContextWindow)})
+			return
+		}
+		if modelData.TimeoutSeconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("模型 %s 的请求超时不能为负数: %d", modelID, modelData.TimeoutSeconds)})
+			return
+		}
+		if strings.HasSuffix(modelID, "external") && modelData.Enabled && modelData.CustomAPIURL == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("外部决策服务 %s 需要设置服务地址 (custom_api_url)", modelID)})
+			return
+		}
+	}
+
 	// 更新每个模型的配置
 	for modelID, modelData := range req.Models {
-		err := s.database.UpdateAIModel(userID, modelID, modelData.Enabled, modelData.APIKey, modelData.CustomAPIURL, modelData.CustomModelName)
+		err := s.database.UpdateAIModel(userID, modelID, modelData.Enabled, modelData.APIKey, modelData.CustomAPIURL, modelData.CustomModelName, modelData.OutputMode, modelData.ContextWindow, modelData.TimeoutSeconds)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新模型 %s 失败: %v", modelID, err)})
 			return
@@ -856,6 +968,12 @@ func (s *Server) handleGetTraderConfig(c *gin.Context) {
 		"is_cross_margin":       traderConfig.IsCrossMargin,
 		"use_coin_pool":         traderConfig.UseCoinPool,
 		"use_oi_top":            traderConfig.UseOITop,
+		"ai_repair_rounds":      traderConfig.AIRepairRounds,
+		"block_stale_opens":     traderConfig.BlockStaleOpens,
+		"max_slippage_pct":      traderConfig.MaxSlippagePct,
+		"decision_mode":         traderConfig.DecisionMode,
+		"rule_strategy":         traderConfig.RuleStrategy,
+		"locale":                traderConfig.Locale,
 		"is_running":            isRunning,
 	}
 
@@ -880,6 +998,41 @@ func (s *Server) handleStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, status)
 }
 
+// handleMarketHealth 行情WebSocket流健康状况
+func (s *Server) handleMarketHealth(c *gin.Context) {
+	if market.WSMonitorCli == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "行情监控未启动"})
+		return
+	}
+	c.JSON(http.StatusOK, market.WSMonitorCli.Health())
+}
+
+// handleMarketAlerts 最近的行情警报和币种评分（?symbol=BTCUSDT&type=volume_spike&minutes=60&limit=100）
+func (s *Server) handleMarketAlerts(c *gin.Context) {
+	if market.WSMonitorCli == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "行情监控未启动"})
+		return
+	}
+
+	minutes, err := strconv.Atoi(c.DefaultQuery("minutes", "60"))
+	if err != nil || minutes <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "minutes 必须为正整数"})
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if err != nil || limit <= 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit 必须为正整数"})
+		return
+	}
+
+	since := time.Now().Add(-time.Duration(minutes) * time.Minute)
+	c.JSON(http.StatusOK, gin.H{
+		"alerts":     market.WSMonitorCli.GetRecentAlerts(since, c.Query("symbol"), c.Query("type"), limit),
+		"scores":     market.WSMonitorCli.GetSymbolScores(0, limit),
+		"thresholds": market.GetAlertConfig().AlertThresholds,
+	})
+}
+
 // handleAccount 账户信息
 func (s *Server) handleAccount(c *gin.Context) {
 	_, traderID, err := s.getTraderFromQuery(c)
@@ -995,6 +1148,54 @@ func (s *Server) handleLatestDecisions(c *gin.Context) {
 	c.JSON(http.StatusOK, records)
 }
 
+// handleDecisionSnapshot 决策周期的市场数据快照（file为空时返回最近一个有快照的周期）
+func (s *Server) handleDecisionSnapshot(c *gin.Context) {
+	_, traderID, err := s.getTraderFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	decisionLogger := trader.GetDecisionLogger()
+	filename := c.Query("file")
+	if filename == "" {
+		records, err := decisionLogger.GetLatestRecords(20)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": fmt.Sprintf("获取决策日志失败: %v", err),
+			})
+			return
+		}
+		for i := len(records) - 1; i >= 0; i-- {
+			if records[i].SnapshotFile != "" {
+				filename = records[i].SnapshotFile
+				break
+			}
+		}
+		if filename == "" {
+			c.JSON(http.StatusNotFound, gin.H{"error": "暂无市场数据快照"})
+			return
+		}
+	}
+
+	snapshot, err := decisionLogger.LoadSnapshot(filename)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("读取市场数据快照失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"file":     filename,
+		"snapshot": snapshot,
+	})
+}
+
 // handleStatistics 统计信息
 func (s *Server) handleStatistics(c *gin.Context) {
 	_, traderID, err := s.getTraderFromQuery(c)
@@ -1154,6 +1355,42 @@ func (s *Server) handlePerformance(c *gin.Context) {
 	c.JSON(http.StatusOK, performance)
 }
 
+// handleExperimentReport 提示词实验报告（按变体拆分胜率、盈亏比、夏普比率）
+func (s *Server) handleExperimentReport(c *gin.Context) {
+	_, traderID, err := s.getTraderFromQuery(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 默认分析最近500个周期（实验需要更多样本），可通过 ?cycles=N 调整
+	cycles := 500
+	if cyclesStr := c.Query("cycles"); cyclesStr != "" {
+		if n, err := strconv.Atoi(cyclesStr); err == nil && n > 0 {
+			cycles = n
+		}
+	}
+
+	report, err := trader.GetDecisionLogger().AnalyzeExperiment(cycles)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": fmt.Sprintf("分析提示词实验失败: %v", err),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"experiment": trader.GetPromptExperiment(),
+		"report":     report,
+	})
+}
+
 // authMiddleware JWT认证中间件
 func (s *Server) authMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -1480,13 +1717,35 @@ func (s *Server) Start() error {
 	log.Printf("  • PUT  /api/models           - 更新AI模型配置")
 	log.Printf("  • GET  /api/exchanges        - 获取交易所配置")
 	log.Printf("  • PUT  /api/exchanges        - 更新交易所配置")
+	log.Printf("  • POST /api/prompt-templates/reload - 重新加载提示词模板（返回渲染错误）")
+	log.Printf("  • GET  /api/user/prompt-templates - 获取用户提示词模板（数据库，带版本）")
+	log.Printf("  • POST /api/user/prompt-templates - 创建用户提示词模板")
+	log.Printf("  • PUT  /api/user/prompt-templates/:id - 更新用户提示词模板（创建新版本）")
+	log.Printf("  • GET  /api/user/prompt-templates/:id/versions - 提示词模板版本历史")
+	log.Printf("  • GET  /api/user/prompt-templates/:id/diff?from=1&to=2 - 对比两个版本")
+	log.Printf("  • POST /api/user/prompt-templates/:id/rollback - 回滚到指定版本")
+	log.Printf("  • PUT  /api/traders/:id/prompt-template - 设置交易员的提示词模板及固定版本")
 	log.Printf("  • GET  /api/status?trader_id=xxx     - 指定trader的系统状态")
+	log.Printf("  • GET  /api/market/health            - 行情WebSocket流健康状况（过时的流、重连和回填记录）")
+	log.Printf("  • GET  /api/market/alerts            - 最近的行情警报和币种评分（?symbol=&type=&minutes=60&limit=100）")
 	log.Printf("  • GET  /api/account?trader_id=xxx    - 指定trader的账户信息")
 	log.Printf("  • GET  /api/positions?trader_id=xxx  - 指定trader的持仓列表")
 	log.Printf("  • GET  /api/decisions?trader_id=xxx  - 指定trader的决策日志")
 	log.Printf("  • GET  /api/decisions/latest?trader_id=xxx - 指定trader的最新决策")
+	log.Printf("  • GET  /api/decisions/snapshot?trader_id=xxx&file=xxx - 决策周期的市场数据快照")
 	log.Printf("  • GET  /api/statistics?trader_id=xxx - 指定trader的统计信息")
 	log.Printf("  • GET  /api/performance?trader_id=xxx - 指定trader的AI学习表现分析")
+	log.Printf("  • GET  /api/experiment?trader_id=xxx  - 指定trader的提示词实验报告（按变体拆分表现）")
+	log.Printf("  • PUT  /api/traders/:id/prompt-experiment - 设置交易员的提示词A/B实验")
+	log.Printf("  • GET  /api/signal-analyzers         - 可用的信号分析器及默认参数")
+	log.Printf("  • PUT  /api/traders/:id/signal-analyzers - 设置交易员启用的信号分析器")
+	log.Printf("  • PUT  /api/traders/:id/decision-mode - 设置交易员的决策方式（ai/rule/ai_rule_fallback）及规则策略")
+	log.Printf("  • POST /api/traders/:id/replay       - 用其他AI模型/模板重放历史周期并对比假设结果")
+	log.Printf("  • PUT  /api/traders/:id/locale       - 设置交易员的提示词语言（zh-CN/en）")
+	log.Printf("  • PUT  /api/traders/:id/change-detector - 设置市场状态无变化时跳过AI调用的阈值")
+	log.Printf("  • PUT  /api/traders/:id/universe-filter - 设置候选币种筛选条件（持仓价值、成交额、价差、上线天数、数量上限）")
+	log.Printf("  • PUT  /api/traders/:id/data-profile - 设置行情数据配置（时间框架、指标及参数，预设 default/scalper/swing）")
+	log.Printf("  • PUT  /api/traders/:id/alert-trigger - 设置行情警报是否提前触发交易周期、是否把高评分币种加入候选")
 	log.Println()
 
 	return s.router.Run(addr)
@@ -1501,7 +1760,9 @@ func (s *Server) handleGetPromptTemplates(c *gin.Context) {
 	response := make([]map[string]interface{}, 0, len(templates))
 	for _, tmpl := range templates {
 		response = append(response, map[string]interface{}{
-			"name": tmpl.Name,
+			"name":         tmpl.Name,
+			"locale":       tmpl.Locale,
+			"render_error": tmpl.RenderError,
 		})
 	}
 
@@ -1510,19 +1771,40 @@ func (s *Server) handleGetPromptTemplates(c *gin.Context) {
 	})
 }
 
-// handleGetPromptTemplate 获取指定名称的提示词模板内容
+// handleGetPromptTemplate 获取指定名称的提示词模板内容（?locale=en 获取指定语言版本）
 func (s *Server) handleGetPromptTemplate(c *gin.Context) {
 	templateName := c.Param("name")
+	locale := c.DefaultQuery("locale", decision.DefaultLocale)
+	if err := decision.ValidateLocale(locale); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
-	template, err := decision.GetPromptTemplate(templateName)
+	template, err := decision.GetPromptTemplateForLocale(templateName, locale)
 	if err != nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("模板不存在: %s", templateName)})
 		return
 	}
 
 	c.JSON(http.StatusOK, gin.H{
-		"name":    template.Name,
-		"content": template.Content,
+		"name":         template.Name,
+		"locale":       template.Locale,
+		"content":      template.Content,
+		"render_error": template.RenderError,
+	})
+}
+
+// handleReloadPromptTemplates 重新加载提示词模板（包含共享片段），返回渲染失败的模板
+func (s *Server) handleReloadPromptTemplates(c *gin.Context) {
+	templateErrors, err := decision.ReloadPromptTemplates()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("重新加载提示词模板失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"templates": decision.GetAllPromptTemplateNames(),
+		"errors":    templateErrors,
 	})
 }
 
@@ -1734,3 +2016,747 @@ func (s *Server) handleGetPublicTraderConfig(c *gin.Context) {
 	c.JSON(http.StatusOK, result)
 }
 
+
+// parsePromptTemplateID 解析路径中的用户提示词模板ID
+func parsePromptTemplateID(c *gin.Context) (int64, bool) {
+	templateID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的模板ID"})
+		return 0, false
+	}
+	return templateID, true
+}
+
+// refreshTraderPromptTemplates 模板变更后刷新用户内存中交易员使用的模板
+func (s *Server) refreshTraderPromptTemplates(userID string) {
+	if err := s.traderManager.RefreshPromptTemplates(s.database, userID); err != nil {
+		log.Printf("⚠️ 刷新交易员提示词模板失败: %v", err)
+	}
+}
+
+// handleGetUserPromptTemplates 获取用户的提示词模板列表（当前版本）
+func (s *Server) handleGetUserPromptTemplates(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	templates, err := s.database.GetPromptTemplates(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取提示词模板失败: %v", err)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"templates": templates})
+}
+
+// handleCreateUserPromptTemplate 创建用户提示词模板（版本1）
+func (s *Server) handleCreateUserPromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+
+	var req struct {
+		Name        string `json:"name" binding:"required"`
+		Description string `json:"description"`
+		Content     string `json:"content" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 保存前校验模板语法和变量
+	if _, err := decision.NewPromptTemplate(req.Name, req.Content, 1); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := s.database.CreatePromptTemplate(userID, req.Name, req.Description, req.Content)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("创建提示词模板失败: %v", err)})
+		return
+	}
+
+	// 已引用该名称的交易员切换到数据库模板
+	s.refreshTraderPromptTemplates(userID)
+
+	log.Printf("✓ 创建提示词模板成功: %s (user=%s)", req.Name, userID)
+	c.JSON(http.StatusCreated, template)
+}
+
+// handleUpdateUserPromptTemplate 更新用户提示词模板（创建新版本）
+func (s *Server) handleUpdateUserPromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	templateID, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Description string `json:"description"`
+		Content     string `json:"content" binding:"required"`
+		Comment     string `json:"comment"` // 版本说明
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	existing, err := s.database.GetPromptTemplate(userID, templateID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "提示词模板不存在"})
+		return
+	}
+
+	if _, err := decision.NewPromptTemplate(existing.Name, req.Content, existing.CurrentVersion+1); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := s.database.UpdatePromptTemplate(userID, templateID, req.Description, req.Content, req.Comment)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新提示词模板失败: %v", err)})
+		return
+	}
+
+	s.refreshTraderPromptTemplates(userID)
+
+	log.Printf("✓ 提示词模板 %s 已更新到 v%d", template.Name, template.CurrentVersion)
+	c.JSON(http.StatusOK, template)
+}
+
+// handleDeleteUserPromptTemplate 删除用户提示词模板（仍被交易员引用时拒绝删除）
+func (s *Server) handleDeleteUserPromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	templateID, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	if err := s.database.DeletePromptTemplate(userID, templateID); err != nil {
+		switch {
+		case errors.Is(err, sql.ErrNoRows):
+			c.JSON(http.StatusNotFound, gin.H{"error": "提示词模板不存在"})
+		case errors.Is(err, config.ErrPromptTemplateInUse):
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("删除提示词模板失败: %v（请先将这些交易员切换到其他模板）", err)})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("删除提示词模板失败: %v", err)})
+		}
+		return
+	}
+
+	s.refreshTraderPromptTemplates(userID)
+
+	c.JSON(http.StatusOK, gin.H{"message": "提示词模板已删除"})
+}
+
+// handleGetUserPromptTemplateVersions 获取提示词模板的版本历史
+func (s *Server) handleGetUserPromptTemplateVersions(c *gin.Context) {
+	userID := c.GetString("user_id")
+	templateID, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	versions, err := s.database.GetPromptTemplateVersions(userID, templateID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "提示词模板不存在"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"versions": versions})
+}
+
+// handleDiffUserPromptTemplate 对比提示词模板的两个版本（?from=1&to=2，to 默认为当前版本）
+func (s *Server) handleDiffUserPromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	templateID, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	template, err := s.database.GetPromptTemplate(userID, templateID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "提示词模板不存在"})
+		return
+	}
+
+	fromVersion, err := strconv.Atoi(c.Query("from"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "无效的from版本号"})
+		return
+	}
+	toVersion := template.CurrentVersion
+	if to := c.Query("to"); to != "" {
+		toVersion, err = strconv.Atoi(to)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "无效的to版本号"})
+			return
+		}
+	}
+
+	from, err := s.database.GetPromptTemplateVersion(templateID, fromVersion)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("版本 v%d 不存在", fromVersion)})
+		return
+	}
+	to, err := s.database.GetPromptTemplateVersion(templateID, toVersion)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("版本 v%d 不存在", toVersion)})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"name": template.Name,
+		"from": fromVersion,
+		"to":   toVersion,
+		"diff": config.DiffPromptVersions(from.Content, to.Content),
+	})
+}
+
+// handleRollbackUserPromptTemplate 回滚提示词模板到指定版本（以旧版本内容创建新版本）
+func (s *Server) handleRollbackUserPromptTemplate(c *gin.Context) {
+	userID := c.GetString("user_id")
+	templateID, ok := parsePromptTemplateID(c)
+	if !ok {
+		return
+	}
+
+	var req struct {
+		Version int `json:"version" binding:"required"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	template, err := s.database.RollbackPromptTemplate(userID, templateID, req.Version)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("回滚提示词模板失败: %v", err)})
+		return
+	}
+
+	s.refreshTraderPromptTemplates(userID)
+
+	log.Printf("🔄 提示词模板 %s 已回滚到 v%d（新版本 v%d）", template.Name, req.Version, template.CurrentVersion)
+	c.JSON(http.StatusOK, template)
+}
+
+// handleUpdateTraderPromptTemplate 设置交易员使用的提示词模板（version=0 表示跟随最新版本）
+func (s *Server) handleUpdateTraderPromptTemplate(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req struct {
+		SystemPromptTemplate string `json:"system_prompt_template" binding:"required"`
+		Version              int    `json:"version"`
+	}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Version < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "版本号不能为负数"})
+		return
+	}
+
+	// 固定版本时必须是存在的数据库模板版本
+	if _, err := manager.LoadPromptTemplate(s.database, userID, req.SystemPromptTemplate, req.Version); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Version > 0 {
+		if _, err := s.database.GetPromptTemplateByName(userID, req.SystemPromptTemplate, req.Version); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("提示词模板 %s v%d 不存在", req.SystemPromptTemplate, req.Version)})
+			return
+		}
+	}
+
+	if err := s.database.UpdateTraderPromptTemplate(userID, traderID, req.SystemPromptTemplate, req.Version); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新交易员提示词模板失败: %v", err)})
+		return
+	}
+
+	s.refreshTraderPromptTemplates(userID)
+
+	log.Printf("✓ 交易员 %s 的提示词模板已设置为 %s (版本=%d)", traderID, req.SystemPromptTemplate, req.Version)
+	c.JSON(http.StatusOK, gin.H{"message": "交易员提示词模板已更新"})
+}
+
+// handleUpdateTraderPromptExperiment 设置交易员的提示词A/B实验（variants 为空表示停止实验）
+func (s *Server) handleUpdateTraderPromptExperiment(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req decision.PromptExperiment
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var experiment *decision.PromptExperiment
+	raw := ""
+	if len(req.Variants) > 0 {
+		if err := req.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		experiment = &req
+		raw = experiment.String()
+	}
+
+	if err := s.database.UpdateTraderPromptExperiment(userID, traderID, raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新提示词实验失败: %v", err)})
+		return
+	}
+
+	// 如果trader在内存中，立即应用
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err == nil {
+		trader.SetPromptExperiment(experiment)
+		if experiment != nil {
+			log.Printf("🧪 交易员 %s 启用提示词实验 (%s, %d 个变体)", trader.GetName(), experiment.Mode, len(experiment.Variants))
+		} else {
+			log.Printf("🧪 交易员 %s 已停止提示词实验", trader.GetName())
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":    "提示词实验已更新",
+		"experiment": experiment,
+	})
+}
+
+// handleGetSignalAnalyzers 获取可用的信号分析器
+func (s *Server) handleGetSignalAnalyzers(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"analyzers": decision.GetSignalAnalyzerInfos()})
+}
+
+// UpdateSignalAnalyzersRequest 设置交易员信号分析器的请求
+// analyzers 为 null 表示恢复默认分析器，空数组表示禁用所有分析器
+type UpdateSignalAnalyzersRequest struct {
+	Analyzers []decision.SignalAnalyzerConfig `json:"analyzers"`
+}
+
+// handleUpdateTraderSignalAnalyzers 设置交易员启用的信号分析器及参数
+func (s *Server) handleUpdateTraderSignalAnalyzers(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req UpdateSignalAnalyzersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	analyzers, err := decision.BuildSignalAnalyzers(req.Analyzers)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 已保存的规则策略中的信号规则不能引用被禁用的分析器
+	traderCfg, _, _, err := s.database.GetTraderConfig(userID, traderID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+		return
+	}
+	if strings.TrimSpace(traderCfg.RuleStrategy) != "" {
+		if strategy, err := decision.ParseRuleStrategy(traderCfg.RuleStrategy); err == nil {
+			if err := strategy.ValidateAnalyzers(req.Analyzers); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("规则策略依赖该分析器，请先修改规则策略: %v", err)})
+				return
+			}
+		}
+	}
+
+	raw := ""
+	if req.Analyzers != nil {
+		data, _ := json.Marshal(req.Analyzers)
+		raw = string(data)
+	}
+
+	if err := s.database.UpdateTraderSignalAnalyzers(userID, traderID, raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新信号分析器失败: %v", err)})
+		return
+	}
+
+	// 如果trader在内存中，立即应用（默认分析器传nil）
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err == nil {
+		if req.Analyzers == nil {
+			analyzers = nil
+		}
+		trader.SetSignalAnalyzers(analyzers)
+		log.Printf("📋 交易员 %s 的信号分析器已更新 (%d 个)", trader.GetName(), len(analyzers))
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":   "信号分析器已更新",
+		"analyzers": req.Analyzers,
+	})
+}
+
+// UpdateDecisionModeRequest 设置交易员决策方式的请求
+type UpdateDecisionModeRequest struct {
+	DecisionMode string                 `json:"decision_mode" binding:"required"` // ai/rule/ai_rule_fallback
+	RuleStrategy *decision.RuleStrategy `json:"rule_strategy"`                    // 规则策略（为空表示使用默认规则策略）
+}
+
+// handleUpdateTraderDecisionMode 设置交易员的决策方式及规则策略
+func (s *Server) handleUpdateTraderDecisionMode(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req UpdateDecisionModeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := decision.ValidateDecisionMode(req.DecisionMode); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	raw := ""
+	if req.RuleStrategy != nil {
+		if err := req.RuleStrategy.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		// 信号规则使用的分析器必须在交易员中启用
+		traderCfg, _, _, err := s.database.GetTraderConfig(userID, traderID)
+		if err != nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在"})
+			return
+		}
+		configs, _ := decision.ParseSignalAnalyzerConfigs(traderCfg.SignalAnalyzers)
+		if err := req.RuleStrategy.ValidateAnalyzers(configs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		raw = req.RuleStrategy.String()
+	}
+
+	if err := s.database.UpdateTraderDecisionMode(userID, traderID, req.DecisionMode, raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新决策方式失败: %v", err)})
+		return
+	}
+
+	// 如果trader在内存中，立即应用（下个周期生效）
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err == nil {
+		trader.SetDecisionMode(req.DecisionMode, req.RuleStrategy)
+		log.Printf("📐 交易员 %s 的决策方式已更新为 %s (规则策略: %s)", trader.GetName(), trader.GetDecisionMode(), trader.GetRuleStrategy().Name)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "决策方式已更新",
+		"decision_mode": req.DecisionMode,
+		"rule_strategy": req.RuleStrategy,
+	})
+}
+
+// UpdateLocaleRequest 设置交易员提示词语言的请求
+type UpdateLocaleRequest struct {
+	Locale string `json:"locale" binding:"required"` // zh-CN/en
+}
+
+// handleUpdateTraderLocale 设置交易员的提示词语言（提示词模板、市场数据说明和验证错误均使用该语言）
+func (s *Server) handleUpdateTraderLocale(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req UpdateLocaleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := decision.ValidateLocale(req.Locale); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	locale := decision.NormalizeLocale(req.Locale)
+
+	if err := s.database.UpdateTraderLocale(userID, traderID, locale); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新提示词语言失败: %v", err)})
+		return
+	}
+
+	// 如果trader在内存中，立即应用（下个周期生效）
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err == nil {
+		trader.SetLocale(locale)
+		log.Printf("🌐 交易员 %s 的提示词语言已更新为 %s", trader.GetName(), trader.GetLocale())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message": "提示词语言已更新",
+		"locale":  locale,
+	})
+}
+
+// handleUpdateTraderChangeDetector 设置交易员的市场状态变化检测（enabled 为 false 表示停用）
+func (s *Server) handleUpdateTraderChangeDetector(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req decision.ChangeDetectorConfig
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var detectorCfg *decision.ChangeDetectorConfig
+	raw := ""
+	if req.Enabled {
+		if err := req.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		detectorCfg = &req
+		raw = detectorCfg.String()
+	}
+
+	if err := s.database.UpdateTraderChangeDetector(userID, traderID, raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新变化检测配置失败: %v", err)})
+		return
+	}
+
+	// 如果trader在内存中，立即应用（重新记录比较基准，下个周期调用AI）
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err == nil {
+		trader.SetChangeDetector(detectorCfg)
+		if detectorCfg != nil {
+			log.Printf("💤 交易员 %s 启用变化检测 (价格%.2f%%, 最多连续跳过%d个周期)", trader.GetName(), detectorCfg.PriceChangePct, detectorCfg.MaxSkips)
+		} else {
+			log.Printf("💤 交易员 %s 已停用变化检测", trader.GetName())
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "变化检测配置已更新",
+		"change_detector": detectorCfg,
+	})
+}
+
+// handleUpdateTraderUniverseFilter 设置交易员的候选币种筛选条件（为0的条件不限制）
+func (s *Server) handleUpdateTraderUniverseFilter(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req decision.UniverseFilter
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := s.database.UpdateTraderUniverseFilter(userID, traderID, req.String()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新候选币种筛选条件失败: %v", err)})
+		return
+	}
+
+	// 如果trader在内存中，立即应用（下个周期生效）
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err == nil {
+		trader.SetUniverseFilter(&req)
+		log.Printf("🔎 交易员 %s 的候选币种筛选条件已更新: %s", trader.GetName(), req.String())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":         "候选币种筛选条件已更新",
+		"universe_filter": req,
+	})
+}
+
+// handleUpdateTraderDataProfile 设置交易员的行情数据配置（未指定时间框架时使用预设，default 为原有格式）
+func (s *Server) handleUpdateTraderDataProfile(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req market.DataProfile
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := req.Validate(); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 原有格式保存为空字符串
+	dataProfile := ""
+	if !req.IsDefault() {
+		dataProfile = req.String()
+	}
+	if err := s.database.UpdateTraderDataProfile(userID, traderID, dataProfile); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新行情数据配置失败: %v", err)})
+		return
+	}
+
+	// 如果trader在内存中，立即应用（下个周期生效，新的K线周期在下个周期订阅）
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err == nil {
+		trader.SetDataProfile(&req)
+		log.Printf("📐 交易员 %s 的行情数据配置已更新: %s", trader.GetName(), req.String())
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":      "行情数据配置已更新",
+		"data_profile": req,
+	})
+}
+
+// handleUpdateTraderAlertTrigger 设置交易员的行情警报配置（enabled 为 false 表示不使用警报）
+func (s *Server) handleUpdateTraderAlertTrigger(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	req := decision.DefaultAlertTriggerConfig()
+	if err := c.ShouldBindJSON(req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var alertCfg *decision.AlertTriggerConfig
+	raw := ""
+	if req.Enabled {
+		if err := req.Validate(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		alertCfg = req
+		raw = alertCfg.String()
+	}
+
+	if err := s.database.UpdateTraderAlertTrigger(userID, traderID, raw); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("更新行情警报配置失败: %v", err)})
+		return
+	}
+
+	// 如果trader在内存中，立即应用（警报触发和候选在下一条警报/下个周期生效）
+	trader, err := s.traderManager.GetTrader(traderID)
+	if err == nil {
+		trader.SetAlertTrigger(alertCfg)
+		if alertCfg != nil {
+			log.Printf("🚨 交易员 %s 的行情警报配置已更新: %s", trader.GetName(), alertCfg.String())
+		} else {
+			log.Printf("🚨 交易员 %s 已停用行情警报", trader.GetName())
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":       "行情警报配置已更新",
+		"alert_trigger": alertCfg,
+	})
+}
+
+// maxReplayCycles 单次API重放的最大周期数（每个周期调用一次AI，更多周期请使用命令行工具）
+const maxReplayCycles = 20
+
+// ReplayRequest 决策重放请求
+type ReplayRequest struct {
+	AIModelID      string `json:"ai_model_id"`     // 重放使用的AI模型（为空使用交易员当前模型）
+	TemplateName   string `json:"template_name"`   // 重放使用的提示词模板（为空使用记录中的原始系统提示词）
+	CustomPrompt   string `json:"custom_prompt"`   // 追加的自定义prompt
+	CycleFrom      int    `json:"cycle_from"`      // 起始周期编号
+	CycleTo        int    `json:"cycle_to"`        // 结束周期编号
+	Last           int    `json:"last"`            // 未指定周期范围时重放最近N个周期（默认1）
+	HorizonMinutes int    `json:"horizon_minutes"` // 假设结果的观察期（分钟，默认240）
+	Interval       string `json:"interval"`        // 价格路径的K线周期（默认15m）
+}
+
+// handleReplayDecisions 把历史周期重新提交给其他AI模型或模板，对比决策及其假设结果
+func (s *Server) handleReplayDecisions(c *gin.Context) {
+	traderID := c.Param("id")
+	userID := c.GetString("user_id")
+
+	var req ReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	traders, err := s.database.GetTraders(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取交易员列表失败: %v", err)})
+		return
+	}
+	traderRecord := replay.FindTrader(traders, traderID)
+	if traderRecord == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "交易员不存在或无访问权限"})
+		return
+	}
+
+	// 重放使用的AI模型（默认使用交易员当前模型）
+	modelID := req.AIModelID
+	if modelID == "" {
+		modelID = traderRecord.AIModelID
+	}
+	models, err := s.database.GetAIModels(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("获取AI模型配置失败: %v", err)})
+		return
+	}
+	model := replay.FindAIModel(models, modelID)
+	if model == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("AI模型 %s 不存在", modelID)})
+		return
+	}
+	client, err := replay.NewAIClient(model)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	// 重放使用的提示词模板（数据库模板优先，其次文件模板）
+	options := decision.ReplayOptions{TemplateName: req.TemplateName, CustomPrompt: req.CustomPrompt}
+	if req.TemplateName != "" {
+		dbTemplate, err := manager.LoadPromptTemplate(s.database, userID, req.TemplateName, 0)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("加载提示词模板失败: %v", err)})
+			return
+		}
+		options.PromptTemplate = dbTemplate
+	}
+
+	decisionLogger := replay.NewDecisionLogger(traderID)
+	records, err := replay.LoadRecords(decisionLogger, req.CycleFrom, req.CycleTo, req.Last)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if len(records) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "没有可重放的决策记录"})
+		return
+	}
+	if len(records) > maxReplayCycles {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("单次最多重放 %d 个周期（当前 %d 个），请缩小范围或使用命令行工具", maxReplayCycles, len(records))})
+		return
+	}
+
+	report, err := replay.Run(&replay.Config{
+		TraderName:      traderRecord.Name,
+		DecisionLogger:  decisionLogger,
+		BTCETHLeverage:  traderRecord.BTCETHLeverage,
+		AltcoinLeverage: traderRecord.AltcoinLeverage,
+		Locale:          traderRecord.Locale,
+		Client:          client,
+		ModelName:       model.ID,
+		Options:         options,
+		Horizon:         time.Duration(req.HorizonMinutes) * time.Minute,
+		Interval:        req.Interval,
+	}, records)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	log.Printf("🔁 交易员 %s 重放 %d 个周期 (模型: %s): 动作一致率 %.1f%%", traderRecord.Name, report.Summary.Cycles, model.ID, report.Summary.AgreementRate)
+	c.JSON(http.StatusOK, report)
+}
@@ -111,6 +111,31 @@ func (d *Database) createTables() error {
 			FOREIGN KEY (exchange_id) REFERENCES exchanges(id)
 		)`,
 
+		// 用户提示词模板表（内容按版本存储在 prompt_template_versions）
+		`CREATE TABLE IF NOT EXISTS prompt_templates (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			user_id TEXT NOT NULL,
+			name TEXT NOT NULL,
+			description TEXT DEFAULT '',
+			current_version INTEGER DEFAULT 1,
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (user_id) REFERENCES users(id) ON DELETE CASCADE,
+			UNIQUE(user_id, name)
+		)`,
+
+		// 提示词模板版本表
+		`CREATE TABLE IF NOT EXISTS prompt_template_versions (
+			id INTEGER PRIMARY KEY AUTOINCREMENT,
+			template_id INTEGER NOT NULL,
+			version INTEGER NOT NULL,
+			content TEXT NOT NULL,
+			comment TEXT DEFAULT '',
+			created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+			FOREIGN KEY (template_id) REFERENCES prompt_templates(id) ON DELETE CASCADE,
+			UNIQUE(template_id, version)
+		)`,
+
 		// 用户表
 		`CREATE TABLE IF NOT EXISTS users (
 			id TEXT PRIMARY KEY,
@@ -169,6 +194,12 @@ func (d *Database) createTables() error {
 				UPDATE user_signal_sources SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
 			END`,
 
+		`CREATE TRIGGER IF NOT EXISTS update_prompt_templates_updated_at
+			AFTER UPDATE ON prompt_templates
+			BEGIN
+				UPDATE prompt_templates SET updated_at = CURRENT_TIMESTAMP WHERE id = NEW.id;
+			END`,
+
 		`CREATE TRIGGER IF NOT EXISTS update_system_config_updated_at
 			AFTER UPDATE ON system_config
 			BEGIN
@@ -199,8 +230,24 @@ func (d *Database) createTables() error {
 		`ALTER TABLE traders ADD COLUMN use_coin_pool BOOLEAN DEFAULT 0`,               // 是否使用COIN POOL信号源
 		`ALTER TABLE traders ADD COLUMN use_oi_top BOOLEAN DEFAULT 0`,                  // 是否使用OI TOP信号源
 		`ALTER TABLE traders ADD COLUMN system_prompt_template TEXT DEFAULT 'default'`, // 系统提示词模板名称
+		`ALTER TABLE traders ADD COLUMN ai_repair_rounds INTEGER DEFAULT 1`,            // 决策验证失败时AI自我修复轮数
+		`ALTER TABLE traders ADD COLUMN prompt_template_version INTEGER DEFAULT 0`,     // 固定的提示词模板版本（0=最新）
+		`ALTER TABLE traders ADD COLUMN prompt_experiment TEXT DEFAULT ''`,             // 提示词A/B实验配置（JSON格式）
+		`ALTER TABLE traders ADD COLUMN signal_analyzers TEXT DEFAULT ''`,              // 启用的信号分析器配置（JSON格式）
+		`ALTER TABLE traders ADD COLUMN decision_mode TEXT DEFAULT 'ai'`,               // 决策方式: ai/rule/ai_rule_fallback
+		`ALTER TABLE traders ADD COLUMN rule_strategy TEXT DEFAULT ''`,                 // 规则策略配置（JSON格式）
+		`ALTER TABLE traders ADD COLUMN locale TEXT DEFAULT 'zh-CN'`,                   // 提示词语言: zh-CN/en
+		`ALTER TABLE traders ADD COLUMN change_detector TEXT DEFAULT ''`,               // 市场状态变化检测配置（JSON格式）
+		`ALTER TABLE traders ADD COLUMN universe_filter TEXT DEFAULT ''`,               // 候选币种筛选条件（JSON格式）
+		`ALTER TABLE traders ADD COLUMN block_stale_opens BOOLEAN DEFAULT 0`,           // 行情数据过时时禁止开新仓
+		`ALTER TABLE traders ADD COLUMN data_profile TEXT DEFAULT ''`,                  // 行情数据配置（JSON格式）
+		`ALTER TABLE traders ADD COLUMN max_slippage_pct REAL DEFAULT 0`,               // 市价开仓允许的最大预估滑点（%，0=不限制）
+		`ALTER TABLE traders ADD COLUMN alert_trigger TEXT DEFAULT ''`,                 // 行情警报配置（JSON格式）
 		`ALTER TABLE ai_models ADD COLUMN custom_api_url TEXT DEFAULT ''`,              // 自定义API地址
 		`ALTER TABLE ai_models ADD COLUMN custom_model_name TEXT DEFAULT ''`,           // 自定义模型名称
+		`ALTER TABLE ai_models ADD COLUMN output_mode TEXT DEFAULT 'text'`,             // 输出模式: text/json_object/json_schema
+		`ALTER TABLE ai_models ADD COLUMN context_window INTEGER DEFAULT 0`,            // 上下文窗口（token，0=按模型自动识别）
+		`ALTER TABLE ai_models ADD COLUMN timeout_seconds INTEGER DEFAULT 0`,           // 请求超时（秒，0=使用默认值）
 	}
 
 	for _, query := range alterQueries {
@@ -225,6 +272,7 @@ func (d *Database) initDefaultData() error {
 	}{
 		{"deepseek", "DeepSeek", "deepseek"},
 		{"qwen", "Qwen", "qwen"},
+		{"external", "External HTTP", "external"}, // 外部HTTP决策服务（custom_api_url=服务地址，api_key=签名密钥）
 	}
 
 	for _, model := range aiModels {
@@ -387,6 +435,9 @@ type AIModelConfig struct {
 	APIKey          string    `json:"apiKey"`
 	CustomAPIURL    string    `json:"customApiUrl"`
 	CustomModelName string    `json:"customModelName"`
+	OutputMode      string    `json:"outputMode"`     // 输出模式: text/json_object/json_schema
+	ContextWindow   int       `json:"contextWindow"`  // 上下文窗口（token，0=按模型自动识别）
+	TimeoutSeconds  int       `json:"timeoutSeconds"` // 请求超时（秒，0=使用默认值）
 	CreatedAt       time.Time `json:"created_at"`
 	UpdatedAt       time.Time `json:"updated_at"`
 }
@@ -413,25 +464,38 @@ type ExchangeConfig struct {
 
 // TraderRecord 交易员配置（数据库实体）
 type TraderRecord struct {
-	ID                   string    `json:"id"`
-	UserID               string    `json:"user_id"`
-	Name                 string    `json:"name"`
-	AIModelID            string    `json:"ai_model_id"`
-	ExchangeID           string    `json:"exchange_id"`
-	InitialBalance       float64   `json:"initial_balance"`
-	ScanIntervalMinutes  int       `json:"scan_interval_minutes"`
-	IsRunning            bool      `json:"is_running"`
-	BTCETHLeverage       int       `json:"btc_eth_leverage"`       // BTC/ETH杠杆倍数
-	AltcoinLeverage      int       `json:"altcoin_leverage"`       // 山寨币杠杆倍数
-	TradingSymbols       string    `json:"trading_symbols"`        // 交易币种，逗号分隔
-	UseCoinPool          bool      `json:"use_coin_pool"`          // 是否使用COIN POOL信号源
-	UseOITop             bool      `json:"use_oi_top"`             // 是否使用OI TOP信号源
-	CustomPrompt         string    `json:"custom_prompt"`          // 自定义交易策略prompt
-	OverrideBasePrompt   bool      `json:"override_base_prompt"`   // 是否覆盖基础prompt
-	SystemPromptTemplate string    `json:"system_prompt_template"` // 系统提示词模板名称
-	IsCrossMargin        bool      `json:"is_cross_margin"`        // 是否为全仓模式（true=全仓，false=逐仓）
-	CreatedAt            time.Time `json:"created_at"`
-	UpdatedAt            time.Time `json:"updated_at"`
+	ID                    string    `json:"id"`
+	UserID                string    `json:"user_id"`
+	Name                  string    `json:"name"`
+	AIModelID             string    `json:"ai_model_id"`
+	ExchangeID            string    `json:"exchange_id"`
+	InitialBalance        float64   `json:"initial_balance"`
+	ScanIntervalMinutes   int       `json:"scan_interval_minutes"`
+	IsRunning             bool      `json:"is_running"`
+	BTCETHLeverage        int       `json:"btc_eth_leverage"`        // BTC/ETH杠杆倍数
+	AltcoinLeverage       int       `json:"altcoin_leverage"`        // 山寨币杠杆倍数
+	TradingSymbols        string    `json:"trading_symbols"`         // 交易币种，逗号分隔
+	UseCoinPool           bool      `json:"use_coin_pool"`           // 是否使用COIN POOL信号源
+	UseOITop              bool      `json:"use_oi_top"`              // 是否使用OI TOP信号源
+	CustomPrompt          string    `json:"custom_prompt"`           // 自定义交易策略prompt
+	OverrideBasePrompt    bool      `json:"override_base_prompt"`    // 是否覆盖基础prompt
+	SystemPromptTemplate  string    `json:"system_prompt_template"`  // 系统提示词模板名称
+	IsCrossMargin         bool      `json:"is_cross_margin"`         // 是否为全仓模式（true=全仓，false=逐仓）
+	AIRepairRounds        int       `json:"ai_repair_rounds"`        // 决策验证失败时AI自我修复轮数（0=不修复）
+	BlockStaleOpens       bool      `json:"block_stale_opens"`       // 行情数据过时（WebSocket流断开）时禁止开新仓
+	MaxSlippagePct        float64   `json:"max_slippage_pct"`        // 市价开仓允许的最大预估滑点（%，按订单簿估算，0=不限制）
+	PromptTemplateVersion int       `json:"prompt_template_version"` // 固定的数据库提示词模板版本（0=始终使用最新版本）
+	PromptExperiment      string    `json:"prompt_experiment"`       // 提示词A/B实验配置（JSON格式，为空表示未启用）
+	SignalAnalyzers       string    `json:"signal_analyzers"`        // 启用的信号分析器配置（JSON格式，为空表示使用默认分析器）
+	DecisionMode          string    `json:"decision_mode"`           // 决策方式: ai/rule/ai_rule_fallback
+	RuleStrategy          string    `json:"rule_strategy"`           // 规则策略配置（JSON格式，为空表示使用默认规则策略）
+	Locale                string    `json:"locale"`                  // 提示词语言: zh-CN/en
+	ChangeDetector        string    `json:"change_detector"`         // 市场状态变化检测配置（JSON格式，为空表示每个周期都调用AI）
+	UniverseFilter        string    `json:"universe_filter"`         // 候选币种筛选条件（JSON格式，为空表示使用默认条件）
+	DataProfile           string    `json:"data_profile"`            // 行情数据配置（JSON格式，为空表示使用原有的时间框架和指标）
+	AlertTrigger          string    `json:"alert_trigger"`           // 行情警报配置（JSON格式，为空表示不使用警报触发周期和候选）
+	CreatedAt             time.Time `json:"created_at"`
+	UpdatedAt             time.Time `json:"updated_at"`
 }
 
 // UserSignalSource 用户信号源配置
@@ -552,6 +616,9 @@ func (d *Database) GetAIModels(userID string) ([]*AIModelConfig, error) {
 		SELECT id, user_id, name, provider, enabled, api_key,
 		       COALESCE(custom_api_url, '') as custom_api_url,
 		       COALESCE(custom_model_name, '') as custom_model_name,
+		       COALESCE(output_mode, 'text') as output_mode,
+		       COALESCE(context_window, 0) as context_window,
+		       COALESCE(timeout_seconds, 0) as timeout_seconds,
 		       created_at, updated_at
 		FROM ai_models WHERE user_id = ? ORDER BY id
 	`, userID)
@@ -567,7 +634,7 @@ func (d *Database) GetAIModels(userID string) ([]*AIModelConfig, error) {
 		err := rows.Scan(
 			&model.ID, &model.UserID, &model.Name, &model.Provider,
 			&model.Enabled, &model.APIKey, &model.CustomAPIURL, &model.CustomModelName,
-			&model.CreatedAt, &model.UpdatedAt,
+			&model.OutputMode, &model.ContextWindow, &model.TimeoutSeconds, &model.CreatedAt, &model.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -579,7 +646,11 @@ func (d *Database) GetAIModels(userID string) ([]*AIModelConfig, error) {
 }
 
 // UpdateAIModel 更新AI模型配置，如果不存在则创建用户特定配置
-func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, customAPIURL, customModelName string) error {
+func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, customAPIURL, customModelName, outputMode string, contextWindow, timeoutSeconds int) error {
+	if outputMode == "" {
+		outputMode = "text"
+	}
+
 	// 先尝试精确匹配 ID（新版逻辑，支持多个相同 provider 的模型）
 	var existingID string
 	err := d.db.QueryRow(`
@@ -589,9 +660,9 @@ func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, custom
 	if err == nil {
 		// 找到了现有配置（精确匹配 ID），更新它
 		_, err = d.db.Exec(`
-			UPDATE ai_models SET enabled = ?, api_key = ?, custom_api_url = ?, custom_model_name = ?, updated_at = datetime('now')
+			UPDATE ai_models SET enabled = ?, api_key = ?, custom_api_url = ?, custom_model_name = ?, output_mode = ?, context_window = ?, timeout_seconds = ?, updated_at = datetime('now')
 			WHERE id = ? AND user_id = ?
-		`, enabled, apiKey, customAPIURL, customModelName, existingID, userID)
+		`, enabled, apiKey, customAPIURL, customModelName, outputMode, contextWindow, timeoutSeconds, existingID, userID)
 		return err
 	}
 
@@ -605,15 +676,15 @@ func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, custom
 		// 找到了现有配置（通过 provider 匹配，兼容旧版），更新它
 		log.Printf("⚠️  使用旧版 provider 匹配更新模型: %s -> %s", provider, existingID)
 		_, err = d.db.Exec(`
-			UPDATE ai_models SET enabled = ?, api_key = ?, custom_api_url = ?, custom_model_name = ?, updated_at = datetime('now')
+			UPDATE ai_models SET enabled = ?, api_key = ?, custom_api_url = ?, custom_model_name = ?, output_mode = ?, context_window = ?, timeout_seconds = ?, updated_at = datetime('now')
 			WHERE id = ? AND user_id = ?
-		`, enabled, apiKey, customAPIURL, customModelName, existingID, userID)
+		`, enabled, apiKey, customAPIURL, customModelName, outputMode, contextWindow, timeoutSeconds, existingID, userID)
 		return err
 	}
 
 	// 没有找到任何现有配置，创建新的
 	// 推断 provider（从 id 中提取，或者直接使用 id）
-	if provider == id && (provider == "deepseek" || provider == "qwen") {
+	if provider == id && (provider == "deepseek" || provider == "qwen" || provider == "external") {
 		// id 本身就是 provider
 		provider = id
 	} else {
@@ -652,9 +723,9 @@ func (d *Database) UpdateAIModel(userID, id string, enabled bool, apiKey, custom
 
 	log.Printf("✓ 创建新的 AI 模型配置: ID=%s, Provider=%s, Name=%s", newModelID, provider, name)
 	_, err = d.db.Exec(`
-		INSERT INTO ai_models (id, user_id, name, provider, enabled, api_key, custom_api_url, custom_model_name, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))
-	`, newModelID, userID, name, provider, enabled, apiKey, customAPIURL, customModelName)
+		INSERT INTO ai_models (id, user_id, name, provider, enabled, api_key, custom_api_url, custom_model_name, output_mode, context_window, timeout_seconds, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, datetime('now'), datetime('now'))
+	`, newModelID, userID, name, provider, enabled, apiKey, customAPIURL, customModelName, outputMode, contextWindow, timeoutSeconds)
 
 	return err
 }
@@ -781,9 +852,9 @@ func (d *Database) CreateExchange(userID, id, name, typ string, enabled bool, ap
 // CreateTrader 创建交易员
 func (d *Database) CreateTrader(trader *TraderRecord) error {
 	_, err := d.db.Exec(`
-		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
-	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin)
+		INSERT INTO traders (id, user_id, name, ai_model_id, exchange_id, initial_balance, scan_interval_minutes, is_running, btc_eth_leverage, altcoin_leverage, trading_symbols, use_coin_pool, use_oi_top, custom_prompt, override_base_prompt, system_prompt_template, is_cross_margin, ai_repair_rounds, block_stale_opens, max_slippage_pct)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, trader.ID, trader.UserID, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance, trader.ScanIntervalMinutes, trader.IsRunning, trader.BTCETHLeverage, trader.AltcoinLeverage, trader.TradingSymbols, trader.UseCoinPool, trader.UseOITop, trader.CustomPrompt, trader.OverrideBasePrompt, trader.SystemPromptTemplate, trader.IsCrossMargin, trader.AIRepairRounds, trader.BlockStaleOpens, trader.MaxSlippagePct)
 	return err
 }
 
@@ -796,7 +867,17 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 		       COALESCE(use_coin_pool, 0) as use_coin_pool, COALESCE(use_oi_top, 0) as use_oi_top,
 		       COALESCE(custom_prompt, '') as custom_prompt, COALESCE(override_base_prompt, 0) as override_base_prompt,
 		       COALESCE(system_prompt_template, 'default') as system_prompt_template,
-		       COALESCE(is_cross_margin, 1) as is_cross_margin, created_at, updated_at
+		       COALESCE(is_cross_margin, 1) as is_cross_margin,
+		       COALESCE(ai_repair_rounds, 1) as ai_repair_rounds,
+		       COALESCE(block_stale_opens, 0) as block_stale_opens, COALESCE(max_slippage_pct, 0) as max_slippage_pct,
+		       COALESCE(prompt_template_version, 0) as prompt_template_version,
+		       COALESCE(prompt_experiment, '') as prompt_experiment,
+		       COALESCE(signal_analyzers, '') as signal_analyzers,
+		       COALESCE(decision_mode, 'ai') as decision_mode, COALESCE(rule_strategy, '') as rule_strategy,
+		       COALESCE(locale, 'zh-CN') as locale, COALESCE(change_detector, '') as change_detector,
+		       COALESCE(universe_filter, '') as universe_filter, COALESCE(data_profile, '') as data_profile,
+		       COALESCE(alert_trigger, '') as alert_trigger,
+		       created_at, updated_at
 		FROM traders WHERE user_id = ? ORDER BY created_at DESC
 	`, userID)
 	if err != nil {
@@ -813,8 +894,9 @@ func (d *Database) GetTraders(userID string) ([]*TraderRecord, error) {
 			&trader.BTCETHLeverage, &trader.AltcoinLeverage, &trader.TradingSymbols,
 			&trader.UseCoinPool, &trader.UseOITop,
 			&trader.CustomPrompt, &trader.OverrideBasePrompt, &trader.SystemPromptTemplate,
-			&trader.IsCrossMargin,
-			&trader.CreatedAt, &trader.UpdatedAt,
+			&trader.IsCrossMargin, &trader.AIRepairRounds, &trader.BlockStaleOpens, &trader.MaxSlippagePct, &trader.PromptTemplateVersion, &trader.PromptExperiment,
+			&trader.SignalAnalyzers, &trader.DecisionMode, &trader.RuleStrategy, &trader.Locale, &trader.ChangeDetector,
+			&trader.UniverseFilter, &trader.DataProfile, &trader.AlertTrigger, &trader.CreatedAt, &trader.UpdatedAt,
 		)
 		if err != nil {
 			return nil, err
@@ -838,12 +920,12 @@ func (d *Database) UpdateTrader(trader *TraderRecord) error {
 			name = ?, ai_model_id = ?, exchange_id = ?, initial_balance = ?,
 			scan_interval_minutes = ?, btc_eth_leverage = ?, altcoin_leverage = ?,
 			trading_symbols = ?, custom_prompt = ?, override_base_prompt = ?,
-			system_prompt_template = ?, is_cross_margin = ?, updated_at = CURRENT_TIMESTAMP
+			system_prompt_template = ?, is_cross_margin = ?, ai_repair_rounds = ?, block_stale_opens = ?, max_slippage_pct = ?, updated_at = CURRENT_TIMESTAMP
 		WHERE id = ? AND user_id = ?
 	`, trader.Name, trader.AIModelID, trader.ExchangeID, trader.InitialBalance,
 		trader.ScanIntervalMinutes, trader.BTCETHLeverage, trader.AltcoinLeverage,
 		trader.TradingSymbols, trader.CustomPrompt, trader.OverrideBasePrompt,
-		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.ID, trader.UserID)
+		trader.SystemPromptTemplate, trader.IsCrossMargin, trader.AIRepairRounds, trader.BlockStaleOpens, trader.MaxSlippagePct, trader.ID, trader.UserID)
 	return err
 }
 
@@ -853,6 +935,54 @@ func (d *Database) UpdateTraderCustomPrompt(userID, id string, customPrompt stri
 	return err
 }
 
+// UpdateTraderPromptExperiment 更新交易员的提示词A/B实验配置（空字符串表示停止实验）
+func (d *Database) UpdateTraderPromptExperiment(userID, id, experiment string) error {
+	_, err := d.db.Exec(`UPDATE traders SET prompt_experiment = ? WHERE id = ? AND user_id = ?`, experiment, id, userID)
+	return err
+}
+
+// UpdateTraderSignalAnalyzers 更新交易员的信号分析器配置（空字符串表示使用默认分析器）
+func (d *Database) UpdateTraderSignalAnalyzers(userID, id, analyzers string) error {
+	_, err := d.db.Exec(`UPDATE traders SET signal_analyzers = ? WHERE id = ? AND user_id = ?`, analyzers, id, userID)
+	return err
+}
+
+// UpdateTraderDecisionMode 更新交易员的决策方式及规则策略
+func (d *Database) UpdateTraderDecisionMode(userID, id, mode, ruleStrategy string) error {
+	_, err := d.db.Exec(`UPDATE traders SET decision_mode = ?, rule_strategy = ? WHERE id = ? AND user_id = ?`, mode, ruleStrategy, id, userID)
+	return err
+}
+
+// UpdateTraderLocale 更新交易员的提示词语言
+func (d *Database) UpdateTraderLocale(userID, id, locale string) error {
+	_, err := d.db.Exec(`UPDATE traders SET locale = ? WHERE id = ? AND user_id = ?`, locale, id, userID)
+	return err
+}
+
+// UpdateTraderChangeDetector 更新交易员的市场状态变化检测配置（空字符串表示停用）
+func (d *Database) UpdateTraderChangeDetector(userID, id, changeDetector string) error {
+	_, err := d.db.Exec(`UPDATE traders SET change_detector = ? WHERE id = ? AND user_id = ?`, changeDetector, id, userID)
+	return err
+}
+
+// UpdateTraderUniverseFilter 更新交易员的候选币种筛选条件（空字符串表示使用默认条件）
+func (d *Database) UpdateTraderUniverseFilter(userID, id, universeFilter string) error {
+	_, err := d.db.Exec(`UPDATE traders SET universe_filter = ? WHERE id = ? AND user_id = ?`, universeFilter, id, userID)
+	return err
+}
+
+// UpdateTraderDataProfile 更新交易员的行情数据配置（空字符串表示使用原有格式）
+func (d *Database) UpdateTraderDataProfile(userID, id, dataProfile string) error {
+	_, err := d.db.Exec(`UPDATE traders SET data_profile = ? WHERE id = ? AND user_id = ?`, dataProfile, id, userID)
+	return err
+}
+
+// UpdateTraderAlertTrigger 更新交易员的行情警报配置（空字符串表示不使用警报）
+func (d *Database) UpdateTraderAlertTrigger(userID, id, alertTrigger string) error {
+	_, err := d.db.Exec(`UPDATE traders SET alert_trigger = ? WHERE id = ? AND user_id = ?`, alertTrigger, id, userID)
+	return err
+}
+
 // DeleteTrader 删除交易员
 func (d *Database) DeleteTrader(userID, id string) error {
 	_, err := d.db.Exec(`DELETE FROM traders WHERE id = ? AND user_id = ?`, id, userID)
@@ -867,7 +997,12 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 
 	err := d.db.QueryRow(`
 		SELECT 
-			t.id, t.user_id, t.name, t.ai_model_id, t.exchange_id, t.initial_balance, t.scan_interval_minutes, t.is_running, t.created_at, t.updated_at,
+			t.id, t.user_id, t.name, t.ai_model_id, t.exchange_id, t.initial_balance, t.scan_interval_minutes, t.is_running,
+			COALESCE(t.ai_repair_rounds, 1) as ai_repair_rounds, COALESCE(t.locale, 'zh-CN') as locale,
+			COALESCE(t.block_stale_opens, 0) as block_stale_opens, COALESCE(t.max_slippage_pct, 0) as max_slippage_pct,
+			COALESCE(t.signal_analyzers, '') as signal_analyzers,
+			COALESCE(t.decision_mode, 'ai') as decision_mode, COALESCE(t.rule_strategy, '') as rule_strategy,
+			t.created_at, t.updated_at,
 			a.id, a.user_id, a.name, a.provider, a.enabled, a.api_key, a.created_at, a.updated_at,
 			e.id, e.user_id, e.name, e.type, e.enabled, e.api_key, e.secret_key, e.testnet,
 			COALESCE(e.hyperliquid_wallet_addr, '') as hyperliquid_wallet_addr,
@@ -882,7 +1017,8 @@ func (d *Database) GetTraderConfig(userID, traderID string) (*TraderRecord, *AIM
 	`, traderID, userID).Scan(
 		&trader.ID, &trader.UserID, &trader.Name, &trader.AIModelID, &trader.ExchangeID,
 		&trader.InitialBalance, &trader.ScanIntervalMinutes, &trader.IsRunning,
-		&trader.CreatedAt, &trader.UpdatedAt,
+		&trader.AIRepairRounds, &trader.Locale, &trader.BlockStaleOpens, &trader.MaxSlippagePct,
+		&trader.SignalAnalyzers, &trader.DecisionMode, &trader.RuleStrategy, &trader.CreatedAt, &trader.UpdatedAt,
 		&aiModel.ID, &aiModel.UserID, &aiModel.Name, &aiModel.Provider, &aiModel.Enabled, &aiModel.APIKey,
 		&aiModel.CreatedAt, &aiModel.UpdatedAt,
 		&exchange.ID, &exchange.UserID, &exchange.Name, &exchange.Type, &exchange.Enabled,
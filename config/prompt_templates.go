@@ -0,0 +1,361 @@
+package config
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrPromptTemplateInUse 模板仍被交易员引用，不能删除
+var ErrPromptTemplateInUse = errors.New("提示词模板仍被交易员引用")
+
+// PromptTemplateRecord 用户提示词模板（数据库存储，支持版本管理）
+type PromptTemplateRecord struct {
+	ID             int64     `json:"id"`
+	UserID         string    `json:"user_id"`
+	Name           string    `json:"name"`            // 模板名称（交易员通过 system_prompt_template 引用）
+	Description    string    `json:"description"`     // 模板说明
+	CurrentVersion int       `json:"current_version"` // 当前版本号
+	Content        string    `json:"content"`         // 当前版本内容
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// PromptTemplateVersion 提示词模板的历史版本
+type PromptTemplateVersion struct {
+	ID         int64     `json:"id"`
+	TemplateID int64     `json:"template_id"`
+	Version    int       `json:"version"`
+	Content    string    `json:"content"`
+	Comment    string    `json:"comment"` // 版本说明（如"回滚到v2"）
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// PromptDiffLine 版本差异中的一行
+type PromptDiffLine struct {
+	Type    string `json:"type"` // "equal", "add", "remove"
+	Content string `json:"content"`
+}
+
+// CreatePromptTemplate 创建用户提示词模板（同时创建第1个版本）
+func (d *Database) CreatePromptTemplate(userID, name, description, content string) (*PromptTemplateRecord, error) {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.Exec(`
+		INSERT INTO prompt_templates (user_id, name, description, current_version)
+		VALUES (?, ?, ?, 1)
+	`, userID, name, description)
+	if err != nil {
+		return nil, fmt.Errorf("创建模板失败: %w", err)
+	}
+
+	templateID, err := result.LastInsertId()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO prompt_template_versions (template_id, version, content, comment)
+		VALUES (?, 1, ?, '初始版本')
+	`, templateID, content)
+	if err != nil {
+		return nil, fmt.Errorf("创建模板版本失败: %w", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return nil, err
+	}
+
+	return d.GetPromptTemplate(userID, templateID)
+}
+
+// GetPromptTemplates 获取用户的所有提示词模板（包含当前版本内容）
+func (d *Database) GetPromptTemplates(userID string) ([]*PromptTemplateRecord, error) {
+	rows, err := d.db.Query(`
+		SELECT t.id, t.user_id, t.name, COALESCE(t.description, ''), t.current_version,
+		       COALESCE(v.content, ''), t.created_at, t.updated_at
+		FROM prompt_templates t
+		LEFT JOIN prompt_template_versions v ON v.template_id = t.id AND v.version = t.current_version
+		WHERE t.user_id = ? ORDER BY t.name
+	`, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	templates := make([]*PromptTemplateRecord, 0)
+	for rows.Next() {
+		var t PromptTemplateRecord
+		if err := rows.Scan(&t.ID, &t.UserID, &t.Name, &t.Description, &t.CurrentVersion,
+			&t.Content, &t.CreatedAt, &t.UpdatedAt); err != nil {
+			return nil, err
+		}
+		templates = append(templates, &t)
+	}
+
+	return templates, nil
+}
+
+// GetPromptTemplate 获取用户的指定提示词模板（当前版本）
+func (d *Database) GetPromptTemplate(userID string, templateID int64) (*PromptTemplateRecord, error) {
+	var t PromptTemplateRecord
+	err := d.db.QueryRow(`
+		SELECT t.id, t.user_id, t.name, COALESCE(t.description, ''), t.current_version,
+		       COALESCE(v.content, ''), t.created_at, t.updated_at
+		FROM prompt_templates t
+		LEFT JOIN prompt_template_versions v ON v.template_id = t.id AND v.version = t.current_version
+		WHERE t.id = ? AND t.user_id = ?
+	`, templateID, userID).Scan(&t.ID, &t.UserID, &t.Name, &t.Description, &t.CurrentVersion,
+		&t.Content, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &t, nil
+}
+
+// GetPromptTemplateByName 按名称获取用户提示词模板
+// version > 0 时返回该版本内容（交易员固定版本），否则返回当前版本
+func (d *Database) GetPromptTemplateByName(userID, name string, version int) (*PromptTemplateRecord, error) {
+	var t PromptTemplateRecord
+	err := d.db.QueryRow(`
+		SELECT id, user_id, name, COALESCE(description, ''), current_version, created_at, updated_at
+		FROM prompt_templates WHERE user_id = ? AND name = ?
+	`, userID, name).Scan(&t.ID, &t.UserID, &t.Name, &t.Description, &t.CurrentVersion, &t.CreatedAt, &t.UpdatedAt)
+	if err != nil {
+		return nil, err
+	}
+
+	if version <= 0 {
+		version = t.CurrentVersion
+	}
+
+	v, err := d.GetPromptTemplateVersion(t.ID, version)
+	if err != nil {
+		return nil, fmt.Errorf("模板 %s 的版本 v%d 不存在: %v", name, version, err)
+	}
+
+	// 返回的 CurrentVersion 表示实际使用的版本
+	t.CurrentVersion = v.Version
+	t.Content = v.Content
+	return &t, nil
+}
+
+// UpdatePromptTemplate 更新模板内容（创建新版本）
+func (d *Database) UpdatePromptTemplate(userID string, templateID int64, description, content, comment string) (*PromptTemplateRecord, error) {
+	existing, err := d.GetPromptTemplate(userID, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	if description == "" {
+		description = existing.Description
+	}
+
+	if err := d.addPromptTemplateVersion(existing, description, content, comment); err != nil {
+		return nil, err
+	}
+
+	return d.GetPromptTemplate(userID, templateID)
+}
+
+// RollbackPromptTemplate 回滚到指定版本（以该版本内容创建新版本，保留完整历史）
+func (d *Database) RollbackPromptTemplate(userID string, templateID int64, version int) (*PromptTemplateRecord, error) {
+	existing, err := d.GetPromptTemplate(userID, templateID)
+	if err != nil {
+		return nil, err
+	}
+
+	target, err := d.GetPromptTemplateVersion(templateID, version)
+	if err != nil {
+		return nil, fmt.Errorf("版本 v%d 不存在: %w", version, err)
+	}
+
+	comment := fmt.Sprintf("回滚到v%d", version)
+	if err := d.addPromptTemplateVersion(existing, existing.Description, target.Content, comment); err != nil {
+		return nil, err
+	}
+
+	return d.GetPromptTemplate(userID, templateID)
+}
+
+// addPromptTemplateVersion 为模板追加新版本并更新当前版本号
+func (d *Database) addPromptTemplateVersion(existing *PromptTemplateRecord, description, content, comment string) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	newVersion := existing.CurrentVersion + 1
+	var maxVersion sql.NullInt64
+	if err := tx.QueryRow(`SELECT MAX(version) FROM prompt_template_versions WHERE template_id = ?`, existing.ID).Scan(&maxVersion); err == nil && maxVersion.Valid {
+		newVersion = int(maxVersion.Int64) + 1
+	}
+
+	_, err = tx.Exec(`
+		INSERT INTO prompt_template_versions (template_id, version, content, comment)
+		VALUES (?, ?, ?, ?)
+	`, existing.ID, newVersion, content, comment)
+	if err != nil {
+		return fmt.Errorf("创建模板版本失败: %w", err)
+	}
+
+	_, err = tx.Exec(`
+		UPDATE prompt_templates SET current_version = ?, description = ?, updated_at = CURRENT_TIMESTAMP
+		WHERE id = ?
+	`, newVersion, description, existing.ID)
+	if err != nil {
+		return fmt.Errorf("更新模板失败: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// DeletePromptTemplate 删除模板及其所有版本（模板不存在返回 sql.ErrNoRows，仍被交易员引用返回 ErrPromptTemplateInUse）
+// 引用检查与删除在同一事务中，避免检查后新交易员引用该模板
+func (d *Database) DeletePromptTemplate(userID string, templateID int64) error {
+	tx, err := d.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	var name string
+	if err := tx.QueryRow(`SELECT name FROM prompt_templates WHERE id = ? AND user_id = ?`, templateID, userID).Scan(&name); err != nil {
+		return err
+	}
+
+	rows, err := tx.Query(`SELECT name FROM traders WHERE user_id = ? AND system_prompt_template = ?`, userID, name)
+	if err != nil {
+		return fmt.Errorf("查询引用模板的交易员失败: %w", err)
+	}
+	var traders []string
+	for rows.Next() {
+		var traderName string
+		if err := rows.Scan(&traderName); err != nil {
+			rows.Close()
+			return err
+		}
+		traders = append(traders, traderName)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	if len(traders) > 0 {
+		return fmt.Errorf("%w: %s", ErrPromptTemplateInUse, strings.Join(traders, ", "))
+	}
+
+	if _, err := tx.Exec(`DELETE FROM prompt_template_versions WHERE template_id = ?`, templateID); err != nil {
+		return fmt.Errorf("删除模板版本失败: %w", err)
+	}
+	if _, err := tx.Exec(`DELETE FROM prompt_templates WHERE id = ? AND user_id = ?`, templateID, userID); err != nil {
+		return fmt.Errorf("删除模板失败: %w", err)
+	}
+
+	return tx.Commit()
+}
+
+// GetPromptTemplateVersions 获取模板的版本历史（新版本在前）
+func (d *Database) GetPromptTemplateVersions(userID string, templateID int64) ([]*PromptTemplateVersion, error) {
+	// 校验模板归属
+	if _, err := d.GetPromptTemplate(userID, templateID); err != nil {
+		return nil, err
+	}
+
+	rows, err := d.db.Query(`
+		SELECT id, template_id, version, content, COALESCE(comment, ''), created_at
+		FROM prompt_template_versions WHERE template_id = ? ORDER BY version DESC
+	`, templateID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	versions := make([]*PromptTemplateVersion, 0)
+	for rows.Next() {
+		var v PromptTemplateVersion
+		if err := rows.Scan(&v.ID, &v.TemplateID, &v.Version, &v.Content, &v.Comment, &v.CreatedAt); err != nil {
+			return nil, err
+		}
+		versions = append(versions, &v)
+	}
+
+	return versions, nil
+}
+
+// GetPromptTemplateVersion 获取模板的指定版本
+func (d *Database) GetPromptTemplateVersion(templateID int64, version int) (*PromptTemplateVersion, error) {
+	var v PromptTemplateVersion
+	err := d.db.QueryRow(`
+		SELECT id, template_id, version, content, COALESCE(comment, ''), created_at
+		FROM prompt_template_versions WHERE template_id = ? AND version = ?
+	`, templateID, version).Scan(&v.ID, &v.TemplateID, &v.Version, &v.Content, &v.Comment, &v.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	return &v, nil
+}
+
+// UpdateTraderPromptTemplate 设置交易员使用的提示词模板及固定版本（version=0 表示始终使用最新版本）
+func (d *Database) UpdateTraderPromptTemplate(userID, id, templateName string, version int) error {
+	_, err := d.db.Exec(`
+		UPDATE traders SET system_prompt_template = ?, prompt_template_version = ?
+		WHERE id = ? AND user_id = ?
+	`, templateName, version, id, userID)
+	return err
+}
+
+// DiffPromptVersions 按行比较两个版本的内容（基于最长公共子序列）
+func DiffPromptVersions(from, to string) []PromptDiffLine {
+	a := strings.Split(from, "\n")
+	b := strings.Split(to, "\n")
+
+	// lcs[i][j] = a[i:] 与 b[j:] 的最长公共子序列长度
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else if lcs[i+1][j] >= lcs[i][j+1] {
+				lcs[i][j] = lcs[i+1][j]
+			} else {
+				lcs[i][j] = lcs[i][j+1]
+			}
+		}
+	}
+
+	diff := make([]PromptDiffLine, 0, len(a)+len(b))
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case a[i] == b[j]:
+			diff = append(diff, PromptDiffLine{Type: "equal", Content: a[i]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			diff = append(diff, PromptDiffLine{Type: "remove", Content: a[i]})
+			i++
+		default:
+			diff = append(diff, PromptDiffLine{Type: "add", Content: b[j]})
+			j++
+		}
+	}
+	for ; i < len(a); i++ {
+		diff = append(diff, PromptDiffLine{Type: "remove", Content: a[i]})
+	}
+	for ; j < len(b); j++ {
+		diff = append(diff, PromptDiffLine{Type: "add", Content: b[j]})
+	}
+
+	return diff
+}
@@ -0,0 +1,138 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"nofx/market"
+	"strings"
+	"time"
+)
+
+// AlertTriggerConfig 行情警报的使用方式（警报由 WSMonitor 根据3分钟K线特征产生）
+type AlertTriggerConfig struct {
+	Enabled         bool     `json:"enabled"`
+	TriggerCycle    bool     `json:"trigger_cycle"`    // 持仓/候选币种出现警报时提前执行交易周期
+	AddCandidates   bool     `json:"add_candidates"`   // 把警报评分高的币种加入候选
+	Types           []string `json:"types"`            // 使用的警报类型（为空表示全部类型）
+	MinScore        float64  `json:"min_score"`        // 加入候选的最低币种评分
+	MaxCandidates   int      `json:"max_candidates"`   // 最多加入的警报币种数量
+	CooldownMinutes int      `json:"cooldown_minutes"` // 警报触发的周期与上个周期的最短间隔（分钟）
+}
+
+// DefaultAlertTriggerConfig 默认配置
+func DefaultAlertTriggerConfig() *AlertTriggerConfig {
+	return &AlertTriggerConfig{
+		Enabled:         true,
+		TriggerCycle:    true,
+		AddCandidates:   true,
+		MinScore:        15,
+		MaxCandidates:   5,
+		CooldownMinutes: 3,
+	}
+}
+
+// ParseAlertTriggerConfig 解析并校验数据库中保存的警报配置（空字符串表示未启用）
+func ParseAlertTriggerConfig(raw string) (*AlertTriggerConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	cfg := DefaultAlertTriggerConfig()
+	if err := json.Unmarshal([]byte(raw), cfg); err != nil {
+		return nil, fmt.Errorf("解析警报配置失败: %w", err)
+	}
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// Validate 校验警报配置
+func (c *AlertTriggerConfig) Validate() error {
+	if !c.TriggerCycle && !c.AddCandidates {
+		return fmt.Errorf("trigger_cycle 和 add_candidates 至少启用一项")
+	}
+	if c.MinScore < 0 || c.MaxCandidates < 0 || c.CooldownMinutes < 0 {
+		return fmt.Errorf("警报配置不能为负数")
+	}
+	if c.AddCandidates && c.MaxCandidates == 0 {
+		return fmt.Errorf("加入候选时 max_candidates 必须大于0")
+	}
+	for _, alertType := range c.Types {
+		valid := false
+		for _, known := range market.AlertTypes {
+			if alertType == known {
+				valid = true
+				break
+			}
+		}
+		if !valid {
+			return fmt.Errorf("不支持的警报类型: %s（可选 %s）", alertType, strings.Join(market.AlertTypes, "/"))
+		}
+	}
+	return nil
+}
+
+// String 序列化为JSON（保存到数据库）
+func (c *AlertTriggerConfig) String() string {
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+// Cooldown 警报触发周期的最短间隔
+func (c *AlertTriggerConfig) Cooldown() time.Duration {
+	return time.Duration(c.CooldownMinutes) * time.Minute
+}
+
+// Accepts 是否使用该类型的警报
+func (c *AlertTriggerConfig) Accepts(alertType string) bool {
+	if len(c.Types) == 0 {
+		return true
+	}
+	for _, t := range c.Types {
+		if t == alertType {
+			return true
+		}
+	}
+	return false
+}
+
+// AppendAlertCandidates 把警报评分不低于 MinScore 的币种加入候选（已在候选中的币种只追加来源 "alert"）
+// 只统计最近 NoAlertTimeout 内有指定类型警报的币种
+func AppendAlertCandidates(candidates []CandidateCoin, cfg *AlertTriggerConfig, monitor *market.WSMonitor) []CandidateCoin {
+	if cfg == nil || !cfg.AddCandidates || monitor == nil {
+		return candidates
+	}
+
+	since := time.Now().Add(-market.GetAlertConfig().CleanupConfig.NoAlertTimeout)
+	alerted := make(map[string]bool)
+	for _, alert := range monitor.GetRecentAlerts(since, "", "", 0) {
+		if cfg.Accepts(alert.Type) {
+			alerted[alert.Symbol] = true
+		}
+	}
+
+	index := make(map[string]int, len(candidates))
+	for i, coin := range candidates {
+		index[coin.Symbol] = i
+	}
+	added := 0
+	for _, score := range monitor.GetSymbolScores(cfg.MinScore, 0) {
+		if added >= cfg.MaxCandidates {
+			break
+		}
+		if !alerted[score.Symbol] {
+			continue
+		}
+		if i, ok := index[score.Symbol]; ok {
+			candidates[i].Sources = append(candidates[i].Sources, "alert")
+			continue
+		}
+		candidates = append(candidates, CandidateCoin{Symbol: score.Symbol, Sources: []string{"alert"}})
+		added++
+	}
+	return candidates
+}
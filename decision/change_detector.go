@@ -0,0 +1,229 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"nofx/market"
+	"sort"
+	"strings"
+)
+
+// DecisionSourceNoChange 决策来源：市场状态无变化，跳过AI调用
+const DecisionSourceNoChange = "no_change"
+
+// ChangeDetectorConfig 市场状态变化检测配置（持仓不变且所有币种变化都未超过阈值时跳过AI调用）
+type ChangeDetectorConfig struct {
+	Enabled           bool    `json:"enabled"`             // 是否启用
+	PriceChangePct    float64 `json:"price_change_pct"`    // 价格变化阈值（%，默认0.5）
+	VolumeRatioChange float64 `json:"volume_ratio_change"` // 5分钟成交量比率变化阈值（默认0.5）
+	RSIChange         float64 `json:"rsi_change"`          // RSI7变化阈值（默认5）
+	MaxSkips          int     `json:"max_skips"`           // 连续跳过的周期数上限，达到后强制调用AI（默认5）
+}
+
+// DefaultChangeDetectorConfig 默认变化检测阈值
+func DefaultChangeDetectorConfig() *ChangeDetectorConfig {
+	return &ChangeDetectorConfig{
+		Enabled:           true,
+		PriceChangePct:    0.5,
+		VolumeRatioChange: 0.5,
+		RSIChange:         5,
+		MaxSkips:          5,
+	}
+}
+
+// ParseChangeDetectorConfig 解析并校验数据库中保存的变化检测配置（空字符串或未启用时返回nil）
+func ParseChangeDetectorConfig(raw string) (*ChangeDetectorConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var cfg ChangeDetectorConfig
+	if err := json.Unmarshal([]byte(raw), &cfg); err != nil {
+		return nil, fmt.Errorf("解析变化检测配置失败: %w", err)
+	}
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// Validate 校验变化检测配置（未设置的阈值使用默认值）
+func (c *ChangeDetectorConfig) Validate() error {
+	if c.PriceChangePct < 0 || c.VolumeRatioChange < 0 || c.RSIChange < 0 || c.MaxSkips < 0 {
+		return fmt.Errorf("变化检测阈值不能为负数")
+	}
+
+	defaults := DefaultChangeDetectorConfig()
+	if c.PriceChangePct == 0 {
+		c.PriceChangePct = defaults.PriceChangePct
+	}
+	if c.VolumeRatioChange == 0 {
+		c.VolumeRatioChange = defaults.VolumeRatioChange
+	}
+	if c.RSIChange == 0 {
+		c.RSIChange = defaults.RSIChange
+	}
+	if c.MaxSkips == 0 {
+		c.MaxSkips = defaults.MaxSkips
+	}
+	return nil
+}
+
+// String 序列化为JSON（保存到数据库）
+func (c *ChangeDetectorConfig) String() string {
+	data, _ := json.Marshal(c)
+	return string(data)
+}
+
+// marketState 变化检测比较的单个币种状态
+type marketState struct {
+	price       float64
+	rsi7        float64
+	macd        float64
+	volumeRatio float64
+	supertrend  string // 各时间框架的 Supertrend 信号
+}
+
+// ChangeDetector 市场状态变化检测器
+// 与上一次调用AI时的状态比较（而不是上一个周期），避免缓慢漂移始终低于阈值而永远不调用AI
+type ChangeDetector struct {
+	config    *ChangeDetectorConfig
+	positions string                  // 上次调用AI时的持仓（币种、方向、数量）
+	markets   map[string]*marketState // 上次调用AI时各币种的市场状态
+	skips     int                     // 已连续跳过的周期数
+}
+
+// NewChangeDetector 创建变化检测器
+func NewChangeDetector(config *ChangeDetectorConfig) *ChangeDetector {
+	return &ChangeDetector{config: config}
+}
+
+// Config 获取变化检测配置
+func (d *ChangeDetector) Config() *ChangeDetectorConfig {
+	return d.config
+}
+
+// Check 检查本周期相对上次调用AI时是否有变化，返回是否需要调用AI及原因
+// 返回 false 时计入连续跳过次数
+func (d *ChangeDetector) Check(ctx *Context) (bool, string) {
+	if d.markets == nil {
+		return true, "没有上次调用AI时的市场状态"
+	}
+	if d.skips >= d.config.MaxSkips {
+		return true, fmt.Sprintf("已连续跳过 %d 个周期", d.skips)
+	}
+	if positionsKey(ctx.Positions) != d.positions {
+		return true, "持仓发生变化"
+	}
+
+	markets := captureMarketStates(ctx)
+	if len(markets) != len(d.markets) {
+		return true, "候选币种发生变化"
+	}
+	symbols := make([]string, 0, len(markets))
+	for symbol := range markets {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+
+	for _, symbol := range symbols {
+		current, previous := markets[symbol], d.markets[symbol]
+		if previous == nil {
+			return true, fmt.Sprintf("新增候选币种 %s", symbol)
+		}
+		if reason := d.compare(symbol, previous, current); reason != "" {
+			return true, reason
+		}
+	}
+
+	d.skips++
+	return false, fmt.Sprintf("持仓及 %d 个币种均无明显变化（连续跳过 %d/%d）", len(markets), d.skips, d.config.MaxSkips)
+}
+
+// compare 比较单个币种的状态，超过阈值时返回原因
+func (d *ChangeDetector) compare(symbol string, previous, current *marketState) string {
+	if previous.price > 0 {
+		changePct := (current.price - previous.price) / previous.price * 100
+		if math.Abs(changePct) >= d.config.PriceChangePct {
+			return fmt.Sprintf("%s 价格变化 %+.2f%%", symbol, changePct)
+		}
+	}
+	if delta := current.rsi7 - previous.rsi7; math.Abs(delta) >= d.config.RSIChange {
+		return fmt.Sprintf("%s RSI7变化 %+.1f", symbol, delta)
+	}
+	if delta := current.volumeRatio - previous.volumeRatio; math.Abs(delta) >= d.config.VolumeRatioChange {
+		return fmt.Sprintf("%s 成交量比率变化 %+.2f", symbol, delta)
+	}
+	if (current.macd > 0) != (previous.macd > 0) {
+		return fmt.Sprintf("%s MACD穿越零轴", symbol)
+	}
+	if current.supertrend != previous.supertrend {
+		return fmt.Sprintf("%s Supertrend信号变化 (%s → %s)", symbol, previous.supertrend, current.supertrend)
+	}
+	return ""
+}
+
+// Update 记录本次调用AI时的状态（AI决策成功后调用），并重置连续跳过次数
+func (d *ChangeDetector) Update(ctx *Context) {
+	d.positions = positionsKey(ctx.Positions)
+	d.markets = captureMarketStates(ctx)
+	d.skips = 0
+}
+
+// Reset 清除记录的状态（下个周期必定调用AI）
+func (d *ChangeDetector) Reset() {
+	d.markets = nil
+	d.skips = 0
+}
+
+// positionsKey 持仓的比较键（币种、方向、数量）
+func positionsKey(positions []PositionInfo) string {
+	keys := make([]string, 0, len(positions))
+	for _, pos := range positions {
+		keys = append(keys, fmt.Sprintf("%s:%s:%g", pos.Symbol, pos.Side, pos.Quantity))
+	}
+	sort.Strings(keys)
+	return strings.Join(keys, ",")
+}
+
+// captureMarketStates 提取上下文中各币种（候选币种及持仓币种）的比较状态
+func captureMarketStates(ctx *Context) map[string]*marketState {
+	markets := make(map[string]*marketState, len(ctx.MarketDataMap))
+	for symbol, data := range ctx.MarketDataMap {
+		if data == nil {
+			continue
+		}
+		state := &marketState{
+			price: data.CurrentPrice,
+			rsi7:  data.CurrentRSI7,
+			macd:  data.CurrentMACD,
+		}
+		if data.VolumePriceData != nil {
+			state.volumeRatio = data.VolumePriceData.VolumeRatio5m
+		}
+		state.supertrend = supertrendSignals(data.SupertrendData)
+		markets[symbol] = state
+	}
+	return markets
+}
+
+// supertrendSignals 各时间框架的 Supertrend 信号（用于比较）
+func supertrendSignals(st *market.SupertrendMultiTimeframe) string {
+	if st == nil {
+		return ""
+	}
+	timeframes := []*market.SupertrendData{st.Timeframe3m, st.Timeframe5m, st.Timeframe15m, st.Timeframe30m, st.Timeframe1h, st.Timeframe4h}
+	signals := make([]string, 0, len(timeframes))
+	for _, tf := range timeframes {
+		if tf == nil {
+			signals = append(signals, "-")
+			continue
+		}
+		signals = append(signals, tf.Signal)
+	}
+	return strings.Join(signals, "/")
+}
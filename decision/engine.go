@@ -23,7 +23,8 @@ type PositionInfo struct {
 	UnrealizedPnLPct float64 `json:"unrealized_pnl_pct"`
 	LiquidationPrice float64 `json:"liquidation_price"`
 	MarginUsed       float64 `json:"margin_used"`
-	UpdateTime       int64   `json:"update_time"` // 持仓更新时间戳（毫秒）
+	UpdateTime       int64   `json:"update_time"`  // 持仓更新时间戳（毫秒）
+	FundingCost      float64 `json:"funding_cost"` // 持仓期间资金费估算（USDT，正数为支付，负数为收取）
 }
 
 // AccountInfo 账户信息
@@ -40,32 +41,43 @@ type AccountInfo struct {
 // CandidateCoin 候选币种（来自币种池）
 type CandidateCoin struct {
 	Symbol  string   `json:"symbol"`
-	Sources []string `json:"sources"` // 来源: "ai500" 和/或 "oi_top"
+	Sources []string `json:"sources"` // 来源: "ai500" 和/或 "oi_top"，行情警报加入的币种追加 "alert"
 }
 
 // OITopData 持仓量增长Top数据（用于AI决策参考）
 type OITopData struct {
-	Rank              int     // OI Top排名
-	OIDeltaPercent    float64 // 持仓量变化百分比（1小时）
-	OIDeltaValue      float64 // 持仓量变化价值
-	PriceDeltaPercent float64 // 价格变化百分比
-	NetLong           float64 // 净多仓
-	NetShort          float64 // 净空仓
+	Rank              int     `json:"rank"`                // OI Top排名
+	OIDeltaPercent    float64 `json:"oi_delta_percent"`    // 持仓量变化百分比（1小时）
+	OIDeltaValue      float64 `json:"oi_delta_value"`      // 持仓量变化价值
+	PriceDeltaPercent float64 `json:"price_delta_percent"` // 价格变化百分比
+	NetLong           float64 `json:"net_long"`            // 净多仓
+	NetShort          float64 `json:"net_short"`           // 净空仓
 }
 
 // Context 交易上下文（传递给AI的完整信息）
 type Context struct {
-	CurrentTime     string                  `json:"current_time"`
-	RuntimeMinutes  int                     `json:"runtime_minutes"`
-	CallCount       int                     `json:"call_count"`
-	Account         AccountInfo             `json:"account"`
-	Positions       []PositionInfo          `json:"positions"`
-	CandidateCoins  []CandidateCoin         `json:"candidate_coins"`
-	MarketDataMap   map[string]*market.Data `json:"-"` // 不序列化，但内部使用
-	OITopDataMap    map[string]*OITopData   `json:"-"` // OI Top数据映射
-	Performance     interface{}             `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
-	BTCETHLeverage  int                     `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
-	AltcoinLeverage int                     `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	CurrentTime       string                        `json:"current_time"`
+	RuntimeMinutes    int                           `json:"runtime_minutes"`
+	CallCount         int                           `json:"call_count"`
+	Account           AccountInfo                   `json:"account"`
+	Positions         []PositionInfo                `json:"positions"`
+	CandidateCoins    []CandidateCoin               `json:"candidate_coins"`
+	Locale            string                        `json:"locale"`
+	MarketDataMap     map[string]*market.Data       `json:"-"` // 不序列化，但内部使用
+	MarketProvider    market.MarketDataProvider     `json:"-"` // 行情数据源（与交易员的交易平台一致，nil表示币安）
+	DataProfile       *market.DataProfile           `json:"-"` // 行情数据配置（时间框架和指标，nil表示使用原有格式）
+	OITopDataMap      map[string]*OITopData         `json:"-"` // OI Top数据映射
+	Performance       interface{}                   `json:"-"` // 历史表现分析（logger.PerformanceAnalysis）
+	BTCETHLeverage    int                           `json:"-"` // BTC/ETH杠杆倍数（从配置读取）
+	AltcoinLeverage   int                           `json:"-"` // 山寨币杠杆倍数（从配置读取）
+	MaxRepairRounds   int                           `json:"-"` // 决策验证失败时允许AI自我修复的轮数（0=不修复）
+	TraderName        string                        `json:"-"` // 交易员名称（提示词模板变量）
+	PromptTemplate    *PromptTemplate               `json:"-"` // 数据库版本化提示词模板（设置时优先于文件模板）
+	SignalAnalyzers   []SignalAnalyzer              `json:"-"` // 启用的信号分析器（nil表示使用默认分析器）
+	SignalAnnotations map[string][]SignalAnnotation `json:"-"` // 信号分析器输出（按币种）
+	UniverseFilter    *UniverseFilter               `json:"-"` // 候选币种筛选条件（nil表示使用默认条件）
+	RejectedCoins     []UniverseRejection           `json:"-"` // 未进入候选的币种及原因
+	SkippedFilters    []string                      `json:"-"` // 行情数据源不支持而未检查的筛选条件及原因
 }
 
 // Decision AI的交易决策
@@ -81,13 +93,27 @@ type Decision struct {
 	Reasoning       string  `json:"reasoning"`
 }
 
+// RejectedDecision 未通过验证的决策（附带拒绝原因）
+type RejectedDecision struct {
+	Decision Decision `json:"decision"`
+	Error    string   `json:"error"` // 验证失败原因
+}
+
 // FullDecision AI的完整决策（包含思维链）
 type FullDecision struct {
-	SystemPrompt string     `json:"system_prompt"` // 系统提示词（发送给AI的系统prompt）
-	UserPrompt   string     `json:"user_prompt"`   // 发送给AI的输入prompt
-	CoTTrace     string     `json:"cot_trace"`     // 思维链分析（AI输出）
-	Decisions    []Decision `json:"decisions"`     // 具体决策列表
-	Timestamp    time.Time  `json:"timestamp"`
+	SystemPrompt      string             `json:"system_prompt"`                // 系统提示词（发送给AI的系统prompt）
+	UserPrompt        string             `json:"user_prompt"`                  // 发送给AI的输入prompt
+	CoTTrace          string             `json:"cot_trace"`                    // 思维链分析（AI输出）
+	Decisions         []Decision         `json:"decisions"`                    // 具体决策列表（仅包含通过验证的决策）
+	RejectedDecisions []RejectedDecision `json:"rejected_decisions,omitempty"` // 修复后仍未通过验证的决策
+	RawResponse       string             `json:"raw_response"`                 // AI原始输出
+	RepairedResponses []string           `json:"repaired_responses,omitempty"` // 每轮修复的AI输出
+	TemplateName      string             `json:"template_name"`                // 使用的提示词模板名称
+	TemplateVersion   int                `json:"template_version"`             // 使用的提示词模板版本（文件模板为0）
+	PromptBudget      *PromptBudget      `json:"prompt_budget"`                // prompt token预算使用情况及裁剪记录
+	Signals           []SignalAnnotation `json:"signals"`                      // 信号分析器输出（写入prompt的结构化信号）
+	Source            string             `json:"source"`                       // 决策来源: ai/rule/rule_fallback
+	Timestamp         time.Time          `json:"timestamp"`
 }
 
 // GetFullDecision 获取AI的完整交易决策（批量分析所有币种和持仓）
@@ -97,87 +123,188 @@ func GetFullDecision(ctx *Context, mcpClient *mcp.Client) (*FullDecision, error)
 
 // GetFullDecisionWithCustomPrompt 获取AI的完整交易决策（支持自定义prompt和模板选择）
 func GetFullDecisionWithCustomPrompt(ctx *Context, mcpClient *mcp.Client, customPrompt string, overrideBase bool, templateName string) (*FullDecision, error) {
-	// 1. 为所有币种获取市场数据
-	if err := fetchMarketDataForContext(ctx); err != nil {
-		return nil, fmt.Errorf("获取市场数据失败: %w", err)
+	// 1. 为所有币种获取市场数据（已预先获取时不再重复请求）
+	if ctx.MarketDataMap == nil {
+		if err := fetchMarketDataForContext(ctx); err != nil {
+			return nil, fmt.Errorf("获取市场数据失败: %w", err)
+		}
 	}
+	ctx.SignalAnnotations = runSignalAnalyzers(ctx)
 
 	// 2. 构建 System Prompt（固定规则）和 User Prompt（动态数据）
-	systemPrompt := buildSystemPromptWithCustom(ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, customPrompt, overrideBase, templateName)
-	userPrompt := buildUserPrompt(ctx)
+	promptData := newPromptData(ctx.TraderName, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, ctx.Locale)
+	systemPrompt := buildSystemPromptWithCustom(promptData, customPrompt, overrideBase, templateName, ctx.PromptTemplate)
+	if buildResponseFormat(mcpClient.OutputMode) != nil {
+		systemPrompt += buildStructuredOutputPrompt(ctx.Locale)
+	}
 
-	// 3. 调用AI API（使用 system + user prompt）
-	aiResponse, err := mcpClient.CallWithMessages(systemPrompt, userPrompt)
-	if err != nil {
-		return nil, fmt.Errorf("调用AI API失败: %w", err)
+	// User Prompt 控制在模型上下文预算内（超出时裁剪序列和低排名候选币种）
+	userPrompt, promptBudget := buildUserPromptWithinBudget(ctx, systemPrompt, mcpClient)
+	log.Printf("📏 Prompt token估算: system=%d user=%d 合计=%d / 预算=%d",
+		promptBudget.SystemTokens, promptBudget.UserTokens, promptBudget.TotalTokens(), promptBudget.Budget)
+	for _, cut := range promptBudget.Cuts {
+		log.Printf("✂️  Prompt超出预算(原%d tokens): %s", promptBudget.OriginalTokens, cut)
+	}
+	if promptBudget.OverBudget {
+		log.Printf("⚠️  裁剪后Prompt仍超出预算，可能被模型截断或拒绝")
 	}
 
-	// 4. 解析AI响应
-	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage)
+	// 3. 调用AI API（使用 system + user prompt，支持结构化输出时使用 response_format）
+	messages := []mcp.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: userPrompt},
+	}
+	aiResponse, err := callAI(mcpClient, messages)
 	if err != nil {
-		return decision, fmt.Errorf("解析AI响应失败: %w", err)
+		return nil, fmt.Errorf("%w: 调用AI API失败: %w", ErrAIUnavailable, err)
+	}
+
+	// 4. 解析AI响应（逐条验证，无效决策不影响其他决策）
+	decision, err := parseFullDecisionResponse(aiResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, ctx.Locale)
+	decision.RawResponse = aiResponse
+
+	// 5. 自我修复：把验证错误反馈给AI，让其修正JSON
+	messages = append(messages, mcp.Message{Role: "assistant", Content: aiResponse})
+	for round := 1; round <= ctx.MaxRepairRounds; round++ {
+		if err == nil && len(decision.RejectedDecisions) == 0 {
+			break
+		}
+
+		repairPrompt := buildRepairPrompt(decision.RejectedDecisions, err, ctx.Locale)
+		log.Printf("🔧 决策修复第 %d/%d 轮: %d 个无效决策", round, ctx.MaxRepairRounds, len(decision.RejectedDecisions))
+
+		messages = append(messages, mcp.Message{Role: "user", Content: repairPrompt})
+		repairedResponse, callErr := callAI(mcpClient, messages)
+		if callErr != nil {
+			log.Printf("⚠️  决策修复调用AI失败: %v", callErr)
+			break
+		}
+		messages = append(messages, mcp.Message{Role: "assistant", Content: repairedResponse})
+		decision.RepairedResponses = append(decision.RepairedResponses, repairedResponse)
+
+		repaired, repairErr := parseFullDecisionResponse(repairedResponse, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, ctx.Locale)
+		if repairErr != nil {
+			// 修复输出本身无法解析，保留上一轮结果继续尝试
+			log.Printf("⚠️  决策修复输出解析失败: %v", repairErr)
+			continue
+		}
+
+		added := len(repaired.Decisions)
+		if err != nil {
+			// 原始输出无法解析：修复输出即为完整决策列表
+			decision.Decisions = repaired.Decisions
+		} else {
+			// 原始输出部分有效：修复输出只替换被拒绝的决策（模型常会重新输出完整列表，已通过的决策不能重复执行）
+			before := len(decision.Decisions)
+			decision.Decisions = mergeRepairedDecisions(decision.Decisions, decision.RejectedDecisions, repaired.Decisions)
+			added = len(decision.Decisions) - before
+		}
+		decision.RejectedDecisions = repaired.RejectedDecisions
+		err = nil
+		log.Printf("✓ 决策修复第 %d 轮完成: 新增有效决策 %d 个，仍无效 %d 个",
+			round, added, len(repaired.RejectedDecisions))
 	}
 
 	decision.Timestamp = time.Now()
 	decision.SystemPrompt = systemPrompt // 保存系统prompt
 	decision.UserPrompt = userPrompt     // 保存输入prompt
+	decision.PromptBudget = promptBudget
+	decision.Source = DecisionSourceAI
+	decision.Signals = flattenSignalAnnotations(ctx, ctx.SignalAnnotations)
+	if ctx.PromptTemplate != nil {
+		decision.TemplateName = ctx.PromptTemplate.Name
+		decision.TemplateVersion = ctx.PromptTemplate.Version
+	} else if templateName != "" {
+		decision.TemplateName = templateName
+	} else {
+		decision.TemplateName = "default"
+	}
+	if err != nil {
+		return decision, fmt.Errorf("解析AI响应失败: %w", err)
+	}
+
+	for _, rejected := range decision.RejectedDecisions {
+		log.Printf("⚠️  丢弃无效决策 (%s %s): %s", rejected.Decision.Symbol, rejected.Decision.Action, rejected.Error)
+	}
 	return decision, nil
 }
 
-// fetchMarketDataForContext 为上下文中的所有币种获取市场数据和OI数据
-func fetchMarketDataForContext(ctx *Context) error {
-	ctx.MarketDataMap = make(map[string]*market.Data)
-	ctx.OITopDataMap = make(map[string]*OITopData)
+// buildRepairPrompt 构建决策修复提示（把验证错误反馈给AI）
+func buildRepairPrompt(rejected []RejectedDecision, parseErr error, locale string) string {
+	var sb strings.Builder
 
-	// 收集所有需要获取数据的币种
-	symbolSet := make(map[string]bool)
+	if parseErr != nil {
+		// 整体解析失败：要求重新输出完整决策列表
+		sb.WriteString(localize(locale, "你上一次的输出无法解析为有效的决策JSON数组，错误如下：\n\n"))
+		sb.WriteString(parseErr.Error())
+		sb.WriteString(localize(locale, "\n\n请重新输出完整的决策JSON数组（不需要思维链），格式与系统提示中的要求一致。\n"))
+		return sb.String()
+	}
 
-	// 1. 优先获取持仓币种的数据（这是必须的）
-	for _, pos := range ctx.Positions {
-		symbolSet[pos.Symbol] = true
+	sb.WriteString(localize(locale, "你上一次输出的以下决策未通过验证：\n\n"))
+	for i, r := range rejected {
+		decisionJSON, _ := json.Marshal(r.Decision)
+		sb.WriteString(localizef(locale, "%d. %s\n   错误: %s\n", i+1, string(decisionJSON), r.Error))
 	}
+	sb.WriteString(localize(locale, "\n其他决策已通过验证，无需重复输出。\n"))
+	sb.WriteString(localize(locale, "请只输出以上决策修正后的JSON数组（不需要思维链）；如果无法在约束内修正，请将该决策改为 wait。\n"))
+	return sb.String()
+}
 
-	// 2. 候选币种数量根据账户状态动态调整
-	maxCandidates := calculateMaxCandidates(ctx)
-	for i, coin := range ctx.CandidateCoins {
-		if i >= maxCandidates {
-			break
+// mergeRepairedDecisions 把修复输出合并到已通过验证的决策中
+// 只接受被拒绝币种的修正决策，且 币种+动作 已存在于已通过决策中的修复决策会被丢弃
+func mergeRepairedDecisions(accepted []Decision, rejected []RejectedDecision, repaired []Decision) []Decision {
+	key := func(d Decision) string { return d.Symbol + "|" + d.Action }
+
+	rejectedSymbols := make(map[string]bool, len(rejected))
+	for _, r := range rejected {
+		rejectedSymbols[r.Decision.Symbol] = true
+	}
+	seen := make(map[string]bool, len(accepted)+len(repaired))
+	for _, d := range accepted {
+		seen[key(d)] = true
+	}
+
+	merged := accepted
+	for _, d := range repaired {
+		if !rejectedSymbols[d.Symbol] || seen[key(d)] {
+			log.Printf("⚠️  忽略修复输出中的重复或未被拒绝的决策 (%s %s)", d.Symbol, d.Action)
+			continue
 		}
-		symbolSet[coin.Symbol] = true
+		seen[key(d)] = true
+		merged = append(merged, d)
 	}
+	return merged
+}
+
+// FetchMarketData 在获取决策之前预先为上下文获取市场数据（用于变化检测，之后获取决策时不再重复请求）
+func FetchMarketData(ctx *Context) error {
+	return fetchMarketDataForContext(ctx)
+}
+
+// fetchMarketDataForContext 为上下文中的所有币种获取市场数据和OI数据
+func fetchMarketDataForContext(ctx *Context) error {
+	ctx.MarketDataMap = make(map[string]*market.Data)
+	ctx.OITopDataMap = make(map[string]*OITopData)
+	ctx.RejectedCoins = nil
+	ctx.SkippedFilters = nil
 
-	// 并发获取市场数据
-	// 持仓币种集合（用于判断是否跳过OI检查）
+	// 1. 优先获取持仓币种的数据（这是必须的，现有持仓不参与筛选，需要决策是否平仓）
 	positionSymbols := make(map[string]bool)
 	for _, pos := range ctx.Positions {
 		positionSymbols[pos.Symbol] = true
-	}
-
-	for symbol := range symbolSet {
-		data, err := market.Get(symbol)
+		data, err := market.GetWithProfile(marketProvider(ctx), pos.Symbol, ctx.DataProfile)
 		if err != nil {
 			// 单个币种失败不影响整体，只记录错误
+			log.Printf("⚠️  获取持仓币种 %s 市场数据失败: %v", pos.Symbol, err)
 			continue
 		}
-
-		// ⚠️ 流动性过滤：持仓价值低于15M USD的币种不做（多空都不做）
-		// 持仓价值 = 持仓量 × 当前价格
-		// 但现有持仓必须保留（需要决策是否平仓）
-		isExistingPosition := positionSymbols[symbol]
-		if !isExistingPosition && data.OpenInterest != nil && data.CurrentPrice > 0 {
-			// 计算持仓价值（USD）= 持仓量 × 当前价格
-			oiValue := data.OpenInterest.Latest * data.CurrentPrice
-			oiValueInMillions := oiValue / 1_000_000 // 转换为百万美元单位
-			if oiValueInMillions < 15 {
-				log.Printf("⚠️  %s 持仓价值过低(%.2fM USD < 15M)，跳过此币种 [持仓量:%.0f × 价格:%.4f]",
-					symbol, oiValueInMillions, data.OpenInterest.Latest, data.CurrentPrice)
-				continue
-			}
-		}
-
-		ctx.MarketDataMap[symbol] = data
+		ctx.MarketDataMap[pos.Symbol] = data
 	}
 
+	// 2. 候选币种按交易员的筛选条件过滤（持仓价值、成交额、价差、上线时间、数量上限）
+	filterCandidates(ctx, positionSymbols)
+
 	// 加载OI Top数据（不影响主流程）
 	oiPositions, err := pool.GetOITopPositions()
 	if err == nil {
@@ -198,23 +325,34 @@ func fetchMarketDataForContext(ctx *Context) error {
 	return nil
 }
 
-// calculateMaxCandidates 根据账户状态计算需要分析的候选币种数量
-func calculateMaxCandidates(ctx *Context) int {
-	// 直接返回候选池的全部币种数量
-	// 因为候选池已经在 auto_trader.go 中筛选过了
-	// 固定分析前20个评分最高的币种（来自AI500）
+// marketProvider 上下文使用的行情数据源（未设置时使用币安）
+func marketProvider(ctx *Context) market.MarketDataProvider {
+	if ctx.MarketProvider == nil {
+		return market.ProviderFor(market.ProviderBinance, false)
+	}
+	return ctx.MarketProvider
+}
+
+// calculateMaxCandidates 计算需要分析的候选币种数量（筛选条件未设置上限时分析候选池的全部币种）
+func calculateMaxCandidates(ctx *Context, filter *UniverseFilter) int {
+	if filter.MaxCandidates > 0 {
+		return filter.MaxCandidates
+	}
 	return len(ctx.CandidateCoins)
 }
 
 // buildSystemPromptWithCustom 构建包含自定义内容的 System Prompt
-func buildSystemPromptWithCustom(accountEquity float64, btcEthLeverage, altcoinLeverage int, customPrompt string, overrideBase bool, templateName string) string {
+func buildSystemPromptWithCustom(data *PromptData, customPrompt string, overrideBase bool, templateName string, dbTemplate *PromptTemplate) string {
+	// 自定义prompt同样支持模板变量（渲染失败时按原文使用）
+	customPrompt = renderCustomPrompt(customPrompt, data)
+
 	// 如果覆盖基础prompt且有自定义prompt，只使用自定义prompt
 	if overrideBase && customPrompt != "" {
 		return customPrompt
 	}
 
 	// 获取基础prompt（使用指定的模板）
-	basePrompt := buildSystemPrompt(accountEquity, btcEthLeverage, altcoinLeverage, templateName)
+	basePrompt := buildSystemPrompt(data, templateName, dbTemplate)
 
 	// 如果没有自定义prompt，直接返回基础prompt
 	if customPrompt == "" {
@@ -225,100 +363,120 @@ func buildSystemPromptWithCustom(accountEquity float64, btcEthLeverage, altcoinL
 	var sb strings.Builder
 	sb.WriteString(basePrompt)
 	sb.WriteString("\n\n")
-	sb.WriteString("# 📌 个性化交易策略\n\n")
+	sb.WriteString(localize(data.Locale, "# 📌 个性化交易策略\n\n"))
 	sb.WriteString(customPrompt)
 	sb.WriteString("\n\n")
-	sb.WriteString("注意: 以上个性化策略是对基础规则的补充，不能违背基础风险控制原则。\n")
+	sb.WriteString(localize(data.Locale, "注意: 以上个性化策略是对基础规则的补充，不能违背基础风险控制原则。\n"))
 
 	return sb.String()
 }
 
+// renderCustomPrompt 渲染自定义prompt中的模板变量（不含模板语法或渲染失败时返回原文）
+func renderCustomPrompt(customPrompt string, data *PromptData) string {
+	if !strings.Contains(customPrompt, "{{") {
+		return customPrompt
+	}
+
+	globalPromptManager.mu.RLock()
+	tmpl, err := globalPromptManager.parseTemplate("custom_prompt", data.Locale, customPrompt)
+	globalPromptManager.mu.RUnlock()
+	if err == nil {
+		var rendered string
+		rendered, err = executeTemplate(tmpl, data)
+		if err == nil {
+			return rendered
+		}
+	}
+
+	log.Printf("⚠️  自定义prompt模板渲染失败，使用原文: %v", err)
+	return customPrompt
+}
+
 // buildSystemPrompt 构建 System Prompt（使用模板+动态部分）
-func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage int, templateName string) string {
+// dbTemplate 不为空时优先使用数据库中的版本化模板，渲染失败再回退到文件模板
+func buildSystemPrompt(data *PromptData, templateName string, dbTemplate *PromptTemplate) string {
 	var sb strings.Builder
+	locale := data.Locale
 
-	// 1. 加载提示词模板（核心交易策略部分）
+	// 1. 渲染提示词模板（核心交易策略部分，按提示词语言选择模板文件）
 	if templateName == "" {
 		templateName = "default" // 默认使用 default 模板
 	}
 
-	template, err := GetPromptTemplate(templateName)
+	var content string
+	var err error
+	if dbTemplate != nil {
+		content, err = dbTemplate.Render(data)
+		if err != nil {
+			log.Printf("⚠️  数据库提示词模板 '%s' v%d 渲染失败，使用文件模板: %v", dbTemplate.Name, dbTemplate.Version, err)
+			content, err = RenderPromptTemplate(templateName, data)
+		}
+	} else {
+		content, err = RenderPromptTemplate(templateName, data)
+	}
 	if err != nil {
-		// 如果模板不存在，记录错误并使用 default
-		log.Printf("⚠️  提示词模板 '%s' 不存在，使用 default: %v", templateName, err)
-		template, err = GetPromptTemplate("default")
+		// 如果模板不存在或渲染失败，记录错误并使用 default
+		log.Printf("⚠️  提示词模板 '%s' 不可用，使用 default: %v", templateName, err)
+		content, err = RenderPromptTemplate("default", data)
 		if err != nil {
-			// 如果连 default 都不存在，使用内置的简化版本
+			// 如果连 default 都不可用，使用内置的简化版本
 			log.Printf("❌ 无法加载任何提示词模板，使用内置简化版本")
-			sb.WriteString("你是专业的加密货币交易AI。请根据市场数据做出交易决策。\n\n")
+			sb.WriteString(localize(locale, "你是专业的加密货币交易AI。请根据市场数据做出交易决策。\n\n"))
 		} else {
-			sb.WriteString(template.Content)
+			sb.WriteString(content)
 			sb.WriteString("\n\n")
 		}
 	} else {
-		sb.WriteString(template.Content)
+		sb.WriteString(content)
 		sb.WriteString("\n\n")
 	}
 
 	// 2. Supertrend 多时间框架交易策略
-	sb.WriteString("# 📈 Supertrend 多时间框架交易策略\n\n")
-	sb.WriteString("## 核心交易规则：\n\n")
-	sb.WriteString("1. **信号触发条件（优化后，短期策略优先5分钟信号，3分钟信号对短期获利至关重要）**：\n")
-	sb.WriteString("   - 优先级策略：5m+15m一致（优先，最敏感，5分钟信号改变可能影响后续）> 15m+30m一致 > 5m+30m一致\n")
-	sb.WriteString("   - 🔴 3分钟信号对短期获利至关重要：如果3m与主信号相反，需要非常谨慎（3分钟信号变化可能预示短期趋势变化）\n")
-	sb.WriteString("   - ✅ 如果3m与主信号一致，信号更强，可以更积极开仓\n")
-	sb.WriteString("   - 5分钟信号最重要：因为短期策略中，5分钟信号改变可能改变后续信号，需要优先关注\n")
-	sb.WriteString("   - 大趋势验证（灵活策略）：1小时为主，4小时为辅\n")
-	sb.WriteString("   - ✅ 只要1小时或4小时其中一个与交易信号一致，就允许开仓（更灵活）\n")
-	sb.WriteString("   - ❌ 如果1小时和4小时都与交易信号相反，则阻止开仓（风险控制）\n\n")
-	sb.WriteString("2. **短期盈利优势判断（新增）**：\n")
-	sb.WriteString("   - 做多优势：RSI < 40（超卖反弹）、MACD转强、价格低于EMA20\n")
-	sb.WriteString("   - 做空优势：RSI > 60（超买回调）、MACD转弱、价格高于EMA20\n")
-	sb.WriteString("   - 有短期盈利优势时，信号更强，可以更积极开仓\n")
-	sb.WriteString("   - 没有明显优势时，需谨慎但也可以开仓（信号统一即可）\n\n")
-	sb.WriteString("3. **量价关系验证（放宽）**：\n")
-	sb.WriteString("   - 优先关注量价关系健康（价涨量增或价跌量减）\n")
-	sb.WriteString("   - 如果量价关系不够理想但信号较强，可以交易但需谨慎\n")
-	sb.WriteString("   - 成交量比率建议在0.3-3.0之间（<0.3极低需谨慎，>3.0异常波动需注意）\n\n")
-	sb.WriteString("4. **时间框架优先级（短期策略优化）**：\n")
-	sb.WriteString("   - 5分钟：核心信号（最重要，5分钟信号改变可能影响后续信号）\n")
-	sb.WriteString("   - 15分钟：核心确认（与5分钟信号一致，形成主要交易信号）\n")
-	sb.WriteString("   - 🔴 3分钟：关键信号（对短期获利至关重要，如果与主信号相反，需要非常谨慎）\n")
-	sb.WriteString("   - 30分钟：中期确认（与5-15分钟信号一致）\n")
-	sb.WriteString("   - 1小时：大趋势判断（主要参考，必须与交易信号一致或至少1h/4h其中一个一致）\n")
-	sb.WriteString("   - 4小时：大趋势参考（辅助参考，与1小时配合使用）\n\n")
-	sb.WriteString("5. **开仓条件总结（优化后，短期策略优先5分钟信号，3分钟信号对短期获利至关重要）**：\n")
-	sb.WriteString("   - ✅ 5m+15m一致（优先，最敏感，5分钟信号最重要）\n")
-	sb.WriteString("   - ✅ 或 15m+30m一致（备选，但需注意5分钟信号）\n")
-	sb.WriteString("   - ✅ 或 5m+30m一致（备选，但需注意15分钟信号）\n")
-	sb.WriteString("   - 🔴 3分钟信号对短期获利至关重要：与主信号一致时信号更强，相反时需要非常谨慎（3分钟信号变化可能预示短期趋势变化）\n")
-	sb.WriteString("   - ✅ 大趋势验证：1小时或4小时至少一个与交易信号一致（灵活策略）\n")
-	sb.WriteString("   - ❌ 如果1小时和4小时都与交易信号相反，则阻止开仓（风险控制）\n")
-	sb.WriteString("   - ✅ 有短期盈利优势时（RSI超买/超卖、MACD转强/转弱等），信号更强\n")
-	sb.WriteString("   - ⚠️ 量价关系健康为佳，但不强制（信号强时可放宽）\n")
-	sb.WriteString("   - ⚠️ 成交量比率>0.3为佳，<0.3极低需谨慎\n\n")
-
-	// 2. 硬约束（风险控制）- 动态生成
-	sb.WriteString("# 硬约束（风险控制）\n\n")
-	sb.WriteString("1. 风险回报比: 必须 ≥ 1:3（冒1%风险，赚3%+收益）\n")
-	sb.WriteString("2. 最多持仓: 3个币种（质量>数量）\n")
-	sb.WriteString(fmt.Sprintf("3. 单币仓位: 山寨%.0f-%.0f U(%dx杠杆) | BTC/ETH %.0f-%.0f U(%dx杠杆)\n",
-		accountEquity*0.8, accountEquity*1.5, altcoinLeverage, accountEquity*5, accountEquity*10, btcEthLeverage))
-	sb.WriteString("4. 保证金: 总使用率 ≤ 90%\n\n")
-
-	// 3. 输出格式 - 动态生成
-	sb.WriteString("#输出格式\n\n")
-	sb.WriteString("第一步: 思维链（纯文本）\n")
-	sb.WriteString("简洁分析你的思考过程\n\n")
-	sb.WriteString("第二步: JSON决策数组\n\n")
-	sb.WriteString("```json\n[\n")
-	sb.WriteString(fmt.Sprintf("  {\"symbol\": \"BTCUSDT\", \"action\": \"open_short\", \"leverage\": %d, \"position_size_usd\": %.0f, \"stop_loss\": 97000, \"take_profit\": 91000, \"confidence\": 85, \"risk_usd\": 300, \"reasoning\": \"下跌趋势+MACD死叉\"},\n", btcEthLeverage, accountEquity*5))
-	sb.WriteString("  {\"symbol\": \"ETHUSDT\", \"action\": \"close_long\", \"reasoning\": \"止盈离场\"}\n")
-	sb.WriteString("]\n```\n\n")
-	sb.WriteString("字段说明:\n")
-	sb.WriteString("- `action`: open_long | open_short | close_long | close_short | hold | wait\n")
-	sb.WriteString("- `confidence`: 0-100（开仓建议≥75）\n")
-	sb.WriteString("- 开仓时必填: leverage, position_size_usd, stop_loss, take_profit, confidence, risk_usd, reasoning\n\n")
+	sb.WriteString(localize(locale, "# 📈 Supertrend 多时间框架交易策略\n\n"))
+	sb.WriteString(localize(locale, "## 核心交易规则：\n\n"))
+	sb.WriteString(localize(locale, "1. **信号触发条件（优化后，短期策略优先5分钟信号，3分钟信号对短期获利至关重要）**：\n"))
+	sb.WriteString(localize(locale, "   - 优先级策略：5m+15m一致（优先，最敏感，5分钟信号改变可能影响后续）> 15m+30m一致 > 5m+30m一致\n"))
+	sb.WriteString(localize(locale, "   - 🔴 3分钟信号对短期获利至关重要：如果3m与主信号相反，需要非常谨慎（3分钟信号变化可能预示短期趋势变化）\n"))
+	sb.WriteString(localize(locale, "   - ✅ 如果3m与主信号一致，信号更强，可以更积极开仓\n"))
+	sb.WriteString(localize(locale, "   - 5分钟信号最重要：因为短期策略中，5分钟信号改变可能改变后续信号，需要优先关注\n"))
+	sb.WriteString(localize(locale, "   - 大趋势验证（灵活策略）：1小时为主，4小时为辅\n"))
+	sb.WriteString(localize(locale, "   - ✅ 只要1小时或4小时其中一个与交易信号一致，就允许开仓（更灵活）\n"))
+	sb.WriteString(localize(locale, "   - ❌ 如果1小时和4小时都与交易信号相反，则阻止开仓（风险控制）\n\n"))
+	sb.WriteString(localize(locale, "2. **短期盈利优势判断（新增）**：\n"))
+	sb.WriteString(localize(locale, "   - 做多优势：RSI < 40（超卖反弹）、MACD转强、价格低于EMA20\n"))
+	sb.WriteString(localize(locale, "   - 做空优势：RSI > 60（超买回调）、MACD转弱、价格高于EMA20\n"))
+	sb.WriteString(localize(locale, "   - 有短期盈利优势时，信号更强，可以更积极开仓\n"))
+	sb.WriteString(localize(locale, "   - 没有明显优势时，需谨慎但也可以开仓（信号统一即可）\n\n"))
+	sb.WriteString(localize(locale, "3. **量价关系验证（放宽）**：\n"))
+	sb.WriteString(localize(locale, "   - 优先关注量价关系健康（价涨量增或价跌量减）\n"))
+	sb.WriteString(localize(locale, "   - 如果量价关系不够理想但信号较强，可以交易但需谨慎\n"))
+	sb.WriteString(localize(locale, "   - 成交量比率建议在0.3-3.0之间（<0.3极低需谨慎，>3.0异常波动需注意）\n\n"))
+	sb.WriteString(localize(locale, "4. **时间框架优先级（短期策略优化）**：\n"))
+	sb.WriteString(localize(locale, "   - 5分钟：核心信号（最重要，5分钟信号改变可能影响后续信号）\n"))
+	sb.WriteString(localize(locale, "   - 15分钟：核心确认（与5分钟信号一致，形成主要交易信号）\n"))
+	sb.WriteString(localize(locale, "   - 🔴 3分钟：关键信号（对短期获利至关重要，如果与主信号相反，需要非常谨慎）\n"))
+	sb.WriteString(localize(locale, "   - 30分钟：中期确认（与5-15分钟信号一致）\n"))
+	sb.WriteString(localize(locale, "   - 1小时：大趋势判断（主要参考，必须与交易信号一致或至少1h/4h其中一个一致）\n"))
+	sb.WriteString(localize(locale, "   - 4小时：大趋势参考（辅助参考，与1小时配合使用）\n\n"))
+	sb.WriteString(localize(locale, "5. **开仓条件总结（优化后，短期策略优先5分钟信号，3分钟信号对短期获利至关重要）**：\n"))
+	sb.WriteString(localize(locale, "   - ✅ 5m+15m一致（优先，最敏感，5分钟信号最重要）\n"))
+	sb.WriteString(localize(locale, "   - ✅ 或 15m+30m一致（备选，但需注意5分钟信号）\n"))
+	sb.WriteString(localize(locale, "   - ✅ 或 5m+30m一致（备选，但需注意15分钟信号）\n"))
+	sb.WriteString(localize(locale, "   - 🔴 3分钟信号对短期获利至关重要：与主信号一致时信号更强，相反时需要非常谨慎（3分钟信号变化可能预示短期趋势变化）\n"))
+	sb.WriteString(localize(locale, "   - ✅ 大趋势验证：1小时或4小时至少一个与交易信号一致（灵活策略）\n"))
+	sb.WriteString(localize(locale, "   - ❌ 如果1小时和4小时都与交易信号相反，则阻止开仓（风险控制）\n"))
+	sb.WriteString(localize(locale, "   - ✅ 有短期盈利优势时（RSI超买/超卖、MACD转强/转弱等），信号更强\n"))
+	sb.WriteString(localize(locale, "   - ⚠️ 量价关系健康为佳，但不强制（信号强时可放宽）\n"))
+	sb.WriteString(localize(locale, "   - ⚠️ 成交量比率>0.3为佳，<0.3极低需谨慎\n\n"))
+
+	// 3. 硬约束和输出格式（共享片段 risk_limits，账户净值、杠杆和验证策略作为模板变量）
+	riskLimits, err := globalPromptManager.RenderPartial(riskLimitsPartial, data)
+	if err != nil {
+		log.Printf("❌ 共享片段 %s 渲染失败，系统提示词缺少硬约束和输出格式: %v", riskLimitsPartial, err)
+	} else {
+		sb.WriteString(riskLimits)
+		sb.WriteString("\n")
+	}
 
 	return sb.String()
 }
@@ -326,20 +484,21 @@ func buildSystemPrompt(accountEquity float64, btcEthLeverage, altcoinLeverage in
 // buildUserPrompt 构建 User Prompt（动态数据）
 func buildUserPrompt(ctx *Context) string {
 	var sb strings.Builder
+	locale := ctx.Locale
 
 	// 系统状态
-	sb.WriteString(fmt.Sprintf("时间: %s | 周期: #%d | 运行: %d分钟\n\n",
+	sb.WriteString(localizef(locale, "时间: %s | 周期: #%d | 运行: %d分钟\n\n",
 		ctx.CurrentTime, ctx.CallCount, ctx.RuntimeMinutes))
 
 	// BTC 市场
 	if btcData, hasBTC := ctx.MarketDataMap["BTCUSDT"]; hasBTC {
-		sb.WriteString(fmt.Sprintf("BTC: %.2f (1h: %+.2f%%, 4h: %+.2f%%) | MACD: %.4f | RSI: %.2f\n\n",
+		sb.WriteString(localizef(locale, "BTC: %.2f (1h: %+.2f%%, 4h: %+.2f%%) | MACD: %.4f | RSI: %.2f\n\n",
 			btcData.CurrentPrice, btcData.PriceChange1h, btcData.PriceChange4h,
 			btcData.CurrentMACD, btcData.CurrentRSI7))
 	}
 
 	// 账户
-	sb.WriteString(fmt.Sprintf("账户: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
+	sb.WriteString(localizef(locale, "账户: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n",
 		ctx.Account.TotalEquity,
 		ctx.Account.AvailableBalance,
 		(ctx.Account.AvailableBalance/ctx.Account.TotalEquity)*100,
@@ -349,7 +508,7 @@ func buildUserPrompt(ctx *Context) string {
 
 	// 持仓（完整市场数据）
 	if len(ctx.Positions) > 0 {
-		sb.WriteString("## 当前持仓\n")
+		sb.WriteString(localize(locale, "## 当前持仓\n"))
 		for i, pos := range ctx.Positions {
 			// 计算持仓时长
 			holdingDuration := ""
@@ -357,15 +516,20 @@ func buildUserPrompt(ctx *Context) string {
 				durationMs := time.Now().UnixMilli() - pos.UpdateTime
 				durationMin := durationMs / (1000 * 60) // 转换为分钟
 				if durationMin < 60 {
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d分钟", durationMin)
+					holdingDuration = localizef(locale, " | 持仓时长%d分钟", durationMin)
 				} else {
 					durationHour := durationMin / 60
 					durationMinRemainder := durationMin % 60
-					holdingDuration = fmt.Sprintf(" | 持仓时长%d小时%d分钟", durationHour, durationMinRemainder)
+					holdingDuration = localizef(locale, " | 持仓时长%d小时%d分钟", durationHour, durationMinRemainder)
 				}
 			}
 
-			sb.WriteString(fmt.Sprintf("%d. %s %s | 入场价%.4f 当前价%.4f | 盈亏%+.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n",
+			// 持仓期间的资金费
+			if pos.FundingCost != 0 {
+				holdingDuration += localizef(locale, " | 资金费盈亏%+.2f USDT", -pos.FundingCost)
+			}
+
+			sb.WriteString(localizef(locale, "%d. %s %s | 入场价%.4f 当前价%.4f | 盈亏%+.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n",
 				i+1, pos.Symbol, strings.ToUpper(pos.Side),
 				pos.EntryPrice, pos.MarkPrice, pos.UnrealizedPnLPct,
 				pos.Leverage, pos.MarginUsed, pos.LiquidationPrice, holdingDuration))
@@ -373,15 +537,15 @@ func buildUserPrompt(ctx *Context) string {
 			// 使用FormatMarketData输出完整市场数据
 			if marketData, ok := ctx.MarketDataMap[pos.Symbol]; ok {
 				sb.WriteString(market.Format(marketData))
-				sb.WriteString("\n")
+				sb.WriteString(localize(locale, "\n"))
 			}
 		}
 	} else {
-		sb.WriteString("当前持仓: 无\n\n")
+		sb.WriteString(localize(locale, "当前持仓: 无\n\n"))
 	}
 
 	// 候选币种（完整市场数据）
-	sb.WriteString(fmt.Sprintf("## 候选币种 (%d个)\n\n", len(ctx.MarketDataMap)))
+	sb.WriteString(localizef(locale, "## 候选币种 (%d个)\n\n", len(ctx.MarketDataMap)))
 	displayedCount := 0
 	for _, coin := range ctx.CandidateCoins {
 		marketData, hasData := ctx.MarketDataMap[coin.Symbol]
@@ -390,64 +554,31 @@ func buildUserPrompt(ctx *Context) string {
 		}
 		displayedCount++
 
+		// 行情警报来源（"alert"）追加在最后，单独标注
+		sources, alerted := coin.Sources, false
+		if n := len(sources); n > 0 && sources[n-1] == "alert" {
+			sources, alerted = sources[:n-1], true
+		}
 		sourceTags := ""
-		if len(coin.Sources) > 1 {
-			sourceTags = " (AI500+OI_Top双重信号)"
-		} else if len(coin.Sources) == 1 && coin.Sources[0] == "oi_top" {
-			sourceTags = " (OI_Top持仓增长)"
+		if len(sources) > 1 {
+			sourceTags = localize(locale, " (AI500+OI_Top双重信号)")
+		} else if len(sources) == 1 && sources[0] == "oi_top" {
+			sourceTags = localize(locale, " (OI_Top持仓增长)")
+		}
+		if alerted {
+			sourceTags += localize(locale, " (行情警报)")
 		}
 
 		// 使用FormatMarketData输出完整市场数据
-		sb.WriteString(fmt.Sprintf("### %d. %s%s\n\n", displayedCount, coin.Symbol, sourceTags))
+		sb.WriteString(localizef(locale, "### %d. %s%s\n\n", displayedCount, coin.Symbol, sourceTags))
 		sb.WriteString(market.Format(marketData))
 		
-		// 添加 Supertrend 交易信号分析
-		// 调试信息：检查数据是否存在
-		if marketData.SupertrendData == nil {
-			sb.WriteString("⚠️  Supertrend 数据为 nil（数据未计算）\n\n")
-		} else if marketData.VolumePriceData == nil {
-			sb.WriteString("⚠️  量价关系数据为 nil（数据未计算）\n\n")
-		} else if marketData.SupertrendData != nil && marketData.VolumePriceData != nil {
-			// 显示 Supertrend 状态信息（短期策略：3m、5m、15m为核心，30m、1h、4h为参考）
-			sb.WriteString("📊 Supertrend 多时间框架分析:\n")
-			st := marketData.SupertrendData
-			if st.Timeframe3m != nil {
-				sb.WriteString(fmt.Sprintf("  3m (关键): %s (信号: %s) - 短期获利依赖3分钟信号\n", st.Timeframe3m.Trend, st.Timeframe3m.Signal))
-			}
-			if st.Timeframe5m != nil {
-				sb.WriteString(fmt.Sprintf("  5m (核心): %s (信号: %s)\n", st.Timeframe5m.Trend, st.Timeframe5m.Signal))
-			}
-			if st.Timeframe15m != nil {
-				sb.WriteString(fmt.Sprintf("  15m (核心): %s (信号: %s)\n", st.Timeframe15m.Trend, st.Timeframe15m.Signal))
-			}
-			if st.Timeframe30m != nil {
-				sb.WriteString(fmt.Sprintf("  30m (确认): %s (信号: %s)\n", st.Timeframe30m.Trend, st.Timeframe30m.Signal))
-			}
-			if st.Timeframe1h != nil {
-				sb.WriteString(fmt.Sprintf("  1h (大趋势): %s (信号: %s)\n", st.Timeframe1h.Trend, st.Timeframe1h.Signal))
-			}
-			if st.Timeframe4h != nil {
-				sb.WriteString(fmt.Sprintf("  4h (参考): %s (信号: %s)\n", st.Timeframe4h.Trend, st.Timeframe4h.Signal))
-			}
-			
-			// 显示量价关系
-			vp := marketData.VolumePriceData
-			sb.WriteString(fmt.Sprintf("  量价关系: %v (成交量比率: %.2f)\n", vp.PriceVolumeOK, vp.VolumeRatio3m))
-			
-			// 分析交易信号（传入完整市场数据以判断短期盈利优势）
-			signal := analyzeSupertrendSignal(marketData.SupertrendData, marketData.VolumePriceData, marketData)
-			if signal != "" {
-				sb.WriteString(fmt.Sprintf("  ✅ 交易信号: %s\n\n", signal))
-			} else {
-				sb.WriteString("  ⚠️  当前不满足开仓条件（需要5m+15m一致，或15m+30m一致，或5m+30m一致，且1h或4h大趋势至少一个支持）\n\n")
-			}
-		} else {
-			sb.WriteString("⚠️  Supertrend 数据未计算（可能K线数据不足）\n\n")
-		}
-		
-		sb.WriteString("\n")
+		// 信号分析器输出（Supertrend、RSI背离、放量突破等，按交易员配置）
+		sb.WriteString(formatSignalAnnotations(ctx.SignalAnnotations[coin.Symbol]))
+
+		sb.WriteString(localize(locale, "\n"))
 	}
-	sb.WriteString("\n")
+	sb.WriteString(localize(locale, "\n"))
 
 	// 夏普比率（直接传值，不要复杂格式化）
 	if ctx.Performance != nil {
@@ -458,42 +589,43 @@ func buildUserPrompt(ctx *Context) string {
 		var perfData PerformanceData
 		if jsonData, err := json.Marshal(ctx.Performance); err == nil {
 			if err := json.Unmarshal(jsonData, &perfData); err == nil {
-				sb.WriteString(fmt.Sprintf("## 📊 夏普比率: %.2f\n\n", perfData.SharpeRatio))
+				sb.WriteString(localizef(locale, "## 📊 夏普比率: %.2f\n\n", perfData.SharpeRatio))
 			}
 		}
 	}
 
-	sb.WriteString("---\n\n")
-	sb.WriteString("现在请分析并输出决策（思维链 + JSON）\n")
+	sb.WriteString(localize(locale, "---\n\n"))
+	sb.WriteString(localize(locale, "现在请分析并输出决策（思维链 + JSON）\n"))
 
 	return sb.String()
 }
 
 // parseFullDecisionResponse 解析AI的完整决策响应
-func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int) (*FullDecision, error) {
-	// 1. 提取思维链
-	cotTrace := extractCoTTrace(aiResponse)
-
-	// 2. 提取JSON决策列表
-	decisions, err := extractDecisions(aiResponse)
-	if err != nil {
-		return &FullDecision{
-			CoTTrace:  cotTrace,
-			Decisions: []Decision{},
-		}, fmt.Errorf("提取决策失败: %w", err)
+// locale 决定验证错误的语言（错误会反馈给AI修复）
+func parseFullDecisionResponse(aiResponse string, accountEquity float64, btcEthLeverage, altcoinLeverage int, locale string) (*FullDecision, error) {
+	// 1. 优先按结构化输出解析（JSON对象，不受思维链中括号的干扰）
+	cotTrace, decisions, ok := parseStructuredResponse(aiResponse)
+	if !ok {
+		// 2. 回退到文本解析：提取思维链和JSON决策列表
+		cotTrace = extractCoTTrace(aiResponse)
+
+		var err error
+		decisions, err = extractDecisions(aiResponse, locale)
+		if err != nil {
+			return &FullDecision{
+				CoTTrace:  cotTrace,
+				Decisions: []Decision{},
+			}, fmt.Errorf("%s: %w", localize(locale, "提取决策失败"), err)
+		}
 	}
 
-	// 3. 验证决策
-	if err := validateDecisions(decisions, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
-		return &FullDecision{
-			CoTTrace:  cotTrace,
-			Decisions: decisions,
-		}, fmt.Errorf("决策验证失败: %w", err)
-	}
+	// 3. 逐条验证决策（无效决策单独拒绝，不影响其他决策执行）
+	valid, rejected := validateDecisions(decisions, accountEquity, btcEthLeverage, altcoinLeverage, locale)
 
 	return &FullDecision{
-		CoTTrace:  cotTrace,
-		Decisions: decisions,
+		CoTTrace:          cotTrace,
+		Decisions:         valid,
+		RejectedDecisions: rejected,
 	}, nil
 }
 
@@ -512,17 +644,17 @@ func extractCoTTrace(response string) string {
 }
 
 // extractDecisions 提取JSON决策列表
-func extractDecisions(response string) ([]Decision, error) {
+func extractDecisions(response, locale string) ([]Decision, error) {
 	// 直接查找JSON数组 - 找第一个完整的JSON数组
 	arrayStart := strings.Index(response, "[")
 	if arrayStart == -1 {
-		return nil, fmt.Errorf("无法找到JSON数组起始")
+		return nil, localeErrorf(locale, "无法找到JSON数组起始")
 	}
 
 	// 从 [ 开始，匹配括号找到对应的 ]
 	arrayEnd := findMatchingBracket(response, arrayStart)
 	if arrayEnd == -1 {
-		return nil, fmt.Errorf("无法找到JSON数组结束")
+		return nil, localeErrorf(locale, "无法找到JSON数组结束")
 	}
 
 	jsonContent := strings.TrimSpace(response[arrayStart : arrayEnd+1])
@@ -536,7 +668,7 @@ func extractDecisions(response string) ([]Decision, error) {
 	// 解析JSON
 	var decisions []Decision
 	if err := json.Unmarshal([]byte(jsonContent), &decisions); err != nil {
-		return nil, fmt.Errorf("JSON解析失败: %w\nJSON内容: %s", err, jsonContent)
+		return nil, fmt.Errorf("%s: %w\n%s: %s", localize(locale, "JSON解析失败"), err, localize(locale, "JSON内容"), jsonContent)
 	}
 
 	return decisions, nil
@@ -551,14 +683,22 @@ func fixMissingQuotes(jsonStr string) string {
 	return jsonStr
 }
 
-// validateDecisions 验证所有决策（需要账户信息和杠杆配置）
-func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
+// validateDecisions 逐条验证决策（需要账户信息和杠杆配置），返回通过验证和被拒绝的决策
+// 验证错误按 locale 输出（会反馈给AI修复）
+func validateDecisions(decisions []Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int, locale string) ([]Decision, []RejectedDecision) {
+	valid := make([]Decision, 0, len(decisions))
+	var rejected []RejectedDecision
 	for i, decision := range decisions {
-		if err := validateDecision(&decision, accountEquity, btcEthLeverage, altcoinLeverage); err != nil {
-			return fmt.Errorf("决策 #%d 验证失败: %w", i+1, err)
+		if err := validateDecision(&decision, accountEquity, btcEthLeverage, altcoinLeverage, locale); err != nil {
+			rejected = append(rejected, RejectedDecision{
+				Decision: decision,
+				Error:    localizef(locale, "决策 #%d 验证失败: %v", i+1, err),
+			})
+			continue
 		}
+		valid = append(valid, decision)
 	}
-	return nil
+	return valid, rejected
 }
 
 // findMatchingBracket 查找匹配的右括号
@@ -584,7 +724,7 @@ func findMatchingBracket(s string, start int) int {
 }
 
 // validateDecision 验证单个决策的有效性
-func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int) error {
+func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoinLeverage int, locale string) error {
 	// 验证action
 	validActions := map[string]bool{
 		"open_long":   true,
@@ -596,46 +736,47 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 	}
 
 	if !validActions[d.Action] {
-		return fmt.Errorf("无效的action: %s", d.Action)
+		return localeErrorf(locale, "无效的action: %s", d.Action)
 	}
 
 	// 开仓操作必须提供完整参数
 	if d.Action == "open_long" || d.Action == "open_short" {
 		// 根据币种使用配置的杠杆上限
-		maxLeverage := altcoinLeverage          // 山寨币使用配置的杠杆
-		maxPositionValue := accountEquity * 1.5 // 山寨币最多1.5倍账户净值
+		policy := DefaultValidationPolicy
+		maxLeverage := altcoinLeverage                                     // 山寨币使用配置的杠杆
+		maxPositionValue := accountEquity * policy.AltcoinMaxPositionRatio // 山寨币最多1.5倍账户净值
 		if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-			maxLeverage = btcEthLeverage          // BTC和ETH使用配置的杠杆
-			maxPositionValue = accountEquity * 10 // BTC/ETH最多10倍账户净值
+			maxLeverage = btcEthLeverage                                     // BTC和ETH使用配置的杠杆
+			maxPositionValue = accountEquity * policy.BTCETHMaxPositionRatio // BTC/ETH最多10倍账户净值
 		}
 
 		if d.Leverage <= 0 || d.Leverage > maxLeverage {
-			return fmt.Errorf("杠杆必须在1-%d之间（%s，当前配置上限%d倍）: %d", maxLeverage, d.Symbol, maxLeverage, d.Leverage)
+			return localeErrorf(locale, "杠杆必须在1-%d之间（%s，当前配置上限%d倍）: %d", maxLeverage, d.Symbol, maxLeverage, d.Leverage)
 		}
 		if d.PositionSizeUSD <= 0 {
-			return fmt.Errorf("仓位大小必须大于0: %.2f", d.PositionSizeUSD)
+			return localeErrorf(locale, "仓位大小必须大于0: %.2f", d.PositionSizeUSD)
 		}
 		// 验证仓位价值上限（加1%容差以避免浮点数精度问题）
 		tolerance := maxPositionValue * 0.01 // 1%容差
 		if d.PositionSizeUSD > maxPositionValue+tolerance {
 			if d.Symbol == "BTCUSDT" || d.Symbol == "ETHUSDT" {
-				return fmt.Errorf("BTC/ETH单币种仓位价值不能超过%.0f USDT（10倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD)
+				return localeErrorf(locale, "BTC/ETH单币种仓位价值不能超过%.0f USDT（%.0f倍账户净值），实际: %.0f", maxPositionValue, policy.BTCETHMaxPositionRatio, d.PositionSizeUSD)
 			} else {
-				return fmt.Errorf("山寨币单币种仓位价值不能超过%.0f USDT（1.5倍账户净值），实际: %.0f", maxPositionValue, d.PositionSizeUSD)
+				return localeErrorf(locale, "山寨币单币种仓位价值不能超过%.0f USDT（%.1f倍账户净值），实际: %.0f", maxPositionValue, policy.AltcoinMaxPositionRatio, d.PositionSizeUSD)
 			}
 		}
 		if d.StopLoss <= 0 || d.TakeProfit <= 0 {
-			return fmt.Errorf("止损和止盈必须大于0")
+			return localeErrorf(locale, "止损和止盈必须大于0")
 		}
 
 		// 验证止损止盈的合理性
 		if d.Action == "open_long" {
 			if d.StopLoss >= d.TakeProfit {
-				return fmt.Errorf("做多时止损价必须小于止盈价")
+				return localeErrorf(locale, "做多时止损价必须小于止盈价")
 			}
 		} else {
 			if d.StopLoss <= d.TakeProfit {
-				return fmt.Errorf("做空时止损价必须大于止盈价")
+				return localeErrorf(locale, "做空时止损价必须大于止盈价")
 			}
 		}
 
@@ -666,9 +807,9 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 		}
 
 		// 硬约束：风险回报比必须≥3.0
-		if riskRewardRatio < 3.0 {
-			return fmt.Errorf("风险回报比过低(%.2f:1)，必须≥3.0:1 [风险:%.2f%% 收益:%.2f%%] [止损:%.2f 止盈:%.2f]",
-				riskRewardRatio, riskPercent, rewardPercent, d.StopLoss, d.TakeProfit)
+		if riskRewardRatio < policy.MinRiskRewardRatio {
+			return localeErrorf(locale, "风险回报比过低(%.2f:1)，必须≥%.1f:1 [风险:%.2f%% 收益:%.2f%%] [止损:%.2f 止盈:%.2f]",
+				riskRewardRatio, policy.MinRiskRewardRatio, riskPercent, rewardPercent, d.StopLoss, d.TakeProfit)
 		}
 	}
 
@@ -676,18 +817,18 @@ func validateDecision(d *Decision, accountEquity float64, btcEthLeverage, altcoi
 }
 
 // analyzeSupertrendSignal 分析 Supertrend 多时间框架信号
-// 返回交易信号描述字符串，如果满足开仓条件则返回具体信号，否则返回空字符串
+// 返回交易信号描述（按 locale 输出）和信号方向（long/short），不满足开仓条件时都返回空字符串
 // 优化策略：优先15m+30m一致（最稳定），即使5m相反也可以开仓；增加短期盈利优势判断
-func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.VolumePriceData, marketData *market.Data) string {
+func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.VolumePriceData, marketData *market.Data, locale string) (string, string) {
 	if st == nil || vp == nil {
-		return ""
+		return "", ""
 	}
 
 	var signals []string
 
 	// 检查各个时间框架的数据是否存在（短期策略：5m和15m是必需的）
 	if st.Timeframe5m == nil || st.Timeframe15m == nil {
-		return ""
+		return "", ""
 	}
 
 	signal3m := ""
@@ -721,15 +862,15 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 		// 3分钟信号对短期获利至关重要：如果相反，需要非常谨慎
 		if signal3m != "none" && signal3m == signalDirection {
 			validSignals = append(validSignals, "3m")
-			signals = append(signals, fmt.Sprintf("✅ 3m信号(%s)与5m+15m一致，信号强化（3分钟信号对短期获利至关重要）", signal3m))
+			signals = append(signals, localizef(locale, "✅ 3m信号(%s)与5m+15m一致，信号强化（3分钟信号对短期获利至关重要）", signal3m))
 		} else if signal3m != "none" && signal3m != signalDirection {
 			// 3分钟信号相反，需要非常谨慎（3分钟信号变化可能预示短期趋势变化）
-			signals = append(signals, fmt.Sprintf("🔴 3m信号(%s)与5m+15m相反，3分钟信号变化需非常谨慎（短期获利依赖3分钟信号）", signal3m))
+			signals = append(signals, localizef(locale, "🔴 3m信号(%s)与5m+15m相反，3分钟信号变化需非常谨慎（短期获利依赖3分钟信号）", signal3m))
 			// 3分钟信号相反时，降低信号强度，但不完全阻止（给用户决策空间）
 		}
 		// 30分钟信号作为确认
 		if signal30m != "none" && signal30m != signalDirection {
-			signals = append(signals, fmt.Sprintf("⚠️ 30m信号(%s)与5m+15m相反，但5m+15m为主信号", signal30m))
+			signals = append(signals, localizef(locale, "⚠️ 30m信号(%s)与5m+15m相反，但5m+15m为主信号", signal30m))
 		} else if signal30m == signalDirection {
 			validSignals = append(validSignals, "30m")
 		}
@@ -739,38 +880,38 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 		validSignals = append(validSignals, "15m", "30m")
 		// 如果5m与15m+30m相反，标记为冲突（5分钟信号很重要，需要谨慎）
 		if signal5m != "none" && signal5m != signalDirection {
-			signals = append(signals, fmt.Sprintf("⚠️ 5m信号(%s)与15m+30m相反，5分钟信号变化可能影响后续，需谨慎", signal5m))
+			signals = append(signals, localizef(locale, "⚠️ 5m信号(%s)与15m+30m相反，5分钟信号变化可能影响后续，需谨慎", signal5m))
 		} else if signal5m == signalDirection {
 			validSignals = append(validSignals, "5m")
 		}
 		// 3分钟信号对短期获利至关重要：如果相反，需要非常谨慎
 		if signal3m != "none" && signal3m == signalDirection {
 			validSignals = append(validSignals, "3m")
-			signals = append(signals, fmt.Sprintf("✅ 3m信号(%s)与15m+30m一致，信号强化（3分钟信号对短期获利至关重要）", signal3m))
+			signals = append(signals, localizef(locale, "✅ 3m信号(%s)与15m+30m一致，信号强化（3分钟信号对短期获利至关重要）", signal3m))
 		} else if signal3m != "none" && signal3m != signalDirection {
 			// 3分钟信号相反，需要非常谨慎
-			signals = append(signals, fmt.Sprintf("🔴 3m信号(%s)与15m+30m相反，3分钟信号变化需非常谨慎（短期获利依赖3分钟信号）", signal3m))
+			signals = append(signals, localizef(locale, "🔴 3m信号(%s)与15m+30m相反，3分钟信号变化需非常谨慎（短期获利依赖3分钟信号）", signal3m))
 		}
 	} else if signal5m != "none" && signal30m != "none" && signal5m == signal30m {
 		// 最后检查5m和30m是否一致（备选方案）
 		signalDirection = signal5m
 		validSignals = append(validSignals, "5m", "30m")
 		if signal15m != "none" && signal15m != signalDirection {
-			signals = append(signals, fmt.Sprintf("⚠️ 15m信号(%s)与5m+30m相反，15分钟信号缺失确认", signal15m))
+			signals = append(signals, localizef(locale, "⚠️ 15m信号(%s)与5m+30m相反，15分钟信号缺失确认", signal15m))
 		} else if signal15m == signalDirection {
 			validSignals = append(validSignals, "15m")
 		}
 		// 3分钟信号对短期获利至关重要：如果相反，需要非常谨慎
 		if signal3m != "none" && signal3m == signalDirection {
 			validSignals = append(validSignals, "3m")
-			signals = append(signals, fmt.Sprintf("✅ 3m信号(%s)与5m+30m一致，信号强化（3分钟信号对短期获利至关重要）", signal3m))
+			signals = append(signals, localizef(locale, "✅ 3m信号(%s)与5m+30m一致，信号强化（3分钟信号对短期获利至关重要）", signal3m))
 		} else if signal3m != "none" && signal3m != signalDirection {
 			// 3分钟信号相反，需要非常谨慎
-			signals = append(signals, fmt.Sprintf("🔴 3m信号(%s)与5m+30m相反，3分钟信号变化需非常谨慎（短期获利依赖3分钟信号）", signal3m))
+			signals = append(signals, localizef(locale, "🔴 3m信号(%s)与5m+30m相反，3分钟信号变化需非常谨慎（短期获利依赖3分钟信号）", signal3m))
 		}
 	} else {
 		// 没有任何两个时间框架一致，不满足条件
-		return "" // 信号不足或不一致
+		return "", "" // 信号不足或不一致
 	}
 
 	// 2. 检查短期盈利优势（新增：判断是否有短期盈利潜力）
@@ -784,7 +925,7 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 			// RSI < 40 表示超卖，有反弹潜力
 			if marketData.CurrentRSI7 < 40 {
 				hasAdvantage = true
-				advantageReasons = append(advantageReasons, fmt.Sprintf("RSI超卖(%.1f)", marketData.CurrentRSI7))
+				advantageReasons = append(advantageReasons, localizef(locale, "RSI超卖(%.1f)", marketData.CurrentRSI7))
 				advantageScore++
 			}
 			// MACD 负值但趋势向上（MACD值在改善）
@@ -793,14 +934,14 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 				prevMACD := marketData.IntradaySeries.MACDValues[len(marketData.IntradaySeries.MACDValues)-2]
 				if recentMACD > prevMACD {
 					hasAdvantage = true
-					advantageReasons = append(advantageReasons, "MACD转强")
+					advantageReasons = append(advantageReasons, localize(locale, "MACD转强"))
 					advantageScore++
 				}
 			}
 			// 动量对齐：价格相对EMA20（短线顺势更优：多看价>=EMA20）
 			if marketData.CurrentPrice >= marketData.CurrentEMA20 {
 				hasAdvantage = true
-				advantageReasons = append(advantageReasons, "价格站上EMA20")
+				advantageReasons = append(advantageReasons, localize(locale, "价格站上EMA20"))
 				advantageScore++
 			}
 			// RSI 短期回升（更偏向反弹持续）
@@ -809,7 +950,7 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 				rsiPrev := marketData.IntradaySeries.RSI7Values[len(marketData.IntradaySeries.RSI7Values)-2]
 				if rsiNow > rsiPrev {
 					hasAdvantage = true
-					advantageReasons = append(advantageReasons, "RSI走强")
+					advantageReasons = append(advantageReasons, localize(locale, "RSI走强"))
 					advantageScore++
 				}
 			}
@@ -820,7 +961,7 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 			// RSI > 60 表示超买，有回调潜力
 			if marketData.CurrentRSI7 > 60 {
 				hasAdvantage = true
-				advantageReasons = append(advantageReasons, fmt.Sprintf("RSI超买(%.1f)", marketData.CurrentRSI7))
+				advantageReasons = append(advantageReasons, localizef(locale, "RSI超买(%.1f)", marketData.CurrentRSI7))
 				advantageScore++
 			}
 			// MACD 正值但趋势向下（MACD值在恶化）
@@ -829,14 +970,14 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 				prevMACD := marketData.IntradaySeries.MACDValues[len(marketData.IntradaySeries.MACDValues)-2]
 				if recentMACD < prevMACD {
 					hasAdvantage = true
-					advantageReasons = append(advantageReasons, "MACD转弱")
+					advantageReasons = append(advantageReasons, localize(locale, "MACD转弱"))
 					advantageScore++
 				}
 			}
 			// 动量对齐：价格相对EMA20（空看价<=EMA20）
 			if marketData.CurrentPrice <= marketData.CurrentEMA20 {
 				hasAdvantage = true
-				advantageReasons = append(advantageReasons, "价格跌破EMA20")
+				advantageReasons = append(advantageReasons, localize(locale, "价格跌破EMA20"))
 				advantageScore++
 			}
 			// RSI 短期走弱
@@ -845,7 +986,7 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 				rsiPrev := marketData.IntradaySeries.RSI7Values[len(marketData.IntradaySeries.RSI7Values)-2]
 				if rsiNow < rsiPrev {
 					hasAdvantage = true
-					advantageReasons = append(advantageReasons, "RSI走弱")
+					advantageReasons = append(advantageReasons, localize(locale, "RSI走弱"))
 					advantageScore++
 				}
 			}
@@ -853,10 +994,10 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 		
 		// 需要至少2项优势成立，提升短期胜率
 		if hasAdvantage && advantageScore >= 2 {
-			signals = append(signals, fmt.Sprintf("✅ 短期盈利优势：%s", strings.Join(advantageReasons, "、")))
+			signals = append(signals, localizef(locale, "✅ 短期盈利优势：%s", strings.Join(advantageReasons, localize(locale, "、"))))
 		} else {
 			// 优势不足，直接放弃信号，避免低质量短线
-			return "" // 放弃低质量短线机会
+			return "", "" // 放弃低质量短线机会
 		}
 	}
 
@@ -869,9 +1010,9 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 	if signal1h != "" && signal1h != "none" {
 		if signal1h == signalDirection {
 			majorTrendMatch = true
-			majorTrendInfo = append(majorTrendInfo, fmt.Sprintf("✅ 1h趋势同向(%s)", signal1h))
+			majorTrendInfo = append(majorTrendInfo, localizef(locale, "✅ 1h趋势同向(%s)", signal1h))
 		} else {
-			majorTrendInfo = append(majorTrendInfo, fmt.Sprintf("⚠️ 1h趋势相反(%s)", signal1h))
+			majorTrendInfo = append(majorTrendInfo, localizef(locale, "⚠️ 1h趋势相反(%s)", signal1h))
 		}
 	}
 	
@@ -879,68 +1020,68 @@ func analyzeSupertrendSignal(st *market.SupertrendMultiTimeframe, vp *market.Vol
 	if signal4h != "" && signal4h != "none" {
 		if signal4h == signalDirection {
 			majorTrendMatch = true
-			majorTrendInfo = append(majorTrendInfo, fmt.Sprintf("✅ 4h趋势同向(%s)", signal4h))
+			majorTrendInfo = append(majorTrendInfo, localizef(locale, "✅ 4h趋势同向(%s)", signal4h))
 		} else {
-			majorTrendInfo = append(majorTrendInfo, fmt.Sprintf("⚠️ 4h趋势相反(%s)", signal4h))
+			majorTrendInfo = append(majorTrendInfo, localizef(locale, "⚠️ 4h趋势相反(%s)", signal4h))
 		}
 	}
 	
 	// 如果1小时和4小时都与交易信号相反，阻止开仓
 	if signal1h != "" && signal1h != "none" && signal4h != "" && signal4h != "none" {
 		if signal1h != signalDirection && signal4h != signalDirection {
-			return "" // 1小时和4小时都相反，阻止开仓
+			return "", "" // 1小时和4小时都相反，阻止开仓
 		}
 	} else if signal1h != "" && signal1h != "none" && signal4h == "" {
 		// 只有1小时数据，必须与交易信号一致
 		if signal1h != signalDirection {
-			return "" // 1小时相反，阻止开仓
+			return "", "" // 1小时相反，阻止开仓
 		}
 	} else if signal1h == "" && signal4h != "" && signal4h != "none" {
 		// 只有4小时数据，必须与交易信号一致
 		if signal4h != signalDirection {
-			return "" // 4小时相反，阻止开仓
+			return "", "" // 4小时相反，阻止开仓
 		}
 	}
 	
 	// 添加大趋势信息到信号列表
 	if len(majorTrendInfo) > 0 {
 		if majorTrendMatch {
-			signals = append(signals, strings.Join(majorTrendInfo, " | ")+" | 大趋势支持")
+			signals = append(signals, strings.Join(majorTrendInfo, " | ")+localize(locale, " | 大趋势支持"))
 		} else {
-			signals = append(signals, strings.Join(majorTrendInfo, " | ")+" | 大趋势部分支持")
+			signals = append(signals, strings.Join(majorTrendInfo, " | ")+localize(locale, " | 大趋势部分支持"))
 		}
 	}
 
 	// 4. 检查量价关系（放宽条件：只要不是明显不健康即可）
 	// 如果量价关系不健康，给出警告但不阻止交易
 	if !vp.PriceVolumeOK {
-		signals = append(signals, "⚠️ 量价关系不够理想，但信号较强")
+		signals = append(signals, localize(locale, "⚠️ 量价关系不够理想，但信号较强"))
 	}
 
 	// 5. 检查成交量比率（放宽条件：只要不是极端低即可）
 	volumeRatio := vp.VolumeRatio3m
 	if volumeRatio < 0.3 {
 		// 成交量比率极低，给出警告但不阻止交易
-		signals = append(signals, fmt.Sprintf("⚠️ 成交量比率极低(%.2f)，流动性不足，需谨慎", volumeRatio))
+		signals = append(signals, localizef(locale, "⚠️ 成交量比率极低(%.2f)，流动性不足，需谨慎", volumeRatio))
 	} else if volumeRatio < 0.5 {
 		// 成交量比率较低，给出警告但不阻止交易
-		signals = append(signals, fmt.Sprintf("⚠️ 成交量比率较低(%.2f)，建议谨慎", volumeRatio))
+		signals = append(signals, localizef(locale, "⚠️ 成交量比率较低(%.2f)，建议谨慎", volumeRatio))
 	} else if volumeRatio > 3.0 {
 		// 成交量比率过高，可能是异常波动
-		signals = append(signals, fmt.Sprintf("⚠️ 成交量比率较高(%.2f)，注意风险", volumeRatio))
+		signals = append(signals, localizef(locale, "⚠️ 成交量比率较高(%.2f)，注意风险", volumeRatio))
 	}
 
 	// 如果所有条件都满足，生成交易信号
 	if signalDirection == "long" {
-		timeframeStr := strings.Join(validSignals, "、")
-		signals = append(signals, fmt.Sprintf("✅ 做多信号：%s信号统一为做多", timeframeStr))
-		return strings.Join(signals, " | ")
+		timeframeStr := strings.Join(validSignals, localize(locale, "、"))
+		signals = append(signals, localizef(locale, "✅ 做多信号：%s信号统一为做多", timeframeStr))
+		return strings.Join(signals, " | "), SignalDirectionLong
 	} else if signalDirection == "short" {
-		timeframeStr := strings.Join(validSignals, "、")
-		signals = append(signals, fmt.Sprintf("✅ 做空信号：%s信号统一为做空", timeframeStr))
-		return strings.Join(signals, " | ")
+		timeframeStr := strings.Join(validSignals, localize(locale, "、"))
+		signals = append(signals, localizef(locale, "✅ 做空信号：%s信号统一为做空", timeframeStr))
+		return strings.Join(signals, " | "), SignalDirectionShort
 	}
 
-	return "" // 不满足开仓条件
+	return "", "" // 不满足开仓条件
 }
 
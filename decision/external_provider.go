@@ -0,0 +1,298 @@
+package decision
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"nofx/market"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ExternalSchemaVersion 外部决策服务协议版本（主版本号不同视为不兼容）
+const ExternalSchemaVersion = "1.0"
+
+// DecisionSourceExternal 决策来源：外部HTTP决策服务
+const DecisionSourceExternal = "external"
+
+// 外部决策服务请求头
+const (
+	ExternalHeaderSchemaVersion = "X-Nofx-Schema-Version"
+	ExternalHeaderTimestamp     = "X-Nofx-Timestamp"
+	ExternalHeaderRequestID     = "X-Nofx-Request-Id" // 响应必须原样返回请求的 request_id
+	ExternalHeaderSignature     = "X-Nofx-Signature"  // 请求: "sha256=" + hex(HMAC-SHA256(secret, timestamp + "." + body))；响应见 SignExternalResponse
+)
+
+// defaultExternalTimeout 外部决策服务默认超时
+const defaultExternalTimeout = 30 * time.Second
+
+// externalMaxClockSkew 配置了签名密钥时，响应时间戳与本地时间允许的最大偏差（防止重放旧的签名响应）
+const externalMaxClockSkew = 5 * time.Minute
+
+// ExternalProvider 外部HTTP决策服务（替代LLM生成决策，复用nofx的验证、执行和日志）
+type ExternalProvider struct {
+	URL     string        // 服务地址（POST）
+	Secret  string        // 签名密钥（与服务端共享）
+	Model   string        // 模型标识（随请求发送，便于服务端路由）
+	Timeout time.Duration // 请求超时
+
+	httpClient *http.Client
+}
+
+// ExternalConstraints 决策约束（与nofx的决策验证规则一致，便于服务端预先遵守）
+type ExternalConstraints struct {
+	BTCETHMaxLeverage     int      `json:"btc_eth_max_leverage"`
+	AltcoinMaxLeverage    int      `json:"altcoin_max_leverage"`
+	BTCETHMaxPositionUSD  float64  `json:"btc_eth_max_position_usd"`
+	AltcoinMaxPositionUSD float64  `json:"altcoin_max_position_usd"`
+	MinRiskRewardRatio    float64  `json:"min_risk_reward_ratio"`
+	ValidActions          []string `json:"valid_actions"`
+}
+
+// ExternalDecisionRequest 发送给外部决策服务的请求体
+type ExternalDecisionRequest struct {
+	SchemaVersion string                  `json:"schema_version"`
+	RequestID     string                  `json:"request_id"`
+	Timestamp     int64                   `json:"timestamp"` // Unix秒
+	Model         string                  `json:"model,omitempty"`
+	TraderName    string                  `json:"trader_name"`
+	Context       *Context                `json:"context"`     // 时间、周期、账户、持仓、候选币种
+	MarketData    map[string]*market.Data `json:"market_data"` // 持仓和候选币种的市场数据
+	OITop         map[string]*OITopData   `json:"oi_top,omitempty"`
+	Performance   interface{}             `json:"performance,omitempty"` // 历史表现分析
+	Signals       []SignalAnnotation      `json:"signals,omitempty"`     // 信号分析器输出
+	Constraints   ExternalConstraints     `json:"constraints"`
+}
+
+// ExternalDecisionResponse 外部决策服务的响应体（也可以直接返回决策JSON数组）
+type ExternalDecisionResponse struct {
+	SchemaVersion string     `json:"schema_version"`
+	Reasoning     string     `json:"reasoning"` // 决策说明（记录为思维链）
+	Decisions     []Decision `json:"decisions"`
+}
+
+// NewExternalProvider 创建外部决策服务客户端（timeout<=0 时使用默认超时）
+func NewExternalProvider(url, secret, model string, timeout time.Duration) *ExternalProvider {
+	if timeout <= 0 {
+		timeout = defaultExternalTimeout
+	}
+	return &ExternalProvider{
+		URL:        url,
+		Secret:     secret,
+		Model:      model,
+		Timeout:    timeout,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// SignExternalPayload 计算签名：hex(HMAC-SHA256(secret, timestamp + "." + body))
+func SignExternalPayload(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// SignExternalResponse 计算响应签名：hex(HMAC-SHA256(secret, timestamp + "." + requestID + "." + body))
+// 签名包含 request_id，一个响应只能对应发出的那次请求（防止把其他请求的签名响应重放到本次请求）
+func SignExternalResponse(secret, timestamp, requestID string, body []byte) string {
+	return SignExternalPayload(secret, timestamp+"."+requestID, body)
+}
+
+// GetExternalDecision 请求外部决策服务获取决策
+// 决策与AI决策使用同样的验证逻辑；网络错误、超时和5xx响应包装为 ErrAIUnavailable（可回退到规则策略）
+func GetExternalDecision(ctx *Context, provider *ExternalProvider) (*FullDecision, error) {
+	if provider == nil || provider.URL == "" {
+		return nil, fmt.Errorf("外部决策服务地址未配置")
+	}
+
+	// 1. 获取市场数据和信号（已预先获取时不再重复请求）
+	if ctx.MarketDataMap == nil {
+		if err := fetchMarketDataForContext(ctx); err != nil {
+			return nil, fmt.Errorf("获取市场数据失败: %w", err)
+		}
+	}
+	ctx.SignalAnnotations = runSignalAnalyzers(ctx)
+	signals := flattenSignalAnnotations(ctx, ctx.SignalAnnotations)
+
+	// 2. 构建并签名请求
+	now := time.Now()
+	request := &ExternalDecisionRequest{
+		SchemaVersion: ExternalSchemaVersion,
+		RequestID:     fmt.Sprintf("%d-%d", ctx.CallCount, now.UnixNano()),
+		Timestamp:     now.Unix(),
+		Model:         provider.Model,
+		TraderName:    ctx.TraderName,
+		Context:       ctx,
+		MarketData:    ctx.MarketDataMap,
+		OITop:         ctx.OITopDataMap,
+		Performance:   ctx.Performance,
+		Signals:       signals,
+		Constraints:   buildExternalConstraints(ctx),
+	}
+	body, err := json.Marshal(request)
+	if err != nil {
+		return nil, fmt.Errorf("序列化请求失败: %w", err)
+	}
+
+	// 3. 调用外部服务
+	log.Printf("🌐 请求外部决策服务: %s (request_id=%s, %d bytes)", provider.URL, request.RequestID, len(body))
+	responseBody, err := provider.post(request, body)
+	if err != nil {
+		return nil, err
+	}
+
+	// 4. 解析并逐条验证决策
+	reasoning, decisions, err := parseExternalResponse(responseBody)
+	if err != nil {
+		return &FullDecision{
+			UserPrompt:  string(body),
+			RawResponse: string(responseBody),
+			Decisions:   []Decision{},
+			Source:      DecisionSourceExternal,
+			Timestamp:   time.Now(),
+		}, err
+	}
+	valid, rejected := validateDecisions(decisions, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, ctx.Locale)
+
+	return &FullDecision{
+		SystemPrompt:      fmt.Sprintf("外部决策服务: %s (schema %s)", provider.URL, ExternalSchemaVersion),
+		UserPrompt:        string(body),
+		CoTTrace:          reasoning,
+		Decisions:         valid,
+		RejectedDecisions: rejected,
+		RawResponse:       string(responseBody),
+		TemplateName:      "external",
+		Signals:           signals,
+		Source:            DecisionSourceExternal,
+		Timestamp:         time.Now(),
+	}, nil
+}
+
+// post 发送签名请求并校验响应（配置了签名密钥时响应必须带签名且时间戳在允许范围内）
+func (p *ExternalProvider) post(request *ExternalDecisionRequest, body []byte) ([]byte, error) {
+	timestamp := strconv.FormatInt(request.Timestamp, 10)
+	req, err := http.NewRequest(http.MethodPost, p.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("创建请求失败: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(ExternalHeaderSchemaVersion, ExternalSchemaVersion)
+	req.Header.Set(ExternalHeaderTimestamp, timestamp)
+	req.Header.Set(ExternalHeaderRequestID, request.RequestID)
+	if p.Secret != "" {
+		req.Header.Set(ExternalHeaderSignature, "sha256="+SignExternalPayload(p.Secret, timestamp, body))
+	}
+
+	client := p.httpClient
+	if client == nil {
+		client = &http.Client{Timeout: defaultExternalTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 外部决策服务请求失败: %w", ErrAIUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	responseBody, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 读取外部决策服务响应失败: %w", ErrAIUnavailable, err)
+	}
+
+	if resp.StatusCode >= 500 {
+		return nil, fmt.Errorf("%w: 外部决策服务返回错误 (status %d): %s", ErrAIUnavailable, resp.StatusCode, string(responseBody))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("外部决策服务拒绝请求 (status %d): %s", resp.StatusCode, string(responseBody))
+	}
+
+	// 服务端签名响应：timestamp 取响应头，request_id 必须与请求一致，body 为响应体
+	if p.Secret != "" {
+		if err := verifyExternalResponse(p.Secret, request.RequestID, resp.Header, responseBody, time.Now()); err != nil {
+			return nil, err
+		}
+	}
+
+	return responseBody, nil
+}
+
+// verifyExternalResponse 校验响应签名、request_id 和时间戳
+// 缺少签名、request_id 与请求不一致、时间戳超出 externalMaxClockSkew 或签名不匹配时返回错误
+func verifyExternalResponse(secret, requestID string, header http.Header, body []byte, now time.Time) error {
+	signature := header.Get(ExternalHeaderSignature)
+	if signature == "" {
+		return fmt.Errorf("外部决策服务响应缺少签名（已配置签名密钥）")
+	}
+
+	if got := header.Get(ExternalHeaderRequestID); got != requestID {
+		return fmt.Errorf("外部决策服务响应 request_id 不匹配（期望 %q，实际 %q）", requestID, got)
+	}
+
+	timestamp := header.Get(ExternalHeaderTimestamp)
+	seconds, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return fmt.Errorf("外部决策服务响应时间戳无效: %q", timestamp)
+	}
+	if skew := now.Sub(time.Unix(seconds, 0)); skew > externalMaxClockSkew || skew < -externalMaxClockSkew {
+		return fmt.Errorf("外部决策服务响应时间戳超出允许范围（偏差 %s，最多 %s）", skew.Round(time.Second), externalMaxClockSkew)
+	}
+
+	expected := "sha256=" + SignExternalResponse(secret, timestamp, requestID, body)
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return fmt.Errorf("外部决策服务响应签名校验失败")
+	}
+	return nil
+}
+
+// parseExternalResponse 解析外部决策服务的响应（决策对象或决策JSON数组）
+func parseExternalResponse(body []byte) (string, []Decision, error) {
+	trimmed := strings.TrimSpace(string(body))
+	if strings.HasPrefix(trimmed, "[") {
+		var decisions []Decision
+		if err := json.Unmarshal([]byte(trimmed), &decisions); err != nil {
+			return "", nil, fmt.Errorf("解析决策数组失败: %w", err)
+		}
+		return "", decisions, nil
+	}
+
+	var response ExternalDecisionResponse
+	if err := json.Unmarshal([]byte(trimmed), &response); err != nil {
+		return "", nil, fmt.Errorf("解析外部决策服务响应失败: %w", err)
+	}
+	if response.SchemaVersion != "" && majorVersion(response.SchemaVersion) != majorVersion(ExternalSchemaVersion) {
+		return "", nil, fmt.Errorf("外部决策服务协议版本不兼容: %s（当前 %s）", response.SchemaVersion, ExternalSchemaVersion)
+	}
+	if response.Decisions == nil {
+		return response.Reasoning, nil, fmt.Errorf("外部决策服务响应缺少 decisions 字段")
+	}
+	return response.Reasoning, response.Decisions, nil
+}
+
+// buildExternalConstraints 根据账户净值和杠杆配置生成决策约束
+func buildExternalConstraints(ctx *Context) ExternalConstraints {
+	policy := DefaultValidationPolicy
+	return ExternalConstraints{
+		BTCETHMaxLeverage:     ctx.BTCETHLeverage,
+		AltcoinMaxLeverage:    ctx.AltcoinLeverage,
+		BTCETHMaxPositionUSD:  ctx.Account.TotalEquity * policy.BTCETHMaxPositionRatio,
+		AltcoinMaxPositionUSD: ctx.Account.TotalEquity * policy.AltcoinMaxPositionRatio,
+		MinRiskRewardRatio:    policy.MinRiskRewardRatio,
+		ValidActions:          []string{"open_long", "open_short", "close_long", "close_short", "hold", "wait"},
+	}
+}
+
+// majorVersion 返回版本号的主版本部分（"1.2" -> "1"）
+func majorVersion(version string) string {
+	if i := strings.Index(version, "."); i >= 0 {
+		return version[:i]
+	}
+	return version
+}
@@ -0,0 +1,79 @@
+package decision
+
+import (
+	"fmt"
+	"strings"
+)
+
+// 提示词语言
+const (
+	LocaleZhCN = "zh-CN" // 简体中文（默认，代码中的原文）
+	LocaleEN   = "en"    // 英文
+)
+
+// DefaultLocale 默认提示词语言（未标注语言的模板文件视为该语言）
+const DefaultLocale = LocaleZhCN
+
+// SupportedLocales 支持的提示词语言
+var SupportedLocales = []string{LocaleZhCN, LocaleEN}
+
+// localeMessages 各语言的译文（键为代码中的中文原文或格式串，zh-CN 直接使用原文）
+var localeMessages = map[string]map[string]string{
+	LocaleEN: enMessages,
+}
+
+// NormalizeLocale 规范化语言标识（zh/zh_CN/zh-cn -> zh-CN，en-US/en_GB -> en，空字符串 -> 默认语言）
+// 不支持的语言原样返回，由 ValidateLocale 报错
+func NormalizeLocale(locale string) string {
+	normalized := strings.ToLower(strings.ReplaceAll(strings.TrimSpace(locale), "_", "-"))
+	switch {
+	case normalized == "":
+		return DefaultLocale
+	case normalized == "zh" || strings.HasPrefix(normalized, "zh-"):
+		return LocaleZhCN
+	case normalized == "en" || strings.HasPrefix(normalized, "en-"):
+		return LocaleEN
+	}
+	return locale
+}
+
+// ValidateLocale 验证提示词语言
+func ValidateLocale(locale string) error {
+	normalized := NormalizeLocale(locale)
+	for _, supported := range SupportedLocales {
+		if normalized == supported {
+			return nil
+		}
+	}
+	return fmt.Errorf("不支持的提示词语言: %s（可选: %s）", locale, strings.Join(SupportedLocales, ", "))
+}
+
+// isSupportedLocaleTag 文件名中的语言标记是否为支持的语言（模板文件 name.en.txt）
+func isSupportedLocaleTag(tag string) bool {
+	for _, supported := range SupportedLocales {
+		if strings.EqualFold(tag, supported) {
+			return true
+		}
+	}
+	return false
+}
+
+// localize 返回文本在指定语言下的译文（没有译文时返回原文）
+func localize(locale, text string) string {
+	if messages, ok := localeMessages[NormalizeLocale(locale)]; ok {
+		if translated, ok := messages[text]; ok {
+			return translated
+		}
+	}
+	return text
+}
+
+// localizef 按指定语言的格式串格式化文本
+func localizef(locale, format string, args ...interface{}) string {
+	return fmt.Sprintf(localize(locale, format), args...)
+}
+
+// localeErrorf 按指定语言的格式串生成错误（用于反馈给AI的验证错误）
+func localeErrorf(locale, format string, args ...interface{}) error {
+	return fmt.Errorf(localize(locale, format), args...)
+}
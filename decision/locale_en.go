@@ -0,0 +1,153 @@
+package decision
+
+// enMessages 英文译文（键为代码中的中文原文或格式串，格式化动词的顺序必须与原文一致）
+var enMessages = map[string]string{
+	// 修复提示（buildRepairPrompt）
+	"你上一次的输出无法解析为有效的决策JSON数组，错误如下：\n\n":             "Your previous output could not be parsed as a valid decision JSON array. Error:\n\n",
+	"\n\n请重新输出完整的决策JSON数组（不需要思维链），格式与系统提示中的要求一致。\n": "\n\nPlease output the complete decision JSON array again (no chain of thought), in the format required by the system prompt.\n",
+	"你上一次输出的以下决策未通过验证：\n\n":                         "The following decisions from your previous output failed validation:\n\n",
+	"%d. %s\n   错误: %s\n":   "%d. %s\n   Error: %s\n",
+	"\n其他决策已通过验证，无需重复输出。\n": "\nAll other decisions passed validation; do not repeat them.\n",
+	"请只输出以上决策修正后的JSON数组（不需要思维链）；如果无法在约束内修正，请将该决策改为 wait。\n": "Output only the corrected JSON array for the decisions above (no chain of thought); if a decision cannot be fixed within the constraints, change it to wait.\n",
+
+	// 系统提示词（buildSystemPrompt / buildSystemPromptWithCustom）
+	"# 📌 个性化交易策略\n\n": "# 📌 Custom Trading Strategy\n\n",
+	"注意: 以上个性化策略是对基础规则的补充，不能违背基础风险控制原则。\n": "Note: the custom strategy above supplements the base rules and must not violate the base risk-control principles.\n",
+	"你是专业的加密货币交易AI。请根据市场数据做出交易决策。\n\n":     "You are a professional cryptocurrency trading AI. Make trading decisions based on the market data.\n\n",
+	"# 📈 Supertrend 多时间框架交易策略\n\n":         "# 📈 Supertrend Multi-Timeframe Trading Strategy\n\n",
+	"## 核心交易规则：\n\n": "## Core Trading Rules:\n\n",
+	"1. **信号触发条件（优化后，短期策略优先5分钟信号，3分钟信号对短期获利至关重要）**：\n":                  "1. **Signal trigger conditions (optimized: short-term strategy prioritizes the 5m signal; the 3m signal is critical for short-term profit)**:\n",
+	"   - 优先级策略：5m+15m一致（优先，最敏感，5分钟信号改变可能影响后续）> 15m+30m一致 > 5m+30m一致\n": "   - Priority: 5m+15m agree (preferred, most sensitive; a 5m signal change may affect later signals) > 15m+30m agree > 5m+30m agree\n",
+	"   - 🔴 3分钟信号对短期获利至关重要：如果3m与主信号相反，需要非常谨慎（3分钟信号变化可能预示短期趋势变化）\n":      "   - 🔴 The 3m signal is critical for short-term profit: if 3m opposes the main signal, be very cautious (a 3m change may signal a short-term trend change)\n",
+	"   - ✅ 如果3m与主信号一致，信号更强，可以更积极开仓\n":                                  "   - ✅ If 3m agrees with the main signal, the signal is stronger and you may open more aggressively\n",
+	"   - 5分钟信号最重要：因为短期策略中，5分钟信号改变可能改变后续信号，需要优先关注\n":                    "   - The 5m signal matters most: in a short-term strategy a 5m change may change later signals, so watch it first\n",
+	"   - 大趋势验证（灵活策略）：1小时为主，4小时为辅\n":                                    "   - Higher-timeframe trend check (flexible): 1h is primary, 4h is secondary\n",
+	"   - ✅ 只要1小时或4小时其中一个与交易信号一致，就允许开仓（更灵活）\n":                          "   - ✅ Opening is allowed as long as either 1h or 4h agrees with the trade signal (more flexible)\n",
+	"   - ❌ 如果1小时和4小时都与交易信号相反，则阻止开仓（风险控制）\n\n":                          "   - ❌ If both 1h and 4h oppose the trade signal, do not open (risk control)\n\n",
+	"2. **短期盈利优势判断（新增）**：\n":                                            "2. **Short-term profit edge (new)**:\n",
+	"   - 做多优势：RSI < 40（超卖反弹）、MACD转强、价格低于EMA20\n":                       "   - Long edge: RSI < 40 (oversold bounce), MACD strengthening, price below EMA20\n",
+	"   - 做空优势：RSI > 60（超买回调）、MACD转弱、价格高于EMA20\n":                       "   - Short edge: RSI > 60 (overbought pullback), MACD weakening, price above EMA20\n",
+	"   - 有短期盈利优势时，信号更强，可以更积极开仓\n":                                      "   - With a short-term profit edge the signal is stronger and you may open more aggressively\n",
+	"   - 没有明显优势时，需谨慎但也可以开仓（信号统一即可）\n\n":                                "   - Without a clear edge, be cautious but opening is still allowed (aligned signals are enough)\n\n",
+	"3. **量价关系验证（放宽）**：\n":                                              "3. **Price-volume check (relaxed)**:\n",
+	"   - 优先关注量价关系健康（价涨量增或价跌量减）\n":                                      "   - Prefer a healthy price-volume relationship (price up on rising volume, or price down on falling volume)\n",
+	"   - 如果量价关系不够理想但信号较强，可以交易但需谨慎\n":                                   "   - If the price-volume relationship is not ideal but the signal is strong, you may trade with caution\n",
+	"   - 成交量比率建议在0.3-3.0之间（<0.3极低需谨慎，>3.0异常波动需注意）\n\n":                 "   - Volume ratio should be between 0.3 and 3.0 (<0.3 is very low, be cautious; >3.0 is abnormal volatility, take care)\n\n",
+	"4. **时间框架优先级（短期策略优化）**：\n":                                         "4. **Timeframe priority (short-term optimized)**:\n",
+	"   - 5分钟：核心信号（最重要，5分钟信号改变可能影响后续信号）\n":                              "   - 5m: core signal (most important; a 5m change may affect later signals)\n",
+	"   - 15分钟：核心确认（与5分钟信号一致，形成主要交易信号）\n":                               "   - 15m: core confirmation (agrees with 5m to form the main trade signal)\n",
+	"   - 🔴 3分钟：关键信号（对短期获利至关重要，如果与主信号相反，需要非常谨慎）\n":                      "   - 🔴 3m: key signal (critical for short-term profit; be very cautious if it opposes the main signal)\n",
+	"   - 30分钟：中期确认（与5-15分钟信号一致）\n":                                     "   - 30m: mid-term confirmation (agrees with the 5m-15m signals)\n",
+	"   - 1小时：大趋势判断（主要参考，必须与交易信号一致或至少1h/4h其中一个一致）\n":                    "   - 1h: main trend (primary reference; must agree with the trade signal, or at least one of 1h/4h must agree)\n",
+	"   - 4小时：大趋势参考（辅助参考，与1小时配合使用）\n\n":                                 "   - 4h: trend reference (secondary, used together with 1h)\n\n",
+	"5. **开仓条件总结（优化后，短期策略优先5分钟信号，3分钟信号对短期获利至关重要）**：\n":                  "5. **Entry conditions summary (optimized: short-term strategy prioritizes the 5m signal; the 3m signal is critical for short-term profit)**:\n",
+	"   - ✅ 5m+15m一致（优先，最敏感，5分钟信号最重要）\n":                                "   - ✅ 5m+15m agree (preferred, most sensitive; the 5m signal matters most)\n",
+	"   - ✅ 或 15m+30m一致（备选，但需注意5分钟信号）\n":                                "   - ✅ or 15m+30m agree (alternative, but watch the 5m signal)\n",
+	"   - ✅ 或 5m+30m一致（备选，但需注意15分钟信号）\n":                                "   - ✅ or 5m+30m agree (alternative, but watch the 15m signal)\n",
+	"   - 🔴 3分钟信号对短期获利至关重要：与主信号一致时信号更强，相反时需要非常谨慎（3分钟信号变化可能预示短期趋势变化）\n":  "   - 🔴 The 3m signal is critical for short-term profit: agreement with the main signal strengthens it, opposition calls for great caution (a 3m change may signal a short-term trend change)\n",
+	"   - ✅ 大趋势验证：1小时或4小时至少一个与交易信号一致（灵活策略）\n":                           "   - ✅ Trend check: at least one of 1h or 4h agrees with the trade signal (flexible)\n",
+	"   - ❌ 如果1小时和4小时都与交易信号相反，则阻止开仓（风险控制）\n":                            "   - ❌ If both 1h and 4h oppose the trade signal, do not open (risk control)\n",
+	"   - ✅ 有短期盈利优势时（RSI超买/超卖、MACD转强/转弱等），信号更强\n":                       "   - ✅ With a short-term profit edge (RSI overbought/oversold, MACD strengthening/weakening, etc.) the signal is stronger\n",
+	"   - ⚠️ 量价关系健康为佳，但不强制（信号强时可放宽）\n":                                  "   - ⚠️ A healthy price-volume relationship is preferred but not required (may be relaxed when the signal is strong)\n",
+	"   - ⚠️ 成交量比率>0.3为佳，<0.3极低需谨慎\n\n":                                 "   - ⚠️ Volume ratio >0.3 is preferred; <0.3 is very low, be cautious\n\n",
+
+	// 结构化输出（buildStructuredOutputPrompt）
+	"\n\n# 结构化输出（优先于上面的输出格式）\n\n": "\n\n# Structured Output (takes precedence over the output format above)\n\n",
+	"只输出一个JSON对象，不要输出任何其他文本:\n\n": "Output a single JSON object and nothing else:\n\n",
+	"{\"cot_trace\": \"简洁的思维链分析\", \"decisions\": [{\"symbol\": \"ETHUSDT\", \"action\": \"close_long\", \"leverage\": 0, \"position_size_usd\": 0, \"stop_loss\": 0, \"take_profit\": 0, \"confidence\": 80, \"risk_usd\": 0, \"reasoning\": \"止盈离场\"}]}\n": "{\"cot_trace\": \"brief chain-of-thought analysis\", \"decisions\": [{\"symbol\": \"ETHUSDT\", \"action\": \"close_long\", \"leverage\": 0, \"position_size_usd\": 0, \"stop_loss\": 0, \"take_profit\": 0, \"confidence\": 80, \"risk_usd\": 0, \"reasoning\": \"Take profit and exit\"}]}\n",
+	"- `decisions` 字段说明与上面的JSON决策数组相同，非开仓决策的数值字段填0\n": "- `decisions` fields are the same as in the JSON decision array above; use 0 for numeric fields of non-opening decisions\n",
+
+	// 用户提示词（buildUserPrompt）
+	"时间: %s | 周期: #%d | 运行: %d分钟\n\n":                                  "Time: %s | Cycle: #%d | Runtime: %d min\n\n",
+	"账户: 净值%.2f | 余额%.2f (%.1f%%) | 盈亏%+.2f%% | 保证金%.1f%% | 持仓%d个\n\n": "Account: equity %.2f | available %.2f (%.1f%%) | PnL %+.2f%% | margin %.1f%% | positions %d\n\n",
+	"## 当前持仓\n": "## Current Positions\n",
+	"%d. %s %s | 入场价%.4f 当前价%.4f | 盈亏%+.2f%% | 杠杆%dx | 保证金%.0f | 强平价%.4f%s\n\n": "%d. %s %s | entry %.4f mark %.4f | PnL %+.2f%% | leverage %dx | margin %.0f | liquidation %.4f%s\n\n",
+	" | 持仓时长%d分钟":              " | held %dm",
+	" | 持仓时长%d小时%d分钟":          " | held %dh %dm",
+	" | 资金费盈亏%+.2f USDT":       " | funding PnL %+.2f USDT",
+	" (AI500+OI_Top双重信号)":      " (AI500+OI_Top dual signal)",
+	" (OI_Top持仓增长)":            " (OI_Top open interest rising)",
+	" (行情警报)":                  " (market alert)",
+	"当前持仓: 无\n\n":              "Current positions: none\n\n",
+	"## 候选币种 (%d个)\n\n":        "## Candidate Coins (%d)\n\n",
+	"## 📊 夏普比率: %.2f\n\n":      "## 📊 Sharpe Ratio: %.2f\n\n",
+	"现在请分析并输出决策（思维链 + JSON）\n": "Now analyze and output your decisions (chain of thought + JSON)\n",
+
+	// 决策解析与验证（反馈给AI的错误）
+	"提取决策失败":          "failed to extract decisions",
+	"无法找到JSON数组起始":    "cannot find the start of the JSON array",
+	"无法找到JSON数组结束":    "cannot find the end of the JSON array",
+	"JSON解析失败":        "JSON parse error",
+	"JSON内容":          "JSON content",
+	"决策 #%d 验证失败: %v": "decision #%d failed validation: %v",
+	"无效的action: %s":   "invalid action: %s",
+	"杠杆必须在1-%d之间（%s，当前配置上限%d倍）: %d":                                     "leverage must be between 1 and %d (%s, configured limit %dx): %d",
+	"仓位大小必须大于0: %.2f":                                                   "position size must be greater than 0: %.2f",
+	"BTC/ETH单币种仓位价值不能超过%.0f USDT（%.0f倍账户净值），实际: %.0f":                   "BTC/ETH position value cannot exceed %.0f USDT (%.0fx account equity), got: %.0f",
+	"山寨币单币种仓位价值不能超过%.0f USDT（%.1f倍账户净值），实际: %.0f":                       "altcoin position value cannot exceed %.0f USDT (%.1fx account equity), got: %.0f",
+	"止损和止盈必须大于0":                                                        "stop loss and take profit must be greater than 0",
+	"做多时止损价必须小于止盈价":                                                     "for a long, stop loss must be below take profit",
+	"做空时止损价必须大于止盈价":                                                     "for a short, stop loss must be above take profit",
+	"风险回报比过低(%.2f:1)，必须≥%.1f:1 [风险:%.2f%% 收益:%.2f%%] [止损:%.2f 止盈:%.2f]": "risk/reward too low (%.2f:1), must be ≥%.1f:1 [risk: %.2f%% reward: %.2f%%] [stop loss: %.2f take profit: %.2f]",
+
+	// Supertrend 信号分析（analyzeSupertrendSignal）
+	"✅ 3m信号(%s)与5m+15m一致，信号强化（3分钟信号对短期获利至关重要）":       "✅ 3m signal (%s) agrees with 5m+15m, signal strengthened (3m is critical for short-term profit)",
+	"🔴 3m信号(%s)与5m+15m相反，3分钟信号变化需非常谨慎（短期获利依赖3分钟信号）":  "🔴 3m signal (%s) opposes 5m+15m, be very cautious (short-term profit depends on the 3m signal)",
+	"⚠️ 30m信号(%s)与5m+15m相反，但5m+15m为主信号":              "⚠️ 30m signal (%s) opposes 5m+15m, but 5m+15m is the main signal",
+	"⚠️ 5m信号(%s)与15m+30m相反，5分钟信号变化可能影响后续，需谨慎":        "⚠️ 5m signal (%s) opposes 15m+30m; a 5m change may affect later signals, be cautious",
+	"✅ 3m信号(%s)与15m+30m一致，信号强化（3分钟信号对短期获利至关重要）":      "✅ 3m signal (%s) agrees with 15m+30m, signal strengthened (3m is critical for short-term profit)",
+	"🔴 3m信号(%s)与15m+30m相反，3分钟信号变化需非常谨慎（短期获利依赖3分钟信号）": "🔴 3m signal (%s) opposes 15m+30m, be very cautious (short-term profit depends on the 3m signal)",
+	"⚠️ 15m信号(%s)与5m+30m相反，15分钟信号缺失确认":               "⚠️ 15m signal (%s) opposes 5m+30m, missing 15m confirmation",
+	"✅ 3m信号(%s)与5m+30m一致，信号强化（3分钟信号对短期获利至关重要）":       "✅ 3m signal (%s) agrees with 5m+30m, signal strengthened (3m is critical for short-term profit)",
+	"🔴 3m信号(%s)与5m+30m相反，3分钟信号变化需非常谨慎（短期获利依赖3分钟信号）":  "🔴 3m signal (%s) opposes 5m+30m, be very cautious (short-term profit depends on the 3m signal)",
+	"RSI超卖(%.1f)":   "RSI oversold (%.1f)",
+	"MACD转强":        "MACD strengthening",
+	"价格站上EMA20":     "price above EMA20",
+	"RSI走强":         "RSI rising",
+	"RSI超买(%.1f)":   "RSI overbought (%.1f)",
+	"MACD转弱":        "MACD weakening",
+	"价格跌破EMA20":     "price below EMA20",
+	"RSI走弱":         "RSI falling",
+	"✅ 短期盈利优势：%s":   "✅ Short-term profit edge: %s",
+	"、":             ", ",
+	"✅ 1h趋势同向(%s)":  "✅ 1h trend aligned (%s)",
+	"⚠️ 1h趋势相反(%s)": "⚠️ 1h trend opposed (%s)",
+	"✅ 4h趋势同向(%s)":  "✅ 4h trend aligned (%s)",
+	"⚠️ 4h趋势相反(%s)": "⚠️ 4h trend opposed (%s)",
+	" | 大趋势支持":      " | higher-timeframe trend supports",
+	" | 大趋势部分支持":    " | higher-timeframe trend partly supports",
+	"⚠️ 量价关系不够理想，但信号较强":          "⚠️ Price-volume relationship not ideal, but the signal is strong",
+	"⚠️ 成交量比率极低(%.2f)，流动性不足，需谨慎": "⚠️ Volume ratio very low (%.2f), thin liquidity, be cautious",
+	"⚠️ 成交量比率较低(%.2f)，建议谨慎":      "⚠️ Volume ratio low (%.2f), caution advised",
+	"⚠️ 成交量比率较高(%.2f)，注意风险":      "⚠️ Volume ratio high (%.2f), watch the risk",
+	"✅ 做多信号：%s信号统一为做多":           "✅ Long signal: %s aligned long",
+	"✅ 做空信号：%s信号统一为做空":           "✅ Short signal: %s aligned short",
+
+	// 内置信号分析器（signal_analyzers_builtin.go）
+	"⚠️  Supertrend 数据为 nil（数据未计算）\n\n":      "⚠️  Supertrend data is nil (not calculated)\n\n",
+	"⚠️  量价关系数据为 nil（数据未计算）\n\n":             "⚠️  Price-volume data is nil (not calculated)\n\n",
+	"📊 Supertrend 多时间框架分析:\n":                "📊 Supertrend multi-timeframe analysis:\n",
+	"  3m (关键): %s (信号: %s) - 短期获利依赖3分钟信号\n": "  3m (key): %s (signal: %s) - short-term profit depends on the 3m signal\n",
+	"  5m (核心): %s (信号: %s)\n":               "  5m (core): %s (signal: %s)\n",
+	"  15m (核心): %s (信号: %s)\n":              "  15m (core): %s (signal: %s)\n",
+	"  30m (确认): %s (信号: %s)\n":              "  30m (confirm): %s (signal: %s)\n",
+	"  1h (大趋势): %s (信号: %s)\n":              "  1h (trend): %s (signal: %s)\n",
+	"  4h (参考): %s (信号: %s)\n":               "  4h (reference): %s (signal: %s)\n",
+	"  量价关系: %v (成交量比率: %.2f)\n":             "  Price-volume healthy: %v (volume ratio: %.2f)\n",
+	"  ✅ 交易信号: %s\n\n":                       "  ✅ Trade signal: %s\n\n",
+	"  ⚠️  当前不满足开仓条件（需要5m+15m一致，或15m+30m一致，或5m+30m一致，且1h或4h大趋势至少一个支持）\n\n": "  ⚠️  Entry conditions not met (need 5m+15m, 15m+30m or 5m+30m to agree, with at least one of the 1h/4h trends supporting)\n\n",
+	"🔎 RSI顶背离: 价格创%d根K线新高(%.4f > %.4f)，RSI14却下降(%.1f → %.1f)，上涨动能减弱":       "🔎 RSI bearish divergence: price made a %d-candle high (%.4f > %.4f) but RSI14 fell (%.1f → %.1f), upside momentum fading",
+	"🔎 RSI底背离: 价格创%d根K线新低(%.4f < %.4f)，RSI14却上升(%.1f → %.1f)，下跌动能减弱":       "🔎 RSI bullish divergence: price made a %d-candle low (%.4f < %.4f) but RSI14 rose (%.1f → %.1f), downside momentum fading",
+	"🔎 放量向上突破: 价格%.4f突破%d根K线高点%.4f，成交量比率%.2f":                              "🔎 Volume breakout up: price %.4f broke the %d-candle high %.4f, volume ratio %.2f",
+	"🔎 放量向下突破: 价格%.4f跌破%d根K线低点%.4f，成交量比率%.2f":                              "🔎 Volume breakdown: price %.4f broke the %d-candle low %.4f, volume ratio %.2f",
+	"🔎 资金费率极高: %.4f%%，多头拥挤，追多需谨慎（警惕多头踩踏）":                                  "🔎 Funding rate extremely high: %.4f%%, longs crowded, be careful chasing longs (watch for a long squeeze)",
+	"🔎 资金费率极低: %.4f%%，空头拥挤，追空需谨慎（警惕轧空）":                                    "🔎 Funding rate extremely low: %.4f%%, shorts crowded, be careful chasing shorts (watch for a short squeeze)",
+	"🔎 持仓量激增: OI 1h %+.2f%%，价格 %+.2f%%，新增资金偏多":                             "🔎 Open interest surge: OI 1h %+.2f%%, price %+.2f%%, new money leaning long",
+	"🔎 持仓量激增: OI 1h %+.2f%%，价格 %+.2f%%，新增资金偏空":                             "🔎 Open interest surge: OI 1h %+.2f%%, price %+.2f%%, new money leaning short",
+	"🔎 持仓量激增: OI 1h %+.2f%%，价格持平，多空分歧加大":                                   "🔎 Open interest surge: OI 1h %+.2f%%, price flat, long/short disagreement widening",
+
+	// Prompt token预算裁剪说明（buildUserPromptWithinBudget）
+	"候选币种日内序列缩短至最近%d个数据点": "Candidate intraday series shortened to the last %d data points",
+	"丢弃排名靠后的候选币种%d个: %v":  "Dropped %d lowest-ranked candidate coins: %v",
+	"持仓币种日内序列缩短至最近%d个数据点": "Position intraday series shortened to the last %d data points",
+}
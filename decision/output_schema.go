@@ -0,0 +1,128 @@
+package decision
+
+import (
+	"encoding/json"
+	"log"
+	"nofx/mcp"
+	"strings"
+)
+
+// StructuredResponse 结构化输出模式下AI返回的JSON对象
+// 注意：json_object 模式要求顶层必须是对象，因此决策数组放在 decisions 字段中
+type StructuredResponse struct {
+	CoTTrace  string      `json:"cot_trace"` // 思维链分析
+	Decisions *[]Decision `json:"decisions"` // 决策列表（指针用于区分"字段缺失"和"空数组"）
+}
+
+// decisionJSONSchema []Decision 的 JSON Schema（strict 模式要求所有字段必填且不允许额外字段）
+var decisionJSONSchema = map[string]interface{}{
+	"type": "object",
+	"properties": map[string]interface{}{
+		"cot_trace": map[string]interface{}{
+			"type":        "string",
+			"description": "简洁的思维链分析",
+		},
+		"decisions": map[string]interface{}{
+			"type": "array",
+			"items": map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"symbol": map[string]interface{}{"type": "string"},
+					"action": map[string]interface{}{
+						"type": "string",
+						"enum": []string{"open_long", "open_short", "close_long", "close_short", "hold", "wait"},
+					},
+					"leverage":          map[string]interface{}{"type": "integer", "description": "开仓杠杆，非开仓填0"},
+					"position_size_usd": map[string]interface{}{"type": "number", "description": "仓位价值(USDT)，非开仓填0"},
+					"stop_loss":         map[string]interface{}{"type": "number", "description": "止损价，非开仓填0"},
+					"take_profit":       map[string]interface{}{"type": "number", "description": "止盈价，非开仓填0"},
+					"confidence":        map[string]interface{}{"type": "integer", "description": "信心度 0-100"},
+					"risk_usd":          map[string]interface{}{"type": "number", "description": "最大美元风险，非开仓填0"},
+					"reasoning":         map[string]interface{}{"type": "string"},
+				},
+				"required": []string{
+					"symbol", "action", "leverage", "position_size_usd", "stop_loss",
+					"take_profit", "confidence", "risk_usd", "reasoning",
+				},
+				"additionalProperties": false,
+			},
+		},
+	},
+	"required":             []string{"cot_trace", "decisions"},
+	"additionalProperties": false,
+}
+
+// buildResponseFormat 根据输出模式构建 response_format 参数（文本模式返回nil）
+func buildResponseFormat(outputMode string) map[string]interface{} {
+	switch outputMode {
+	case mcp.OutputModeJSONSchema:
+		return map[string]interface{}{
+			"type": "json_schema",
+			"json_schema": map[string]interface{}{
+				"name":   "trading_decisions",
+				"strict": true,
+				"schema": decisionJSONSchema,
+			},
+		}
+	case mcp.OutputModeJSONObject:
+		return map[string]interface{}{"type": "json_object"}
+	default:
+		return nil
+	}
+}
+
+// buildStructuredOutputPrompt 结构化输出模式下追加到系统提示词的格式说明（覆盖"思维链 + JSON数组"格式）
+func buildStructuredOutputPrompt(locale string) string {
+	var sb strings.Builder
+	sb.WriteString(localize(locale, "\n\n# 结构化输出（优先于上面的输出格式）\n\n"))
+	sb.WriteString(localize(locale, "只输出一个JSON对象，不要输出任何其他文本:\n\n"))
+	sb.WriteString("```json\n")
+	sb.WriteString(localize(locale, "{\"cot_trace\": \"简洁的思维链分析\", \"decisions\": [{\"symbol\": \"ETHUSDT\", \"action\": \"close_long\", \"leverage\": 0, \"position_size_usd\": 0, \"stop_loss\": 0, \"take_profit\": 0, \"confidence\": 80, \"risk_usd\": 0, \"reasoning\": \"止盈离场\"}]}\n"))
+	sb.WriteString("```\n\n")
+	sb.WriteString(localize(locale, "- `decisions` 字段说明与上面的JSON决策数组相同，非开仓决策的数值字段填0\n"))
+	return sb.String()
+}
+
+// callAI 调用AI（配置了结构化输出时使用 response_format，提供商不支持该格式时回退到普通文本模式）
+// 网络错误、超时、5xx等其他错误直接返回，不再用文本模式重复请求
+func callAI(mcpClient *mcp.Client, messages []mcp.Message) (string, error) {
+	if responseFormat := buildResponseFormat(mcpClient.OutputMode); responseFormat != nil {
+		response, err := mcpClient.CallWithResponseFormat(messages, responseFormat)
+		if err == nil {
+			return response, nil
+		}
+		if !mcp.IsResponseFormatUnsupported(err) {
+			return "", err
+		}
+		log.Printf("⚠️  提供商不支持结构化输出(%s)，回退到文本模式: %v", mcpClient.OutputMode, err)
+	}
+	return mcpClient.CallWithConversation(messages)
+}
+
+// parseStructuredResponse 尝试按结构化输出解析AI响应（顶层为JSON对象且包含decisions字段）
+// 解析失败时返回 ok=false，由调用方回退到文本解析
+func parseStructuredResponse(response string) (cotTrace string, decisions []Decision, ok bool) {
+	content := strings.TrimSpace(response)
+
+	// 兼容部分模型在JSON外包裹 ```json 代码块
+	if strings.HasPrefix(content, "```") {
+		content = strings.TrimPrefix(content, "```json")
+		content = strings.TrimPrefix(content, "```")
+		content = strings.TrimSuffix(strings.TrimSpace(content), "```")
+		content = strings.TrimSpace(content)
+	}
+
+	if !strings.HasPrefix(content, "{") {
+		return "", nil, false
+	}
+
+	var structured StructuredResponse
+	if err := json.Unmarshal([]byte(fixMissingQuotes(content)), &structured); err != nil {
+		return "", nil, false
+	}
+	if structured.Decisions == nil {
+		return "", nil, false
+	}
+
+	return strings.TrimSpace(structured.CoTTrace), *structured.Decisions, true
+}
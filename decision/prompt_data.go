@@ -0,0 +1,89 @@
+package decision
+
+import (
+	"time"
+)
+
+// ValidationPolicy 决策验证策略（validateDecision 与提示词模板共用同一份数值，避免两边不一致）
+type ValidationPolicy struct {
+	MinRiskRewardRatio      float64 `json:"min_risk_reward_ratio"`      // 最低风险回报比（开仓硬约束）
+	MaxPositions            int     `json:"max_positions"`              // 最多同时持仓币种数（提示）
+	MaxMarginUsagePct       float64 `json:"max_margin_usage_pct"`       // 最大保证金使用率%（提示）
+	MinOpenConfidence       int     `json:"min_open_confidence"`        // 建议的最低开仓信心度（提示）
+	AltcoinMinPositionRatio float64 `json:"altcoin_min_position_ratio"` // 山寨币建议最小仓位（账户净值倍数）
+	AltcoinMaxPositionRatio float64 `json:"altcoin_max_position_ratio"` // 山寨币最大仓位（账户净值倍数，硬约束）
+	BTCETHMinPositionRatio  float64 `json:"btc_eth_min_position_ratio"` // BTC/ETH建议最小仓位（账户净值倍数）
+	BTCETHMaxPositionRatio  float64 `json:"btc_eth_max_position_ratio"` // BTC/ETH最大仓位（账户净值倍数，硬约束）
+}
+
+// DefaultValidationPolicy 默认验证策略
+var DefaultValidationPolicy = ValidationPolicy{
+	MinRiskRewardRatio:      3.0,
+	MaxPositions:            3,
+	MaxMarginUsagePct:       90,
+	MinOpenConfidence:       75,
+	AltcoinMinPositionRatio: 0.8,
+	AltcoinMaxPositionRatio: 1.5,
+	BTCETHMinPositionRatio:  5,
+	BTCETHMaxPositionRatio:  10,
+}
+
+// PromptData 提示词模板可访问的变量（text/template 渲染时传入）
+//
+// 模板示例:
+//
+//	账户净值: {{printf "%.2f" .AccountEquity}} USDT
+//	山寨币仓位上限: {{printf "%.0f" .AltcoinMaxPosition}} U ({{.AltcoinLeverage}}x)
+//	风险回报比 ≥ {{.Policy.MinRiskRewardRatio}}
+//
+// 硬约束和输出格式由共享片段 risk_limits 渲染并自动追加在模板之后，模板中无需再引用
+type PromptData struct {
+	TraderName      string           // 交易员名称
+	AccountEquity   float64          // 账户净值（USDT）
+	BTCETHLeverage  int              // BTC/ETH杠杆上限
+	AltcoinLeverage int              // 山寨币杠杆上限
+	Policy          ValidationPolicy // 验证策略
+	CurrentTime     string           // 当前时间（2006-01-02 15:04:05）
+	Now             time.Time        // 当前时间（可使用 .Now.Hour 等方法）
+	Locale          string           // 提示词语言（zh-CN/en）
+}
+
+// newPromptData 根据账户和配置构建模板变量
+func newPromptData(traderName string, accountEquity float64, btcEthLeverage, altcoinLeverage int, locale string) *PromptData {
+	now := time.Now()
+	return &PromptData{
+		TraderName:      traderName,
+		AccountEquity:   accountEquity,
+		BTCETHLeverage:  btcEthLeverage,
+		AltcoinLeverage: altcoinLeverage,
+		Policy:          DefaultValidationPolicy,
+		CurrentTime:     now.Format("2006-01-02 15:04:05"),
+		Now:             now,
+		Locale:          NormalizeLocale(locale),
+	}
+}
+
+// samplePromptData 加载模板时用于预渲染检查的示例数据（检测引用了不存在的变量等错误）
+func samplePromptData() *PromptData {
+	return newPromptData("sample_trader", 1000, 5, 5, DefaultLocale)
+}
+
+// AltcoinMinPosition 山寨币建议最小仓位（USDT）
+func (d *PromptData) AltcoinMinPosition() float64 {
+	return d.AccountEquity * d.Policy.AltcoinMinPositionRatio
+}
+
+// AltcoinMaxPosition 山寨币最大仓位（USDT）
+func (d *PromptData) AltcoinMaxPosition() float64 {
+	return d.AccountEquity * d.Policy.AltcoinMaxPositionRatio
+}
+
+// BTCETHMinPosition BTC/ETH建议最小仓位（USDT）
+func (d *PromptData) BTCETHMinPosition() float64 {
+	return d.AccountEquity * d.Policy.BTCETHMinPositionRatio
+}
+
+// BTCETHMaxPosition BTC/ETH最大仓位（USDT）
+func (d *PromptData) BTCETHMaxPosition() float64 {
+	return d.AccountEquity * d.Policy.BTCETHMaxPositionRatio
+}
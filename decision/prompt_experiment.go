@@ -0,0 +1,100 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+)
+
+// 提示词实验的周期分配方式
+const (
+	ExperimentModeAlternate = "alternate" // 按周期轮流使用各变体
+	ExperimentModeRandom    = "random"    // 每个周期随机选择变体
+)
+
+// PromptVariant 提示词实验中的一个变体
+type PromptVariant struct {
+	Name               string `json:"name"`                 // 变体名称（记录到决策日志，用于分组统计）
+	Template           string `json:"template"`             // 系统提示词模板名称（为空时使用交易员的模板）
+	CustomPrompt       string `json:"custom_prompt"`        // 该变体的自定义prompt（为空时使用交易员的自定义prompt）
+	OverrideBasePrompt bool   `json:"override_base_prompt"` // 是否用自定义prompt覆盖基础prompt（仅在设置了 CustomPrompt 时生效）
+}
+
+// PromptExperiment 提示词A/B实验（同一交易员在多个模板或自定义prompt之间分配周期）
+type PromptExperiment struct {
+	Mode     string          `json:"mode"`     // 分配方式: alternate/random（默认alternate）
+	Variants []PromptVariant `json:"variants"` // 参与实验的变体（至少2个）
+}
+
+// ParsePromptExperiment 解析并校验数据库中保存的实验配置（空字符串表示未启用实验）
+func ParsePromptExperiment(raw string) (*PromptExperiment, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var experiment PromptExperiment
+	if err := json.Unmarshal([]byte(raw), &experiment); err != nil {
+		return nil, fmt.Errorf("解析提示词实验配置失败: %w", err)
+	}
+	if err := experiment.Validate(); err != nil {
+		return nil, err
+	}
+
+	return &experiment, nil
+}
+
+// Validate 校验实验配置（未指定分配方式时默认轮流）
+func (e *PromptExperiment) Validate() error {
+	switch e.Mode {
+	case "":
+		e.Mode = ExperimentModeAlternate
+	case ExperimentModeAlternate, ExperimentModeRandom:
+	default:
+		return fmt.Errorf("不支持的实验分配方式: %s（可选 alternate/random）", e.Mode)
+	}
+
+	if len(e.Variants) < 2 {
+		return fmt.Errorf("提示词实验至少需要2个变体，当前 %d 个", len(e.Variants))
+	}
+
+	names := make(map[string]bool, len(e.Variants))
+	for i, variant := range e.Variants {
+		if variant.Name == "" {
+			return fmt.Errorf("第 %d 个变体缺少名称", i+1)
+		}
+		if names[variant.Name] {
+			return fmt.Errorf("变体名称重复: %s", variant.Name)
+		}
+		names[variant.Name] = true
+
+		if variant.OverrideBasePrompt && variant.CustomPrompt == "" {
+			return fmt.Errorf("变体 %s 设置了覆盖基础prompt但没有自定义prompt", variant.Name)
+		}
+	}
+
+	return nil
+}
+
+// SelectVariant 为指定周期选择变体（cycle 从1开始）
+func (e *PromptExperiment) SelectVariant(cycle int) *PromptVariant {
+	if len(e.Variants) == 0 {
+		return nil
+	}
+
+	if e.Mode == ExperimentModeRandom {
+		return &e.Variants[rand.Intn(len(e.Variants))]
+	}
+
+	index := (cycle - 1) % len(e.Variants)
+	if index < 0 {
+		index = 0
+	}
+	return &e.Variants[index]
+}
+
+// String 返回实验配置的JSON（用于保存到数据库）
+func (e *PromptExperiment) String() string {
+	data, _ := json.Marshal(e)
+	return string(data)
+}
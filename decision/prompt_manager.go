@@ -1,23 +1,31 @@
 package decision
 
 import (
+	"bytes"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"text/template"
 )
 
 // PromptTemplate 系统提示词模板
 type PromptTemplate struct {
-	Name    string // 模板名称（文件名，不含扩展名）
-	Content string // 模板内容
+	Name        string             // 模板名称（文件名，不含扩展名和语言标记）
+	Locale      string             // 模板语言（文件名 name.en.txt 标记的语言，未标记为 zh-CN）
+	Content     string             // 模板内容（text/template 源码）
+	Version     int                // 模板版本（数据库模板使用，文件模板为0）
+	RenderError string             // 加载时预渲染的错误（为空表示模板可用）
+	tmpl        *template.Template // 已解析的模板（包含共享片段）
 }
 
 // PromptManager 提示词管理器
 type PromptManager struct {
-	templates map[string]*PromptTemplate
+	templates map[string]*PromptTemplate    // 键为 templateKey(名称, 语言)
+	partials  map[string]*template.Template // 各语言的共享片段（prompts/partials/*.txt）
 	mu        sync.RWMutex
 }
 
@@ -26,8 +34,18 @@ var (
 	globalPromptManager *PromptManager
 	// promptsDir 提示词文件夹路径
 	promptsDir = "prompts"
+	// partialsDirName 共享片段子目录名（片段可在模板中通过 {{template "片段名" .}} 引用）
+	partialsDirName = "partials"
+	// riskLimitsPartial 硬约束和输出格式片段（buildSystemPrompt 在每个模板之后追加）
+	riskLimitsPartial = "risk_limits"
 )
 
+// promptFuncs 模板中可用的辅助函数
+var promptFuncs = template.FuncMap{
+	"mul": func(a, b float64) float64 { return a * b },
+	"pct": func(v float64) string { return fmt.Sprintf("%.0f%%", v) },
+}
+
 // init 包初始化时加载所有提示词模板
 func init() {
 	globalPromptManager = NewPromptManager()
@@ -46,24 +64,43 @@ func NewPromptManager() *PromptManager {
 }
 
 // LoadTemplates 从指定目录加载所有提示词模板
+// 模板和共享片段先加载到新的集合，加载成功后才替换当前集合（加载期间和加载失败时继续使用原有模板）
 func (pm *PromptManager) LoadTemplates(dir string) error {
+	templates, partials, err := loadTemplateSet(dir)
+	if err != nil {
+		return err
+	}
+
 	pm.mu.Lock()
-	defer pm.mu.Unlock()
+	pm.templates = templates
+	pm.partials = partials
+	pm.mu.Unlock()
+	return nil
+}
 
+// loadTemplateSet 从指定目录加载模板和共享片段（不修改管理器的状态）
+func loadTemplateSet(dir string) (map[string]*PromptTemplate, map[string]*template.Template, error) {
 	// 检查目录是否存在
 	if _, err := os.Stat(dir); os.IsNotExist(err) {
-		return fmt.Errorf("提示词目录不存在: %s", dir)
+		return nil, nil, fmt.Errorf("提示词目录不存在: %s", dir)
 	}
 
 	// 扫描目录中的所有 .txt 文件
 	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
 	if err != nil {
-		return fmt.Errorf("扫描提示词目录失败: %w", err)
+		return nil, nil, fmt.Errorf("扫描提示词目录失败: %w", err)
+	}
+
+	// 加载共享片段
+	partials, err := loadPartials(filepath.Join(dir, partialsDirName))
+	if err != nil {
+		return nil, nil, fmt.Errorf("加载共享片段失败: %w", err)
 	}
 
+	templates := make(map[string]*PromptTemplate, len(files))
 	if len(files) == 0 {
 		log.Printf("⚠️  提示词目录 %s 中没有找到 .txt 文件", dir)
-		return nil
+		return templates, partials, nil
 	}
 
 	// 加载每个模板文件
@@ -75,28 +112,214 @@ func (pm *PromptManager) LoadTemplates(dir string) error {
 			continue
 		}
 
-		// 提取文件名（不含扩展名）作为模板名称
+		// 提取文件名（不含扩展名和语言标记）作为模板名称
 		fileName := filepath.Base(file)
-		templateName := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+		templateName, locale := parseTemplateFileName(fileName)
 
-		// 存储模板
-		pm.templates[templateName] = &PromptTemplate{
+		// 解析并预渲染模板（检测语法错误和不存在的变量）
+		promptTemplate := &PromptTemplate{
 			Name:    templateName,
+			Locale:  locale,
 			Content: string(content),
 		}
+		promptTemplate.tmpl, err = parseWithPartials(partials, templateName, locale, promptTemplate.Content)
+		if err == nil {
+			sample := samplePromptData()
+			sample.Locale = locale
+			_, err = executeTemplate(promptTemplate.tmpl, sample)
+		}
+		if err != nil {
+			promptTemplate.RenderError = err.Error()
+			log.Printf("⚠️  提示词模板 %s [%s] 渲染检查失败: %v", templateName, locale, err)
+		}
+
+		// 存储模板
+		templates[templateKey(templateName, locale)] = promptTemplate
 
-		log.Printf("  📄 加载提示词模板: %s (%s)", templateName, fileName)
+		log.Printf("  📄 加载提示词模板: %s [%s] (%s)", templateName, locale, fileName)
 	}
 
-	return nil
+	return templates, partials, nil
+}
+
+// loadPartials 加载共享片段目录（目录不存在时返回空集合），返回每种语言一组片段
+// 未标记语言的片段对所有语言可用，标记了语言的同名片段（risk_limits.en.txt）在该语言下覆盖它
+func loadPartials(dir string) (map[string]*template.Template, error) {
+	partials := make(map[string]*template.Template, len(SupportedLocales))
+	for _, locale := range SupportedLocales {
+		partials[locale] = template.New("partials").Funcs(promptFuncs).Option("missingkey=error")
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.txt"))
+	if err != nil {
+		return nil, err
+	}
+
+	// 先解析默认语言的片段，再解析其他语言的片段覆盖同名片段
+	sort.SliceStable(files, func(i, j int) bool {
+		_, localeI := parseTemplateFileName(filepath.Base(files[i]))
+		_, localeJ := parseTemplateFileName(filepath.Base(files[j]))
+		return localeI == DefaultLocale && localeJ != DefaultLocale
+	})
+
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("读取片段文件失败 %s: %w", file, err)
+		}
+
+		fileName := filepath.Base(file)
+		partialName, locale := parseTemplateFileName(fileName)
+		for _, target := range SupportedLocales {
+			if locale != DefaultLocale && locale != target {
+				continue
+			}
+			if _, err := partials[target].New(partialName).Parse(string(content)); err != nil {
+				return nil, fmt.Errorf("解析片段 %s 失败: %w", fileName, err)
+			}
+		}
+
+		log.Printf("  🧩 加载共享片段: %s [%s] (%s)", partialName, locale, fileName)
+	}
+
+	return partials, nil
+}
+
+// parseTemplateFileName 从文件名解析模板名称和语言（default.en.txt -> default, en；default.txt -> default, zh-CN）
+func parseTemplateFileName(fileName string) (string, string) {
+	stem := strings.TrimSuffix(fileName, filepath.Ext(fileName))
+	if i := strings.LastIndex(stem, "."); i > 0 && isSupportedLocaleTag(stem[i+1:]) {
+		return stem[:i], NormalizeLocale(stem[i+1:])
+	}
+	return stem, DefaultLocale
+}
+
+// templateKey 模板在管理器中的键（默认语言为模板名称，其他语言为 名称.语言）
+func templateKey(name, locale string) string {
+	locale = NormalizeLocale(locale)
+	if locale == DefaultLocale {
+		return name
+	}
+	return name + "." + locale
+}
+
+// lookup 查找指定语言的模板，没有该语言版本时依次回退到默认语言版本、任一语言版本（调用方需持有读锁）
+func (pm *PromptManager) lookup(name, locale string) (*PromptTemplate, bool) {
+	if promptTemplate, exists := pm.templates[templateKey(name, locale)]; exists {
+		return promptTemplate, true
+	}
+	for _, supported := range SupportedLocales {
+		if promptTemplate, exists := pm.templates[templateKey(name, supported)]; exists {
+			return promptTemplate, true
+		}
+	}
+	return nil, false
+}
+
+// parseTemplate 在指定语言的共享片段基础上解析模板（调用方需持有读锁）
+func (pm *PromptManager) parseTemplate(name, locale, content string) (*template.Template, error) {
+	return parseWithPartials(pm.partials, name, locale, content)
+}
+
+// parseWithPartials 在给定共享片段集合中指定语言的片段基础上解析模板
+func parseWithPartials(partialSets map[string]*template.Template, name, locale, content string) (*template.Template, error) {
+	var base *template.Template
+	if partials, ok := partialSets[NormalizeLocale(locale)]; ok {
+		cloned, err := partials.Clone()
+		if err != nil {
+			return nil, err
+		}
+		base = cloned.New(name)
+	} else {
+		base = template.New(name).Funcs(promptFuncs).Option("missingkey=error")
+	}
+
+	return base.Parse(content)
+}
+
+// executeTemplate 渲染模板
+func executeTemplate(tmpl *template.Template, data *PromptData) (string, error) {
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// RenderTemplate 使用给定变量渲染指定模板（按 data.Locale 选择语言版本）
+func (pm *PromptManager) RenderTemplate(name string, data *PromptData) (string, error) {
+	pm.mu.RLock()
+	promptTemplate, exists := pm.lookup(name, data.Locale)
+	pm.mu.RUnlock()
+
+	if !exists {
+		return "", fmt.Errorf("提示词模板不存在: %s", name)
+	}
+	return promptTemplate.Render(data)
+}
+
+// RenderPartial 使用给定变量渲染共享片段（按 data.Locale 选择语言版本）
+func (pm *PromptManager) RenderPartial(name string, data *PromptData) (string, error) {
+	var tmpl *template.Template
+	pm.mu.RLock()
+	if partials, ok := pm.partials[NormalizeLocale(data.Locale)]; ok {
+		tmpl = partials.Lookup(name)
+	}
+	pm.mu.RUnlock()
+
+	if tmpl == nil {
+		return "", fmt.Errorf("共享片段不存在: %s", name)
+	}
+	return executeTemplate(tmpl, data)
+}
+
+// NewPromptTemplate 解析外部（数据库）提示词模板，使用当前已加载的共享片段并以示例数据预渲染
+// 模板存在语法错误或引用了不存在的变量时返回错误
+func (pm *PromptManager) NewPromptTemplate(name, content string, version int) (*PromptTemplate, error) {
+	pm.mu.RLock()
+	tmpl, err := pm.parseTemplate(name, DefaultLocale, content)
+	pm.mu.RUnlock()
+	if err != nil {
+		return nil, fmt.Errorf("解析提示词模板 %s 失败: %w", name, err)
+	}
+
+	if _, err := executeTemplate(tmpl, samplePromptData()); err != nil {
+		return nil, fmt.Errorf("提示词模板 %s 渲染检查失败: %w", name, err)
+	}
+
+	return &PromptTemplate{
+		Name:    name,
+		Locale:  DefaultLocale,
+		Content: content,
+		Version: version,
+		tmpl:    tmpl,
+	}, nil
 }
 
-// GetTemplate 获取指定名称的提示词模板
+// Render 使用给定变量渲染模板
+func (t *PromptTemplate) Render(data *PromptData) (string, error) {
+	if t.tmpl == nil {
+		return "", fmt.Errorf("提示词模板 %s 解析失败: %s", t.Name, t.RenderError)
+	}
+
+	rendered, err := executeTemplate(t.tmpl, data)
+	if err != nil {
+		return "", fmt.Errorf("渲染提示词模板 %s 失败: %w", t.Name, err)
+	}
+	return rendered, nil
+}
+
+// GetTemplate 获取指定名称的提示词模板（默认语言版本）
 func (pm *PromptManager) GetTemplate(name string) (*PromptTemplate, error) {
+	return pm.GetTemplateForLocale(name, DefaultLocale)
+}
+
+// GetTemplateForLocale 获取指定名称和语言的提示词模板（没有该语言版本时返回默认语言版本）
+func (pm *PromptManager) GetTemplateForLocale(name, locale string) (*PromptTemplate, error) {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
-	template, exists := pm.templates[name]
+	template, exists := pm.lookup(name, locale)
 	if !exists {
 		return nil, fmt.Errorf("提示词模板不存在: %s", name)
 	}
@@ -104,20 +327,24 @@ func (pm *PromptManager) GetTemplate(name string) (*PromptTemplate, error) {
 	return template, nil
 }
 
-// GetAllTemplateNames 获取所有模板名称列表
+// GetAllTemplateNames 获取所有模板名称列表（多语言版本只列出一次）
 func (pm *PromptManager) GetAllTemplateNames() []string {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
 
+	seen := make(map[string]bool, len(pm.templates))
 	names := make([]string, 0, len(pm.templates))
-	for name := range pm.templates {
-		names = append(names, name)
+	for _, template := range pm.templates {
+		if !seen[template.Name] {
+			seen[template.Name] = true
+			names = append(names, template.Name)
+		}
 	}
 
 	return names
 }
 
-// GetAllTemplates 获取所有模板
+// GetAllTemplates 获取所有模板（包含各语言版本）
 func (pm *PromptManager) GetAllTemplates() []*PromptTemplate {
 	pm.mu.RLock()
 	defer pm.mu.RUnlock()
@@ -130,13 +357,38 @@ func (pm *PromptManager) GetAllTemplates() []*PromptTemplate {
 	return templates
 }
 
-// ReloadTemplates 重新加载所有模板
-func (pm *PromptManager) ReloadTemplates(dir string) error {
-	pm.mu.Lock()
-	pm.templates = make(map[string]*PromptTemplate)
-	pm.mu.Unlock()
+// TemplateErrors 返回加载时渲染检查失败的模板（模板名[.语言] -> 错误信息）
+func (pm *PromptManager) TemplateErrors() map[string]string {
+	pm.mu.RLock()
+	defer pm.mu.RUnlock()
+
+	errors := make(map[string]string)
+	for name, promptTemplate := range pm.templates {
+		if promptTemplate.RenderError != "" {
+			errors[name] = promptTemplate.RenderError
+		}
+	}
 
-	return pm.LoadTemplates(dir)
+	return errors
+}
+
+// ReloadTemplates 重新加载所有模板，返回每个渲染失败模板的错误信息（加载失败时保留原有模板）
+func (pm *PromptManager) ReloadTemplates(dir string) (map[string]string, error) {
+	if err := pm.LoadTemplates(dir); err != nil {
+		return nil, err
+	}
+
+	templateErrors := pm.TemplateErrors()
+	if len(templateErrors) > 0 {
+		names := make([]string, 0, len(templateErrors))
+		for name := range templateErrors {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		log.Printf("⚠️  %d 个提示词模板渲染失败: %s", len(names), strings.Join(names, ", "))
+	}
+
+	return templateErrors, nil
 }
 
 // === 全局函数（供外部调用）===
@@ -146,6 +398,11 @@ func GetPromptTemplate(name string) (*PromptTemplate, error) {
 	return globalPromptManager.GetTemplate(name)
 }
 
+// GetPromptTemplateForLocale 获取指定名称和语言的提示词模板（全局函数）
+func GetPromptTemplateForLocale(name, locale string) (*PromptTemplate, error) {
+	return globalPromptManager.GetTemplateForLocale(name, locale)
+}
+
 // GetAllPromptTemplateNames 获取所有模板名称（全局函数）
 func GetAllPromptTemplateNames() []string {
 	return globalPromptManager.GetAllTemplateNames()
@@ -156,7 +413,17 @@ func GetAllPromptTemplates() []*PromptTemplate {
 	return globalPromptManager.GetAllTemplates()
 }
 
-// ReloadPromptTemplates 重新加载所有模板（全局函数）
-func ReloadPromptTemplates() error {
+// RenderPromptTemplate 渲染指定名称的提示词模板（全局函数）
+func RenderPromptTemplate(name string, data *PromptData) (string, error) {
+	return globalPromptManager.RenderTemplate(name, data)
+}
+
+// NewPromptTemplate 解析并校验外部（数据库）提示词模板（全局函数）
+func NewPromptTemplate(name, content string, version int) (*PromptTemplate, error) {
+	return globalPromptManager.NewPromptTemplate(name, content, version)
+}
+
+// ReloadPromptTemplates 重新加载所有模板，返回每个渲染失败模板的错误信息（全局函数）
+func ReloadPromptTemplates() (map[string]string, error) {
 	return globalPromptManager.ReloadTemplates(promptsDir)
 }
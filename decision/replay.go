@@ -0,0 +1,84 @@
+package decision
+
+import (
+	"fmt"
+	"nofx/mcp"
+	"time"
+)
+
+// ReplayInput 重放历史周期所需的输入（来自决策记录中保存的prompt）
+type ReplayInput struct {
+	TraderName      string  // 交易员名称（重新渲染模板时使用）
+	SystemPrompt    string  // 原始系统提示词
+	UserPrompt      string  // 原始输入prompt（包含当时的市场数据）
+	AccountEquity   float64 // 当时的账户净值（决策验证使用）
+	BTCETHLeverage  int     // BTC/ETH杠杆上限
+	AltcoinLeverage int     // 山寨币杠杆上限
+	Locale          string  // 提示词语言（重新渲染模板和验证错误使用）
+}
+
+// ReplayOptions 重放时替换的提示词（均为空时使用记录中的原始系统提示词）
+type ReplayOptions struct {
+	TemplateName       string          // 文件模板名称
+	PromptTemplate     *PromptTemplate // 数据库版本化模板（优先于文件模板）
+	CustomPrompt       string          // 自定义prompt
+	OverrideBasePrompt bool            // 是否只使用自定义prompt
+}
+
+// changesSystemPrompt 是否需要重新构建系统提示词
+func (o ReplayOptions) changesSystemPrompt() bool {
+	return o.TemplateName != "" || o.PromptTemplate != nil || o.CustomPrompt != ""
+}
+
+// ReplayDecision 把历史周期的prompt重新提交给指定AI模型（可替换模板），返回解析后的决策
+// 输入prompt保持不变，保证不同模型/模板面对完全相同的市场数据
+func ReplayDecision(input *ReplayInput, mcpClient *mcp.Client, opts ReplayOptions) (*FullDecision, error) {
+	if input.UserPrompt == "" {
+		return nil, fmt.Errorf("决策记录缺少输入prompt，无法重放")
+	}
+
+	systemPrompt := input.SystemPrompt
+	templateName := "original"
+	templateVersion := 0
+	if opts.changesSystemPrompt() {
+		promptData := newPromptData(input.TraderName, input.AccountEquity, input.BTCETHLeverage, input.AltcoinLeverage, input.Locale)
+		systemPrompt = buildSystemPromptWithCustom(promptData, opts.CustomPrompt, opts.OverrideBasePrompt, opts.TemplateName, opts.PromptTemplate)
+		if buildResponseFormat(mcpClient.OutputMode) != nil {
+			systemPrompt += buildStructuredOutputPrompt(input.Locale)
+		}
+		switch {
+		case opts.PromptTemplate != nil:
+			templateName = opts.PromptTemplate.Name
+			templateVersion = opts.PromptTemplate.Version
+		case opts.TemplateName != "":
+			templateName = opts.TemplateName
+		default:
+			templateName = "default"
+		}
+	}
+	if systemPrompt == "" {
+		return nil, fmt.Errorf("决策记录缺少系统提示词，请指定重放使用的模板")
+	}
+
+	messages := []mcp.Message{
+		{Role: "system", Content: systemPrompt},
+		{Role: "user", Content: input.UserPrompt},
+	}
+	aiResponse, err := callAI(mcpClient, messages)
+	if err != nil {
+		return nil, fmt.Errorf("%w: 调用AI API失败: %w", ErrAIUnavailable, err)
+	}
+
+	decision, err := parseFullDecisionResponse(aiResponse, input.AccountEquity, input.BTCETHLeverage, input.AltcoinLeverage, input.Locale)
+	decision.SystemPrompt = systemPrompt
+	decision.UserPrompt = input.UserPrompt
+	decision.RawResponse = aiResponse
+	decision.TemplateName = templateName
+	decision.TemplateVersion = templateVersion
+	decision.Source = DecisionSourceAI
+	decision.Timestamp = time.Now()
+	if err != nil {
+		return decision, fmt.Errorf("解析AI响应失败: %w", err)
+	}
+	return decision, nil
+}
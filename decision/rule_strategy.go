@@ -0,0 +1,414 @@
+package decision
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"nofx/market"
+	"strings"
+	"time"
+)
+
+// 交易员的决策方式
+const (
+	DecisionModeAI             = "ai"               // 仅使用AI决策（默认）
+	DecisionModeRule           = "rule"             // 仅使用规则策略（不调用AI，作为基准对照）
+	DecisionModeAIRuleFallback = "ai_rule_fallback" // 使用AI决策，AI服务不可用时回退到规则策略
+)
+
+// 决策来源（记录到决策日志）
+const (
+	DecisionSourceAI           = "ai"
+	DecisionSourceRule         = "rule"
+	DecisionSourceRuleFallback = "rule_fallback"
+)
+
+// 规则类型
+const (
+	RuleTypeSupertrend = "supertrend" // 指定时间框架的 Supertrend 趋势一致
+	RuleTypeEMATrend   = "ema_trend"  // 价格与EMA20的位置（可要求4小时EMA20与EMA50同向）
+	RuleTypeRSIFilter  = "rsi_filter" // RSI7超买时禁止做多，超卖时禁止做空
+	RuleTypeSignal     = "signal"     // 指定信号分析器给出的方向
+)
+
+// ErrAIUnavailable AI服务调用失败（网络错误、超时、服务端错误等）
+var ErrAIUnavailable = errors.New("AI服务不可用")
+
+// StrategyRule 规则策略中的一条规则（每条规则给出允许的开仓方向）
+type StrategyRule struct {
+	Type        string   `json:"type"`                   // 规则类型: supertrend/ema_trend/rsi_filter/signal
+	Timeframes  []string `json:"timeframes,omitempty"`   // supertrend: 需要趋势一致的时间框架（默认 15m,30m,1h）
+	UseLonger   bool     `json:"use_longer,omitempty"`   // ema_trend: 同时要求4小时EMA20与EMA50同向
+	Oversold    float64  `json:"oversold,omitempty"`     // rsi_filter: 超卖阈值（默认30）
+	Overbought  float64  `json:"overbought,omitempty"`   // rsi_filter: 超买阈值（默认70）
+	Analyzer    string   `json:"analyzer,omitempty"`     // signal: 信号分析器名称（需在交易员的分析器中启用）
+	MinStrength float64  `json:"min_strength,omitempty"` // signal: 最小信号强度（0-1）
+}
+
+// RuleStrategy 规则策略配置（不依赖LLM的确定性策略）
+type RuleStrategy struct {
+	Name            string         `json:"name"`              // 策略名称（记录到决策日志）
+	EntryRules      []StrategyRule `json:"entry_rules"`       // 开仓规则（全部规则允许同一方向时开仓）
+	ExitOnReversal  bool           `json:"exit_on_reversal"`  // 开仓规则只允许反向时平仓
+	PositionSizePct float64        `json:"position_size_pct"` // 单笔仓位价值占账户净值的百分比
+	Leverage        int            `json:"leverage"`          // 杠杆倍数（0=使用交易员配置的上限）
+	StopLossPct     float64        `json:"stop_loss_pct"`     // 止损距离百分比
+	TakeProfitPct   float64        `json:"take_profit_pct"`   // 止盈距离百分比
+	MaxPositions    int            `json:"max_positions"`     // 最多同时持仓数
+}
+
+// DefaultRuleStrategy 默认规则策略：15m/30m/1h Supertrend 一致 + RSI过滤
+func DefaultRuleStrategy() *RuleStrategy {
+	return &RuleStrategy{
+		Name: "supertrend_baseline",
+		EntryRules: []StrategyRule{
+			{Type: RuleTypeSupertrend, Timeframes: []string{"15m", "30m", "1h"}},
+			{Type: RuleTypeRSIFilter, Oversold: 30, Overbought: 70},
+		},
+		ExitOnReversal:  true,
+		PositionSizePct: 50,
+		StopLossPct:     1.5,
+		TakeProfitPct:   4.5,
+		MaxPositions:    3,
+	}
+}
+
+// ParseRuleStrategy 解析并校验数据库中保存的规则策略（空字符串表示使用默认策略）
+func ParseRuleStrategy(raw string) (*RuleStrategy, error) {
+	if strings.TrimSpace(raw) == "" {
+		return DefaultRuleStrategy(), nil
+	}
+
+	var strategy RuleStrategy
+	if err := json.Unmarshal([]byte(raw), &strategy); err != nil {
+		return nil, fmt.Errorf("解析规则策略失败: %w", err)
+	}
+	if err := strategy.Validate(); err != nil {
+		return nil, err
+	}
+	return &strategy, nil
+}
+
+// ValidateDecisionMode 校验决策方式（空字符串视为AI决策）
+func ValidateDecisionMode(mode string) error {
+	switch mode {
+	case "", DecisionModeAI, DecisionModeRule, DecisionModeAIRuleFallback:
+		return nil
+	}
+	return fmt.Errorf("不支持的决策方式: %s（可选 ai/rule/ai_rule_fallback）", mode)
+}
+
+// Validate 校验规则策略（未设置的参数使用默认值）
+func (s *RuleStrategy) Validate() error {
+	defaults := DefaultRuleStrategy()
+	if s.Name == "" {
+		s.Name = "custom"
+	}
+	if len(s.EntryRules) == 0 {
+		return fmt.Errorf("规则策略至少需要1条开仓规则")
+	}
+	if s.PositionSizePct <= 0 {
+		s.PositionSizePct = defaults.PositionSizePct
+	}
+	if s.StopLossPct <= 0 {
+		s.StopLossPct = defaults.StopLossPct
+	}
+	if s.TakeProfitPct <= 0 {
+		s.TakeProfitPct = defaults.TakeProfitPct
+	}
+	if s.MaxPositions <= 0 {
+		s.MaxPositions = defaults.MaxPositions
+	}
+	if s.Leverage < 0 {
+		return fmt.Errorf("杠杆倍数不能为负数: %d", s.Leverage)
+	}
+	if s.TakeProfitPct < s.StopLossPct*DefaultValidationPolicy.MinRiskRewardRatio {
+		return fmt.Errorf("止盈距离(%.2f%%)必须至少为止损距离(%.2f%%)的%.1f倍", s.TakeProfitPct, s.StopLossPct, DefaultValidationPolicy.MinRiskRewardRatio)
+	}
+
+	for i := range s.EntryRules {
+		rule := &s.EntryRules[i]
+		switch rule.Type {
+		case RuleTypeSupertrend:
+			if len(rule.Timeframes) == 0 {
+				rule.Timeframes = []string{"15m", "30m", "1h"}
+			}
+			for _, tf := range rule.Timeframes {
+				if _, ok := supertrendTimeframe(&market.SupertrendMultiTimeframe{}, tf); !ok {
+					return fmt.Errorf("规则 #%d: 不支持的时间框架 %s（可选 3m/5m/15m/30m/1h/4h）", i+1, tf)
+				}
+			}
+		case RuleTypeEMATrend:
+		case RuleTypeRSIFilter:
+			if rule.Oversold <= 0 {
+				rule.Oversold = 30
+			}
+			if rule.Overbought <= 0 {
+				rule.Overbought = 70
+			}
+			if rule.Oversold >= rule.Overbought {
+				return fmt.Errorf("规则 #%d: 超卖阈值必须小于超买阈值", i+1)
+			}
+		case RuleTypeSignal:
+			if _, exists := signalAnalyzerRegistry[rule.Analyzer]; !exists {
+				return fmt.Errorf("规则 #%d: 未知的信号分析器 %s", i+1, rule.Analyzer)
+			}
+		default:
+			return fmt.Errorf("规则 #%d: 不支持的规则类型 %s", i+1, rule.Type)
+		}
+	}
+	return nil
+}
+
+// ValidateAnalyzers 校验信号规则使用的分析器已在交易员中启用（configs为nil表示默认分析器）
+// 未启用的分析器不会产生信号，这样的规则永远不满足，策略将无法开仓
+func (s *RuleStrategy) ValidateAnalyzers(configs []SignalAnalyzerConfig) error {
+	if configs == nil {
+		configs = defaultSignalAnalyzerConfigs
+	}
+	enabled := make(map[string]bool, len(configs))
+	for _, cfg := range configs {
+		enabled[cfg.Name] = true
+	}
+	for i, rule := range s.EntryRules {
+		if rule.Type == RuleTypeSignal && !enabled[rule.Analyzer] {
+			return fmt.Errorf("规则 #%d: 信号分析器 %s 未在交易员中启用", i+1, rule.Analyzer)
+		}
+	}
+	return nil
+}
+
+// String 返回规则策略的JSON（用于保存到数据库和记录到决策日志）
+func (s *RuleStrategy) String() string {
+	data, _ := json.Marshal(s)
+	return string(data)
+}
+
+// GetRuleBasedDecision 按规则策略生成决策（不调用AI）
+// 决策与AI决策使用同样的验证逻辑，结果格式与 GetFullDecision 一致，可直接进入执行和日志流程
+func GetRuleBasedDecision(ctx *Context, strategy *RuleStrategy) (*FullDecision, error) {
+	if strategy == nil {
+		strategy = DefaultRuleStrategy()
+	}
+
+	// AI回退时市场数据已获取，无需重复请求
+	if ctx.MarketDataMap == nil {
+		if err := fetchMarketDataForContext(ctx); err != nil {
+			return nil, fmt.Errorf("获取市场数据失败: %w", err)
+		}
+	}
+	if ctx.SignalAnnotations == nil {
+		ctx.SignalAnnotations = runSignalAnalyzers(ctx)
+	}
+
+	decisions, reasoning := strategy.evaluate(ctx)
+	valid, rejected := validateDecisions(decisions, ctx.Account.TotalEquity, ctx.BTCETHLeverage, ctx.AltcoinLeverage, ctx.Locale)
+	log.Printf("📐 规则策略 %s: %d 个决策，%d 个未通过验证", strategy.Name, len(valid), len(rejected))
+
+	return &FullDecision{
+		SystemPrompt:      "规则策略: " + strategy.String(),
+		CoTTrace:          reasoning,
+		Decisions:         valid,
+		RejectedDecisions: rejected,
+		TemplateName:      "rule:" + strategy.Name,
+		Signals:           flattenSignalAnnotations(ctx, ctx.SignalAnnotations),
+		Source:            DecisionSourceRule,
+		Timestamp:         time.Now(),
+	}, nil
+}
+
+// evaluate 按规则生成决策，返回决策列表和规则判断过程（记录为思维链）
+func (s *RuleStrategy) evaluate(ctx *Context) ([]Decision, string) {
+	var trace strings.Builder
+	decisions := make([]Decision, 0)
+	held := make(map[string]bool)
+	openCount := len(ctx.Positions)
+
+	// 1. 持仓：开仓规则只允许反向时平仓
+	for _, pos := range ctx.Positions {
+		held[pos.Symbol] = true
+		data, hasData := ctx.MarketDataMap[pos.Symbol]
+		if !hasData {
+			trace.WriteString(fmt.Sprintf("%s %s: 无市场数据，继续持有\n", pos.Symbol, pos.Side))
+			continue
+		}
+
+		allowLong, allowShort, reasons := s.evaluateRules(ctx, pos.Symbol, data)
+		reversed := (pos.Side == "long" && allowShort && !allowLong) || (pos.Side == "short" && allowLong && !allowShort)
+		if s.ExitOnReversal && reversed {
+			decisions = append(decisions, Decision{
+				Symbol:    pos.Symbol,
+				Action:    "close_" + pos.Side,
+				Reasoning: "规则反转: " + strings.Join(reasons, "；"),
+			})
+			openCount--
+			trace.WriteString(fmt.Sprintf("%s %s: 规则反转，平仓（%s）\n", pos.Symbol, pos.Side, strings.Join(reasons, "；")))
+			continue
+		}
+
+		decisions = append(decisions, Decision{Symbol: pos.Symbol, Action: "hold", Reasoning: "规则未反转，继续持有"})
+		trace.WriteString(fmt.Sprintf("%s %s: 继续持有（%s）\n", pos.Symbol, pos.Side, strings.Join(reasons, "；")))
+	}
+
+	// 2. 候选币种：按排名顺序，全部规则允许同一方向时开仓
+	for _, coin := range ctx.CandidateCoins {
+		if openCount >= s.MaxPositions {
+			trace.WriteString(fmt.Sprintf("已达到最大持仓数 %d，停止开仓\n", s.MaxPositions))
+			break
+		}
+		if held[coin.Symbol] {
+			continue
+		}
+		data, hasData := ctx.MarketDataMap[coin.Symbol]
+		if !hasData || data.CurrentPrice <= 0 {
+			continue
+		}
+		held[coin.Symbol] = true
+
+		allowLong, allowShort, reasons := s.evaluateRules(ctx, coin.Symbol, data)
+		if allowLong == allowShort {
+			trace.WriteString(fmt.Sprintf("%s: 规则未给出明确方向，观望（%s）\n", coin.Symbol, strings.Join(reasons, "；")))
+			continue
+		}
+
+		d := s.buildOpenDecision(ctx, coin.Symbol, data.CurrentPrice, allowLong)
+		d.Reasoning = strings.Join(reasons, "；")
+		decisions = append(decisions, d)
+		openCount++
+		trace.WriteString(fmt.Sprintf("%s: %s（%s）\n", coin.Symbol, d.Action, d.Reasoning))
+	}
+
+	if len(decisions) == 0 {
+		decisions = append(decisions, Decision{Symbol: "ALL", Action: "wait", Reasoning: "没有满足规则的交易机会"})
+	}
+	return decisions, trace.String()
+}
+
+// evaluateRules 依次评估开仓规则，返回全部规则是否允许做多/做空及每条规则的判断说明
+func (s *RuleStrategy) evaluateRules(ctx *Context, symbol string, data *market.Data) (bool, bool, []string) {
+	allowLong, allowShort := true, true
+	reasons := make([]string, 0, len(s.EntryRules))
+	for _, rule := range s.EntryRules {
+		long, short, reason := rule.evaluate(ctx.SignalAnnotations[symbol], data)
+		allowLong = allowLong && long
+		allowShort = allowShort && short
+		reasons = append(reasons, reason)
+	}
+	return allowLong, allowShort, reasons
+}
+
+// evaluate 评估单条规则，返回是否允许做多、是否允许做空及判断说明
+func (r *StrategyRule) evaluate(annotations []SignalAnnotation, data *market.Data) (bool, bool, string) {
+	switch r.Type {
+	case RuleTypeSupertrend:
+		if data.SupertrendData == nil {
+			return false, false, "Supertrend 数据未计算"
+		}
+		ups, downs := 0, 0
+		for _, tf := range r.Timeframes {
+			st, _ := supertrendTimeframe(data.SupertrendData, tf)
+			if st == nil {
+				continue
+			}
+			switch st.Trend {
+			case "up":
+				ups++
+			case "down":
+				downs++
+			}
+		}
+		timeframes := strings.Join(r.Timeframes, "/")
+		switch {
+		case ups == len(r.Timeframes):
+			return true, false, fmt.Sprintf("Supertrend %s 全部向上", timeframes)
+		case downs == len(r.Timeframes):
+			return false, true, fmt.Sprintf("Supertrend %s 全部向下", timeframes)
+		}
+		return false, false, fmt.Sprintf("Supertrend %s 不一致(上%d/下%d)", timeframes, ups, downs)
+
+	case RuleTypeEMATrend:
+		long := data.CurrentPrice > data.CurrentEMA20
+		short := data.CurrentPrice < data.CurrentEMA20
+		reason := fmt.Sprintf("价格%.4f vs EMA20 %.4f", data.CurrentPrice, data.CurrentEMA20)
+		if r.UseLonger {
+			if data.LongerTermContext == nil {
+				return false, false, "4小时EMA数据未计算"
+			}
+			long = long && data.LongerTermContext.EMA20 > data.LongerTermContext.EMA50
+			short = short && data.LongerTermContext.EMA20 < data.LongerTermContext.EMA50
+			reason += fmt.Sprintf("，4h EMA20 %.4f vs EMA50 %.4f", data.LongerTermContext.EMA20, data.LongerTermContext.EMA50)
+		}
+		return long, short, reason
+
+	case RuleTypeRSIFilter:
+		rsi := data.CurrentRSI7
+		return rsi < r.Overbought, rsi > r.Oversold, fmt.Sprintf("RSI7 %.1f（超卖%.0f/超买%.0f）", rsi, r.Oversold, r.Overbought)
+
+	case RuleTypeSignal:
+		for _, annotation := range annotations {
+			if annotation.Analyzer != r.Analyzer || annotation.Strength <= 0 || annotation.Strength < r.MinStrength {
+				continue
+			}
+			reason := fmt.Sprintf("%s信号 %s(强度%.2f)", r.Analyzer, annotation.Direction, annotation.Strength)
+			switch annotation.Direction {
+			case SignalDirectionLong:
+				return true, false, reason
+			case SignalDirectionShort:
+				return false, true, reason
+			}
+		}
+		return false, false, fmt.Sprintf("%s无信号", r.Analyzer)
+	}
+
+	return false, false, "未知规则: " + r.Type
+}
+
+// buildOpenDecision 按策略的仓位和止损止盈参数生成开仓决策
+func (s *RuleStrategy) buildOpenDecision(ctx *Context, symbol string, price float64, long bool) Decision {
+	maxLeverage := ctx.AltcoinLeverage
+	if symbol == "BTCUSDT" || symbol == "ETHUSDT" {
+		maxLeverage = ctx.BTCETHLeverage
+	}
+	leverage := maxLeverage
+	if s.Leverage > 0 && s.Leverage < maxLeverage {
+		leverage = s.Leverage
+	}
+
+	d := Decision{
+		Symbol:          symbol,
+		Leverage:        leverage,
+		PositionSizeUSD: ctx.Account.TotalEquity * s.PositionSizePct / 100,
+		Confidence:      100,
+	}
+	if long {
+		d.Action = "open_long"
+		d.StopLoss = price * (1 - s.StopLossPct/100)
+		d.TakeProfit = price * (1 + s.TakeProfitPct/100)
+	} else {
+		d.Action = "open_short"
+		d.StopLoss = price * (1 + s.StopLossPct/100)
+		d.TakeProfit = price * (1 - s.TakeProfitPct/100)
+	}
+	d.RiskUSD = d.PositionSizeUSD * s.StopLossPct / 100
+	return d
+}
+
+// supertrendTimeframe 按名称获取 Supertrend 时间框架数据
+func supertrendTimeframe(st *market.SupertrendMultiTimeframe, timeframe string) (*market.SupertrendData, bool) {
+	switch timeframe {
+	case "3m":
+		return st.Timeframe3m, true
+	case "5m":
+		return st.Timeframe5m, true
+	case "15m":
+		return st.Timeframe15m, true
+	case "30m":
+		return st.Timeframe30m, true
+	case "1h":
+		return st.Timeframe1h, true
+	case "4h":
+		return st.Timeframe4h, true
+	}
+	return nil, false
+}
@@ -0,0 +1,240 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"nofx/market"
+	"sort"
+	"strings"
+)
+
+// 信号方向
+const (
+	SignalDirectionLong    = "long"
+	SignalDirectionShort   = "short"
+	SignalDirectionNeutral = "neutral"
+)
+
+// SignalInput 信号分析器的输入（单个币种）
+type SignalInput struct {
+	Symbol string
+	Data   *market.Data
+	OITop  *OITopData // OI Top数据（不在OI Top榜单时为nil）
+	Locale string     // 提示词语言（Message 按该语言输出）
+}
+
+// SignalAnnotation 信号分析器的输出（写入prompt，并结构化记录到决策日志）
+type SignalAnnotation struct {
+	Analyzer  string             `json:"analyzer"`         // 分析器名称
+	Symbol    string             `json:"symbol"`           // 币种
+	Direction string             `json:"direction"`        // long/short/neutral
+	Strength  float64            `json:"strength"`         // 信号强度 0-1（0表示无信号，仅为状态说明）
+	Message   string             `json:"message"`          // 写入prompt的说明文字
+	Values    map[string]float64 `json:"values,omitempty"` // 关键数值（用于事后评估）
+}
+
+// SignalAnalyzer 信号分析器（基于市场数据生成交易信号注释）
+type SignalAnalyzer interface {
+	// Name 分析器名称（与注册表中的名称一致）
+	Name() string
+	// Analyze 分析单个币种，没有可报告的内容时返回nil
+	Analyze(input *SignalInput) *SignalAnnotation
+}
+
+// SignalAnalyzerFactory 根据参数创建分析器（参数已合并默认值）
+type SignalAnalyzerFactory func(params map[string]float64) SignalAnalyzer
+
+// SignalAnalyzerInfo 已注册分析器的说明
+type SignalAnalyzerInfo struct {
+	Name          string             `json:"name"`
+	Description   string             `json:"description"`
+	DefaultParams map[string]float64 `json:"default_params"` // 可配置参数及默认值
+}
+
+// SignalAnalyzerConfig 交易员启用的分析器及参数
+type SignalAnalyzerConfig struct {
+	Name   string             `json:"name"`
+	Params map[string]float64 `json:"params,omitempty"` // 覆盖默认参数（未设置的使用默认值）
+}
+
+type signalAnalyzerEntry struct {
+	info    SignalAnalyzerInfo
+	factory SignalAnalyzerFactory
+}
+
+// signalAnalyzerRegistry 分析器注册表
+var signalAnalyzerRegistry = make(map[string]*signalAnalyzerEntry)
+
+// defaultSignalAnalyzerConfigs 交易员未配置分析器时启用的分析器（保持原有的Supertrend信号）
+var defaultSignalAnalyzerConfigs = []SignalAnalyzerConfig{{Name: "supertrend"}}
+
+// RegisterSignalAnalyzer 注册信号分析器（同名分析器会被覆盖）
+func RegisterSignalAnalyzer(info SignalAnalyzerInfo, factory SignalAnalyzerFactory) {
+	if info.DefaultParams == nil {
+		info.DefaultParams = map[string]float64{}
+	}
+	signalAnalyzerRegistry[info.Name] = &signalAnalyzerEntry{info: info, factory: factory}
+}
+
+// GetSignalAnalyzerInfos 获取所有已注册分析器的说明（按名称排序）
+func GetSignalAnalyzerInfos() []SignalAnalyzerInfo {
+	infos := make([]SignalAnalyzerInfo, 0, len(signalAnalyzerRegistry))
+	for _, entry := range signalAnalyzerRegistry {
+		infos = append(infos, entry.info)
+	}
+	sort.Slice(infos, func(i, j int) bool {
+		return infos[i].Name < infos[j].Name
+	})
+	return infos
+}
+
+// ParseSignalAnalyzerConfigs 解析并校验数据库中保存的分析器配置（空字符串表示使用默认分析器）
+func ParseSignalAnalyzerConfigs(raw string) ([]SignalAnalyzerConfig, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var configs []SignalAnalyzerConfig
+	if err := json.Unmarshal([]byte(raw), &configs); err != nil {
+		return nil, fmt.Errorf("解析信号分析器配置失败: %w", err)
+	}
+	if err := ValidateSignalAnalyzerConfigs(configs); err != nil {
+		return nil, err
+	}
+
+	return configs, nil
+}
+
+// ValidateSignalAnalyzerConfigs 校验分析器配置（名称已注册、不重复、参数名和参数值有效）
+func ValidateSignalAnalyzerConfigs(configs []SignalAnalyzerConfig) error {
+	names := make(map[string]bool, len(configs))
+	for _, cfg := range configs {
+		entry, exists := signalAnalyzerRegistry[cfg.Name]
+		if !exists {
+			return fmt.Errorf("未知的信号分析器: %s", cfg.Name)
+		}
+		if names[cfg.Name] {
+			return fmt.Errorf("信号分析器重复: %s", cfg.Name)
+		}
+		names[cfg.Name] = true
+
+		for key, value := range cfg.Params {
+			if _, ok := entry.info.DefaultParams[key]; !ok {
+				return fmt.Errorf("信号分析器 %s 不支持参数: %s", cfg.Name, key)
+			}
+			if err := validateSignalParam(key, value); err != nil {
+				return fmt.Errorf("信号分析器 %s 参数 %s 无效: %w", cfg.Name, key, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validateSignalParam 校验参数值：lookback 类参数（名称为 lookback 或以 _lookback 结尾）必须是正整数，其余阈值参数必须大于0
+// 阈值为0或负数时信号强度的计算会得到 NaN/Inf，导致决策日志无法序列化
+func validateSignalParam(key string, value float64) error {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("必须是有限数值")
+	}
+	if key == "lookback" || strings.HasSuffix(key, "_lookback") {
+		if value < 1 || value != math.Trunc(value) {
+			return fmt.Errorf("必须是正整数（当前 %v）", value)
+		}
+		return nil
+	}
+	if value <= 0 {
+		return fmt.Errorf("必须大于0（当前 %v）", value)
+	}
+	return nil
+}
+
+// BuildSignalAnalyzers 根据配置创建分析器（configs为nil时使用默认分析器，空列表表示全部禁用）
+func BuildSignalAnalyzers(configs []SignalAnalyzerConfig) ([]SignalAnalyzer, error) {
+	if configs == nil {
+		configs = defaultSignalAnalyzerConfigs
+	}
+	if err := ValidateSignalAnalyzerConfigs(configs); err != nil {
+		return nil, err
+	}
+
+	analyzers := make([]SignalAnalyzer, 0, len(configs))
+	for _, cfg := range configs {
+		entry := signalAnalyzerRegistry[cfg.Name]
+		params := make(map[string]float64, len(entry.info.DefaultParams))
+		for key, value := range entry.info.DefaultParams {
+			params[key] = value
+		}
+		for key, value := range cfg.Params {
+			params[key] = value
+		}
+		analyzers = append(analyzers, entry.factory(params))
+	}
+	return analyzers, nil
+}
+
+// runSignalAnalyzers 对所有候选币种运行分析器（未配置分析器时使用默认分析器）
+func runSignalAnalyzers(ctx *Context) map[string][]SignalAnnotation {
+	analyzers := ctx.SignalAnalyzers
+	if analyzers == nil {
+		analyzers, _ = BuildSignalAnalyzers(nil)
+	}
+
+	annotations := make(map[string][]SignalAnnotation)
+	for _, coin := range ctx.CandidateCoins {
+		data, hasData := ctx.MarketDataMap[coin.Symbol]
+		if !hasData {
+			continue
+		}
+		if _, done := annotations[coin.Symbol]; done {
+			continue
+		}
+
+		input := &SignalInput{Symbol: coin.Symbol, Data: data, Locale: ctx.Locale}
+		if ctx.OITopDataMap != nil {
+			input.OITop = ctx.OITopDataMap[coin.Symbol]
+		}
+
+		list := make([]SignalAnnotation, 0, len(analyzers))
+		for _, analyzer := range analyzers {
+			annotation := analyzer.Analyze(input)
+			if annotation == nil {
+				continue
+			}
+			annotation.Analyzer = analyzer.Name()
+			annotation.Symbol = coin.Symbol
+			if annotation.Direction == "" {
+				annotation.Direction = SignalDirectionNeutral
+			}
+			list = append(list, *annotation)
+		}
+		annotations[coin.Symbol] = list
+	}
+	return annotations
+}
+
+// flattenSignalAnnotations 按候选币种顺序展开信号注释（用于决策日志）
+func flattenSignalAnnotations(ctx *Context, annotations map[string][]SignalAnnotation) []SignalAnnotation {
+	flat := make([]SignalAnnotation, 0)
+	seen := make(map[string]bool)
+	for _, coin := range ctx.CandidateCoins {
+		if seen[coin.Symbol] {
+			continue
+		}
+		seen[coin.Symbol] = true
+		flat = append(flat, annotations[coin.Symbol]...)
+	}
+	return flat
+}
+
+// formatSignalAnnotations 把单个币种的信号注释格式化为prompt文本
+func formatSignalAnnotations(annotations []SignalAnnotation) string {
+	var sb strings.Builder
+	for _, annotation := range annotations {
+		sb.WriteString(annotation.Message)
+		if !strings.HasSuffix(annotation.Message, "\n") {
+			sb.WriteString("\n")
+		}
+	}
+	return sb.String()
+}
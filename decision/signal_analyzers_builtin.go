@@ -0,0 +1,294 @@
+package decision
+
+import (
+	"math"
+	"strings"
+)
+
+func init() {
+	RegisterSignalAnalyzer(SignalAnalyzerInfo{
+		Name:        "supertrend",
+		Description: "Supertrend 多时间框架共振 + 量价关系（原有开仓信号）",
+	}, func(params map[string]float64) SignalAnalyzer {
+		return &supertrendAnalyzer{}
+	})
+
+	RegisterSignalAnalyzer(SignalAnalyzerInfo{
+		Name:        "rsi_divergence",
+		Description: "价格创新高/新低而RSI14未同步（顶背离/底背离）",
+		DefaultParams: map[string]float64{
+			"lookback":  10, // 比较的日内数据点数（3分钟K线）
+			"min_delta": 3,  // RSI背离的最小差值
+		},
+	}, func(params map[string]float64) SignalAnalyzer {
+		return &rsiDivergenceAnalyzer{lookback: int(params["lookback"]), minDelta: params["min_delta"]}
+	})
+
+	RegisterSignalAnalyzer(SignalAnalyzerInfo{
+		Name:        "volume_breakout",
+		Description: "放量突破最近区间高点/低点",
+		DefaultParams: map[string]float64{
+			"lookback":     10,  // 区间的日内数据点数（3分钟K线）
+			"volume_ratio": 2.0, // 3分钟成交量比率阈值
+		},
+	}, func(params map[string]float64) SignalAnalyzer {
+		return &volumeBreakoutAnalyzer{lookback: int(params["lookback"]), volumeRatio: params["volume_ratio"]}
+	})
+
+	RegisterSignalAnalyzer(SignalAnalyzerInfo{
+		Name:        "funding_extreme",
+		Description: "资金费率极端（多空一方过度拥挤，反向提示）",
+		DefaultParams: map[string]float64{
+			"threshold": 0.0005, // 资金费率绝对值阈值（0.05%）
+		},
+	}, func(params map[string]float64) SignalAnalyzer {
+		return &fundingExtremeAnalyzer{threshold: params["threshold"]}
+	})
+
+	RegisterSignalAnalyzer(SignalAnalyzerInfo{
+		Name:        "oi_surge",
+		Description: "持仓量1小时内快速增长（结合价格方向判断新增多/空）",
+		DefaultParams: map[string]float64{
+			"min_oi_change": 5, // 持仓量1小时变化百分比阈值
+		},
+	}, func(params map[string]float64) SignalAnalyzer {
+		return &oiSurgeAnalyzer{minChange: params["min_oi_change"]}
+	})
+}
+
+// supertrendAnalyzer Supertrend 多时间框架信号（输出完整的状态说明）
+type supertrendAnalyzer struct{}
+
+func (a *supertrendAnalyzer) Name() string { return "supertrend" }
+
+func (a *supertrendAnalyzer) Analyze(input *SignalInput) *SignalAnnotation {
+	marketData := input.Data
+	if marketData.SupertrendData == nil {
+		return &SignalAnnotation{Message: localize(input.Locale, "⚠️  Supertrend 数据为 nil（数据未计算）\n\n")}
+	}
+	if marketData.VolumePriceData == nil {
+		return &SignalAnnotation{Message: localize(input.Locale, "⚠️  量价关系数据为 nil（数据未计算）\n\n")}
+	}
+
+	var sb strings.Builder
+	// 显示 Supertrend 状态信息（短期策略：3m、5m、15m为核心，30m、1h、4h为参考）
+	sb.WriteString(localize(input.Locale, "📊 Supertrend 多时间框架分析:\n"))
+	st := marketData.SupertrendData
+	if st.Timeframe3m != nil {
+		sb.WriteString(localizef(input.Locale, "  3m (关键): %s (信号: %s) - 短期获利依赖3分钟信号\n", st.Timeframe3m.Trend, st.Timeframe3m.Signal))
+	}
+	if st.Timeframe5m != nil {
+		sb.WriteString(localizef(input.Locale, "  5m (核心): %s (信号: %s)\n", st.Timeframe5m.Trend, st.Timeframe5m.Signal))
+	}
+	if st.Timeframe15m != nil {
+		sb.WriteString(localizef(input.Locale, "  15m (核心): %s (信号: %s)\n", st.Timeframe15m.Trend, st.Timeframe15m.Signal))
+	}
+	if st.Timeframe30m != nil {
+		sb.WriteString(localizef(input.Locale, "  30m (确认): %s (信号: %s)\n", st.Timeframe30m.Trend, st.Timeframe30m.Signal))
+	}
+	if st.Timeframe1h != nil {
+		sb.WriteString(localizef(input.Locale, "  1h (大趋势): %s (信号: %s)\n", st.Timeframe1h.Trend, st.Timeframe1h.Signal))
+	}
+	if st.Timeframe4h != nil {
+		sb.WriteString(localizef(input.Locale, "  4h (参考): %s (信号: %s)\n", st.Timeframe4h.Trend, st.Timeframe4h.Signal))
+	}
+
+	// 显示量价关系
+	vp := marketData.VolumePriceData
+	sb.WriteString(localizef(input.Locale, "  量价关系: %v (成交量比率: %.2f)\n", vp.PriceVolumeOK, vp.VolumeRatio3m))
+
+	annotation := &SignalAnnotation{
+		Direction: SignalDirectionNeutral,
+		Values:    map[string]float64{"volume_ratio_3m": vp.VolumeRatio3m},
+	}
+
+	// 分析交易信号（传入完整市场数据以判断短期盈利优势）
+	signal, direction := analyzeSupertrendSignal(st, vp, marketData, input.Locale)
+	if signal != "" {
+		sb.WriteString(localizef(input.Locale, "  ✅ 交易信号: %s\n\n", signal))
+		annotation.Strength = 1
+		annotation.Direction = direction
+	} else {
+		sb.WriteString(localize(input.Locale, "  ⚠️  当前不满足开仓条件（需要5m+15m一致，或15m+30m一致，或5m+30m一致，且1h或4h大趋势至少一个支持）\n\n"))
+	}
+
+	annotation.Message = sb.String()
+	return annotation
+}
+
+// rsiDivergenceAnalyzer RSI背离：价格创新高而RSI走低（顶背离），或价格创新低而RSI走高（底背离）
+type rsiDivergenceAnalyzer struct {
+	lookback int
+	minDelta float64
+}
+
+func (a *rsiDivergenceAnalyzer) Name() string { return "rsi_divergence" }
+
+func (a *rsiDivergenceAnalyzer) Analyze(input *SignalInput) *SignalAnnotation {
+	series := input.Data.IntradaySeries
+	if series == nil || a.lookback < 3 {
+		return nil
+	}
+	n := len(series.MidPrices)
+	if len(series.RSI14Values) < n {
+		n = len(series.RSI14Values)
+	}
+	if n < a.lookback {
+		return nil
+	}
+	prices := series.MidPrices[len(series.MidPrices)-a.lookback:]
+	rsis := series.RSI14Values[len(series.RSI14Values)-a.lookback:]
+
+	// 最新数据点与之前区间的极值点比较
+	last := len(prices) - 1
+	highIdx, lowIdx := 0, 0
+	for i := 1; i < last; i++ {
+		if prices[i] > prices[highIdx] {
+			highIdx = i
+		}
+		if prices[i] < prices[lowIdx] {
+			lowIdx = i
+		}
+	}
+
+	if prices[last] > prices[highIdx] && rsis[highIdx]-rsis[last] >= a.minDelta {
+		delta := rsis[highIdx] - rsis[last]
+		return &SignalAnnotation{
+			Direction: SignalDirectionShort,
+			Strength:  math.Min(delta/(a.minDelta*3), 1),
+			Message: localizef(input.Locale, "🔎 RSI顶背离: 价格创%d根K线新高(%.4f > %.4f)，RSI14却下降(%.1f → %.1f)，上涨动能减弱",
+				a.lookback, prices[last], prices[highIdx], rsis[highIdx], rsis[last]),
+			Values: map[string]float64{"price": prices[last], "prev_high": prices[highIdx], "rsi": rsis[last], "prev_rsi": rsis[highIdx]},
+		}
+	}
+
+	if prices[last] < prices[lowIdx] && rsis[last]-rsis[lowIdx] >= a.minDelta {
+		delta := rsis[last] - rsis[lowIdx]
+		return &SignalAnnotation{
+			Direction: SignalDirectionLong,
+			Strength:  math.Min(delta/(a.minDelta*3), 1),
+			Message: localizef(input.Locale, "🔎 RSI底背离: 价格创%d根K线新低(%.4f < %.4f)，RSI14却上升(%.1f → %.1f)，下跌动能减弱",
+				a.lookback, prices[last], prices[lowIdx], rsis[lowIdx], rsis[last]),
+			Values: map[string]float64{"price": prices[last], "prev_low": prices[lowIdx], "rsi": rsis[last], "prev_rsi": rsis[lowIdx]},
+		}
+	}
+
+	return nil
+}
+
+// volumeBreakoutAnalyzer 放量突破：价格突破最近区间且3分钟成交量比率超过阈值
+type volumeBreakoutAnalyzer struct {
+	lookback    int
+	volumeRatio float64
+}
+
+func (a *volumeBreakoutAnalyzer) Name() string { return "volume_breakout" }
+
+func (a *volumeBreakoutAnalyzer) Analyze(input *SignalInput) *SignalAnnotation {
+	data := input.Data
+	if data.IntradaySeries == nil || data.VolumePriceData == nil || a.lookback < 2 {
+		return nil
+	}
+	prices := data.IntradaySeries.MidPrices
+	if len(prices) < a.lookback+1 {
+		return nil
+	}
+	ratio := data.VolumePriceData.VolumeRatio3m
+	if ratio < a.volumeRatio {
+		return nil
+	}
+
+	// 区间不包含最新数据点
+	window := prices[len(prices)-a.lookback-1 : len(prices)-1]
+	high, low := window[0], window[0]
+	for _, p := range window {
+		high = math.Max(high, p)
+		low = math.Min(low, p)
+	}
+	price := prices[len(prices)-1]
+	values := map[string]float64{"price": price, "range_high": high, "range_low": low, "volume_ratio_3m": ratio}
+	strength := math.Min(ratio/(a.volumeRatio*2), 1)
+
+	switch {
+	case price > high:
+		return &SignalAnnotation{
+			Direction: SignalDirectionLong,
+			Strength:  strength,
+			Message:   localizef(input.Locale, "🔎 放量向上突破: 价格%.4f突破%d根K线高点%.4f，成交量比率%.2f", price, a.lookback, high, ratio),
+			Values:    values,
+		}
+	case price < low:
+		return &SignalAnnotation{
+			Direction: SignalDirectionShort,
+			Strength:  strength,
+			Message:   localizef(input.Locale, "🔎 放量向下突破: 价格%.4f跌破%d根K线低点%.4f，成交量比率%.2f", price, a.lookback, low, ratio),
+			Values:    values,
+		}
+	}
+	return nil
+}
+
+// fundingExtremeAnalyzer 资金费率极端：正费率过高说明多头拥挤（偏空），负费率过低说明空头拥挤（偏多）
+type fundingExtremeAnalyzer struct {
+	threshold float64
+}
+
+func (a *fundingExtremeAnalyzer) Name() string { return "funding_extreme" }
+
+func (a *fundingExtremeAnalyzer) Analyze(input *SignalInput) *SignalAnnotation {
+	rate := input.Data.FundingRate
+	if a.threshold <= 0 || math.Abs(rate) < a.threshold {
+		return nil
+	}
+
+	annotation := &SignalAnnotation{
+		Strength: math.Min(math.Abs(rate)/(a.threshold*3), 1),
+		Values:   map[string]float64{"funding_rate": rate},
+	}
+	if rate > 0 {
+		annotation.Direction = SignalDirectionShort
+		annotation.Message = localizef(input.Locale, "🔎 资金费率极高: %.4f%%，多头拥挤，追多需谨慎（警惕多头踩踏）", rate*100)
+	} else {
+		annotation.Direction = SignalDirectionLong
+		annotation.Message = localizef(input.Locale, "🔎 资金费率极低: %.4f%%，空头拥挤，追空需谨慎（警惕轧空）", rate*100)
+	}
+	return annotation
+}
+
+// oiSurgeAnalyzer 持仓量激增：OI增长 + 价格上涨 = 新增多头，OI增长 + 价格下跌 = 新增空头
+type oiSurgeAnalyzer struct {
+	minChange float64
+}
+
+func (a *oiSurgeAnalyzer) Name() string { return "oi_surge" }
+
+func (a *oiSurgeAnalyzer) Analyze(input *SignalInput) *SignalAnnotation {
+	// 持仓量变化只有OI Top数据提供（market.Data 中的平均值为近似值）
+	if input.OITop == nil || input.OITop.OIDeltaPercent < a.minChange {
+		return nil
+	}
+
+	oiChange := input.OITop.OIDeltaPercent
+	priceChange := input.OITop.PriceDeltaPercent
+	annotation := &SignalAnnotation{
+		Strength: math.Min(oiChange/(a.minChange*3), 1),
+		Values: map[string]float64{
+			"oi_change_pct":    oiChange,
+			"price_change_pct": priceChange,
+			"oi_rank":          float64(input.OITop.Rank),
+		},
+	}
+
+	switch {
+	case priceChange > 0:
+		annotation.Direction = SignalDirectionLong
+		annotation.Message = localizef(input.Locale, "🔎 持仓量激增: OI 1h %+.2f%%，价格 %+.2f%%，新增资金偏多", oiChange, priceChange)
+	case priceChange < 0:
+		annotation.Direction = SignalDirectionShort
+		annotation.Message = localizef(input.Locale, "🔎 持仓量激增: OI 1h %+.2f%%，价格 %+.2f%%，新增资金偏空", oiChange, priceChange)
+	default:
+		annotation.Direction = SignalDirectionNeutral
+		annotation.Message = localizef(input.Locale, "🔎 持仓量激增: OI 1h %+.2f%%，价格持平，多空分歧加大", oiChange)
+	}
+	return annotation
+}
@@ -0,0 +1,165 @@
+package decision
+
+import (
+	"nofx/market"
+	"nofx/mcp"
+)
+
+// 超出预算时日内序列依次缩短到的数据点数
+var seriesLengthSteps = []int{5, 3}
+
+// PromptBudget 本周期prompt的token预算使用情况
+type PromptBudget struct {
+	Budget            int      `json:"budget"`             // 输入token预算（上下文窗口扣除输出token和余量）
+	SystemTokens      int      `json:"system_tokens"`      // System Prompt 估算token数
+	UserTokens        int      `json:"user_tokens"`        // User Prompt 估算token数（裁剪后）
+	OriginalTokens    int      `json:"original_tokens"`    // 裁剪前 User Prompt 估算token数
+	SeriesLength      int      `json:"series_length"`      // 日内序列保留的数据点数（0表示未缩短）
+	DroppedCandidates []string `json:"dropped_candidates"` // 因预算被丢弃的候选币种（按排名从低到高）
+	Cuts              []string `json:"cuts"`               // 裁剪说明（按 Context.Locale 本地化）
+	OverBudget        bool     `json:"over_budget"`        // 裁剪后仍超出预算
+}
+
+// TotalTokens system + user 的估算token总数
+func (b *PromptBudget) TotalTokens() int {
+	return b.SystemTokens + b.UserTokens
+}
+
+// buildUserPromptWithinBudget 在token预算内构建 User Prompt
+// 超出预算时依次：缩短候选币种的日内序列 → 按排名从低到高丢弃候选币种 → 缩短持仓币种的日内序列
+// 持仓币种永远不会被丢弃（需要决策是否平仓）
+func buildUserPromptWithinBudget(ctx *Context, systemPrompt string, mcpClient *mcp.Client) (string, *PromptBudget) {
+	budget := &PromptBudget{
+		Budget:            mcpClient.InputTokenBudget(),
+		SystemTokens:      mcp.EstimateTokens(systemPrompt),
+		DroppedCandidates: []string{},
+		Cuts:              []string{},
+	}
+	userBudget := budget.Budget - budget.SystemTokens
+
+	prompt := buildUserPrompt(ctx)
+	budget.OriginalTokens = mcp.EstimateTokens(prompt)
+	budget.UserTokens = budget.OriginalTokens
+	if budget.UserTokens <= userBudget {
+		return prompt, budget
+	}
+
+	positionSymbols := make(map[string]bool)
+	for _, pos := range ctx.Positions {
+		positionSymbols[pos.Symbol] = true
+	}
+
+	// 裁剪使用上下文副本，不影响后续的决策验证和执行
+	trimmedCtx := *ctx
+	trimmedCtx.CandidateCoins = append([]CandidateCoin(nil), ctx.CandidateCoins...)
+	rebuild := func(seriesLength int, trimPositions bool) {
+		trimmedCtx.MarketDataMap = make(map[string]*market.Data, len(ctx.MarketDataMap))
+		for symbol, data := range ctx.MarketDataMap {
+			if seriesLength > 0 && (trimPositions || !positionSymbols[symbol]) {
+				data = trimMarketSeries(data, seriesLength)
+			}
+			trimmedCtx.MarketDataMap[symbol] = data
+		}
+		prompt = buildUserPrompt(&trimmedCtx)
+		budget.UserTokens = mcp.EstimateTokens(prompt)
+	}
+
+	// 1. 缩短候选币种的日内序列
+	for _, length := range seriesLengthSteps {
+		rebuild(length, false)
+		budget.SeriesLength = length
+		if budget.UserTokens <= userBudget {
+			break
+		}
+	}
+	budget.Cuts = append(budget.Cuts, localizef(ctx.Locale, "候选币种日内序列缩短至最近%d个数据点", budget.SeriesLength))
+
+	// 2. 按排名从低到高丢弃候选币种（持仓币种保留）
+	for budget.UserTokens > userBudget && len(trimmedCtx.CandidateCoins) > 0 {
+		last := trimmedCtx.CandidateCoins[len(trimmedCtx.CandidateCoins)-1]
+		trimmedCtx.CandidateCoins = trimmedCtx.CandidateCoins[:len(trimmedCtx.CandidateCoins)-1]
+		if positionSymbols[last.Symbol] {
+			continue
+		}
+		if _, hasData := trimmedCtx.MarketDataMap[last.Symbol]; !hasData {
+			continue
+		}
+
+		delete(trimmedCtx.MarketDataMap, last.Symbol)
+		budget.DroppedCandidates = append(budget.DroppedCandidates, last.Symbol)
+		prompt = buildUserPrompt(&trimmedCtx)
+		budget.UserTokens = mcp.EstimateTokens(prompt)
+	}
+	if len(budget.DroppedCandidates) > 0 {
+		budget.Cuts = append(budget.Cuts, localizef(ctx.Locale, "丢弃排名靠后的候选币种%d个: %v", len(budget.DroppedCandidates), budget.DroppedCandidates))
+	}
+
+	// 3. 仍超出预算（此时候选币种已全部丢弃）：持仓币种的日内序列也缩短到最短
+	if budget.UserTokens > userBudget {
+		dropped := make(map[string]bool, len(budget.DroppedCandidates))
+		for _, symbol := range budget.DroppedCandidates {
+			dropped[symbol] = true
+		}
+		length := seriesLengthSteps[len(seriesLengthSteps)-1]
+		rebuild(length, true)
+		for symbol := range dropped {
+			delete(trimmedCtx.MarketDataMap, symbol)
+		}
+		prompt = buildUserPrompt(&trimmedCtx)
+		budget.UserTokens = mcp.EstimateTokens(prompt)
+		budget.Cuts = append(budget.Cuts, localizef(ctx.Locale, "持仓币种日内序列缩短至最近%d个数据点", length))
+	}
+
+	budget.OverBudget = budget.UserTokens > userBudget
+	return prompt, budget
+}
+
+// trimMarketSeries 返回只保留最近N个序列数据点的市场数据副本
+func trimMarketSeries(data *market.Data, maxPoints int) *market.Data {
+	trimmed := *data
+
+	if data.IntradaySeries != nil {
+		series := *data.IntradaySeries
+		series.MidPrices = tailFloats(series.MidPrices, maxPoints)
+		series.EMA20Values = tailFloats(series.EMA20Values, maxPoints)
+		series.MACDValues = tailFloats(series.MACDValues, maxPoints)
+		series.RSI7Values = tailFloats(series.RSI7Values, maxPoints)
+		series.RSI14Values = tailFloats(series.RSI14Values, maxPoints)
+		trimmed.IntradaySeries = &series
+	}
+
+	if data.LongerTermContext != nil {
+		longer := *data.LongerTermContext
+		longer.MACDValues = tailFloats(longer.MACDValues, maxPoints)
+		longer.RSI14Values = tailFloats(longer.RSI14Values, maxPoints)
+		trimmed.LongerTermContext = &longer
+	}
+
+	// 行情数据配置的各时间框架（收盘价及指标序列同样只保留最近N个）
+	if len(data.Timeframes) > 0 {
+		trimmed.Timeframes = make([]*market.TimeframeData, len(data.Timeframes))
+		for i, tf := range data.Timeframes {
+			if tf == nil {
+				continue
+			}
+			frame := *tf
+			frame.Closes = tailFloats(tf.Closes, maxPoints)
+			frame.Indicators = make([]market.IndicatorValue, len(tf.Indicators))
+			for j, indicator := range tf.Indicators {
+				indicator.Series = tailFloats(indicator.Series, maxPoints)
+				frame.Indicators[j] = indicator
+			}
+			trimmed.Timeframes[i] = &frame
+		}
+	}
+
+	return &trimmed
+}
+
+// tailFloats 返回切片最后N个元素
+func tailFloats(values []float64, n int) []float64 {
+	if len(values) <= n {
+		return values
+	}
+	return values[len(values)-n:]
+}
@@ -0,0 +1,258 @@
+package decision
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/market"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 候选币种超过数量上限时的排序方式
+const (
+	UniverseRankSource      = "source"       // 保持币种池顺序（AI500评分/OI Top排名）
+	UniverseRankOIValue     = "oi_value"     // 持仓价值从高到低
+	UniverseRankQuoteVolume = "quote_volume" // 24小时成交额从高到低
+	UniverseRankVolatility  = "volatility"   // 1小时价格变化绝对值从高到低
+)
+
+// UniverseFilter 候选币种筛选条件（持仓币种不参与筛选，始终提供给AI）
+type UniverseFilter struct {
+	MinOIValueUSD     float64 `json:"min_oi_value_usd"`     // 最小持仓价值（USD，0=不限制）
+	MinQuoteVolume24h float64 `json:"min_quote_volume_24h"` // 最小24小时成交额（USDT，0=不限制）
+	MaxSpreadPct      float64 `json:"max_spread_pct"`       // 最大买卖价差（%，0=不限制）
+	MinListingDays    int     `json:"min_listing_days"`     // 最少上线天数（0=不限制）
+	MaxCandidates     int     `json:"max_candidates"`       // 最多候选币种数量（0=不限制）
+	RankBy            string  `json:"rank_by"`              // 超过数量上限时的排序方式: source/oi_value/quote_volume/volatility
+}
+
+// UniverseRejection 未进入候选的币种及原因
+type UniverseRejection struct {
+	Symbol string `json:"symbol"`
+	Reason string `json:"reason"`
+}
+
+// universeCandidate 通过筛选的候选币种（附带排序使用的指标）
+type universeCandidate struct {
+	symbol      string
+	data        *market.Data
+	oiValue     float64
+	quoteVolume float64 // 24小时成交额（-1 表示未获取）
+}
+
+// DefaultUniverseFilter 默认筛选条件：持仓价值不低于15M USD（原有的流动性过滤）
+func DefaultUniverseFilter() *UniverseFilter {
+	return &UniverseFilter{
+		MinOIValueUSD: 15_000_000,
+		RankBy:        UniverseRankSource,
+	}
+}
+
+// ParseUniverseFilter 解析并校验数据库中保存的筛选条件（空字符串表示使用默认条件）
+func ParseUniverseFilter(raw string) (*UniverseFilter, error) {
+	if strings.TrimSpace(raw) == "" {
+		return DefaultUniverseFilter(), nil
+	}
+
+	var filter UniverseFilter
+	if err := json.Unmarshal([]byte(raw), &filter); err != nil {
+		return nil, fmt.Errorf("解析候选币种筛选条件失败: %w", err)
+	}
+	if err := filter.Validate(); err != nil {
+		return nil, err
+	}
+	return &filter, nil
+}
+
+// Validate 校验筛选条件（未指定排序方式时保持币种池顺序）
+func (f *UniverseFilter) Validate() error {
+	if f.MinOIValueUSD < 0 || f.MinQuoteVolume24h < 0 || f.MaxSpreadPct < 0 || f.MinListingDays < 0 || f.MaxCandidates < 0 {
+		return fmt.Errorf("候选币种筛选条件不能为负数")
+	}
+	switch f.RankBy {
+	case "":
+		f.RankBy = UniverseRankSource
+	case UniverseRankSource, UniverseRankOIValue, UniverseRankQuoteVolume, UniverseRankVolatility:
+	default:
+		return fmt.Errorf("不支持的排序方式: %s（可选 source/oi_value/quote_volume/volatility）", f.RankBy)
+	}
+	return nil
+}
+
+// String 序列化为JSON（保存到数据库）
+func (f *UniverseFilter) String() string {
+	data, _ := json.Marshal(f)
+	return string(data)
+}
+
+// unsupportedChecks 返回数据源无法提供数据而不检查的筛选条件及原因
+func (f *UniverseFilter) unsupportedChecks(providerName string) []string {
+	var checks []string
+	if f.MinQuoteVolume24h > 0 {
+		checks = append(checks, "min_quote_volume_24h")
+	}
+	if f.MaxSpreadPct > 0 {
+		checks = append(checks, "max_spread_pct")
+	}
+	if f.MinListingDays > 0 {
+		checks = append(checks, "min_listing_days")
+	}
+	if f.RankBy == UniverseRankQuoteVolume {
+		checks = append(checks, "rank_by=quote_volume")
+	}
+
+	skipped := make([]string, 0, len(checks))
+	for _, check := range checks {
+		skipped = append(skipped, fmt.Sprintf("%s: %s 行情不提供成交额/价差/上线时间数据，未检查", check, providerName))
+	}
+	return skipped
+}
+
+// evaluate 检查单个币种是否满足筛选条件，不满足时返回原因
+// 需要额外请求的条件（成交额、价差、上线时间，取自交易员的行情数据源）只在设置了对应阈值时检查，stats 为nil时不检查
+func (f *UniverseFilter) evaluate(symbol string, data *market.Data, stats market.LiquidityStatsProvider) (*universeCandidate, string) {
+	candidate := &universeCandidate{symbol: symbol, data: data, quoteVolume: -1}
+
+	// 持仓价值 = 持仓量 × 当前价格（没有持仓量数据时不过滤）
+	if data.OpenInterest != nil && data.CurrentPrice > 0 {
+		candidate.oiValue = data.OpenInterest.Latest * data.CurrentPrice
+		if f.MinOIValueUSD > 0 && candidate.oiValue < f.MinOIValueUSD {
+			return nil, fmt.Sprintf("持仓价值过低(%.2fM USD < %.2fM) [持仓量:%.0f × 价格:%.4f]",
+				candidate.oiValue/1_000_000, f.MinOIValueUSD/1_000_000, data.OpenInterest.Latest, data.CurrentPrice)
+		}
+	}
+
+	if stats == nil {
+		return candidate, ""
+	}
+
+	if f.MinQuoteVolume24h > 0 {
+		quoteVolume, err := stats.Get24hQuoteVolume(symbol)
+		if err != nil {
+			return nil, fmt.Sprintf("获取24小时成交额失败: %v", err)
+		}
+		candidate.quoteVolume = quoteVolume
+		if quoteVolume < f.MinQuoteVolume24h {
+			return nil, fmt.Sprintf("24小时成交额过低(%.2fM USDT < %.2fM)", quoteVolume/1_000_000, f.MinQuoteVolume24h/1_000_000)
+		}
+	}
+
+	if f.MaxSpreadPct > 0 {
+		spreadPct, err := stats.GetSpreadPct(symbol)
+		if err != nil {
+			return nil, fmt.Sprintf("获取买卖价差失败: %v", err)
+		}
+		if spreadPct > f.MaxSpreadPct {
+			return nil, fmt.Sprintf("买卖价差过大(%.4f%% > %.4f%%)", spreadPct, f.MaxSpreadPct)
+		}
+	}
+
+	if f.MinListingDays > 0 {
+		listedAt, err := stats.GetListingTime(symbol)
+		if err != nil {
+			return nil, fmt.Sprintf("获取上线时间失败: %v", err)
+		}
+		if days := time.Since(listedAt).Hours() / 24; days < float64(f.MinListingDays) {
+			return nil, fmt.Sprintf("上线时间过短(%.1f天 < %d天，上线于 %s)", days, f.MinListingDays, listedAt.Format("2006-01-02"))
+		}
+	}
+
+	return candidate, ""
+}
+
+// rank 按排序方式对通过筛选的币种排序（source 保持币种池顺序）
+func (f *UniverseFilter) rank(candidates []*universeCandidate, stats market.LiquidityStatsProvider) {
+	var key func(c *universeCandidate) float64
+	switch f.RankBy {
+	case UniverseRankOIValue:
+		key = func(c *universeCandidate) float64 { return c.oiValue }
+	case UniverseRankQuoteVolume:
+		if stats == nil {
+			return
+		}
+		for _, c := range candidates {
+			if c.quoteVolume < 0 {
+				if quoteVolume, err := stats.Get24hQuoteVolume(c.symbol); err == nil {
+					c.quoteVolume = quoteVolume
+				}
+			}
+		}
+		key = func(c *universeCandidate) float64 { return c.quoteVolume }
+	case UniverseRankVolatility:
+		key = func(c *universeCandidate) float64 { return math.Abs(c.data.PriceChange1h) }
+	default:
+		return
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return key(candidates[i]) > key(candidates[j])
+	})
+}
+
+// filterCandidates 获取候选币种的市场数据并按筛选条件过滤，超过数量上限的按排序方式截取
+// 未进入候选的币种及原因记录到 ctx.RejectedCoins 并输出日志
+func filterCandidates(ctx *Context, skip map[string]bool) {
+	filter := ctx.UniverseFilter
+	if filter == nil {
+		filter = DefaultUniverseFilter()
+	}
+
+	provider := marketProvider(ctx)
+	reject := func(symbol, reason string) {
+		ctx.RejectedCoins = append(ctx.RejectedCoins, UniverseRejection{Symbol: symbol, Reason: reason})
+		log.Printf("⚠️  %s 未进入候选: %s", symbol, reason)
+	}
+
+	// 成交额、价差、上线时间取自交易员的行情数据源，数据源不提供时跳过对应条件并记录原因
+	stats, _ := provider.(market.LiquidityStatsProvider)
+	if stats == nil {
+		ctx.SkippedFilters = filter.unsupportedChecks(provider.Name())
+		for _, reason := range ctx.SkippedFilters {
+			log.Printf("⚠️  候选币种筛选条件 %s", reason)
+		}
+	}
+
+	var passed []*universeCandidate
+	seen := make(map[string]bool)
+	for _, coin := range ctx.CandidateCoins {
+		if skip[coin.Symbol] || seen[coin.Symbol] {
+			continue
+		}
+		seen[coin.Symbol] = true
+
+		// 交易所未上架的币种无法交易（查询失败时不过滤，由获取市场数据的结果决定）
+		if listed, err := provider.HasSymbol(coin.Symbol); err == nil && !listed {
+			reject(coin.Symbol, fmt.Sprintf("%s 未上架该币种", provider.Name()))
+			continue
+		}
+
+		data, err := market.GetWithProfile(provider, coin.Symbol, ctx.DataProfile)
+		if err != nil {
+			reject(coin.Symbol, fmt.Sprintf("获取市场数据失败: %v", err))
+			continue
+		}
+		candidate, reason := filter.evaluate(coin.Symbol, data, stats)
+		if reason != "" {
+			reject(coin.Symbol, reason)
+			continue
+		}
+		passed = append(passed, candidate)
+	}
+
+	filter.rank(passed, stats)
+	maxCandidates := calculateMaxCandidates(ctx, filter)
+	for i, candidate := range passed {
+		if i >= maxCandidates {
+			reject(candidate.symbol, fmt.Sprintf("按 %s 排名第%d，超出候选数量上限%d", filter.RankBy, i+1, maxCandidates))
+			continue
+		}
+		ctx.MarketDataMap[candidate.symbol] = candidate.data
+	}
+
+	if len(ctx.RejectedCoins) > 0 {
+		log.Printf("🔎 候选币种筛选: %d 个进入候选，%d 个被过滤", min(len(passed), maxCandidates), len(ctx.RejectedCoins))
+	}
+}
@@ -12,19 +12,58 @@ import (
 
 // DecisionRecord 决策记录
 type DecisionRecord struct {
-	Timestamp      time.Time          `json:"timestamp"`       // 决策时间
-	CycleNumber    int                `json:"cycle_number"`    // 周期编号
-	SystemPrompt   string             `json:"system_prompt"`   // 系统提示词（发送给AI的系统prompt）
-	InputPrompt    string             `json:"input_prompt"`    // 发送给AI的输入prompt
-	CoTTrace       string             `json:"cot_trace"`       // AI思维链（输出）
-	DecisionJSON   string             `json:"decision_json"`   // 决策JSON
-	AccountState   AccountSnapshot    `json:"account_state"`   // 账户状态快照
-	Positions      []PositionSnapshot `json:"positions"`       // 持仓快照
-	CandidateCoins []string           `json:"candidate_coins"` // 候选币种列表
-	Decisions      []DecisionAction   `json:"decisions"`       // 执行的决策
-	ExecutionLog   []string           `json:"execution_log"`   // 执行日志
-	Success        bool               `json:"success"`         // 是否成功
-	ErrorMessage   string             `json:"error_message"`   // 错误信息（如果有）
+	Timestamp         time.Time          `json:"timestamp"`                    // 决策时间
+	CycleNumber       int                `json:"cycle_number"`                 // 周期编号
+	SystemPrompt      string             `json:"system_prompt"`                // 系统提示词（发送给AI的系统prompt）
+	PromptTemplate    string             `json:"prompt_template"`              // 使用的提示词模板名称
+	PromptVersion     int                `json:"prompt_version"`               // 使用的提示词模板版本（文件模板为0）
+	PromptVariant     string             `json:"prompt_variant,omitempty"`     // 提示词实验变体名称（未启用实验时为空）
+	Locale            string             `json:"locale,omitempty"`             // 提示词语言（重放时使用同一语言）
+	DecisionSource    string             `json:"decision_source,omitempty"`    // 决策来源: ai/rule/rule_fallback
+	InputPrompt       string             `json:"input_prompt"`                 // 发送给AI的输入prompt
+	PromptTokens      int                `json:"prompt_tokens"`                // system + user prompt 估算token数
+	PromptCuts        []string           `json:"prompt_cuts,omitempty"`        // 超出token预算时的裁剪记录
+	Signals           []SignalRecord     `json:"signals,omitempty"`            // 信号分析器输出（用于评估各信号的效果）
+	CoTTrace          string             `json:"cot_trace"`                    // AI思维链（输出）
+	DecisionJSON      string             `json:"decision_json"`                // 决策JSON（通过验证的决策）
+	RawResponse       string             `json:"raw_response"`                 // AI原始输出
+	RepairedResponses []string           `json:"repaired_responses,omitempty"` // AI修复后的输出（每轮修复一条）
+	RejectedDecisions []RejectedDecision `json:"rejected_decisions,omitempty"` // 未通过验证被丢弃的决策
+	AccountState      AccountSnapshot    `json:"account_state"`                // 账户状态快照
+	Positions         []PositionSnapshot `json:"positions"`                    // 持仓快照
+	CandidateCoins    []string           `json:"candidate_coins"`              // 候选币种列表
+	RejectedCoins     []CoinRejection    `json:"rejected_coins,omitempty"`     // 未进入候选的币种及原因（筛选条件过滤）
+	SkippedFilters    []string           `json:"skipped_filters,omitempty"`    // 行情数据源不支持而未检查的筛选条件及原因
+	SnapshotFile      string             `json:"snapshot_file,omitempty"`      // 市场数据快照文件（snapshots目录下）
+	MarketSnapshot    *MarketSnapshot    `json:"-"`                            // 待保存的市场数据快照（单独压缩存储）
+	Decisions         []DecisionAction   `json:"decisions"`                    // 执行的决策
+	ExecutionLog      []string           `json:"execution_log"`                // 执行日志
+	Success           bool               `json:"success"`                      // 是否成功
+	ErrorMessage      string             `json:"error_message"`                // 错误信息（如果有）
+}
+
+// SignalRecord 信号分析器输出
+type SignalRecord struct {
+	Analyzer  string             `json:"analyzer"`         // 分析器名称
+	Symbol    string             `json:"symbol"`           // 币种
+	Direction string             `json:"direction"`        // long/short/neutral
+	Strength  float64            `json:"strength"`         // 信号强度 0-1
+	Message   string             `json:"message"`          // 写入prompt的说明文字
+	Values    map[string]float64 `json:"values,omitempty"` // 关键数值
+}
+
+// CoinRejection 未进入候选的币种
+type CoinRejection struct {
+	Symbol string `json:"symbol"` // 币种
+	Reason string `json:"reason"` // 过滤原因
+}
+
+// RejectedDecision 被拒绝的决策
+type RejectedDecision struct {
+	Symbol       string `json:"symbol"`        // 币种
+	Action       string `json:"action"`        // 决策动作
+	DecisionJSON string `json:"decision_json"` // 原始决策JSON
+	Error        string `json:"error"`         // 验证失败原因
 }
 
 // AccountSnapshot 账户状态快照
@@ -46,6 +85,7 @@ type PositionSnapshot struct {
 	UnrealizedProfit float64 `json:"unrealized_profit"`
 	Leverage         float64 `json:"leverage"`
 	LiquidationPrice float64 `json:"liquidation_price"`
+	FundingCost      float64 `json:"funding_cost,omitempty"` // 持仓期间资金费估算（USDT，正数为支付）
 }
 
 // DecisionAction 决策动作
@@ -97,6 +137,16 @@ func (l *DecisionLogger) LogDecision(record *DecisionRecord) error {
 
 	filepath := filepath.Join(l.logDir, filename)
 
+	// 市场数据快照单独压缩保存，记录中只保存文件名（快照失败不影响决策记录）
+	if record.MarketSnapshot != nil {
+		snapshotFile, err := l.saveSnapshot(record)
+		if err != nil {
+			fmt.Printf("⚠ 保存市场数据快照失败: %v\n", err)
+		} else {
+			record.SnapshotFile = snapshotFile
+		}
+	}
+
 	// 序列化为JSON（带缩进，方便阅读）
 	data, err := json.MarshalIndent(record, "", "  ")
 	if err != nil {
@@ -208,6 +258,10 @@ func (l *DecisionLogger) CleanOldRecords(days int) error {
 		fmt.Printf("🗑️ 已清理 %d 条旧记录（%d天前）\n", removedCount, days)
 	}
 
+	if removedSnapshots := l.cleanOldSnapshots(cutoffTime); removedSnapshots > 0 {
+		fmt.Printf("🗑️ 已清理 %d 个旧快照（%d天前）\n", removedSnapshots, days)
+	}
+
 	return nil
 }
 
@@ -284,6 +338,7 @@ type TradeOutcome struct {
 	OpenTime      time.Time `json:"open_time"`      // 开仓时间
 	CloseTime     time.Time `json:"close_time"`     // 平仓时间
 	WasStopLoss   bool      `json:"was_stop_loss"`  // 是否止损
+	Variant       string    `json:"variant"`        // 开仓周期使用的提示词实验变体
 }
 
 // PerformanceAnalysis 交易表现分析
@@ -327,12 +382,20 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 		}, nil
 	}
 
-	analysis := &PerformanceAnalysis{
-		RecentTrades: []TradeOutcome{},
-		SymbolStats:  make(map[string]*SymbolPerformance),
-	}
+	trades := l.collectTradeOutcomes(records, lookbackCycles)
+	analysis := summarizeTrades(trades)
+
+	// 计算夏普比率（需要至少2个数据点）
+	analysis.SharpeRatio = l.calculateSharpeRatio(records)
+
+	return analysis, nil
+}
 
-	// 追踪持仓状态：symbol_side -> {side, openPrice, openTime, quantity, leverage}
+// collectTradeOutcomes 从分析窗口内的记录中配对开平仓，生成交易结果（按平仓时间正序）
+func (l *DecisionLogger) collectTradeOutcomes(records []*DecisionRecord, lookbackCycles int) []TradeOutcome {
+	var trades []TradeOutcome
+
+	// 追踪持仓状态：symbol_side -> {side, openPrice, openTime, quantity, leverage, variant}
 	openPositions := make(map[string]map[string]interface{})
 
 	// 为了避免开仓记录在窗口外导致匹配失败，需要先从所有历史记录中找出未平仓的持仓
@@ -364,6 +427,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 						"openTime":  action.Timestamp,
 						"quantity":  action.Quantity,
 						"leverage":  action.Leverage,
+						"variant":   record.PromptVariant,
 					}
 				case "close_long", "close_short":
 					// 移除已平仓记录
@@ -398,6 +462,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					"openTime":  action.Timestamp,
 					"quantity":  action.Quantity,
 					"leverage":  action.Leverage,
+					"variant":   record.PromptVariant,
 				}
 
 			case "close_long", "close_short":
@@ -408,6 +473,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					side := openPos["side"].(string)
 					quantity := openPos["quantity"].(float64)
 					leverage := openPos["leverage"].(int)
+					variant := openPos["variant"].(string)
 
 					// 计算实际盈亏（USDT）
 					// 合约交易 PnL 计算：quantity × 价格差
@@ -428,7 +494,7 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 					}
 
 					// 记录交易结果
-					outcome := TradeOutcome{
+					trades = append(trades, TradeOutcome{
 						Symbol:        symbol,
 						Side:          side,
 						Quantity:      quantity,
@@ -442,35 +508,8 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 						Duration:      action.Timestamp.Sub(openTime).String(),
 						OpenTime:      openTime,
 						CloseTime:     action.Timestamp,
-					}
-
-					analysis.RecentTrades = append(analysis.RecentTrades, outcome)
-					analysis.TotalTrades++
-
-					// 分类交易：盈利、亏损、持平（避免将pnl=0算入亏损）
-					if pnl > 0 {
-						analysis.WinningTrades++
-						analysis.AvgWin += pnl
-					} else if pnl < 0 {
-						analysis.LosingTrades++
-						analysis.AvgLoss += pnl
-					}
-					// pnl == 0 的交易不计入盈利也不计入亏损，但计入总交易数
-
-					// 更新币种统计
-					if _, exists := analysis.SymbolStats[symbol]; !exists {
-						analysis.SymbolStats[symbol] = &SymbolPerformance{
-							Symbol: symbol,
-						}
-					}
-					stats := analysis.SymbolStats[symbol]
-					stats.TotalTrades++
-					stats.TotalPnL += pnl
-					if pnl > 0 {
-						stats.WinningTrades++
-					} else if pnl < 0 {
-						stats.LosingTrades++
-					}
+						Variant:       variant,
+					})
 
 					// 移除已平仓记录
 					delete(openPositions, posKey)
@@ -479,6 +518,49 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 		}
 	}
 
+	return trades
+}
+
+// summarizeTrades 根据交易结果计算胜率、盈亏比和各币种表现（不含夏普比率）
+func summarizeTrades(trades []TradeOutcome) *PerformanceAnalysis {
+	analysis := &PerformanceAnalysis{
+		RecentTrades: []TradeOutcome{},
+		SymbolStats:  make(map[string]*SymbolPerformance),
+	}
+
+	for _, outcome := range trades {
+		pnl := outcome.PnL
+		symbol := outcome.Symbol
+
+		analysis.RecentTrades = append(analysis.RecentTrades, outcome)
+		analysis.TotalTrades++
+
+		// 分类交易：盈利、亏损、持平（避免将pnl=0算入亏损）
+		if pnl > 0 {
+			analysis.WinningTrades++
+			analysis.AvgWin += pnl
+		} else if pnl < 0 {
+			analysis.LosingTrades++
+			analysis.AvgLoss += pnl
+		}
+		// pnl == 0 的交易不计入盈利也不计入亏损，但计入总交易数
+
+		// 更新币种统计
+		if _, exists := analysis.SymbolStats[symbol]; !exists {
+			analysis.SymbolStats[symbol] = &SymbolPerformance{
+				Symbol: symbol,
+			}
+		}
+		stats := analysis.SymbolStats[symbol]
+		stats.TotalTrades++
+		stats.TotalPnL += pnl
+		if pnl > 0 {
+			stats.WinningTrades++
+		} else if pnl < 0 {
+			stats.LosingTrades++
+		}
+	}
+
 	// 计算统计指标
 	if analysis.TotalTrades > 0 {
 		analysis.WinRate = (float64(analysis.WinningTrades) / float64(analysis.TotalTrades)) * 100
@@ -524,23 +606,14 @@ func (l *DecisionLogger) AnalyzePerformance(lookbackCycles int) (*PerformanceAna
 	}
 
 	// 只保留最近的交易（倒序：最新的在前）
+	for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
+		analysis.RecentTrades[i], analysis.RecentTrades[j] = analysis.RecentTrades[j], analysis.RecentTrades[i]
+	}
 	if len(analysis.RecentTrades) > 10 {
-		// 反转数组，让最新的在前
-		for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
-			analysis.RecentTrades[i], analysis.RecentTrades[j] = analysis.RecentTrades[j], analysis.RecentTrades[i]
-		}
 		analysis.RecentTrades = analysis.RecentTrades[:10]
-	} else if len(analysis.RecentTrades) > 0 {
-		// 反转数组
-		for i, j := 0, len(analysis.RecentTrades)-1; i < j; i, j = i+1, j-1 {
-			analysis.RecentTrades[i], analysis.RecentTrades[j] = analysis.RecentTrades[j], analysis.RecentTrades[i]
-		}
 	}
 
-	// 计算夏普比率（需要至少2个数据点）
-	analysis.SharpeRatio = l.calculateSharpeRatio(records)
-
-	return analysis, nil
+	return analysis
 }
 
 // calculateSharpeRatio 计算夏普比率
@@ -575,6 +648,11 @@ func (l *DecisionLogger) calculateSharpeRatio(records []*DecisionRecord) float64
 		}
 	}
 
+	return sharpeRatioFromReturns(returns)
+}
+
+// sharpeRatioFromReturns 根据周期收益率计算夏普比率（假设无风险利率为0）
+func sharpeRatioFromReturns(returns []float64) float64 {
 	if len(returns) == 0 {
 		return 0.0
 	}
@@ -0,0 +1,103 @@
+package logger
+
+import (
+	"fmt"
+	"sort"
+)
+
+// noVariantName 未启用实验时记录的周期在报告中的分组名称
+const noVariantName = "(none)"
+
+// VariantPerformance 单个提示词变体的表现
+type VariantPerformance struct {
+	Variant      string               `json:"variant"`       // 变体名称
+	Cycles       int                  `json:"cycles"`        // 使用该变体的周期数
+	FailedCycles int                  `json:"failed_cycles"` // 失败周期数（AI调用或解析失败等）
+	Templates    []string             `json:"templates"`     // 该变体实际使用的模板（名称@版本）
+	Performance  *PerformanceAnalysis `json:"performance"`   // 按变体拆分的交易表现（交易归属于开仓周期的变体）
+}
+
+// ExperimentReport 提示词实验报告
+type ExperimentReport struct {
+	LookbackCycles int                   `json:"lookback_cycles"` // 分析的周期数
+	TotalCycles    int                   `json:"total_cycles"`    // 实际读取到的周期数
+	Variants       []*VariantPerformance `json:"variants"`        // 各变体表现（按变体名称排序）
+}
+
+// AnalyzeExperiment 按提示词实验变体拆分最近N个周期的交易表现
+// 交易按开仓周期的变体归属；夏普比率使用每个周期到下一周期的净值变化，归属于前一周期的变体
+func (l *DecisionLogger) AnalyzeExperiment(lookbackCycles int) (*ExperimentReport, error) {
+	records, err := l.GetLatestRecords(lookbackCycles)
+	if err != nil {
+		return nil, fmt.Errorf("读取历史记录失败: %w", err)
+	}
+
+	report := &ExperimentReport{
+		LookbackCycles: lookbackCycles,
+		TotalCycles:    len(records),
+		Variants:       []*VariantPerformance{},
+	}
+	if len(records) == 0 {
+		return report, nil
+	}
+
+	variants := make(map[string]*VariantPerformance)
+	templates := make(map[string]map[string]bool)
+	getVariant := func(name string) *VariantPerformance {
+		if name == "" {
+			name = noVariantName
+		}
+		if _, exists := variants[name]; !exists {
+			variants[name] = &VariantPerformance{Variant: name, Templates: []string{}}
+			templates[name] = make(map[string]bool)
+		}
+		return variants[name]
+	}
+
+	// 统计每个变体的周期数和使用的模板
+	for _, record := range records {
+		vp := getVariant(record.PromptVariant)
+		vp.Cycles++
+		if !record.Success {
+			vp.FailedCycles++
+		}
+		if record.PromptTemplate != "" {
+			templates[vp.Variant][fmt.Sprintf("%s@v%d", record.PromptTemplate, record.PromptVersion)] = true
+		}
+	}
+
+	// 交易结果按变体拆分
+	tradesByVariant := make(map[string][]TradeOutcome)
+	for _, trade := range l.collectTradeOutcomes(records, lookbackCycles) {
+		name := getVariant(trade.Variant).Variant
+		tradesByVariant[name] = append(tradesByVariant[name], trade)
+	}
+
+	// 周期收益率按变体拆分
+	returnsByVariant := make(map[string][]float64)
+	for i := 1; i < len(records); i++ {
+		prev := records[i-1].AccountState.TotalBalance
+		curr := records[i].AccountState.TotalBalance
+		if prev <= 0 || curr <= 0 {
+			continue
+		}
+		name := getVariant(records[i-1].PromptVariant).Variant
+		returnsByVariant[name] = append(returnsByVariant[name], (curr-prev)/prev)
+	}
+
+	for name, vp := range variants {
+		vp.Performance = summarizeTrades(tradesByVariant[name])
+		vp.Performance.SharpeRatio = sharpeRatioFromReturns(returnsByVariant[name])
+		for template := range templates[name] {
+			vp.Templates = append(vp.Templates, template)
+		}
+		sort.Strings(vp.Templates)
+		report.Variants = append(report.Variants, vp)
+	}
+
+	sort.Slice(report.Variants, func(i, j int) bool {
+		return report.Variants[i].Variant < report.Variants[j].Variant
+	})
+
+	return report, nil
+}
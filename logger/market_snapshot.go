@@ -0,0 +1,158 @@
+package logger
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"nofx/market"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// MarketSnapshotVersion 市场数据快照格式版本
+const MarketSnapshotVersion = 1
+
+// snapshotDirName 快照子目录（与决策记录分开存放，避免被当作决策记录读取）
+const snapshotDirName = "snapshots"
+
+// MarketSnapshot 单个周期的结构化市场数据快照（决策时的原始输入，用于特征分析和重放）
+type MarketSnapshot struct {
+	Version        int                       `json:"version"`         // 快照格式版本
+	Timestamp      time.Time                 `json:"timestamp"`       // 保存时间（与决策记录一致）
+	CycleNumber    int                       `json:"cycle_number"`    // 周期编号（与决策记录一致）
+	CurrentTime    string                    `json:"current_time"`    // 决策上下文中的时间
+	RuntimeMinutes int                       `json:"runtime_minutes"` // 运行时长（分钟）
+	CallCount      int                       `json:"call_count"`      // 调用次数
+	CandidateCoins []CandidateSnapshot       `json:"candidate_coins"` // 候选币种及来源
+	MarketData     map[string]*market.Data   `json:"market_data"`     // 持仓和候选币种的市场数据
+	OITop          map[string]*OITopSnapshot `json:"oi_top,omitempty"`
+}
+
+// CandidateSnapshot 候选币种快照
+type CandidateSnapshot struct {
+	Symbol  string   `json:"symbol"`
+	Sources []string `json:"sources"` // 来源: "ai500" 和/或 "oi_top"
+}
+
+// OITopSnapshot OI Top数据快照
+type OITopSnapshot struct {
+	Rank              int     `json:"rank"`                // OI Top排名
+	OIDeltaPercent    float64 `json:"oi_delta_percent"`    // 持仓量变化百分比（1小时）
+	OIDeltaValue      float64 `json:"oi_delta_value"`      // 持仓量变化价值
+	PriceDeltaPercent float64 `json:"price_delta_percent"` // 价格变化百分比
+	NetLong           float64 `json:"net_long"`            // 净多仓
+	NetShort          float64 `json:"net_short"`           // 净空仓
+}
+
+// saveSnapshot 保存市场数据快照（紧凑JSON + gzip），返回快照文件名
+func (l *DecisionLogger) saveSnapshot(record *DecisionRecord) (string, error) {
+	snapshot := record.MarketSnapshot
+	snapshot.Version = MarketSnapshotVersion
+	snapshot.Timestamp = record.Timestamp
+	snapshot.CycleNumber = record.CycleNumber
+
+	dir := filepath.Join(l.logDir, snapshotDirName)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("创建快照目录失败: %w", err)
+	}
+
+	// 生成文件名：snapshot_YYYYMMDD_HHMMSS_cycleN.json.gz（与决策记录对应）
+	filename := fmt.Sprintf("snapshot_%s_cycle%d.json.gz",
+		record.Timestamp.Format("20060102_150405"),
+		record.CycleNumber)
+
+	// 先写入临时文件，成功后再重命名，避免失败时留下不完整的快照（决策记录会引用该文件名）
+	file, err := os.CreateTemp(dir, "."+filename+".tmp-*")
+	if err != nil {
+		return "", fmt.Errorf("创建快照文件失败: %w", err)
+	}
+	tmpPath := file.Name()
+	if err := writeSnapshot(file, snapshot); err != nil {
+		os.Remove(tmpPath)
+		return "", err
+	}
+	if err := os.Rename(tmpPath, filepath.Join(dir, filename)); err != nil {
+		os.Remove(tmpPath)
+		return "", fmt.Errorf("保存快照文件失败: %w", err)
+	}
+
+	return filename, nil
+}
+
+// writeSnapshot 将快照以gzip压缩的JSON写入文件并关闭文件
+func writeSnapshot(file *os.File, snapshot *MarketSnapshot) error {
+	writer := gzip.NewWriter(file)
+	if err := json.NewEncoder(writer).Encode(snapshot); err != nil {
+		file.Close()
+		return fmt.Errorf("序列化快照失败: %w", err)
+	}
+	if err := writer.Close(); err != nil {
+		file.Close()
+		return fmt.Errorf("写入快照失败: %w", err)
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("写入快照失败: %w", err)
+	}
+	return nil
+}
+
+// LoadSnapshot 读取指定文件名的市场数据快照
+func (l *DecisionLogger) LoadSnapshot(filename string) (*MarketSnapshot, error) {
+	if filename == "" || filename != filepath.Base(filename) || !strings.HasPrefix(filename, "snapshot_") {
+		return nil, fmt.Errorf("无效的快照文件名: %s", filename)
+	}
+
+	file, err := os.Open(filepath.Join(l.logDir, snapshotDirName, filename))
+	if err != nil {
+		return nil, fmt.Errorf("打开快照文件失败: %w", err)
+	}
+	defer file.Close()
+
+	reader, err := gzip.NewReader(file)
+	if err != nil {
+		return nil, fmt.Errorf("解压快照失败: %w", err)
+	}
+	defer reader.Close()
+
+	var snapshot MarketSnapshot
+	if err := json.NewDecoder(reader).Decode(&snapshot); err != nil {
+		return nil, fmt.Errorf("解析快照失败: %w", err)
+	}
+	return &snapshot, nil
+}
+
+// LoadRecordSnapshot 读取决策记录对应的市场数据快照（记录没有快照时返回nil）
+func (l *DecisionLogger) LoadRecordSnapshot(record *DecisionRecord) (*MarketSnapshot, error) {
+	if record == nil || record.SnapshotFile == "" {
+		return nil, nil
+	}
+	return l.LoadSnapshot(record.SnapshotFile)
+}
+
+// cleanOldSnapshots 清理N天前的旧快照
+func (l *DecisionLogger) cleanOldSnapshots(cutoffTime time.Time) int {
+	dir := filepath.Join(l.logDir, snapshotDirName)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return 0
+	}
+
+	removedCount := 0
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil || !info.ModTime().Before(cutoffTime) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dir, entry.Name())); err != nil {
+			fmt.Printf("⚠ 删除旧快照失败 %s: %v\n", entry.Name(), err)
+			continue
+		}
+		removedCount++
+	}
+	return removedCount
+}
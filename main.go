@@ -145,6 +145,11 @@ func loadBetaCodesToDatabase(database *config.Database) error {
 }
 
 func main() {
+	// 命令行工具：nofx replay ...（不启动交易系统）
+	if len(os.Args) > 1 && os.Args[1] == "replay" {
+		os.Exit(runReplayCommand(os.Args[2:]))
+	}
+
 	fmt.Println("╔════════════════════════════════════════════════════════════╗")
 	fmt.Println("║    🤖 AI多模型交易系统 - 支持 DeepSeek & Qwen            ║")
 	fmt.Println("╚════════════════════════════════════════════════════════════╝")
@@ -2,10 +2,14 @@ package manager
 
 import (
 	"context"
+	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"nofx/config"
+	"nofx/decision"
+	"nofx/market"
 	"nofx/trader"
 	"sort"
 	"strconv"
@@ -175,6 +179,7 @@ func (tm *TraderManager) LoadTradersFromDatabase(database *config.Database) erro
 			log.Printf("❌ 添加交易员 %s 失败: %v", traderCfg.Name, err)
 			continue
 		}
+		tm.applyPromptTemplate(database, traderCfg)
 	}
 
 	log.Printf("✓ 成功加载 %d 个交易员到内存", len(tm.traders))
@@ -228,6 +233,9 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		QwenKey:               "",
 		CustomAPIURL:          aiModelCfg.CustomAPIURL,    // 自定义API URL
 		CustomModelName:       aiModelCfg.CustomModelName, // 自定义模型名称
+		AIOutputMode:          aiModelCfg.OutputMode,      // 结构化输出模式
+		AIContextWindow:       aiModelCfg.ContextWindow,   // 上下文窗口（token预算）
+		AITimeout:             time.Duration(aiModelCfg.TimeoutSeconds) * time.Second,
 		ScanInterval:          time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
 		InitialBalance:        traderCfg.InitialBalance,
 		BTCETHLeverage:        traderCfg.BTCETHLeverage,
@@ -239,6 +247,9 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		DefaultCoins:          defaultCoins,
 		TradingCoins:          tradingCoins,
 		SystemPromptTemplate:  traderCfg.SystemPromptTemplate, // 系统提示词模板
+		AIRepairRounds:        traderCfg.AIRepairRounds,       // 决策修复轮数
+		BlockStaleOpens:       traderCfg.BlockStaleOpens,      // 行情数据过时时禁止开新仓
+		MaxSlippagePct:        traderCfg.MaxSlippagePct,       // 市价开仓允许的最大预估滑点
 	}
 
 	// 根据交易所类型设置API密钥
@@ -259,6 +270,8 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		traderConfig.QwenKey = aiModelCfg.APIKey
 	} else if aiModelCfg.Provider == "deepseek" {
 		traderConfig.DeepSeekKey = aiModelCfg.APIKey
+	} else if aiModelCfg.Provider == "external" {
+		traderConfig.CustomAPIKey = aiModelCfg.APIKey // 外部决策服务的签名密钥
 	}
 
 	// 创建trader实例
@@ -278,6 +291,16 @@ func (tm *TraderManager) addTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		}
 	}
 
+	// 设置提示词A/B实验（如果有）
+	applyPromptExperiment(at, traderCfg)
+	applySignalAnalyzers(at, traderCfg)
+	applyDecisionMode(at, traderCfg)
+	applyLocale(at, traderCfg)
+	applyChangeDetector(at, traderCfg)
+	applyUniverseFilter(at, traderCfg)
+	applyDataProfile(at, traderCfg)
+	applyAlertTrigger(at, traderCfg)
+
 	tm.traders[traderCfg.ID] = at
 	log.Printf("✓ Trader '%s' (%s + %s) 已加载到内存", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ID)
 	return nil
@@ -335,6 +358,9 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		QwenKey:               "",
 		CustomAPIURL:          aiModelCfg.CustomAPIURL,    // 自定义API URL
 		CustomModelName:       aiModelCfg.CustomModelName, // 自定义模型名称
+		AIOutputMode:          aiModelCfg.OutputMode,      // 结构化输出模式
+		AIContextWindow:       aiModelCfg.ContextWindow,   // 上下文窗口（token预算）
+		AITimeout:             time.Duration(aiModelCfg.TimeoutSeconds) * time.Second,
 		ScanInterval:          time.Duration(traderCfg.ScanIntervalMinutes) * time.Minute,
 		InitialBalance:        traderCfg.InitialBalance,
 		BTCETHLeverage:        traderCfg.BTCETHLeverage,
@@ -345,6 +371,9 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		IsCrossMargin:         traderCfg.IsCrossMargin,
 		DefaultCoins:          defaultCoins,
 		TradingCoins:          tradingCoins,
+		AIRepairRounds:        traderCfg.AIRepairRounds,  // 决策修复轮数
+		BlockStaleOpens:       traderCfg.BlockStaleOpens, // 行情数据过时时禁止开新仓
+		MaxSlippagePct:        traderCfg.MaxSlippagePct,  // 市价开仓允许的最大预估滑点
 	}
 
 	// 根据交易所类型设置API密钥
@@ -365,6 +394,8 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		traderConfig.QwenKey = aiModelCfg.APIKey
 	} else if aiModelCfg.Provider == "deepseek" {
 		traderConfig.DeepSeekKey = aiModelCfg.APIKey
+	} else if aiModelCfg.Provider == "external" {
+		traderConfig.CustomAPIKey = aiModelCfg.APIKey // 外部决策服务的签名密钥
 	}
 
 	// 创建trader实例
@@ -384,6 +415,16 @@ func (tm *TraderManager) AddTraderFromDB(traderCfg *config.TraderRecord, aiModel
 		}
 	}
 
+	// 设置提示词A/B实验（如果有）
+	applyPromptExperiment(at, traderCfg)
+	applySignalAnalyzers(at, traderCfg)
+	applyDecisionMode(at, traderCfg)
+	applyLocale(at, traderCfg)
+	applyChangeDetector(at, traderCfg)
+	applyUniverseFilter(at, traderCfg)
+	applyDataProfile(at, traderCfg)
+	applyAlertTrigger(at, traderCfg)
+
 	tm.traders[traderCfg.ID] = at
 	log.Printf("✓ Trader '%s' (%s + %s) 已添加", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ID)
 	return nil
@@ -472,6 +513,7 @@ func (tm *TraderManager) GetComparisonData() (map[string]interface{}, error) {
 			"trader_id":       t.GetID(),
 			"trader_name":     t.GetName(),
 			"ai_model":        t.GetAIModel(),
+			"decision_mode":   t.GetDecisionMode(),
 			"exchange":        t.GetExchange(),
 			"total_equity":    account["total_equity"],
 			"total_pnl":       account["total_pnl"],
@@ -593,6 +635,7 @@ func (tm *TraderManager) getConcurrentTraderData(traders []*trader.AutoTrader) [
 					"trader_id":       trader.GetID(),
 					"trader_name":     trader.GetName(),
 					"ai_model":        trader.GetAIModel(),
+					"decision_mode":   trader.GetDecisionMode(),
 					"exchange":        trader.GetExchange(),
 					"total_equity":    account["total_equity"],
 					"total_pnl":       account["total_pnl"],
@@ -608,6 +651,7 @@ func (tm *TraderManager) getConcurrentTraderData(traders []*trader.AutoTrader) [
 					"trader_id":       trader.GetID(),
 					"trader_name":     trader.GetName(),
 					"ai_model":        trader.GetAIModel(),
+					"decision_mode":   trader.GetDecisionMode(),
 					"exchange":        trader.GetExchange(),
 					"total_equity":    0.0,
 					"total_pnl":       0.0,
@@ -624,6 +668,7 @@ func (tm *TraderManager) getConcurrentTraderData(traders []*trader.AutoTrader) [
 					"trader_id":       trader.GetID(),
 					"trader_name":     trader.GetName(),
 					"ai_model":        trader.GetAIModel(),
+					"decision_mode":   trader.GetDecisionMode(),
 					"exchange":        trader.GetExchange(),
 					"total_equity":    0.0,
 					"total_pnl":       0.0,
@@ -835,12 +880,210 @@ func (tm *TraderManager) LoadUserTraders(database *config.Database, userID strin
 		err = tm.loadSingleTrader(traderCfg, aiModelCfg, exchangeCfg, coinPoolURL, oiTopURL, maxDailyLoss, maxDrawdown, stopTradingMinutes, defaultCoins)
 		if err != nil {
 			log.Printf("⚠️ 加载交易员 %s 失败: %v", traderCfg.Name, err)
+			continue
+		}
+		tm.applyPromptTemplate(database, traderCfg)
+	}
+
+	return nil
+}
+
+// applyPromptTemplate 为已加载的交易员应用数据库中的版本化提示词模板（不加锁，因为调用方已加锁）
+// 用户没有同名数据库模板时使用 prompts 目录下的文件模板
+func (tm *TraderManager) applyPromptTemplate(database *config.Database, traderCfg *config.TraderRecord) {
+	at, exists := tm.traders[traderCfg.ID]
+	if !exists {
+		return
+	}
+
+	promptTemplate, err := LoadPromptTemplate(database, traderCfg.UserID, traderCfg.SystemPromptTemplate, traderCfg.PromptTemplateVersion)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的提示词模板 %s 不可用，使用文件模板: %v", traderCfg.Name, traderCfg.SystemPromptTemplate, err)
+	}
+	at.SetPromptTemplate(promptTemplate)
+	if promptTemplate != nil {
+		log.Printf("📋 交易员 %s 使用数据库提示词模板 %s v%d", traderCfg.Name, promptTemplate.Name, promptTemplate.Version)
+	}
+}
+
+// applySignalAnalyzers 解析交易员的信号分析器配置并应用（配置无效时使用默认分析器）
+func applySignalAnalyzers(at *trader.AutoTrader, traderCfg *config.TraderRecord) {
+	configs, err := decision.ParseSignalAnalyzerConfigs(traderCfg.SignalAnalyzers)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的信号分析器配置无效，使用默认分析器: %v", traderCfg.Name, err)
+		return
+	}
+	if configs == nil {
+		return
+	}
+
+	analyzers, err := decision.BuildSignalAnalyzers(configs)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的信号分析器创建失败，使用默认分析器: %v", traderCfg.Name, err)
+		return
+	}
+	at.SetSignalAnalyzers(analyzers)
+
+	names := make([]string, 0, len(configs))
+	for _, cfg := range configs {
+		names = append(names, cfg.Name)
+	}
+	log.Printf("📋 交易员 %s 启用信号分析器: %v", traderCfg.Name, names)
+}
+
+// applyDecisionMode 解析交易员的决策方式及规则策略并应用（配置无效时使用AI决策）
+func applyDecisionMode(at *trader.AutoTrader, traderCfg *config.TraderRecord) {
+	if err := decision.ValidateDecisionMode(traderCfg.DecisionMode); err != nil {
+		log.Printf("⚠️ 交易员 %s 的决策方式无效，使用AI决策: %v", traderCfg.Name, err)
+		return
+	}
+	strategy, err := decision.ParseRuleStrategy(traderCfg.RuleStrategy)
+	if err == nil {
+		// 分析器配置无效时交易员使用默认分析器，按默认分析器校验
+		configs, _ := decision.ParseSignalAnalyzerConfigs(traderCfg.SignalAnalyzers)
+		err = strategy.ValidateAnalyzers(configs)
+	}
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的规则策略无效，使用默认规则策略: %v", traderCfg.Name, err)
+		strategy = nil
+	}
+
+	at.SetDecisionMode(traderCfg.DecisionMode, strategy)
+	if mode := at.GetDecisionMode(); mode != decision.DecisionModeAI {
+		log.Printf("📐 交易员 %s 决策方式: %s (规则策略: %s)", traderCfg.Name, mode, at.GetRuleStrategy().Name)
+	}
+}
+
+// applyLocale 应用交易员的提示词语言（配置无效时使用默认语言）
+func applyLocale(at *trader.AutoTrader, traderCfg *config.TraderRecord) {
+	if err := decision.ValidateLocale(traderCfg.Locale); err != nil {
+		log.Printf("⚠️ 交易员 %s 的提示词语言无效，使用默认语言: %v", traderCfg.Name, err)
+		return
+	}
+
+	at.SetLocale(traderCfg.Locale)
+	if locale := at.GetLocale(); locale != decision.DefaultLocale {
+		log.Printf("🌐 交易员 %s 提示词语言: %s", traderCfg.Name, locale)
+	}
+}
+
+// applyChangeDetector 解析交易员的市场状态变化检测配置并应用（配置无效时每个周期都调用AI）
+func applyChangeDetector(at *trader.AutoTrader, traderCfg *config.TraderRecord) {
+	detectorCfg, err := decision.ParseChangeDetectorConfig(traderCfg.ChangeDetector)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的变化检测配置无效，已忽略: %v", traderCfg.Name, err)
+		return
+	}
+	if detectorCfg == nil {
+		return
+	}
+
+	at.SetChangeDetector(detectorCfg)
+	log.Printf("💤 交易员 %s 启用变化检测: 价格%.2f%% | RSI7 %.1f | 成交量比率%.2f | 最多连续跳过%d个周期",
+		traderCfg.Name, detectorCfg.PriceChangePct, detectorCfg.RSIChange, detectorCfg.VolumeRatioChange, detectorCfg.MaxSkips)
+}
+
+// applyUniverseFilter 解析交易员的候选币种筛选条件并应用（配置无效时使用默认条件）
+func applyUniverseFilter(at *trader.AutoTrader, traderCfg *config.TraderRecord) {
+	filter, err := decision.ParseUniverseFilter(traderCfg.UniverseFilter)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的候选币种筛选条件无效，使用默认条件: %v", traderCfg.Name, err)
+		return
+	}
+
+	at.SetUniverseFilter(filter)
+	if traderCfg.UniverseFilter != "" {
+		log.Printf("🔎 交易员 %s 候选币种筛选: %s", traderCfg.Name, filter.String())
+	}
+}
+
+// applyDataProfile 解析交易员的行情数据配置并应用（配置无效时使用原有格式）
+func applyDataProfile(at *trader.AutoTrader, traderCfg *config.TraderRecord) {
+	profile, err := market.ParseDataProfile(traderCfg.DataProfile)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的行情数据配置无效，使用原有格式: %v", traderCfg.Name, err)
+		return
+	}
+
+	at.SetDataProfile(profile)
+	if profile != nil {
+		log.Printf("📐 交易员 %s 行情数据配置: %s", traderCfg.Name, profile.String())
+	}
+}
+
+// applyAlertTrigger 解析交易员的行情警报配置并应用（配置无效时不使用警报）
+func applyAlertTrigger(at *trader.AutoTrader, traderCfg *config.TraderRecord) {
+	alertCfg, err := decision.ParseAlertTriggerConfig(traderCfg.AlertTrigger)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的行情警报配置无效，已忽略: %v", traderCfg.Name, err)
+		return
+	}
+	if alertCfg == nil {
+		return
+	}
+
+	at.SetAlertTrigger(alertCfg)
+	log.Printf("🚨 交易员 %s 启用行情警报: 触发周期=%v | 加入候选=%v (评分≥%.0f, 最多%d个)",
+		traderCfg.Name, alertCfg.TriggerCycle, alertCfg.AddCandidates, alertCfg.MinScore, alertCfg.MaxCandidates)
+}
+
+// applyPromptExperiment 解析交易员的提示词实验配置并应用（配置无效时忽略实验）
+func applyPromptExperiment(at *trader.AutoTrader, traderCfg *config.TraderRecord) {
+	experiment, err := decision.ParsePromptExperiment(traderCfg.PromptExperiment)
+	if err != nil {
+		log.Printf("⚠️ 交易员 %s 的提示词实验配置无效，已忽略: %v", traderCfg.Name, err)
+		return
+	}
+	if experiment == nil {
+		return
+	}
+
+	at.SetPromptExperiment(experiment)
+	names := make([]string, 0, len(experiment.Variants))
+	for _, variant := range experiment.Variants {
+		names = append(names, variant.Name)
+	}
+	log.Printf("🧪 交易员 %s 启用提示词实验 (%s): %s", traderCfg.Name, experiment.Mode, strings.Join(names, ", "))
+}
+
+// RefreshPromptTemplates 重新为用户在内存中的交易员应用数据库提示词模板（模板更新、回滚或交易员固定版本后调用）
+func (tm *TraderManager) RefreshPromptTemplates(database *config.Database, userID string) error {
+	traders, err := database.GetTraders(userID)
+	if err != nil {
+		return fmt.Errorf("获取用户 %s 的交易员列表失败: %w", userID, err)
+	}
+
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+
+	for _, traderCfg := range traders {
+		if at, exists := tm.traders[traderCfg.ID]; exists {
+			at.SetSystemPromptTemplate(traderCfg.SystemPromptTemplate)
 		}
+		tm.applyPromptTemplate(database, traderCfg)
 	}
 
 	return nil
 }
 
+// LoadPromptTemplate 从数据库加载用户的版本化提示词模板（version=0 表示最新版本）
+// 用户没有该名称的数据库模板时返回 nil, nil（由调用方使用文件模板）
+func LoadPromptTemplate(database *config.Database, userID, name string, version int) (*decision.PromptTemplate, error) {
+	if name == "" {
+		return nil, nil
+	}
+
+	record, err := database.GetPromptTemplateByName(userID, name, version)
+	if errors.Is(err, sql.ErrNoRows) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return decision.NewPromptTemplate(record.Name, record.Content, record.CurrentVersion)
+}
+
 // loadSingleTrader 加载单个交易员（从现有代码提取的公共逻辑）
 func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiModelCfg *config.AIModelConfig, exchangeCfg *config.ExchangeConfig, coinPoolURL, oiTopURL string, maxDailyLoss, maxDrawdown float64, stopTradingMinutes int, defaultCoins []string) error {
 	// 处理交易币种列表
@@ -881,6 +1124,9 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		CoinPoolAPIURL:       effectiveCoinPoolURL,
 		CustomAPIURL:         aiModelCfg.CustomAPIURL,    // 自定义API URL
 		CustomModelName:      aiModelCfg.CustomModelName, // 自定义模型名称
+		AIOutputMode:         aiModelCfg.OutputMode,      // 结构化输出模式
+		AIContextWindow:      aiModelCfg.ContextWindow,   // 上下文窗口（token预算）
+		AITimeout:            time.Duration(aiModelCfg.TimeoutSeconds) * time.Second,
 		UseQwen:              aiModelCfg.Provider == "qwen",
 		MaxDailyLoss:         maxDailyLoss,
 		MaxDrawdown:          maxDrawdown,
@@ -889,6 +1135,9 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		DefaultCoins:         defaultCoins,
 		TradingCoins:         tradingCoins,
 		SystemPromptTemplate: traderCfg.SystemPromptTemplate, // 系统提示词模板
+		AIRepairRounds:       traderCfg.AIRepairRounds,       // 决策修复轮数
+		BlockStaleOpens:      traderCfg.BlockStaleOpens,      // 行情数据过时时禁止开新仓
+		MaxSlippagePct:       traderCfg.MaxSlippagePct,       // 市价开仓允许的最大预估滑点
 	}
 
 	// 根据交易所类型设置API密钥
@@ -909,6 +1158,8 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		traderConfig.QwenKey = aiModelCfg.APIKey
 	} else if aiModelCfg.Provider == "deepseek" {
 		traderConfig.DeepSeekKey = aiModelCfg.APIKey
+	} else if aiModelCfg.Provider == "external" {
+		traderConfig.CustomAPIKey = aiModelCfg.APIKey // 外部决策服务的签名密钥
 	}
 
 	// 创建trader实例
@@ -928,6 +1179,16 @@ func (tm *TraderManager) loadSingleTrader(traderCfg *config.TraderRecord, aiMode
 		}
 	}
 
+	// 设置提示词A/B实验（如果有）
+	applyPromptExperiment(at, traderCfg)
+	applySignalAnalyzers(at, traderCfg)
+	applyDecisionMode(at, traderCfg)
+	applyLocale(at, traderCfg)
+	applyChangeDetector(at, traderCfg)
+	applyUniverseFilter(at, traderCfg)
+	applyDataProfile(at, traderCfg)
+	applyAlertTrigger(at, traderCfg)
+
 	tm.traders[traderCfg.ID] = at
 	log.Printf("✓ Trader '%s' (%s + %s) 已为用户加载到内存", traderCfg.Name, aiModelCfg.Provider, exchangeCfg.ID)
 	return nil
@@ -0,0 +1,479 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 警报类型
+const (
+	AlertVolumeSpike   = "volume_spike"   // 成交量突增（当前K线成交量/前20根均量）
+	AlertPriceChange   = "price_change"   // 15分钟价格大幅变化
+	AlertVolumeTrend   = "volume_trend"   // 成交量持续放大（近5根均量/前20根均量）
+	AlertRSIOverbought = "rsi_overbought" // RSI14 上穿超买线
+	AlertRSIOversold   = "rsi_oversold"   // RSI14 下穿超卖线
+)
+
+// AlertTypes 所有警报类型
+var AlertTypes = []string{AlertVolumeSpike, AlertPriceChange, AlertVolumeTrend, AlertRSIOverbought, AlertRSIOversold}
+
+// featureInterval 计算币种特征使用的K线周期
+const featureInterval = "3m"
+
+// minFeatureKlines 计算特征需要的最少K线数（前20根均量 + 最近5根）
+const minFeatureKlines = 26
+
+// alertCooldown 同一币种同一类型警报的最短间隔（未收盘K线的更新会重复满足条件）
+const alertCooldown = 15 * time.Minute
+
+// maxRecentAlerts 保留的最近警报数
+const maxRecentAlerts = 500
+
+// alertScoreHalfLife 币种评分的半衰期
+const alertScoreHalfLife = 30 * time.Minute
+
+// alertWeights 各类型警报的基础评分（按超出阈值的倍数放大，最多3倍）
+var alertWeights = map[string]float64{
+	AlertVolumeSpike:   10,
+	AlertPriceChange:   10,
+	AlertVolumeTrend:   5,
+	AlertRSIOverbought: 3,
+	AlertRSIOversold:   3,
+}
+
+// SymbolScore 币种的警报评分
+type SymbolScore struct {
+	Symbol           string    `json:"symbol"`
+	Score            float64   `json:"score"` // 按半衰期衰减后的当前评分
+	AlertCount       int       `json:"alert_count"`
+	VolumeSpikeCount int       `json:"volume_spike_count"`
+	LastAlertTime    time.Time `json:"last_alert_time"`
+	LastActiveTime   time.Time `json:"last_active_time"`
+}
+
+// alertCenter 最近警报和订阅者（WSMonitor 产生的警报经 alertsChan 分发）
+type alertCenter struct {
+	mu          sync.Mutex
+	recent      []Alert              // 最近的警报（从旧到新）
+	lastFired   map[string]time.Time // symbol|type -> 最近一次警报时间
+	subscribers map[int]chan Alert
+	nextID      int
+}
+
+func newAlertCenter() *alertCenter {
+	return &alertCenter{
+		lastFired:   make(map[string]time.Time),
+		subscribers: make(map[int]chan Alert),
+	}
+}
+
+// allow 检查同一币种同一类型的警报是否已过冷却时间（通过时记录本次时间）
+func (c *alertCenter) allow(symbol, alertType string, now time.Time) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	key := symbol + "|" + alertType
+	if last, ok := c.lastFired[key]; ok && now.Sub(last) < alertCooldown {
+		return false
+	}
+	c.lastFired[key] = now
+	return true
+}
+
+// publish 记录警报并分发给订阅者（订阅者处理不过来时丢弃）
+func (c *alertCenter) publish(alert Alert) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.recent = append(c.recent, alert)
+	if len(c.recent) > maxRecentAlerts {
+		c.recent = c.recent[len(c.recent)-maxRecentAlerts:]
+	}
+	for _, ch := range c.subscribers {
+		select {
+		case ch <- alert:
+		default:
+		}
+	}
+}
+
+// computeFeatures 根据3分钟K线计算币种特征（K线不足时返回 false）
+func computeFeatures(symbol string, klines []Kline) (*SymbolFeatures, bool) {
+	n := len(klines)
+	if n < minFeatureKlines {
+		return nil, false
+	}
+
+	last := klines[n-1]
+	features := &SymbolFeatures{
+		Symbol:    symbol,
+		Timestamp: time.UnixMilli(last.CloseTime),
+		Price:     last.Close,
+		Volume:    last.Volume,
+		RSI14:     calculateRSI(klines, 14),
+	}
+
+	change := func(bars int) float64 {
+		if n <= bars || klines[n-1-bars].Close == 0 {
+			return 0
+		}
+		return last.Close/klines[n-1-bars].Close - 1
+	}
+	features.PriceChange15Min = change(5)
+	features.PriceChange1H = change(20)
+	features.PriceChange4H = change(80)
+
+	avgVolume := func(from, to int) float64 {
+		sum := 0.0
+		for i := from; i < to; i++ {
+			sum += klines[i].Volume
+		}
+		return sum / float64(to-from)
+	}
+	if avg := avgVolume(n-6, n-1); avg > 0 {
+		features.VolumeRatio5 = last.Volume / avg
+	}
+	if avg := avgVolume(n-21, n-1); avg > 0 {
+		features.VolumeRatio20 = last.Volume / avg
+	}
+	if avg := avgVolume(n-25, n-5); avg > 0 {
+		features.VolumeTrend = avgVolume(n-5, n) / avg
+	}
+
+	closes := make([]float64, n)
+	for i, k := range klines {
+		closes[i] = k.Close
+	}
+	sma := func(period int) float64 {
+		if series := smaSeries(closes, period); len(series) > 0 {
+			return series[len(series)-1]
+		}
+		return 0
+	}
+	features.SMA5 = sma(5)
+	features.SMA10 = sma(10)
+	features.SMA20 = sma(20)
+
+	high, low := highLow(klines, n-1, 20)
+	if low > 0 {
+		features.HighLowRatio = high / low
+	}
+	if high > low {
+		features.PositionInRange = (last.Close - low) / (high - low)
+	}
+
+	// 最近20根K线收益率的标准差
+	var returns []float64
+	for i := n - 20; i < n; i++ {
+		if klines[i-1].Close > 0 {
+			returns = append(returns, klines[i].Close/klines[i-1].Close-1)
+		}
+	}
+	if len(returns) > 1 {
+		mean := 0.0
+		for _, r := range returns {
+			mean += r
+		}
+		mean /= float64(len(returns))
+		variance := 0.0
+		for _, r := range returns {
+			variance += (r - mean) * (r - mean)
+		}
+		features.Volatility20 = math.Sqrt(variance / float64(len(returns)-1))
+	}
+	return features, true
+}
+
+// evaluateAlerts 按阈值检查币种特征（RSI 只在穿越超买/超卖线时报警，prev 为上次计算的特征）
+func evaluateAlerts(features, prev *SymbolFeatures, thresholds AlertThresholds) []Alert {
+	var alerts []Alert
+	add := func(alertType string, value, threshold float64, message string) {
+		alerts = append(alerts, Alert{
+			Type:      alertType,
+			Symbol:    features.Symbol,
+			Value:     value,
+			Threshold: threshold,
+			Message:   message,
+			Timestamp: features.Timestamp,
+		})
+	}
+
+	if thresholds.VolumeSpike > 0 && features.VolumeRatio20 >= thresholds.VolumeSpike {
+		add(AlertVolumeSpike, features.VolumeRatio20, thresholds.VolumeSpike,
+			fmt.Sprintf("%s 成交量突增 %.1f 倍", features.Symbol, features.VolumeRatio20))
+	}
+	if thresholds.PriceChange15Min > 0 && math.Abs(features.PriceChange15Min) >= thresholds.PriceChange15Min {
+		add(AlertPriceChange, features.PriceChange15Min, thresholds.PriceChange15Min,
+			fmt.Sprintf("%s 15分钟价格变化 %+.2f%%", features.Symbol, features.PriceChange15Min*100))
+	}
+	if thresholds.VolumeTrend > 0 && features.VolumeTrend >= thresholds.VolumeTrend {
+		add(AlertVolumeTrend, features.VolumeTrend, thresholds.VolumeTrend,
+			fmt.Sprintf("%s 成交量持续放大 %.1f 倍", features.Symbol, features.VolumeTrend))
+	}
+	if prev != nil {
+		if thresholds.RSIOverbought > 0 && features.RSI14 >= thresholds.RSIOverbought && prev.RSI14 < thresholds.RSIOverbought {
+			add(AlertRSIOverbought, features.RSI14, thresholds.RSIOverbought,
+				fmt.Sprintf("%s RSI14 上穿 %.0f (%.1f)", features.Symbol, thresholds.RSIOverbought, features.RSI14))
+		}
+		if thresholds.RSIOversold > 0 && features.RSI14 <= thresholds.RSIOversold && prev.RSI14 > thresholds.RSIOversold {
+			add(AlertRSIOversold, features.RSI14, thresholds.RSIOversold,
+				fmt.Sprintf("%s RSI14 下穿 %.0f (%.1f)", features.Symbol, thresholds.RSIOversold, features.RSI14))
+		}
+	}
+	return alerts
+}
+
+// alertScore 单个警报的评分（基础分 × 超出阈值的倍数，最多3倍）
+func alertScore(alert Alert) float64 {
+	multiple := 1.0
+	if alert.Threshold != 0 {
+		switch alert.Type {
+		case AlertRSIOverbought, AlertRSIOversold:
+			// RSI 穿越只按基础分计
+		default:
+			multiple = math.Min(math.Abs(alert.Value/alert.Threshold), 3)
+		}
+	}
+	return alertWeights[alert.Type] * multiple
+}
+
+// decayedScore 按半衰期衰减到 now 的评分
+func decayedScore(stats *SymbolStats, now time.Time) float64 {
+	if stats.LastAlertTime.IsZero() {
+		return 0
+	}
+	elapsed := now.Sub(stats.LastAlertTime)
+	return stats.Score * math.Pow(0.5, float64(elapsed)/float64(alertScoreHalfLife))
+}
+
+// updateFeatures 收到3分钟K线更新后计算币种特征并检查警报
+// K线收盘时计算；未收盘的更新距上次计算超过 UpdateInterval 时也计算，以便及时发现异动
+func (m *WSMonitor) updateFeatures(symbol string, klines []Kline, final bool) {
+	now := time.Now()
+	var prev *SymbolFeatures
+	if value, ok := m.featuresMap.Load(symbol); ok {
+		prev = value.(*SymbolFeatures)
+		if !final && now.Sub(prev.Timestamp) < time.Duration(config.UpdateInterval)*time.Second {
+			return
+		}
+	}
+
+	features, ok := computeFeatures(symbol, klines)
+	if !ok {
+		return
+	}
+	// 以计算时间作为特征时间（未收盘K线的收盘时间在未来）
+	features.Timestamp = now
+	m.featuresMap.Store(symbol, features)
+	m.touchSymbol(symbol, now)
+
+	for _, alert := range evaluateAlerts(features, prev, config.AlertThresholds) {
+		if !m.alerts.allow(alert.Symbol, alert.Type, now) {
+			continue
+		}
+		m.publishAlert(alert)
+	}
+}
+
+// publishAlert 将警报发送到 alertsChan（Close 之后或分发不及时时丢弃，不阻塞K线处理）
+func (m *WSMonitor) publishAlert(alert Alert) {
+	m.alertsMu.RLock()
+	defer m.alertsMu.RUnlock()
+
+	if m.alertsClosed {
+		return
+	}
+	select {
+	case m.alertsChan <- alert:
+	default:
+	}
+}
+
+// touchSymbol 记录币种最近一次有行情更新的时间
+func (m *WSMonitor) touchSymbol(symbol string, now time.Time) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	value, _ := m.symbolStats.LoadOrStore(symbol, &SymbolStats{})
+	value.(*SymbolStats).LastActiveTime = now
+}
+
+// recordAlert 更新币种统计和评分
+func (m *WSMonitor) recordAlert(alert Alert) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	value, _ := m.symbolStats.LoadOrStore(alert.Symbol, &SymbolStats{})
+	stats := value.(*SymbolStats)
+	stats.Score = decayedScore(stats, alert.Timestamp) + alertScore(alert)
+	stats.AlertCount++
+	if alert.Type == AlertVolumeSpike {
+		stats.VolumeSpikeCount++
+	}
+	stats.LastAlertTime = alert.Timestamp
+	stats.LastActiveTime = alert.Timestamp
+}
+
+// dispatchAlerts 从 alertsChan 读取警报：更新币种评分、记录最近警报并分发给订阅者（Close 关闭通道后退出）
+func (m *WSMonitor) dispatchAlerts() {
+	for alert := range m.alertsChan {
+		m.recordAlert(alert)
+		m.alerts.publish(alert)
+	}
+
+	m.alerts.mu.Lock()
+	defer m.alerts.mu.Unlock()
+	for id, ch := range m.alerts.subscribers {
+		close(ch)
+		delete(m.alerts.subscribers, id)
+	}
+}
+
+// runStatsCleanup 定期清理不活跃或评分过低且长时间没有警报的币种统计
+func (m *WSMonitor) runStatsCleanup() {
+	ticker := time.NewTicker(config.CleanupConfig.CheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.cleanupSymbolStats(time.Now())
+	}
+}
+
+// cleanupSymbolStats 清理币种统计（见 CleanupConfig）
+func (m *WSMonitor) cleanupSymbolStats(now time.Time) {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	cleanup := config.CleanupConfig
+	removed := 0
+	m.symbolStats.Range(func(key, value interface{}) bool {
+		stats := value.(*SymbolStats)
+		inactive := now.Sub(stats.LastActiveTime) > cleanup.InactiveTimeout
+		quiet := now.Sub(stats.LastAlertTime) > cleanup.NoAlertTimeout && decayedScore(stats, now) < cleanup.MinScoreThreshold
+		if inactive || quiet {
+			m.symbolStats.Delete(key)
+			removed++
+		}
+		return true
+	})
+	if removed > 0 {
+		log.Printf("🧹 已清理 %d 个不活跃或无警报的币种统计", removed)
+	}
+
+	m.alerts.mu.Lock()
+	defer m.alerts.mu.Unlock()
+	for key, last := range m.alerts.lastFired {
+		if now.Sub(last) > alertCooldown {
+			delete(m.alerts.lastFired, key)
+		}
+	}
+}
+
+// SubscribeAlerts 订阅新产生的警报（返回的取消函数停止订阅并关闭通道）
+func (m *WSMonitor) SubscribeAlerts(buffer int) (<-chan Alert, func()) {
+	m.alerts.mu.Lock()
+	defer m.alerts.mu.Unlock()
+
+	id := m.alerts.nextID
+	m.alerts.nextID++
+	ch := make(chan Alert, buffer)
+	m.alerts.subscribers[id] = ch
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			m.alerts.mu.Lock()
+			defer m.alerts.mu.Unlock()
+			if _, ok := m.alerts.subscribers[id]; ok {
+				close(ch)
+				delete(m.alerts.subscribers, id)
+			}
+		})
+	}
+}
+
+// GetRecentAlerts 获取 since 之后的最近警报（从新到旧；symbol/alertType 为空表示不限，limit<=0 表示不限数量）
+func (m *WSMonitor) GetRecentAlerts(since time.Time, symbol, alertType string, limit int) []Alert {
+	symbol = strings.ToUpper(symbol)
+
+	m.alerts.mu.Lock()
+	defer m.alerts.mu.Unlock()
+
+	alerts := []Alert{}
+	for i := len(m.alerts.recent) - 1; i >= 0; i-- {
+		alert := m.alerts.recent[i]
+		if alert.Timestamp.Before(since) {
+			break
+		}
+		if (symbol != "" && alert.Symbol != symbol) || (alertType != "" && alert.Type != alertType) {
+			continue
+		}
+		alerts = append(alerts, alert)
+		if limit > 0 && len(alerts) >= limit {
+			break
+		}
+	}
+	return alerts
+}
+
+// GetSymbolFeatures 获取币种最近一次计算的特征
+func (m *WSMonitor) GetSymbolFeatures(symbol string) (*SymbolFeatures, bool) {
+	value, ok := m.featuresMap.Load(Normalize(symbol))
+	if !ok {
+		return nil, false
+	}
+	return value.(*SymbolFeatures), true
+}
+
+// GetSymbolScores 获取评分不低于 minScore 的币种（按当前评分从高到低，limit<=0 表示不限数量）
+func (m *WSMonitor) GetSymbolScores(minScore float64, limit int) []SymbolScore {
+	now := time.Now()
+
+	m.statsMu.Lock()
+	scores := []SymbolScore{}
+	m.symbolStats.Range(func(key, value interface{}) bool {
+		stats := value.(*SymbolStats)
+		score := decayedScore(stats, now)
+		if stats.AlertCount == 0 || score < minScore {
+			return true
+		}
+		scores = append(scores, SymbolScore{
+			Symbol:           key.(string),
+			Score:            score,
+			AlertCount:       stats.AlertCount,
+			VolumeSpikeCount: stats.VolumeSpikeCount,
+			LastAlertTime:    stats.LastAlertTime,
+			LastActiveTime:   stats.LastActiveTime,
+		})
+		return true
+	})
+	m.statsMu.Unlock()
+
+	sort.Slice(scores, func(i, j int) bool { return scores[i].Score > scores[j].Score })
+	if limit > 0 && len(scores) > limit {
+		scores = scores[:limit]
+	}
+	return scores
+}
+
+// GetSymbolScore 获取币种当前的警报评分（没有警报时为0）
+func (m *WSMonitor) GetSymbolScore(symbol string) float64 {
+	m.statsMu.Lock()
+	defer m.statsMu.Unlock()
+
+	value, ok := m.symbolStats.Load(Normalize(symbol))
+	if !ok {
+		return 0
+	}
+	return decayedScore(value.(*SymbolStats), time.Now())
+}
+
+// GetAlertConfig 获取警报阈值和清理配置
+func GetAlertConfig() Config {
+	return config
+}
@@ -3,9 +3,7 @@ package market
 import (
 	"encoding/json"
 	"fmt"
-	"io"
 	"log"
-	"net/http"
 	"strconv"
 	"time"
 )
@@ -14,66 +12,57 @@ const (
 	baseURL = "https://fapi.binance.com"
 )
 
+// APIClient 币安兼容合约接口的行情客户端（请求经由同一接口地址共享的 restGateway 缓存和限流）
 type APIClient struct {
-	client *http.Client
+	baseURL string
+	gateway *restGateway
 }
 
 func NewAPIClient() *APIClient {
+	return newAPIClientWithBaseURL(baseURL)
+}
+
+// newAPIClientWithBaseURL 创建指向币安兼容接口的客户端（如Aster）
+func newAPIClientWithBaseURL(baseURL string) *APIClient {
 	return &APIClient{
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
+		baseURL: baseURL,
+		gateway: restGatewayFor(baseURL),
 	}
 }
 
+// GetExchangeInfo 获取交易对信息（交易对信息很少变化，请求失败时可以使用过期缓存）
 func (c *APIClient) GetExchangeInfo() (*ExchangeInfo, error) {
-	url := fmt.Sprintf("%s/fapi/v1/exchangeInfo", baseURL)
-	resp, err := c.client.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
 	var exchangeInfo ExchangeInfo
-	err = json.Unmarshal(body, &exchangeInfo)
-	if err != nil {
-		return nil, err
+	if err := c.getJSON("/fapi/v1/exchangeInfo", nil, &exchangeInfo); err != nil {
+		if !IsStaleData(err) {
+			return nil, err
+		}
+		log.Printf("⚠️  使用过期的交易对信息: %v", err)
 	}
-
 	return &exchangeInfo, nil
 }
 
 func (c *APIClient) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
-	url := fmt.Sprintf("%s/fapi/v1/klines", baseURL)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
-		return nil, err
-	}
+	return c.getKlines(symbol, interval, 0, limit)
+}
 
-	q := req.URL.Query()
-	q.Add("symbol", symbol)
-	q.Add("interval", interval)
-	q.Add("limit", strconv.Itoa(limit))
-	req.URL.RawQuery = q.Encode()
+// GetKlinesFrom 获取从指定时间（毫秒）开始的K线（用于历史周期的价格路径）
+func (c *APIClient) GetKlinesFrom(symbol, interval string, startTime int64, limit int) ([]Kline, error) {
+	return c.getKlines(symbol, interval, startTime, limit)
+}
 
-	resp, err := c.client.Do(req)
-	if err != nil {
-		return nil, err
+func (c *APIClient) getKlines(symbol, interval string, startTime int64, limit int) ([]Kline, error) {
+	params := map[string]string{
+		"symbol":   symbol,
+		"interval": interval,
+		"limit":    strconv.Itoa(limit),
 	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
+	if startTime > 0 {
+		params["startTime"] = strconv.FormatInt(startTime, 10)
 	}
 
 	var klineResponses []KlineResponse
-	err = json.Unmarshal(body, &klineResponses)
-	if err != nil {
+	if err := c.getJSON("/fapi/v1/klines", params, &klineResponses); err != nil {
 		return nil, err
 	}
 
@@ -114,37 +103,172 @@ func parseKline(kr KlineResponse) (Kline, error) {
 }
 
 func (c *APIClient) GetCurrentPrice(symbol string) (float64, error) {
-	url := fmt.Sprintf("%s/fapi/v1/ticker/price", baseURL)
-	req, err := http.NewRequest("GET", url, nil)
-	if err != nil {
+	var ticker PriceTicker
+	if err := c.getJSON("/fapi/v1/ticker/price", map[string]string{"symbol": symbol}, &ticker); err != nil {
 		return 0, err
 	}
 
-	q := req.URL.Query()
-	q.Add("symbol", symbol)
-	req.URL.RawQuery = q.Encode()
-
-	resp, err := c.client.Do(req)
+	price, err := strconv.ParseFloat(ticker.Price, 64)
 	if err != nil {
 		return 0, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
+	return price, nil
+}
+
+// GetDepth 获取前 limit 档订单簿
+func (c *APIClient) GetDepth(symbol string, limit int) (*OrderBook, error) {
+	var depth struct {
+		EventTime int64      `json:"E"`
+		Bids      [][]string `json:"bids"`
+		Asks      [][]string `json:"asks"`
+	}
+	params := map[string]string{"symbol": symbol, "limit": strconv.Itoa(limit)}
+	if err := c.getJSON("/fapi/v1/depth", params, &depth); err != nil {
+		return nil, err
 	}
 
-	var ticker PriceTicker
-	err = json.Unmarshal(body, &ticker)
-	if err != nil {
-		return 0, err
+	return newOrderBook(symbol, depth.Bids, depth.Asks, depth.EventTime), nil
+}
+
+// GetAggTrades 获取最近 limit 笔归集成交（从旧到新）
+func (c *APIClient) GetAggTrades(symbol string, limit int) ([]AggTrade, error) {
+	var messages []aggTradeMessage
+	params := map[string]string{"symbol": symbol, "limit": strconv.Itoa(limit)}
+	if err := c.getJSON("/fapi/v1/aggTrades", params, &messages); err != nil {
+		return nil, err
 	}
 
-	price, err := strconv.ParseFloat(ticker.Price, 64)
-	if err != nil {
-		return 0, err
+	trades := make([]AggTrade, 0, len(messages))
+	for _, msg := range messages {
+		trade, err := msg.parse()
+		if err != nil {
+			continue
+		}
+		trades = append(trades, trade)
 	}
+	return trades, nil
+}
 
-	return price, nil
+// getJSON 通过 restGateway 请求GET接口并解析JSON响应
+// 返回过期缓存时仍会解析到 v，同时返回 StaleDataError
+func (c *APIClient) getJSON(path string, params map[string]string, v interface{}) error {
+	body, err := c.gateway.get(path, params)
+	if err != nil && !IsStaleData(err) {
+		return err
+	}
+	if jsonErr := json.Unmarshal(body, v); jsonErr != nil {
+		return jsonErr
+	}
+	return err
+}
+
+// GetOpenInterest 获取持仓量（以币计）
+func (c *APIClient) GetOpenInterest(symbol string) (*OIData, error) {
+	var result struct {
+		OpenInterest string `json:"openInterest"`
+		Symbol       string `json:"symbol"`
+		Time         int64  `json:"time"`
+	}
+	if err := c.getJSON("/fapi/v1/openInterest", map[string]string{"symbol": symbol}, &result); err != nil {
+		return nil, err
+	}
+
+	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
+
+	return &OIData{
+		Latest:  oi,
+		Average: oi * 0.999, // 近似平均值
+	}, nil
+}
+
+// PremiumIndex 标记价格、指数价格和下次结算的资金费率
+type PremiumIndex struct {
+	MarkPrice       float64
+	IndexPrice      float64
+	FundingRate     float64 // 下次结算的预测资金费率（单次结算）
+	NextFundingTime int64   // 下次结算时间（毫秒）
+}
+
+// GetPremiumIndex 获取标记价格和预测资金费率
+func (c *APIClient) GetPremiumIndex(symbol string) (*PremiumIndex, error) {
+	var result struct {
+		MarkPrice       string `json:"markPrice"`
+		IndexPrice      string `json:"indexPrice"`
+		LastFundingRate string `json:"lastFundingRate"`
+		NextFundingTime int64  `json:"nextFundingTime"`
+	}
+	if err := c.getJSON("/fapi/v1/premiumIndex", map[string]string{"symbol": symbol}, &result); err != nil {
+		return nil, err
+	}
+
+	index := &PremiumIndex{NextFundingTime: result.NextFundingTime}
+	index.MarkPrice, _ = strconv.ParseFloat(result.MarkPrice, 64)
+	index.IndexPrice, _ = strconv.ParseFloat(result.IndexPrice, 64)
+	index.FundingRate, _ = strconv.ParseFloat(result.LastFundingRate, 64)
+	return index, nil
+}
+
+// GetFundingHistory 获取最近 limit 次资金费结算（从旧到新，费率为单次结算费率）
+func (c *APIClient) GetFundingHistory(symbol string, limit int) ([]FundingSettlement, error) {
+	var result []struct {
+		FundingRate string `json:"fundingRate"`
+		FundingTime int64  `json:"fundingTime"`
+		MarkPrice   string `json:"markPrice"`
+	}
+	params := map[string]string{"symbol": symbol, "limit": strconv.Itoa(limit)}
+	if err := c.getJSON("/fapi/v1/fundingRate", params, &result); err != nil {
+		return nil, err
+	}
+
+	history := make([]FundingSettlement, 0, len(result))
+	for _, item := range result {
+		settlement := FundingSettlement{Time: time.UnixMilli(item.FundingTime)}
+		settlement.Rate, _ = strconv.ParseFloat(item.FundingRate, 64)
+		settlement.MarkPrice, _ = strconv.ParseFloat(item.MarkPrice, 64)
+		history = append(history, settlement)
+	}
+	return history, nil
+}
+
+// GetTopLongShortPositionRatio 获取大户持仓多空比（最近一个 period 周期）
+func (c *APIClient) GetTopLongShortPositionRatio(symbol, period string) (ratio, longPct, shortPct float64, err error) {
+	var result []struct {
+		LongShortRatio string `json:"longShortRatio"`
+		LongAccount    string `json:"longAccount"`
+		ShortAccount   string `json:"shortAccount"`
+	}
+	params := map[string]string{"symbol": symbol, "period": period, "limit": "1"}
+	if err := c.getJSON("/futures/data/topLongShortPositionRatio", params, &result); err != nil {
+		return 0, 0, 0, err
+	}
+	if len(result) == 0 {
+		return 0, 0, 0, fmt.Errorf("没有 %s 的大户多空比数据", symbol)
+	}
+
+	ratio, _ = strconv.ParseFloat(result[0].LongShortRatio, 64)
+	longPct, _ = strconv.ParseFloat(result[0].LongAccount, 64)
+	shortPct, _ = strconv.ParseFloat(result[0].ShortAccount, 64)
+	return ratio, longPct * 100, shortPct * 100, nil
+}
+
+// GetTakerLongShortRatio 获取主动买卖量比（最近一个 period 周期，成交量以币计）
+func (c *APIClient) GetTakerLongShortRatio(symbol, period string) (ratio, buyVolume, sellVolume float64, err error) {
+	var result []struct {
+		BuySellRatio string `json:"buySellRatio"`
+		BuyVol       string `json:"buyVol"`
+		SellVol      string `json:"sellVol"`
+	}
+	params := map[string]string{"symbol": symbol, "period": period, "limit": "1"}
+	if err := c.getJSON("/futures/data/takerlongshortRatio", params, &result); err != nil {
+		return 0, 0, 0, err
+	}
+	if len(result) == 0 {
+		return 0, 0, 0, fmt.Errorf("没有 %s 的主动买卖比数据", symbol)
+	}
+
+	ratio, _ = strconv.ParseFloat(result[0].BuySellRatio, 64)
+	buyVolume, _ = strconv.ParseFloat(result[0].BuyVol, 64)
+	sellVolume, _ = strconv.ParseFloat(result[0].SellVol, 64)
+	return ratio, buyVolume, sellVolume, nil
 }
@@ -6,18 +6,25 @@ import (
 	"log"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
 )
 
 type CombinedStreamsClient struct {
-	conn        *websocket.Conn
-	mu          sync.RWMutex
-	subscribers map[string]chan []byte
-	reconnect   bool
-	done        chan struct{}
-	batchSize   int // 每批订阅的流数量
+	conn          *websocket.Conn
+	mu            sync.RWMutex
+	writeMu       sync.Mutex // 串行化写入（gorilla/websocket 同一时间只允许一个写入者）
+	subscribers   map[string]chan []byte
+	reconnect     bool
+	done          chan struct{}
+	batchSize     int          // 每批订阅的流数量
+	lastMessageAt sync.Map     // 各流最后一条消息的时间（stream -> time.Time）
+	lastAnyAt     atomic.Int64 // 最后一条消息的时间（Unix毫秒，用于判断连接是否假死）
+	reconnects    int          // 重连次数
+	lastReconnect time.Time    // 最近一次重连成功的时间
+	onReconnect   func()       // 重连并恢复订阅后的回调（用于回填断线期间缺失的K线）
 }
 
 func NewCombinedStreamsClient(batchSize int) *CombinedStreamsClient {
@@ -76,6 +83,50 @@ func (c *CombinedStreamsClient) BatchSubscribeKlines(symbols []string, interval
 	return nil
 }
 
+// BatchSubscribeDepth 批量订阅前20档订单簿（每100毫秒推送一次完整快照）
+func (c *CombinedStreamsClient) BatchSubscribeDepth(symbols []string) error {
+	streams := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = depthStream(symbol)
+	}
+
+	batches := c.splitIntoBatches(streams, c.batchSize)
+	for i, batch := range batches {
+		if err := c.subscribeStreams(batch); err != nil {
+			return fmt.Errorf("第 %d 批订阅深度失败: %v", i+1, err)
+		}
+
+		// 批次间延迟，避免被限制
+		if i < len(batches)-1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	return nil
+}
+
+// BatchSubscribeAggTrades 批量订阅归集成交流
+func (c *CombinedStreamsClient) BatchSubscribeAggTrades(symbols []string) error {
+	streams := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = aggTradeStream(symbol)
+	}
+
+	batches := c.splitIntoBatches(streams, c.batchSize)
+	for i, batch := range batches {
+		if err := c.subscribeStreams(batch); err != nil {
+			return fmt.Errorf("第 %d 批订阅归集成交失败: %v", i+1, err)
+		}
+
+		// 批次间延迟，避免被限制
+		if i < len(batches)-1 {
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+
+	return nil
+}
+
 // splitIntoBatches 将切片分成指定大小的批次
 func (c *CombinedStreamsClient) splitIntoBatches(symbols []string, batchSize int) [][]string {
 	var batches [][]string
@@ -99,15 +150,35 @@ func (c *CombinedStreamsClient) subscribeStreams(streams []string) error {
 		"id":     time.Now().UnixNano(),
 	}
 
+	log.Printf("订阅流: %v", streams)
+	return c.writeJSON(subscribeMsg)
+}
+
+// unsubscribeStreams 取消订阅多个流
+func (c *CombinedStreamsClient) unsubscribeStreams(streams []string) error {
+	unsubscribeMsg := map[string]interface{}{
+		"method": "UNSUBSCRIBE",
+		"params": streams,
+		"id":     time.Now().UnixNano(),
+	}
+
+	log.Printf("取消订阅流: %v", streams)
+	return c.writeJSON(unsubscribeMsg)
+}
+
+// writeJSON 发送JSON消息（运行时订阅/取消订阅与重连后恢复订阅可能并发，写入需持有 writeMu）
+func (c *CombinedStreamsClient) writeJSON(v interface{}) error {
 	c.mu.RLock()
-	defer c.mu.RUnlock()
+	conn := c.conn
+	c.mu.RUnlock()
 
-	if c.conn == nil {
+	if conn == nil {
 		return fmt.Errorf("WebSocket未连接")
 	}
 
-	log.Printf("订阅流: %v", streams)
-	return c.conn.WriteJSON(subscribeMsg)
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return conn.WriteJSON(v)
 }
 
 func (c *CombinedStreamsClient) readMessages() {
@@ -147,12 +218,20 @@ func (c *CombinedStreamsClient) handleCombinedMessage(message []byte) {
 		log.Printf("解析组合消息失败: %v", err)
 		return
 	}
+	if combinedMsg.Stream == "" {
+		// 订阅/取消订阅的响应
+		return
+	}
+
+	now := time.Now()
+	c.lastMessageAt.Store(combinedMsg.Stream, now)
+	c.lastAnyAt.Store(now.UnixMilli())
 
+	// 发送时持有读锁，避免通道被 RemoveSubscriber 同时关闭
 	c.mu.RLock()
-	ch, exists := c.subscribers[combinedMsg.Stream]
-	c.mu.RUnlock()
+	defer c.mu.RUnlock()
 
-	if exists {
+	if ch, exists := c.subscribers[combinedMsg.Stream]; exists {
 		select {
 		case ch <- combinedMsg.Data:
 		default:
@@ -169,17 +248,100 @@ func (c *CombinedStreamsClient) AddSubscriber(stream string, bufferSize int) <-c
 	return ch
 }
 
+// RemoveSubscriber 移除订阅者并关闭其通道
+func (c *CombinedStreamsClient) RemoveSubscriber(stream string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if ch, exists := c.subscribers[stream]; exists {
+		close(ch)
+		delete(c.subscribers, stream)
+	}
+	c.lastMessageAt.Delete(stream)
+}
+
+// SetOnReconnect 设置重连并恢复订阅后的回调
+func (c *CombinedStreamsClient) SetOnReconnect(fn func()) {
+	c.mu.Lock()
+	c.onReconnect = fn
+	c.mu.Unlock()
+}
+
+// LastMessageTime 获取流最后一条消息的时间（没有收到过消息时返回 false）
+func (c *CombinedStreamsClient) LastMessageTime(stream string) (time.Time, bool) {
+	value, ok := c.lastMessageAt.Load(stream)
+	if !ok {
+		return time.Time{}, false
+	}
+	return value.(time.Time), true
+}
+
+// LastAnyMessageTime 获取任意流最后一条消息的时间
+func (c *CombinedStreamsClient) LastAnyMessageTime() time.Time {
+	if ms := c.lastAnyAt.Load(); ms > 0 {
+		return time.UnixMilli(ms)
+	}
+	return time.Time{}
+}
+
+// ConnectionStats 获取连接状态和重连统计
+func (c *CombinedStreamsClient) ConnectionStats() (connected bool, reconnects int, lastReconnect time.Time) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return c.conn != nil, c.reconnects, c.lastReconnect
+}
+
+// dropConnection 主动断开连接（连接假死时使用，读取失败后自动重连）
+func (c *CombinedStreamsClient) dropConnection() {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.conn != nil {
+		c.conn.Close()
+	}
+}
+
 func (c *CombinedStreamsClient) handleReconnect() {
 	if !c.reconnect {
 		return
 	}
 
 	log.Println("组合流尝试重新连接...")
+	c.mu.Lock()
+	if c.conn != nil {
+		c.conn.Close()
+		c.conn = nil
+	}
+	c.mu.Unlock()
 	time.Sleep(3 * time.Second)
 
 	if err := c.Connect(); err != nil {
 		log.Printf("组合流重新连接失败: %v", err)
 		go c.handleReconnect()
+		return
+	}
+
+	// 新连接不会保留之前的订阅，需要重新订阅所有流
+	c.mu.Lock()
+	c.reconnects++
+	c.lastReconnect = time.Now()
+	streams := make([]string, 0, len(c.subscribers))
+	for stream := range c.subscribers {
+		streams = append(streams, stream)
+	}
+	reconnects := c.reconnects
+	onReconnect := c.onReconnect
+	c.mu.Unlock()
+
+	for _, batch := range c.splitIntoBatches(streams, c.batchSize) {
+		if err := c.subscribeStreams(batch); err != nil {
+			log.Printf("⚠️  组合流重连后恢复订阅失败: %v", err)
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	log.Printf("🔌 组合流已重连（第%d次），恢复订阅 %d 个流", reconnects, len(streams))
+
+	if onReconnect != nil {
+		go onReconnect()
 	}
 }
 
@@ -1,26 +1,28 @@
 package market
 
 import (
-	"encoding/json"
 	"fmt"
-	"io"
 	"log"
 	"math"
-	"net/http"
 	"strconv"
 	"strings"
 	"time"
 )
 
-// Get 获取指定代币的市场数据
+// Get 获取指定代币的市场数据（币安行情）
 func Get(symbol string) (*Data, error) {
+	return GetWithProvider(ProviderFor(ProviderBinance, false), symbol)
+}
+
+// GetWithProvider 从指定交易所的行情数据源获取市场数据（价格、资金费率与实际交易的交易所一致）
+func GetWithProvider(provider MarketDataProvider, symbol string) (*Data, error) {
 	var klines3m, klines5m, klines15m, klines30m, klines1h, klines4h []Kline
 	var err error
 	// 标准化symbol
 	symbol = Normalize(symbol)
 	// 获取3分钟K线数据 (最近100个)
 	// 3分钟信号对短期获利至关重要，需要确保数据准确
-	klines3m, err = WSMonitorCli.GetCurrentKlines(symbol, "3m") // 多获取一些用于计算
+	klines3m, err = provider.GetKlines(symbol, "3m", klineLimit) // 多获取一些用于计算
 	if err != nil {
 		return nil, fmt.Errorf("获取3分钟K线失败: %v (3分钟信号对短期获利至关重要)", err)
 	} else if len(klines3m) < 11 {
@@ -37,14 +39,14 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取4小时K线数据 (最近100个) - 先获取，用于30分钟fallback
-	klines4h, err = WSMonitorCli.GetCurrentKlines(symbol, "4h") // 多获取用于计算指标
+	klines4h, err = provider.GetKlines(symbol, "4h", klineLimit) // 多获取用于计算指标
 	if err != nil {
 		return nil, fmt.Errorf("获取4小时K线失败: %v", err)
 	}
 
 	// 获取5分钟K线数据（必须获取真实数据，不使用fallback）
 	// 5分钟信号对短期策略至关重要，需要确保数据准确
-	klines5m, err = WSMonitorCli.GetCurrentKlines(symbol, "5m")
+	klines5m, err = provider.GetKlines(symbol, "5m", klineLimit)
 	if err != nil {
 		log.Printf("⚠️  获取 %s 5分钟K线失败: %v (5分钟信号对短期策略至关重要)", symbol, err)
 		// 不使用fallback，保持为空，后续会标记为数据不足
@@ -55,7 +57,7 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取15分钟K线数据（必须获取真实数据，不使用fallback）
-	klines15m, err = WSMonitorCli.GetCurrentKlines(symbol, "15m")
+	klines15m, err = provider.GetKlines(symbol, "15m", klineLimit)
 	if err != nil {
 		log.Printf("⚠️  获取 %s 15分钟K线失败: %v", symbol, err)
 		// 不使用fallback，保持为空，后续会标记为数据不足
@@ -63,7 +65,7 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取30分钟K线数据（必须获取真实数据，不使用fallback）
-	klines30m, err = WSMonitorCli.GetCurrentKlines(symbol, "30m")
+	klines30m, err = provider.GetKlines(symbol, "30m", klineLimit)
 	if err != nil {
 		log.Printf("⚠️  获取 %s 30分钟K线失败: %v", symbol, err)
 		// 不使用fallback，保持为空，后续会标记为数据不足
@@ -71,7 +73,7 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取1小时K线数据（必须获取真实数据，不使用fallback）
-	klines1h, err = WSMonitorCli.GetCurrentKlines(symbol, "1h")
+	klines1h, err = provider.GetKlines(symbol, "1h", klineLimit)
 	if err != nil {
 		log.Printf("⚠️  获取 %s 1小时K线失败: %v", symbol, err)
 		// 不使用fallback，保持为空，后续会标记为数据不足
@@ -104,14 +106,21 @@ func Get(symbol string) (*Data, error) {
 	}
 
 	// 获取OI数据
-	oiData, err := getOpenInterestData(symbol)
+	oiData, err := provider.GetOpenInterest(symbol)
 	if err != nil {
 		// OI失败不影响整体,使用默认值
 		oiData = &OIData{Latest: 0, Average: 0}
 	}
 
-	// 获取Funding Rate
-	fundingRate, _ := getFundingRate(symbol)
+	// 获取资金费率（含预测费率、下次结算时间和结算历史，失败不影响整体）
+	fundingRate := 0.0
+	funding, err := provider.GetFunding(symbol)
+	if err == nil {
+		fundingRate = funding.PredictedRate
+	}
+
+	// 获取大户多空比和主动买卖比（失败不影响整体）
+	positioning, _ := provider.GetPositioning(symbol)
 
 	// 计算日内系列数据
 	intradayData := calculateIntradaySeries(klines3m)
@@ -125,6 +134,21 @@ func Get(symbol string) (*Data, error) {
 	// 计算量价关系数据
 	volumePriceData := calculateVolumePriceData(klines3m, klines5m, klines30m, currentPrice)
 
+	// 计算扩展技术指标
+	technical := calculateTechnicalIndicators(klines3m, klines15m)
+
+	// 获取订单簿指标（失败不影响整体）
+	var orderBook *OrderBookMetrics
+	if book, err := provider.GetOrderBook(symbol); err == nil {
+		orderBook = book.Metrics()
+	}
+
+	// 获取订单流（失败不影响整体）
+	orderFlow, _ := provider.GetOrderFlow(symbol)
+
+	// 获取强平统计（失败不影响整体）
+	liquidations, _ := provider.GetLiquidations(symbol)
+
 	return &Data{
 		Symbol:            symbol,
 		CurrentPrice:      currentPrice,
@@ -139,6 +163,12 @@ func Get(symbol string) (*Data, error) {
 		LongerTermContext: longerTermData,
 		SupertrendData:    supertrendData,
 		VolumePriceData:   volumePriceData,
+		Technical:         technical,
+		OrderBook:         orderBook,
+		OrderFlow:         orderFlow,
+		Liquidations:      liquidations,
+		Funding:           funding,
+		Positioning:       positioning,
 	}, nil
 }
 
@@ -347,74 +377,12 @@ func calculateLongerTermData(klines []Kline) *LongerTermData {
 	return data
 }
 
-// getOpenInterestData 获取OI数据
-func getOpenInterestData(symbol string) (*OIData, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/openInterest?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-
-	var result struct {
-		OpenInterest string `json:"openInterest"`
-		Symbol       string `json:"symbol"`
-		Time         int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return nil, err
-	}
-
-	oi, _ := strconv.ParseFloat(result.OpenInterest, 64)
-
-	return &OIData{
-		Latest:  oi,
-		Average: oi * 0.999, // 近似平均值
-	}, nil
-}
-
-// getFundingRate 获取资金费率
-func getFundingRate(symbol string) (float64, error) {
-	url := fmt.Sprintf("https://fapi.binance.com/fapi/v1/premiumIndex?symbol=%s", symbol)
-
-	resp, err := http.Get(url)
-	if err != nil {
-		return 0, err
-	}
-	defer resp.Body.Close()
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return 0, err
-	}
-
-	var result struct {
-		Symbol          string `json:"symbol"`
-		MarkPrice       string `json:"markPrice"`
-		IndexPrice      string `json:"indexPrice"`
-		LastFundingRate string `json:"lastFundingRate"`
-		NextFundingTime int64  `json:"nextFundingTime"`
-		InterestRate    string `json:"interestRate"`
-		Time            int64  `json:"time"`
-	}
-
-	if err := json.Unmarshal(body, &result); err != nil {
-		return 0, err
-	}
-
-	rate, _ := strconv.ParseFloat(result.LastFundingRate, 64)
-	return rate, nil
-}
-
 // Format 格式化输出市场数据
 func Format(data *Data) string {
+	if len(data.Timeframes) > 0 {
+		return formatWithProfile(data)
+	}
+
 	var sb strings.Builder
 
 	sb.WriteString(fmt.Sprintf("current_price = %.2f, current_ema20 = %.3f, current_macd = %.3f, current_rsi (7 period) = %.3f\n\n",
@@ -430,6 +398,11 @@ func Format(data *Data) string {
 
 	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
 
+	sb.WriteString(formatFunding(data.Funding))
+	sb.WriteString(formatPositioning(data.Positioning))
+
+	sb.WriteString(formatOrderBook(data.OrderBook))
+
 	if data.IntradaySeries != nil {
 		sb.WriteString("Intraday series (3‑minute intervals, oldest → latest):\n\n")
 
@@ -514,6 +487,12 @@ func Format(data *Data) string {
 		sb.WriteString(fmt.Sprintf("Price-Volume OK: %v\n\n", data.VolumePriceData.PriceVolumeOK))
 	}
 
+	// 添加逐笔成交订单流（K线成交量只有总量，看不到主动买卖方向）
+	sb.WriteString(formatOrderFlow(data.OrderFlow))
+
+	// 添加强平统计（强平连环爆仓是短线策略的重要信号）
+	sb.WriteString(formatLiquidations(data.Liquidations))
+
 	return sb.String()
 }
 
@@ -0,0 +1,214 @@
+package market
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// fundingHistoryLimit 获取的资金费结算历史次数
+const fundingHistoryLimit = 30
+
+// fundingHistoryShown 提示词中输出的最近结算次数
+const fundingHistoryShown = 8
+
+// fundingCacheTTL 预测资金费率缓存有效期（结算历史在下次结算前一直复用）
+const fundingCacheTTL = time.Minute
+
+// positioningCacheTTL 多空比数据缓存有效期（币安按5分钟周期更新）
+const positioningCacheTTL = 5 * time.Minute
+
+// positioningPeriod 多空比统计周期
+const positioningPeriod = "5m"
+
+// defaultFundingIntervalHours 默认资金费结算间隔（小时）
+const defaultFundingIntervalHours = 8
+
+// FundingSettlement 一次资金费结算
+type FundingSettlement struct {
+	Time      time.Time `json:"time"`
+	Rate      float64   `json:"rate"`       // 结算费率（8小时口径）
+	MarkPrice float64   `json:"mark_price"` // 结算时的标记价格（未知时为0）
+}
+
+// FundingData 资金费率数据（费率统一换算为8小时口径，与 Data.FundingRate 一致）
+type FundingData struct {
+	PredictedRate   float64             `json:"predicted_rate"`    // 下次结算的预测费率
+	LastSettledRate float64             `json:"last_settled_rate"` // 最近一次结算的费率
+	AvgRate         float64             `json:"avg_rate"`          // 结算历史的平均费率
+	AnnualizedPct   float64             `json:"annualized_pct"`    // 按预测费率计算的年化（%）
+	NextFundingTime time.Time           `json:"next_funding_time"`
+	IntervalHours   float64             `json:"interval_hours"` // 结算间隔（小时）
+	MarkPrice       float64             `json:"mark_price"`
+	IndexPrice      float64             `json:"index_price"`
+	PremiumPct      float64             `json:"premium_pct"` // 标记价格相对指数价格的溢价（%）
+	History         []FundingSettlement `json:"history"`     // 最近的结算记录（从旧到新）
+	UpdatedAt       time.Time           `json:"updated_at"`
+}
+
+// PositioningData 多空持仓数据（币安大户多空比和主动买卖比）
+type PositioningData struct {
+	Period                  string    `json:"period"`
+	TopTraderLongShortRatio float64   `json:"top_trader_long_short_ratio"` // 大户持仓多空比
+	TopTraderLongPct        float64   `json:"top_trader_long_pct"`         // 大户多头持仓占比（%）
+	TopTraderShortPct       float64   `json:"top_trader_short_pct"`
+	TakerBuySellRatio       float64   `json:"taker_buy_sell_ratio"` // 主动买入量/主动卖出量
+	TakerBuyVolume          float64   `json:"taker_buy_volume"`     // 主动买入量（以币计）
+	TakerSellVolume         float64   `json:"taker_sell_volume"`
+	UpdatedAt               time.Time `json:"updated_at"`
+}
+
+// newFundingData 根据预测费率和结算历史计算资金费率数据
+// predictedRate 为单次结算费率，history 为已换算为8小时口径的结算历史
+func newFundingData(predictedRate float64, nextFundingTime time.Time, intervalHours float64, history []FundingSettlement) *FundingData {
+	data := &FundingData{
+		PredictedRate:   predictedRate * defaultFundingIntervalHours / intervalHours,
+		NextFundingTime: nextFundingTime,
+		IntervalHours:   intervalHours,
+		History:         history,
+		UpdatedAt:       time.Now(),
+	}
+	data.AnnualizedPct = data.PredictedRate * 3 * 365 * 100
+
+	if len(history) > 0 {
+		sum := 0.0
+		for _, settlement := range history {
+			sum += settlement.Rate
+		}
+		data.AvgRate = sum / float64(len(history))
+		data.LastSettledRate = history[len(history)-1].Rate
+	}
+	return data
+}
+
+// fundingInterval 根据最近两次结算的时间间隔推算结算周期（小时），历史不足时返回默认值
+func fundingInterval(history []FundingSettlement) float64 {
+	n := len(history)
+	if n < 2 {
+		return defaultFundingIntervalHours
+	}
+	hours := math.Round(history[n-1].Time.Sub(history[n-2].Time).Hours())
+	if hours <= 0 {
+		return defaultFundingIntervalHours
+	}
+	return hours
+}
+
+// normalizeFundingHistory 把单次结算费率换算为8小时口径
+func normalizeFundingHistory(history []FundingSettlement, intervalHours float64) []FundingSettlement {
+	normalized := make([]FundingSettlement, len(history))
+	for i, settlement := range history {
+		settlement.Rate = settlement.Rate * defaultFundingIntervalHours / intervalHours
+		normalized[i] = settlement
+	}
+	return normalized
+}
+
+// EstimateFundingCost 按结算历史估算持仓自 openedAt 以来的资金费（USDT，正数为支付，负数为收取）
+// 持仓时间早于结算历史的部分不计入；结算时标记价格未知时使用 markPrice
+func EstimateFundingCost(funding *FundingData, side string, quantity, markPrice float64, openedAt time.Time) float64 {
+	if funding == nil {
+		return 0
+	}
+
+	cost := 0.0
+	for _, settlement := range funding.History {
+		if !settlement.Time.After(openedAt) {
+			continue
+		}
+		price := settlement.MarkPrice
+		if price == 0 {
+			price = markPrice
+		}
+		// 结算历史为8小时口径，换算回单次结算费率
+		cost += quantity * price * settlement.Rate * funding.IntervalHours / defaultFundingIntervalHours
+	}
+	// 费率为正时多头支付、空头收取
+	if side == "short" {
+		cost = -cost
+	}
+	return cost
+}
+
+// fundingCache 资金费率缓存（symbol -> *FundingData）
+type fundingCache struct {
+	mu      sync.Mutex
+	entries map[string]*FundingData
+}
+
+func (c *fundingCache) get(symbol string) *FundingData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[symbol]
+}
+
+func (c *fundingCache) put(symbol string, data *FundingData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*FundingData)
+	}
+	c.entries[symbol] = data
+}
+
+// positioningCache 多空比缓存（symbol -> *PositioningData）
+type positioningCache struct {
+	mu      sync.Mutex
+	entries map[string]*PositioningData
+}
+
+func (c *positioningCache) get(symbol string) *PositioningData {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.entries[symbol]
+}
+
+func (c *positioningCache) put(symbol string, data *PositioningData) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.entries == nil {
+		c.entries = make(map[string]*PositioningData)
+	}
+	c.entries[symbol] = data
+}
+
+// formatFunding 格式化资金费率数据（没有数据时返回空字符串）
+func formatFunding(funding *FundingData) string {
+	if funding == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Funding (8h basis): predicted=%.4f%%, last settled=%.4f%%, avg of last %d=%.4f%%, annualized=%.1f%%, premium=%.4f%%\n",
+		funding.PredictedRate*100, funding.LastSettledRate*100, len(funding.History), funding.AvgRate*100,
+		funding.AnnualizedPct, funding.PremiumPct))
+	if !funding.NextFundingTime.IsZero() {
+		sb.WriteString(fmt.Sprintf("Next settlement in %s (every %.0fh)\n",
+			strings.TrimSuffix(time.Until(funding.NextFundingTime).Round(time.Minute).String(), "0s"), funding.IntervalHours))
+	}
+	if len(funding.History) > 0 {
+		history := funding.History
+		if len(history) > fundingHistoryShown {
+			history = history[len(history)-fundingHistoryShown:]
+		}
+		rates := make([]string, len(history))
+		for i, settlement := range history {
+			rates[i] = fmt.Sprintf("%.4f%%", settlement.Rate*100)
+		}
+		sb.WriteString(fmt.Sprintf("Funding history (oldest → latest): [%s]\n", strings.Join(rates, ", ")))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
+
+// formatPositioning 格式化多空持仓数据（没有数据时返回空字符串）
+func formatPositioning(positioning *PositioningData) string {
+	if positioning == nil {
+		return ""
+	}
+	return fmt.Sprintf("Positioning (%s): top trader long/short ratio=%.2f (long %.1f%% / short %.1f%%), taker buy/sell ratio=%.2f\n\n",
+		positioning.Period, positioning.TopTraderLongShortRatio, positioning.TopTraderLongPct, positioning.TopTraderShortPct,
+		positioning.TakerBuySellRatio)
+}
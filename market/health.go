@@ -0,0 +1,334 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// staleStreamAfter K线流超过该时间没有收到消息视为过时
+const staleStreamAfter = 2 * time.Minute
+
+// healthCheckInterval 流健康检查间隔
+const healthCheckInterval = 30 * time.Second
+
+// primaryKlineInterval 判断币种数据是否过时使用的K线周期（当前价格和短期指标来自3分钟K线）
+const primaryKlineInterval = "3m"
+
+// StreamHealth 单个K线流的健康状态
+type StreamHealth struct {
+	Stream      string    `json:"stream"`
+	Symbol      string    `json:"symbol"`
+	Interval    string    `json:"interval"`
+	LastMessage time.Time `json:"last_message"` // 最后一条消息的时间（零值表示订阅后从未收到消息）
+	AgeSeconds  float64   `json:"age_seconds"`  // 距最后一条消息（或开始订阅）的秒数
+}
+
+// HealthSummary WebSocket行情健康状况
+type HealthSummary struct {
+	Connected     bool              `json:"connected"`      // 组合流是否已连接
+	Symbols       int               `json:"symbols"`        // 已订阅的币种数量
+	Streams       int               `json:"streams"`        // 已订阅的K线流数量
+	StaleStreams  []StreamHealth    `json:"stale_streams"`  // 过时的K线流
+	StaleSymbols  map[string]string `json:"stale_symbols"`  // 数据过时的币种及原因（开仓前检查使用）
+	Reconnects    int               `json:"reconnects"`     // 重连次数
+	LastReconnect time.Time         `json:"last_reconnect"` // 最近一次重连时间
+	LastMessage   time.Time         `json:"last_message"`   // 最近一条消息的时间
+	LastGapFill   time.Time         `json:"last_gap_fill"`  // 最近一次回填K线的时间
+	REST          []RESTStats       `json:"rest"`           // 行情REST接口的缓存和请求权重统计
+	CheckedAt     time.Time         `json:"checked_at"`
+}
+
+// runHealthCheck 定期检查K线流健康状况
+func (m *WSMonitor) runHealthCheck() {
+	ticker := time.NewTicker(healthCheckInterval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		m.checkHealth()
+	}
+}
+
+// checkHealth 检查过时的K线流：连接假死时主动重连，个别流过时时用REST回填，状态变化时输出日志
+func (m *WSMonitor) checkHealth() {
+	connected, _, _ := m.combinedClient.ConnectionStats()
+	if !connected {
+		return // 正在重连，重连成功后会回填
+	}
+
+	stale := m.staleStreams()
+	if streams := m.streamCount(); streams > 0 && len(stale) == streams {
+		last := m.combinedClient.LastAnyMessageTime()
+		log.Printf("⚠️  组合流所有K线流均已过时（最后消息: %s），主动断开重连", formatLastMessage(last))
+		m.combinedClient.dropConnection()
+		return
+	}
+
+	current := make(map[string]string)
+	var refill []StreamHealth
+	for _, health := range stale {
+		if health.Interval == primaryKlineInterval {
+			current[health.Symbol] = fmt.Sprintf("%s K线流 %.0f 秒没有更新", health.Interval, health.AgeSeconds)
+		}
+		refill = append(refill, health)
+	}
+
+	m.healthMu.Lock()
+	for symbol, reason := range current {
+		if _, already := m.staleSymbols[symbol]; !already {
+			log.Printf("⚠️  %s 行情数据过时: %s", symbol, reason)
+		}
+	}
+	for symbol := range m.staleSymbols {
+		if _, still := current[symbol]; !still {
+			log.Printf("✅ %s 行情数据已恢复", symbol)
+		}
+	}
+	m.staleSymbols = current
+	m.healthMu.Unlock()
+
+	// 过时的流用REST回填，保证缓存的K线尽量完整（流本身仍标记为过时，直到收到新消息）
+	if len(refill) > 0 {
+		apiClient := NewAPIClient()
+		for _, health := range refill {
+			if err := m.fillGap(apiClient, health.Symbol, health.Interval); err != nil {
+				log.Printf("⚠️  回填 %s 失败: %v", health.Stream, err)
+			}
+		}
+		m.markGapFill()
+	}
+}
+
+// streamCount 已订阅的K线流数量
+func (m *WSMonitor) streamCount() int {
+	count := 0
+	m.streamSince.Range(func(_, _ interface{}) bool {
+		count++
+		return true
+	})
+	return count
+}
+
+// staleStreams 超过 staleStreamAfter 没有收到消息的K线流
+func (m *WSMonitor) staleStreams() []StreamHealth {
+	now := time.Now()
+	var stale []StreamHealth
+	m.streamSince.Range(func(key, value interface{}) bool {
+		stream := key.(string)
+		since := value.(time.Time)
+		last, received := m.combinedClient.LastMessageTime(stream)
+		if received && last.After(since) {
+			since = last
+		}
+		if age := now.Sub(since); age > staleStreamAfter {
+			symbol, interval := parseKlineStream(stream)
+			health := StreamHealth{Stream: stream, Symbol: symbol, Interval: interval, AgeSeconds: age.Seconds()}
+			if received {
+				health.LastMessage = last
+			}
+			stale = append(stale, health)
+		}
+		return true
+	})
+	sort.Slice(stale, func(i, j int) bool { return stale[i].Stream < stale[j].Stream })
+	return stale
+}
+
+// StaleReason 币种的行情数据是否过时（返回原因，空字符串表示正常）
+// 只检查已订阅的币种，未订阅的币种每次通过REST获取，不存在过时问题
+func (m *WSMonitor) StaleReason(symbol string) string {
+	symbol = Normalize(symbol)
+	if !m.isStreamActive(symbol, primaryKlineInterval) {
+		return ""
+	}
+
+	stream := klineStream(symbol, primaryKlineInterval)
+	value, subscribed := m.streamSince.Load(stream)
+	if !subscribed {
+		return "" // 组合流尚未连接，使用REST回填的数据
+	}
+	since := value.(time.Time)
+	if last, received := m.combinedClient.LastMessageTime(stream); received && last.After(since) {
+		since = last
+	}
+	if age := time.Since(since); age > staleStreamAfter {
+		return fmt.Sprintf("%s K线流 %.0f 秒没有更新", primaryKlineInterval, age.Seconds())
+	}
+	return ""
+}
+
+// Health 获取WebSocket行情健康状况
+func (m *WSMonitor) Health() *HealthSummary {
+	connected, reconnects, lastReconnect := m.combinedClient.ConnectionStats()
+
+	symbols := make(map[string]bool)
+	m.activeStreams.Range(func(key, _ interface{}) bool {
+		if isKlineStream(key.(string)) {
+			symbol, _ := parseKlineStream(key.(string))
+			symbols[symbol] = true
+		}
+		return true
+	})
+
+	summary := &HealthSummary{
+		Connected:     connected,
+		Symbols:       len(symbols),
+		Streams:       m.streamCount(),
+		StaleStreams:  m.staleStreams(),
+		StaleSymbols:  make(map[string]string),
+		Reconnects:    reconnects,
+		LastReconnect: lastReconnect,
+		LastMessage:   m.combinedClient.LastAnyMessageTime(),
+		REST:          GetRESTStats(),
+		CheckedAt:     time.Now(),
+	}
+	for _, health := range summary.StaleStreams {
+		if health.Interval == primaryKlineInterval {
+			summary.StaleSymbols[health.Symbol] = fmt.Sprintf("%s K线流 %.0f 秒没有更新", health.Interval, health.AgeSeconds)
+		}
+	}
+
+	m.healthMu.Lock()
+	summary.LastGapFill = m.lastGapFill
+	m.healthMu.Unlock()
+	return summary
+}
+
+// fillGaps 断线重连后用REST回填所有已订阅K线流缺失的K线
+func (m *WSMonitor) fillGaps() {
+	var streams []string
+	m.activeStreams.Range(func(key, _ interface{}) bool {
+		if isKlineStream(key.(string)) {
+			streams = append(streams, key.(string)) // 订单簿流每次推送完整快照，归集成交无法回填
+		}
+		return true
+	})
+	if len(streams) == 0 {
+		return
+	}
+
+	log.Printf("🔧 回填 %d 个K线流断线期间缺失的K线...", len(streams))
+	apiClient := NewAPIClient()
+
+	var wg sync.WaitGroup
+	semaphore := make(chan struct{}, 5) // 限制并发数
+	failed := 0
+	var failedMu sync.Mutex
+
+	for _, stream := range streams {
+		wg.Add(1)
+		semaphore <- struct{}{}
+
+		go func(s string) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			symbol, st := parseKlineStream(s)
+			if err := m.fillGap(apiClient, symbol, st); err != nil {
+				log.Printf("⚠️  回填 %s K线失败: %v", s, err)
+				failedMu.Lock()
+				failed++
+				failedMu.Unlock()
+			}
+		}(stream)
+	}
+	wg.Wait()
+
+	m.markGapFill()
+	log.Printf("✅ K线回填完成（%d 个K线流，失败 %d 个）", len(streams), failed)
+}
+
+// fillGap 用REST回填单个K线流缺失的K线（从缓存中最后一根K线开始；缺口超过缓存长度时整体重新加载）
+func (m *WSMonitor) fillGap(apiClient *APIClient, symbol, interval string) error {
+	klineDataMap := m.getKlineDataMap(interval)
+
+	var lastOpenTime int64
+	if value, exists := klineDataMap.Load(symbol); exists {
+		if klines := value.([]Kline); len(klines) > 0 {
+			lastOpenTime = klines[len(klines)-1].OpenTime
+		}
+	}
+
+	var fetched []Kline
+	var err error
+	duration := klineIntervals[interval]
+	missing := 0
+	if lastOpenTime > 0 && duration > 0 {
+		missing = int(time.Since(time.UnixMilli(lastOpenTime))/duration) + 1
+	}
+	if lastOpenTime == 0 || missing <= 0 || missing >= klineLimit {
+		fetched, err = apiClient.GetKlines(symbol, interval, klineLimit)
+		lastOpenTime = 0
+	} else {
+		fetched, err = apiClient.GetKlinesFrom(symbol, interval, lastOpenTime, klineLimit)
+	}
+	if err != nil {
+		return err
+	}
+	if len(fetched) == 0 {
+		return nil
+	}
+
+	m.klineMu.Lock()
+	defer m.klineMu.Unlock()
+	// 取消订阅的流不再写入缓存
+	if !m.isStreamActive(symbol, interval) {
+		return nil
+	}
+	var existing []Kline
+	if value, exists := klineDataMap.Load(symbol); exists && lastOpenTime > 0 {
+		existing = value.([]Kline)
+	}
+	klineDataMap.Store(symbol, mergeKlines(existing, fetched))
+	return nil
+}
+
+// markGapFill 记录回填时间
+func (m *WSMonitor) markGapFill() {
+	m.healthMu.Lock()
+	m.lastGapFill = time.Now()
+	m.healthMu.Unlock()
+}
+
+// mergeKlines 合并缓存的K线和REST获取的K线（开盘时间相同的以REST数据为准），保留最近 klineLimit 根
+func mergeKlines(existing, fetched []Kline) []Kline {
+	first := fetched[0].OpenTime
+	merged := make([]Kline, 0, len(existing)+len(fetched))
+	for _, kline := range existing {
+		if kline.OpenTime < first {
+			merged = append(merged, kline)
+		}
+	}
+	merged = append(merged, fetched...)
+	if len(merged) > klineLimit {
+		merged = merged[len(merged)-klineLimit:]
+	}
+	return merged
+}
+
+// klineStream K线流名称（BTCUSDT, 3m -> btcusdt@kline_3m）
+func klineStream(symbol, interval string) string {
+	return fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), interval)
+}
+
+// isKlineStream 是否为K线流（区别于订单簿流和归集成交流）
+func isKlineStream(stream string) bool {
+	return strings.Contains(stream, "@kline_")
+}
+
+// parseKlineStream 解析K线流名称（btcusdt@kline_3m -> BTCUSDT, 3m）
+func parseKlineStream(stream string) (string, string) {
+	symbol, interval, _ := strings.Cut(stream, "@kline_")
+	return strings.ToUpper(symbol), interval
+}
+
+// formatLastMessage 格式化最后消息时间
+func formatLastMessage(t time.Time) string {
+	if t.IsZero() {
+		return "从未收到"
+	}
+	return t.Format("15:04:05")
+}
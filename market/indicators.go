@@ -0,0 +1,402 @@
+package market
+
+import (
+	"math"
+	"time"
+)
+
+// TechnicalIndicators 扩展技术指标（基于3分钟K线，与 current_* 指标一致；VWAP 使用15分钟K线覆盖完整交易日）
+type TechnicalIndicators struct {
+	MACD      *MACDData      `json:"macd"`       // MACD(12,26,9)
+	Bollinger *BollingerData `json:"bollinger"`  // 布林带(20,2)
+	VWAP      float64        `json:"vwap"`       // 当日（UTC 0点起）成交量加权均价
+	StochRSI  *StochRSIData  `json:"stoch_rsi"`  // 随机RSI(14,14,3,3)
+	ADX       *ADXData       `json:"adx"`        // ADX/DMI(14)
+	OBV       float64        `json:"obv"`        // 能量潮（K线窗口内累计）
+	OBVChange float64        `json:"obv_change"` // 最近10根K线的OBV变化
+	Ichimoku  *IchimokuData  `json:"ichimoku"`   // 一目均衡表(9,26,52)
+	Donchian  *DonchianData  `json:"donchian"`   // 唐奇安通道(20)
+}
+
+// MACDData MACD线、信号线和柱状图
+type MACDData struct {
+	MACD      float64 `json:"macd"`
+	Signal    float64 `json:"signal"`
+	Histogram float64 `json:"histogram"`
+}
+
+// BollingerData 布林带
+type BollingerData struct {
+	Upper    float64 `json:"upper"`
+	Middle   float64 `json:"middle"`
+	Lower    float64 `json:"lower"`
+	WidthPct float64 `json:"width_pct"` // 带宽（上下轨距离/中轨，%）
+	PercentB float64 `json:"percent_b"` // %B（价格在带内的位置，0=下轨，1=上轨）
+}
+
+// StochRSIData 随机RSI
+type StochRSIData struct {
+	K float64 `json:"k"`
+	D float64 `json:"d"`
+}
+
+// ADXData ADX趋势强度及方向指标
+type ADXData struct {
+	ADX     float64 `json:"adx"`
+	PlusDI  float64 `json:"plus_di"`
+	MinusDI float64 `json:"minus_di"`
+}
+
+// IchimokuData 一目均衡表（先行带为当前K线对应的云层，即 kijun 周期之前计算的值）
+type IchimokuData struct {
+	Tenkan        float64 `json:"tenkan"`
+	Kijun         float64 `json:"kijun"`
+	SenkouA       float64 `json:"senkou_a"`
+	SenkouB       float64 `json:"senkou_b"`
+	CloudPosition string  `json:"cloud_position"` // 价格相对云层的位置: above/below/inside
+}
+
+// DonchianData 唐奇安通道
+type DonchianData struct {
+	Upper  float64 `json:"upper"`
+	Middle float64 `json:"middle"`
+	Lower  float64 `json:"lower"`
+}
+
+// calculateTechnicalIndicators 计算扩展技术指标（数据不足的指标为空）
+func calculateTechnicalIndicators(klines3m, klines15m []Kline) *TechnicalIndicators {
+	data := &TechnicalIndicators{
+		MACD:      calculateMACDLines(klines3m, 12, 26, 9),
+		Bollinger: calculateBollinger(klines3m, 20, 2),
+		StochRSI:  calculateStochRSI(klines3m, 14, 14, 3, 3),
+		ADX:       calculateADX(klines3m, 14),
+		Ichimoku:  calculateIchimoku(klines3m, 9, 26, 52),
+		Donchian:  calculateDonchian(klines3m, 20),
+	}
+	if len(klines15m) > 0 {
+		data.VWAP = calculateVWAP(klines15m, sessionStart(klines15m))
+	}
+	data.OBV, data.OBVChange = calculateOBV(klines3m, 10)
+	return data
+}
+
+// emaSeries 计算EMA序列（与 calculateEMA 相同，以前 period 个值的SMA作为初始值；之前的值为0）
+func emaSeries(values []float64, period int) []float64 {
+	if len(values) < period {
+		return nil
+	}
+	series := make([]float64, len(values))
+	sum := 0.0
+	for i := 0; i < period; i++ {
+		sum += values[i]
+	}
+	series[period-1] = sum / float64(period)
+
+	multiplier := 2.0 / float64(period+1)
+	for i := period; i < len(values); i++ {
+		series[i] = (values[i]-series[i-1])*multiplier + series[i-1]
+	}
+	return series
+}
+
+// rsiSeries 计算RSI序列（Wilder平滑，与 calculateRSI 相同；下标 period 之前的值为0）
+func rsiSeries(klines []Kline, period int) []float64 {
+	if len(klines) <= period {
+		return nil
+	}
+	series := make([]float64, len(klines))
+
+	gains, losses := 0.0, 0.0
+	for i := 1; i <= period; i++ {
+		change := klines[i].Close - klines[i-1].Close
+		if change > 0 {
+			gains += change
+		} else {
+			losses += -change
+		}
+	}
+	avgGain := gains / float64(period)
+	avgLoss := losses / float64(period)
+
+	rsi := func() float64 {
+		if avgLoss == 0 {
+			return 100
+		}
+		return 100 - 100/(1+avgGain/avgLoss)
+	}
+	series[period] = rsi()
+
+	for i := period + 1; i < len(klines); i++ {
+		change := klines[i].Close - klines[i-1].Close
+		gain, loss := math.Max(change, 0), math.Max(-change, 0)
+		avgGain = (avgGain*float64(period-1) + gain) / float64(period)
+		avgLoss = (avgLoss*float64(period-1) + loss) / float64(period)
+		series[i] = rsi()
+	}
+	return series
+}
+
+// calculateMACDLines 计算MACD线、信号线（MACD线的EMA）和柱状图
+func calculateMACDLines(klines []Kline, fast, slow, signal int) *MACDData {
+	if len(klines) < slow+signal-1 {
+		return nil
+	}
+	closes := make([]float64, len(klines))
+	for i, k := range klines {
+		closes[i] = k.Close
+	}
+	fastEMA := emaSeries(closes, fast)
+	slowEMA := emaSeries(closes, slow)
+
+	macdLine := make([]float64, 0, len(klines)-slow+1)
+	for i := slow - 1; i < len(klines); i++ {
+		macdLine = append(macdLine, fastEMA[i]-slowEMA[i])
+	}
+	signalLine := emaSeries(macdLine, signal)
+
+	last := len(macdLine) - 1
+	return &MACDData{
+		MACD:      macdLine[last],
+		Signal:    signalLine[last],
+		Histogram: macdLine[last] - signalLine[last],
+	}
+}
+
+// calculateBollinger 计算布林带（中轨为 period 周期SMA，上下轨为中轨 ± multiplier 倍总体标准差）
+func calculateBollinger(klines []Kline, period int, multiplier float64) *BollingerData {
+	if len(klines) < period {
+		return nil
+	}
+	window := klines[len(klines)-period:]
+	sum := 0.0
+	for _, k := range window {
+		sum += k.Close
+	}
+	middle := sum / float64(period)
+
+	variance := 0.0
+	for _, k := range window {
+		variance += (k.Close - middle) * (k.Close - middle)
+	}
+	stdDev := math.Sqrt(variance / float64(period))
+
+	data := &BollingerData{
+		Upper:  middle + multiplier*stdDev,
+		Middle: middle,
+		Lower:  middle - multiplier*stdDev,
+	}
+	if middle != 0 {
+		data.WidthPct = (data.Upper - data.Lower) / middle * 100
+	}
+	if data.Upper > data.Lower {
+		data.PercentB = (klines[len(klines)-1].Close - data.Lower) / (data.Upper - data.Lower)
+	} else {
+		data.PercentB = 0.5
+	}
+	return data
+}
+
+// sessionStart 最新K线所在交易日（UTC）的开始时间（毫秒）
+func sessionStart(klines []Kline) int64 {
+	latest := time.UnixMilli(klines[len(klines)-1].OpenTime).UTC()
+	return time.Date(latest.Year(), latest.Month(), latest.Day(), 0, 0, 0, 0, time.UTC).UnixMilli()
+}
+
+// calculateVWAP 计算从锚定时间（毫秒，K线开盘时间不早于该时间）开始的成交量加权均价（典型价格 = (高+低+收)/3）
+// 锚定时间早于K线窗口时从第一根K线开始计算
+func calculateVWAP(klines []Kline, anchor int64) float64 {
+	priceVolume, volume := 0.0, 0.0
+	for _, k := range klines {
+		if k.OpenTime < anchor {
+			continue
+		}
+		typical := (k.High + k.Low + k.Close) / 3
+		priceVolume += typical * k.Volume
+		volume += k.Volume
+	}
+	if volume == 0 {
+		return 0
+	}
+	return priceVolume / volume
+}
+
+// calculateStochRSI 计算随机RSI：RSI在 stochPeriod 内的相对位置（0-100），K为其 kSmooth 周期SMA，D为K的 dSmooth 周期SMA
+func calculateStochRSI(klines []Kline, rsiPeriod, stochPeriod, kSmooth, dSmooth int) *StochRSIData {
+	if len(klines) < rsiPeriod+stochPeriod+kSmooth+dSmooth-2 {
+		return nil
+	}
+	rsi := rsiSeries(klines, rsiPeriod)
+
+	// RSI从下标 rsiPeriod 开始有效
+	var stoch []float64
+	for i := rsiPeriod + stochPeriod - 1; i < len(rsi); i++ {
+		window := rsi[i-stochPeriod+1 : i+1]
+		lowest, highest := window[0], window[0]
+		for _, v := range window {
+			lowest = math.Min(lowest, v)
+			highest = math.Max(highest, v)
+		}
+		if highest == lowest {
+			stoch = append(stoch, 50) // RSI没有波动时视为中性
+		} else {
+			stoch = append(stoch, (rsi[i]-lowest)/(highest-lowest)*100)
+		}
+	}
+
+	k := smaSeries(stoch, kSmooth)
+	d := smaSeries(k, dSmooth)
+	return &StochRSIData{K: k[len(k)-1], D: d[len(d)-1]}
+}
+
+// smaSeries 计算SMA序列（结果从第 period 个值开始，长度为 len(values)-period+1）
+func smaSeries(values []float64, period int) []float64 {
+	if len(values) < period {
+		return nil
+	}
+	series := make([]float64, 0, len(values)-period+1)
+	sum := 0.0
+	for i, v := range values {
+		sum += v
+		if i >= period {
+			sum -= values[i-period]
+		}
+		if i >= period-1 {
+			series = append(series, sum/float64(period))
+		}
+	}
+	return series
+}
+
+// calculateADX 计算ADX及±DI（Wilder平滑）
+func calculateADX(klines []Kline, period int) *ADXData {
+	if len(klines) < 2*period+1 {
+		return nil
+	}
+
+	var smoothedTR, smoothedPlusDM, smoothedMinusDM, adx float64
+	var plusDI, minusDI float64
+	var dxSum float64
+	for i := 1; i < len(klines); i++ {
+		high, low, prevClose := klines[i].High, klines[i].Low, klines[i-1].Close
+		tr := math.Max(high-low, math.Max(math.Abs(high-prevClose), math.Abs(low-prevClose)))
+		upMove := high - klines[i-1].High
+		downMove := klines[i-1].Low - low
+		plusDM, minusDM := 0.0, 0.0
+		if upMove > downMove && upMove > 0 {
+			plusDM = upMove
+		}
+		if downMove > upMove && downMove > 0 {
+			minusDM = downMove
+		}
+
+		// 前 period 个值求和作为初始值，之后使用Wilder平滑
+		if i <= period {
+			smoothedTR += tr
+			smoothedPlusDM += plusDM
+			smoothedMinusDM += minusDM
+			if i < period {
+				continue
+			}
+		} else {
+			smoothedTR = smoothedTR - smoothedTR/float64(period) + tr
+			smoothedPlusDM = smoothedPlusDM - smoothedPlusDM/float64(period) + plusDM
+			smoothedMinusDM = smoothedMinusDM - smoothedMinusDM/float64(period) + minusDM
+		}
+
+		plusDI, minusDI = 0, 0
+		if smoothedTR > 0 {
+			plusDI = smoothedPlusDM / smoothedTR * 100
+			minusDI = smoothedMinusDM / smoothedTR * 100
+		}
+		dx := 0.0
+		if plusDI+minusDI > 0 {
+			dx = math.Abs(plusDI-minusDI) / (plusDI + minusDI) * 100
+		}
+
+		// 前 period 个DX的平均值作为初始ADX
+		switch n := i - period + 1; {
+		case n < period:
+			dxSum += dx
+		case n == period:
+			adx = (dxSum + dx) / float64(period)
+		default:
+			adx = (adx*float64(period-1) + dx) / float64(period)
+		}
+	}
+
+	return &ADXData{ADX: adx, PlusDI: plusDI, MinusDI: minusDI}
+}
+
+// calculateOBV 计算K线窗口内的能量潮（从0开始累计）及最近 lookback 根K线的变化
+func calculateOBV(klines []Kline, lookback int) (float64, float64) {
+	if len(klines) < 2 {
+		return 0, 0
+	}
+	obv := make([]float64, len(klines))
+	for i := 1; i < len(klines); i++ {
+		obv[i] = obv[i-1]
+		switch {
+		case klines[i].Close > klines[i-1].Close:
+			obv[i] += klines[i].Volume
+		case klines[i].Close < klines[i-1].Close:
+			obv[i] -= klines[i].Volume
+		}
+	}
+	last := len(obv) - 1
+	if last < lookback {
+		return obv[last], obv[last]
+	}
+	return obv[last], obv[last] - obv[last-lookback]
+}
+
+// calculateIchimoku 计算一目均衡表（先行带使用 kijun 周期之前的值，即当前K线对应的云层）
+func calculateIchimoku(klines []Kline, tenkanPeriod, kijunPeriod, senkouPeriod int) *IchimokuData {
+	if len(klines) < senkouPeriod+kijunPeriod {
+		return nil
+	}
+	last := len(klines) - 1
+	displaced := last - kijunPeriod
+
+	data := &IchimokuData{
+		Tenkan: channelMiddle(klines, last, tenkanPeriod),
+		Kijun:  channelMiddle(klines, last, kijunPeriod),
+	}
+	data.SenkouA = (channelMiddle(klines, displaced, tenkanPeriod) + channelMiddle(klines, displaced, kijunPeriod)) / 2
+	data.SenkouB = channelMiddle(klines, displaced, senkouPeriod)
+
+	price := klines[last].Close
+	switch {
+	case price > math.Max(data.SenkouA, data.SenkouB):
+		data.CloudPosition = "above"
+	case price < math.Min(data.SenkouA, data.SenkouB):
+		data.CloudPosition = "below"
+	default:
+		data.CloudPosition = "inside"
+	}
+	return data
+}
+
+// calculateDonchian 计算唐奇安通道（最近 period 根K线的最高价和最低价）
+func calculateDonchian(klines []Kline, period int) *DonchianData {
+	if len(klines) < period {
+		return nil
+	}
+	highest, lowest := highLow(klines, len(klines)-1, period)
+	return &DonchianData{Upper: highest, Middle: (highest + lowest) / 2, Lower: lowest}
+}
+
+// channelMiddle 以下标 end 结尾的 period 根K线的（最高价+最低价）/2
+func channelMiddle(klines []Kline, end, period int) float64 {
+	highest, lowest := highLow(klines, end, period)
+	return (highest + lowest) / 2
+}
+
+// highLow 以下标 end 结尾的 period 根K线的最高价和最低价
+func highLow(klines []Kline, end, period int) (float64, float64) {
+	highest, lowest := klines[end].High, klines[end].Low
+	for i := end - period + 1; i < end; i++ {
+		highest = math.Max(highest, klines[i].High)
+		lowest = math.Min(lowest, klines[i].Low)
+	}
+	return highest, lowest
+}
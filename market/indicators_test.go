@@ -0,0 +1,323 @@
+package market
+
+import (
+	"math"
+	"testing"
+)
+
+// 参考值均可由输入序列直接推导（推导过程见各用例注释），震荡行情的参考向量见 referenceKlines
+
+const indicatorTolerance = 1e-9
+
+func approxEqual(a, b float64) bool {
+	return math.Abs(a-b) <= indicatorTolerance*math.Max(1, math.Abs(b))
+}
+
+// klinesFromCloses 按收盘价生成K线（开高低收相同，成交量为1）
+func klinesFromCloses(closes ...float64) []Kline {
+	klines := make([]Kline, len(closes))
+	for i, c := range closes {
+		klines[i] = Kline{OpenTime: int64(i) * 60_000, Open: c, High: c, Low: c, Close: c, Volume: 1}
+	}
+	return klines
+}
+
+// risingKlines 生成 n 根逐根上涨1的K线：最低价 i，最高价/收盘价 i+1
+func risingKlines(n int) []Kline {
+	klines := make([]Kline, n)
+	for i := range klines {
+		f := float64(i)
+		klines[i] = Kline{OpenTime: int64(i) * 60_000, Open: f, High: f + 1, Low: f, Close: f + 1, Volume: 1}
+	}
+	return klines
+}
+
+// 震荡行情参考K线（40根，先涨后跌再涨再跌）
+// ADX/StochRSI 的期望值由独立实现（Python，按 Wilder 原始定义：ATR/±DM 用均值形式的Wilder平滑、
+// 首个ADX为前 period 个DX的均值；StochRSI = RSI的随机值 → K=SMA3 → D=SMA3）计算，不复用这里的Go代码
+var (
+	referenceHighs = []float64{
+		102.1, 102.79, 105.25, 108.14, 108.41, 109.83, 111.01, 110.59, 110.01, 110.36,
+		108.23, 106.3, 106.23, 103.0, 101.57, 101.16, 98.57, 98.09, 98.28, 97.7,
+		98.12, 100.22, 100.83, 102.11, 105.85, 106.66, 109.06, 112.36, 112.82, 114.83,
+		116.66, 116.62, 116.75, 117.41, 115.88, 114.18, 114.37, 111.38, 109.69, 109.23,
+	}
+	referenceLows = []float64{
+		99.9, 100.56, 102.56, 105.84, 106.26, 107.05, 108.92, 108.41, 107.59, 107.79,
+		105.84, 104.33, 103.44, 100.72, 99.47, 98.6, 96.17, 95.65, 96.3, 94.97,
+		95.68, 98.25, 98.17, 99.9, 103.43, 104.49, 106.43, 109.81, 111.02, 112.14,
+		114.33, 114.29, 114.41, 114.94, 113.25, 112.5, 111.7, 108.87, 107.49, 106.78,
+	}
+	referenceCloses = []float64{
+		100.7, 101.83, 103.94, 106.9, 107.33, 108.44, 110.18, 109.22, 108.87, 109.17,
+		106.89, 105.4, 104.83, 101.98, 100.29, 99.88, 97.55, 96.69, 97.38, 96.36,
+		96.93, 99.08, 99.46, 101.28, 104.46, 105.58, 107.82, 111.05, 111.86, 113.43,
+		115.7, 115.31, 115.51, 116.33, 114.49, 113.35, 113.0, 110.24, 108.5, 107.89,
+	}
+)
+
+// referenceKlines 返回参考K线的前 n 根
+func referenceKlines(n int) []Kline {
+	klines := make([]Kline, n)
+	for i := range klines {
+		klines[i] = Kline{
+			OpenTime: int64(i) * 60_000,
+			Open:     referenceCloses[i],
+			High:     referenceHighs[i],
+			Low:      referenceLows[i],
+			Close:    referenceCloses[i],
+			Volume:   1,
+		}
+	}
+	return klines
+}
+
+// fallingKlines 生成 n 根逐根下跌1的K线：最高价 n-i，最低价/收盘价 n-i-1
+func fallingKlines(n int) []Kline {
+	klines := make([]Kline, n)
+	for i := range klines {
+		f := float64(n - i)
+		klines[i] = Kline{OpenTime: int64(i) * 60_000, Open: f, High: f, Low: f - 1, Close: f - 1, Volume: 1}
+	}
+	return klines
+}
+
+func TestCalculateBollinger(t *testing.T) {
+	// 收盘价 1..20：均值 10.5，总体标准差 sqrt((20²-1)/12) = 5.766281297335398
+	closes := make([]float64, 20)
+	for i := range closes {
+		closes[i] = float64(i + 1)
+	}
+	stdDev := math.Sqrt(399.0 / 12)
+
+	data := calculateBollinger(klinesFromCloses(closes...), 20, 2)
+	if data == nil {
+		t.Fatal("calculateBollinger returned nil")
+	}
+	want := BollingerData{
+		Upper:    10.5 + 2*stdDev,
+		Middle:   10.5,
+		Lower:    10.5 - 2*stdDev,
+		WidthPct: 4 * stdDev / 10.5 * 100,
+		PercentB: (20 - (10.5 - 2*stdDev)) / (4 * stdDev),
+	}
+	if !approxEqual(data.Upper, want.Upper) || !approxEqual(data.Middle, want.Middle) || !approxEqual(data.Lower, want.Lower) ||
+		!approxEqual(data.WidthPct, want.WidthPct) || !approxEqual(data.PercentB, want.PercentB) {
+		t.Errorf("calculateBollinger = %+v, want %+v", *data, want)
+	}
+
+	// 价格没有波动：上中下轨重合，%B 取中间值
+	flat := calculateBollinger(klinesFromCloses(make([]float64, 20)...), 20, 2)
+	if flat == nil || flat.Upper != 0 || flat.Lower != 0 || flat.WidthPct != 0 || flat.PercentB != 0.5 {
+		t.Errorf("calculateBollinger(flat) = %+v, want zero bands with %%B 0.5", flat)
+	}
+
+	if got := calculateBollinger(klinesFromCloses(closes[:19]...), 20, 2); got != nil {
+		t.Errorf("calculateBollinger(19 klines) = %+v, want nil", got)
+	}
+}
+
+func TestCalculateVWAP(t *testing.T) {
+	klines := []Kline{
+		{OpenTime: 0, High: 12, Low: 8, Close: 10, Volume: 100},     // 锚定前，不计入
+		{OpenTime: 1000, High: 11, Low: 9, Close: 10, Volume: 100},  // 典型价格 10
+		{OpenTime: 2000, High: 22, Low: 18, Close: 20, Volume: 300}, // 典型价格 20
+	}
+	// (10×100 + 20×300) / 400 = 17.5
+	if got := calculateVWAP(klines, 1000); !approxEqual(got, 17.5) {
+		t.Errorf("calculateVWAP(anchor=1000) = %v, want 17.5", got)
+	}
+	// 锚定时间早于窗口：从第一根开始，(10×100 + 10×100 + 20×300) / 500 = 16
+	if got := calculateVWAP(klines, -1); !approxEqual(got, 16) {
+		t.Errorf("calculateVWAP(anchor=-1) = %v, want 16", got)
+	}
+	// 锚定之后没有成交量
+	if got := calculateVWAP(klines, 3000); got != 0 {
+		t.Errorf("calculateVWAP(no volume) = %v, want 0", got)
+	}
+}
+
+func TestCalculateStochRSI(t *testing.T) {
+	// 持续下跌后持续上涨：上涨段RSI逐根升高，当前RSI始终是窗口最高值，K=D=100
+	var closes []float64
+	for i := 0; i < 30; i++ {
+		closes = append(closes, 100-float64(i))
+	}
+	for i := 1; i <= 25; i++ {
+		closes = append(closes, 71+float64(i))
+	}
+	data := calculateStochRSI(klinesFromCloses(closes...), 14, 14, 3, 3)
+	if data == nil || !approxEqual(data.K, 100) || !approxEqual(data.D, 100) {
+		t.Errorf("calculateStochRSI(rebound) = %+v, want K=D=100", data)
+	}
+
+	// 持续上涨后持续下跌：当前RSI始终是窗口最低值，K=D=0
+	closes = closes[:0]
+	for i := 0; i < 30; i++ {
+		closes = append(closes, 100+float64(i))
+	}
+	for i := 1; i <= 25; i++ {
+		closes = append(closes, 129-float64(i))
+	}
+	data = calculateStochRSI(klinesFromCloses(closes...), 14, 14, 3, 3)
+	if data == nil || !approxEqual(data.K, 0) || !approxEqual(data.D, 0) {
+		t.Errorf("calculateStochRSI(selloff) = %+v, want K=D=0", data)
+	}
+
+	// 单边上涨：RSI恒为100没有波动，视为中性50
+	rising := make([]float64, 40)
+	for i := range rising {
+		rising[i] = float64(i + 1)
+	}
+	data = calculateStochRSI(klinesFromCloses(rising...), 14, 14, 3, 3)
+	if data == nil || data.K != 50 || data.D != 50 {
+		t.Errorf("calculateStochRSI(constant RSI) = %+v, want K=D=50", data)
+	}
+
+	// 最少需要 14+14+3+3-2 = 32 根K线
+	if got := calculateStochRSI(klinesFromCloses(rising[:31]...), 14, 14, 3, 3); got != nil {
+		t.Errorf("calculateStochRSI(31 klines) = %+v, want nil", got)
+	}
+	if got := calculateStochRSI(klinesFromCloses(rising[:32]...), 14, 14, 3, 3); got == nil {
+		t.Error("calculateStochRSI(32 klines) = nil, want value")
+	}
+
+	// 震荡行情参考向量（前37根：上涨后回落，K、D均为中间值）
+	data = calculateStochRSI(referenceKlines(37), 14, 14, 3, 3)
+	if data == nil || !approxEqual(data.K, 58.993189921459155) || !approxEqual(data.D, 75.51957620108178) {
+		t.Errorf("calculateStochRSI(reference) = %+v, want K=58.993189921459155 D=75.51957620108178", data)
+	}
+}
+
+func TestCalculateADX(t *testing.T) {
+	// 逐根上涨1：TR=1，+DM=1，-DM=0，因此 +DI=100，-DI=0，DX=ADX=100
+	data := calculateADX(risingKlines(40), 14)
+	if data == nil || !approxEqual(data.ADX, 100) || !approxEqual(data.PlusDI, 100) || data.MinusDI != 0 {
+		t.Errorf("calculateADX(rising) = %+v, want ADX=100 +DI=100 -DI=0", data)
+	}
+
+	// 逐根下跌1：-DI=100，+DI=0，ADX=100
+	data = calculateADX(fallingKlines(40), 14)
+	if data == nil || !approxEqual(data.ADX, 100) || data.PlusDI != 0 || !approxEqual(data.MinusDI, 100) {
+		t.Errorf("calculateADX(falling) = %+v, want ADX=100 +DI=0 -DI=100", data)
+	}
+
+	// 价格没有波动：TR为0，各值为0
+	data = calculateADX(klinesFromCloses(make([]float64, 40)...), 14)
+	if data == nil || data.ADX != 0 || data.PlusDI != 0 || data.MinusDI != 0 {
+		t.Errorf("calculateADX(flat) = %+v, want zeros", data)
+	}
+
+	// 最少需要 2×14+1 = 29 根K线
+	if got := calculateADX(risingKlines(28), 14); got != nil {
+		t.Errorf("calculateADX(28 klines) = %+v, want nil", got)
+	}
+	if got := calculateADX(risingKlines(29), 14); got == nil || !approxEqual(got.ADX, 100) {
+		t.Errorf("calculateADX(29 klines) = %+v, want ADX=100", got)
+	}
+
+	// 震荡行情参考向量（40根）
+	data = calculateADX(referenceKlines(40), 14)
+	if data == nil || !approxEqual(data.ADX, 26.45396731308741) || !approxEqual(data.PlusDI, 22.863931043776795) || !approxEqual(data.MinusDI, 23.42037217561314) {
+		t.Errorf("calculateADX(reference) = %+v, want ADX=26.45396731308741 +DI=22.863931043776795 -DI=23.42037217561314", data)
+	}
+}
+
+func TestCalculateOBV(t *testing.T) {
+	klines := klinesFromCloses(10, 11, 11, 10, 12, 13)
+	volumes := []float64{5, 10, 20, 30, 40, 50}
+	for i := range klines {
+		klines[i].Volume = volumes[i]
+	}
+	// 0 → +10 → 持平 → -30 → +40 → +50：OBV = 70，最近2根变化 = 90
+	obv, change := calculateOBV(klines, 2)
+	if obv != 70 || change != 90 {
+		t.Errorf("calculateOBV = (%v, %v), want (70, 90)", obv, change)
+	}
+
+	// 回看周期超过K线数量：变化为窗口内的全部累计
+	obv, change = calculateOBV(klines, 10)
+	if obv != 70 || change != 70 {
+		t.Errorf("calculateOBV(lookback>len) = (%v, %v), want (70, 70)", obv, change)
+	}
+
+	// 价格没有波动
+	if obv, change = calculateOBV(klinesFromCloses(5, 5, 5), 1); obv != 0 || change != 0 {
+		t.Errorf("calculateOBV(flat) = (%v, %v), want (0, 0)", obv, change)
+	}
+
+	if obv, change = calculateOBV(klinesFromCloses(5), 1); obv != 0 || change != 0 {
+		t.Errorf("calculateOBV(1 kline) = (%v, %v), want (0, 0)", obv, change)
+	}
+}
+
+func TestCalculateIchimoku(t *testing.T) {
+	// 逐根上涨1（最低价 i，最高价 i+1）：以 end 结尾的 p 根K线中值 = end + 1 - p/2
+	// 最后一根 L=77：转换线 73.5，基准线 65；云层取 D=L-26=51：先行A=(47.5+39)/2=43.25，先行B=26
+	data := calculateIchimoku(risingKlines(78), 9, 26, 52)
+	want := IchimokuData{Tenkan: 73.5, Kijun: 65, SenkouA: 43.25, SenkouB: 26, CloudPosition: "above"}
+	if data == nil || *data != want {
+		t.Errorf("calculateIchimoku(rising) = %+v, want %+v", data, want)
+	}
+
+	data = calculateIchimoku(fallingKlines(78), 9, 26, 52)
+	if data == nil || data.CloudPosition != "below" {
+		t.Errorf("calculateIchimoku(falling) = %+v, want below cloud", data)
+	}
+
+	// 价格没有波动：价格与云层重合
+	data = calculateIchimoku(klinesFromCloses(make([]float64, 78)...), 9, 26, 52)
+	if data == nil || data.CloudPosition != "inside" || data.SenkouA != 0 || data.SenkouB != 0 {
+		t.Errorf("calculateIchimoku(flat) = %+v, want inside zero cloud", data)
+	}
+
+	// 最少需要 52+26 = 78 根K线
+	if got := calculateIchimoku(risingKlines(77), 9, 26, 52); got != nil {
+		t.Errorf("calculateIchimoku(77 klines) = %+v, want nil", got)
+	}
+}
+
+func TestCalculateDonchian(t *testing.T) {
+	klines := []Kline{
+		{High: 50, Low: 1}, // 窗口外
+		{High: 12, Low: 8},
+		{High: 15, Low: 9},
+		{High: 11, Low: 6},
+	}
+	data := calculateDonchian(klines, 3)
+	want := DonchianData{Upper: 15, Middle: 10.5, Lower: 6}
+	if data == nil || *data != want {
+		t.Errorf("calculateDonchian = %+v, want %+v", data, want)
+	}
+
+	data = calculateDonchian(klinesFromCloses(7, 7, 7), 3)
+	if data == nil || *data != (DonchianData{Upper: 7, Middle: 7, Lower: 7}) {
+		t.Errorf("calculateDonchian(flat) = %+v, want 7/7/7", data)
+	}
+
+	if got := calculateDonchian(klines[:2], 3); got != nil {
+		t.Errorf("calculateDonchian(2 klines) = %+v, want nil", got)
+	}
+}
+
+func TestCalculateMACDLines(t *testing.T) {
+	// 收盘价逐根加1：以SMA为初值的N周期EMA恰好滞后 (N-1)/2，
+	// 因此 EMA12 = x-5.5，EMA26 = x-12.5，MACD 恒为7，信号线为7，柱状图为0
+	data := calculateMACDLines(risingKlines(60), 12, 26, 9)
+	if data == nil || !approxEqual(data.MACD, 7) || !approxEqual(data.Signal, 7) || !approxEqual(data.Histogram, 0) {
+		t.Errorf("calculateMACDLines(rising) = %+v, want MACD=7 signal=7 histogram=0", data)
+	}
+
+	data = calculateMACDLines(klinesFromCloses(make([]float64, 60)...), 12, 26, 9)
+	if data == nil || data.MACD != 0 || data.Signal != 0 || data.Histogram != 0 {
+		t.Errorf("calculateMACDLines(flat) = %+v, want zeros", data)
+	}
+
+	// 最少需要 26+9-1 = 34 根K线
+	if got := calculateMACDLines(risingKlines(33), 12, 26, 9); got != nil {
+		t.Errorf("calculateMACDLines(33 klines) = %+v, want nil", got)
+	}
+	if got := calculateMACDLines(risingKlines(34), 12, 26, 9); got == nil || !approxEqual(got.MACD, 7) {
+		t.Errorf("calculateMACDLines(34 klines) = %+v, want MACD=7", got)
+	}
+}
@@ -0,0 +1,283 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// liquidationStream 全市场强平订单流（每个币种每秒最多推送一笔最新的强平订单）
+const liquidationStream = "!forceOrder@arr"
+
+// liquidationRetention 强平订单保留时长（覆盖最长的统计窗口）
+const liquidationRetention = 4 * time.Hour
+
+// maxLiquidationsPerSymbol 每个币种最多保留的强平订单数
+const maxLiquidationsPerSymbol = 2000
+
+// liquidationClusterPct 同一强平聚集区的价格范围（相对最低价，%）
+const liquidationClusterPct = 0.3
+
+// maxLiquidationClusters 输出的强平聚集区数量（按名义价值从大到小）
+const maxLiquidationClusters = 5
+
+// 强平方向
+const (
+	LiquidationLong  = "long"  // 多头被强平（强平单为卖单）
+	LiquidationShort = "short" // 空头被强平（强平单为买单）
+)
+
+// Liquidation 强平订单
+type Liquidation struct {
+	Symbol      string
+	Side        string // long / short
+	Price       float64
+	NotionalUSD float64
+	Time        int64 // 成交时间（毫秒）
+}
+
+// LiquidationWindow 单个统计窗口内的强平名义价值
+type LiquidationWindow struct {
+	Interval   string  `json:"interval"`
+	LongUSD    float64 `json:"long_usd"` // 多头被强平的名义价值
+	ShortUSD   float64 `json:"short_usd"`
+	LongCount  int     `json:"long_count"`
+	ShortCount int     `json:"short_count"`
+	LargestUSD float64 `json:"largest_usd"` // 窗口内最大的单笔强平
+	Complete   bool    `json:"complete"`    // 统计是否覆盖整个窗口（刚启动或断线重连后为 false）
+}
+
+// LiquidationCluster 强平聚集区（价格相近的强平订单）
+type LiquidationCluster struct {
+	Side        string    `json:"side"`
+	PriceLow    float64   `json:"price_low"`
+	PriceHigh   float64   `json:"price_high"`
+	AvgPrice    float64   `json:"avg_price"` // 按名义价值加权的均价
+	NotionalUSD float64   `json:"notional_usd"`
+	Count       int       `json:"count"`
+	LastTime    time.Time `json:"last_time"`
+}
+
+// LiquidationData 币种的强平统计
+type LiquidationData struct {
+	Windows  []LiquidationWindow  `json:"windows"`
+	Clusters []LiquidationCluster `json:"clusters"` // 最近4小时的强平聚集区
+}
+
+// liquidationFeed 全市场强平订单（按币种保存最近的强平）
+type liquidationFeed struct {
+	mu     sync.Mutex
+	since  time.Time                // 连续统计的开始时间（之前的窗口不完整）
+	events map[string][]Liquidation // symbol -> 强平订单（从旧到新）
+}
+
+func newLiquidationFeed() *liquidationFeed {
+	return &liquidationFeed{events: make(map[string][]Liquidation)}
+}
+
+// start 开始统计（订阅强平订单流时调用）
+func (f *liquidationFeed) start(at time.Time) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.since = at
+}
+
+// markGap 标记统计缺口（断线期间的强平无法回填）
+func (f *liquidationFeed) markGap(at time.Time) {
+	f.start(at)
+}
+
+// running 是否已开始统计
+func (f *liquidationFeed) running() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return !f.since.IsZero()
+}
+
+// add 记录一笔强平，并清理该币种过期的强平
+func (f *liquidationFeed) add(liq Liquidation) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events := append(f.events[liq.Symbol], liq)
+	cutoff := time.Now().Add(-liquidationRetention).UnixMilli()
+	drop := 0
+	for drop < len(events) && (events[drop].Time < cutoff || len(events)-drop > maxLiquidationsPerSymbol) {
+		drop++
+	}
+	f.events[liq.Symbol] = events[drop:]
+}
+
+// data 计算截至 now 的币种强平统计
+func (f *liquidationFeed) data(symbol string, now time.Time) *LiquidationData {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	events := f.events[symbol]
+	data := &LiquidationData{Windows: make([]LiquidationWindow, 0, len(orderFlowWindows))}
+	for _, w := range orderFlowWindows {
+		start := now.Add(-time.Duration(w.Minutes) * time.Minute)
+		window := LiquidationWindow{Interval: w.Interval, Complete: !f.since.After(start)}
+		for _, liq := range events {
+			if liq.Time < start.UnixMilli() {
+				continue
+			}
+			if liq.Side == LiquidationLong {
+				window.LongUSD += liq.NotionalUSD
+				window.LongCount++
+			} else {
+				window.ShortUSD += liq.NotionalUSD
+				window.ShortCount++
+			}
+			if liq.NotionalUSD > window.LargestUSD {
+				window.LargestUSD = liq.NotionalUSD
+			}
+		}
+		data.Windows = append(data.Windows, window)
+	}
+
+	cutoff := now.Add(-liquidationRetention).UnixMilli()
+	var recent []Liquidation
+	for _, liq := range events {
+		if liq.Time >= cutoff {
+			recent = append(recent, liq)
+		}
+	}
+	data.Clusters = liquidationClusters(recent)
+	return data
+}
+
+// liquidationClusters 按方向把价格相近（liquidationClusterPct 以内）的强平合并为聚集区，返回名义价值最大的几个
+func liquidationClusters(events []Liquidation) []LiquidationCluster {
+	sorted := append([]Liquidation(nil), events...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Side != sorted[j].Side {
+			return sorted[i].Side < sorted[j].Side
+		}
+		return sorted[i].Price < sorted[j].Price
+	})
+
+	var clusters []LiquidationCluster
+	var weighted float64
+	for _, liq := range sorted {
+		n := len(clusters)
+		if n == 0 || clusters[n-1].Side != liq.Side || liq.Price > clusters[n-1].PriceLow*(1+liquidationClusterPct/100) {
+			if n > 0 {
+				clusters[n-1].AvgPrice = weighted / clusters[n-1].NotionalUSD
+			}
+			clusters = append(clusters, LiquidationCluster{Side: liq.Side, PriceLow: liq.Price})
+			weighted = 0
+			n++
+		}
+		cluster := &clusters[n-1]
+		cluster.PriceHigh = liq.Price
+		cluster.NotionalUSD += liq.NotionalUSD
+		cluster.Count++
+		weighted += liq.Price * liq.NotionalUSD
+		if t := time.UnixMilli(liq.Time); t.After(cluster.LastTime) {
+			cluster.LastTime = t
+		}
+	}
+	if n := len(clusters); n > 0 {
+		clusters[n-1].AvgPrice = weighted / clusters[n-1].NotionalUSD
+	}
+
+	sort.Slice(clusters, func(i, j int) bool { return clusters[i].NotionalUSD > clusters[j].NotionalUSD })
+	if len(clusters) > maxLiquidationClusters {
+		clusters = clusters[:maxLiquidationClusters]
+	}
+	return clusters
+}
+
+// parseLiquidation 解析强平订单推送（成交均价×累计成交量为名义价值）
+func parseLiquidation(data []byte) (Liquidation, error) {
+	var msg struct {
+		Order struct {
+			Symbol      string `json:"s"`
+			Side        string `json:"S"`
+			Price       string `json:"p"`
+			AvgPrice    string `json:"ap"`
+			Quantity    string `json:"q"`
+			FilledQty   string `json:"z"`
+			TradeTimeMs int64  `json:"T"`
+		} `json:"o"`
+	}
+	if err := json.Unmarshal(data, &msg); err != nil {
+		return Liquidation{}, err
+	}
+
+	order := msg.Order
+	price, _ := strconv.ParseFloat(order.AvgPrice, 64)
+	quantity, _ := strconv.ParseFloat(order.FilledQty, 64)
+	if price == 0 || quantity == 0 {
+		price, _ = strconv.ParseFloat(order.Price, 64)
+		quantity, _ = strconv.ParseFloat(order.Quantity, 64)
+	}
+	if price == 0 || quantity == 0 {
+		return Liquidation{}, fmt.Errorf("%s 强平订单价格或数量无效", order.Symbol)
+	}
+
+	side := LiquidationShort
+	if order.Side == "SELL" {
+		side = LiquidationLong
+	}
+	return Liquidation{
+		Symbol:      strings.ToUpper(order.Symbol),
+		Side:        side,
+		Price:       price,
+		NotionalUSD: price * quantity,
+		Time:        order.TradeTimeMs,
+	}, nil
+}
+
+// handleLiquidationData 处理全市场强平订单推送
+func (m *WSMonitor) handleLiquidationData(ch <-chan []byte) {
+	for data := range ch {
+		liq, err := parseLiquidation(data)
+		if err != nil {
+			log.Printf("解析强平数据失败: %v", err)
+			continue
+		}
+		m.liquidations.add(liq)
+	}
+}
+
+// GetLiquidations 获取币种最近的强平统计（强平订单流未订阅时返回 false）
+func (m *WSMonitor) GetLiquidations(symbol string) (*LiquidationData, bool) {
+	if !m.liquidations.running() {
+		return nil, false
+	}
+	return m.liquidations.data(Normalize(symbol), time.Now()), true
+}
+
+// formatLiquidations 格式化强平统计（没有数据时返回空字符串）
+func formatLiquidations(data *LiquidationData) string {
+	if data == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString("Liquidations (long = longs force-sold, short = shorts force-bought):\n\n")
+	for _, w := range data.Windows {
+		partial := ""
+		if !w.Complete {
+			partial = " (partial window)"
+		}
+		sb.WriteString(fmt.Sprintf("%s: long=%.0f USD (%d orders), short=%.0f USD (%d orders), largest=%.0f USD%s\n",
+			w.Interval, w.LongUSD, w.LongCount, w.ShortUSD, w.ShortCount, w.LargestUSD, partial))
+	}
+	if len(data.Clusters) > 0 {
+		sb.WriteString("Liquidation clusters (last 4h, largest first):\n")
+		for _, c := range data.Clusters {
+			sb.WriteString(fmt.Sprintf("- %s liquidations at %.4f-%.4f (avg %.4f): %.0f USD, %d orders, last at %s\n",
+				c.Side, c.PriceLow, c.PriceHigh, c.AvgPrice, c.NotionalUSD, c.Count, c.LastTime.Format("15:04:05")))
+		}
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
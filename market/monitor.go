@@ -26,6 +26,23 @@ type WSMonitor struct {
 	filterSymbols  sync.Map // 使用sync.Map来存储需要监控的币种和其状态
 	symbolStats    sync.Map // 存储币种统计信息
 	FilterSymbol   []string //经过筛选的币种
+	subMu          sync.Mutex     // 保护 refCounts 和 connected
+	refCounts      map[string]int // K线流/订单簿流/归集成交流订阅引用计数（启动时的币种常驻，动态订阅的流无人使用时清理）
+	activeStreams  sync.Map       // 已订阅的K线流/订单簿流/归集成交流（更新只写入已订阅流的缓存）
+	orderBooks     sync.Map       // 本地L2订单簿（symbol -> *OrderBook）
+	tradeTapes     sync.Map       // 按分钟聚合的主动买卖量（symbol -> *tradeTape）
+	liquidations   *liquidationFeed // 全市场强平订单
+	alerts         *alertCenter     // 最近警报和警报订阅者
+	statsMu        sync.Mutex       // 保护 symbolStats 中统计的读-改-写
+	klineDataMaps  sync.Map       // 其他周期的K线历史数据（interval -> *sync.Map，如 1m/2h/1d）
+	connected      bool           // 组合流是否已连接并完成初始订阅
+	streamSince    sync.Map       // 各K线流开始订阅的时间（stream -> time.Time，用于判断从未收到消息的流）
+	klineMu        sync.Mutex     // 保护K线缓存的读-改-写（WebSocket更新与REST回填）
+	healthMu       sync.Mutex     // 保护 staleSymbols 和 lastGapFill
+	staleSymbols   map[string]string // 当前数据过时的币种及原因
+	lastGapFill    time.Time         // 最近一次回填K线的时间
+	alertsMu       sync.RWMutex      // 保护 alertsChan 的发送与关闭
+	alertsClosed   bool              // Close 已关闭 alertsChan（之后产生的警报直接丢弃）
 }
 type SymbolStats struct {
 	LastActiveTime   time.Time
@@ -44,7 +61,12 @@ func NewWSMonitor(batchSize int) *WSMonitor {
 		combinedClient: NewCombinedStreamsClient(batchSize),
 		alertsChan:     make(chan Alert, 1000),
 		batchSize:      batchSize,
+		refCounts:      make(map[string]int),
+		staleSymbols:   make(map[string]string),
+		liquidations:   newLiquidationFeed(),
+		alerts:         newAlertCenter(),
 	}
+	go WSMonitorCli.dispatchAlerts()
 	return WSMonitorCli
 }
 
@@ -80,12 +102,24 @@ func (m *WSMonitor) Initialize(coins []string) error {
 }
 
 func (m *WSMonitor) initializeHistoricalData() error {
+	var streams []string
+	for _, symbol := range m.symbols {
+		for _, st := range subKlineTime {
+			streams = append(streams, klineStream(symbol, st))
+		}
+	}
+	m.loadHistoricalData(streams)
+	return nil
+}
+
+// loadHistoricalData 并发获取多个K线流的历史K线
+func (m *WSMonitor) loadHistoricalData(streams []string) {
 	apiClient := NewAPIClient()
 
 	var wg sync.WaitGroup
 	semaphore := make(chan struct{}, 5) // 限制并发数
 
-	for _, symbol := range m.symbols {
+	for _, stream := range streams {
 		wg.Add(1)
 		semaphore <- struct{}{}
 
@@ -93,64 +127,23 @@ func (m *WSMonitor) initializeHistoricalData() error {
 			defer wg.Done()
 			defer func() { <-semaphore }()
 
-			// 获取历史K线数据 - 3m
-			klines3m, err := apiClient.GetKlines(s, "3m", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-			} else if len(klines3m) > 0 {
-				m.klineDataMap3m.Store(s, klines3m)
-				log.Printf("已加载 %s 的历史K线数据-3m: %d 条", s, len(klines3m))
-			}
-
-			// 获取历史K线数据 - 5m
-			klines5m, err := apiClient.GetKlines(s, "5m", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-			} else if len(klines5m) > 0 {
-				m.klineDataMap5m.Store(s, klines5m)
-				log.Printf("已加载 %s 的历史K线数据-5m: %d 条", s, len(klines5m))
-			}
-
-			// 获取历史K线数据 - 15m
-			klines15m, err := apiClient.GetKlines(s, "15m", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-			} else if len(klines15m) > 0 {
-				m.klineDataMap15m.Store(s, klines15m)
-				log.Printf("已加载 %s 的历史K线数据-15m: %d 条", s, len(klines15m))
-			}
-
-			// 获取历史K线数据 - 30m
-			klines30m, err := apiClient.GetKlines(s, "30m", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-			} else if len(klines30m) > 0 {
-				m.klineDataMap30m.Store(s, klines30m)
-				log.Printf("已加载 %s 的历史K线数据-30m: %d 条", s, len(klines30m))
-			}
-
-			// 获取历史K线数据 - 1h
-			klines1h, err := apiClient.GetKlines(s, "1h", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-			} else if len(klines1h) > 0 {
-				m.klineDataMap1h.Store(s, klines1h)
-				log.Printf("已加载 %s 的历史K线数据-1h: %d 条", s, len(klines1h))
-			}
-
-			// 获取历史K线数据 - 4h
-			klines4h, err := apiClient.GetKlines(s, "4h", 100)
-			if err != nil {
-				log.Printf("获取 %s 历史数据失败: %v", s, err)
-			} else if len(klines4h) > 0 {
-				m.klineDataMap4h.Store(s, klines4h)
-				log.Printf("已加载 %s 的历史K线数据-4h: %d 条", s, len(klines4h))
-			}
-		}(symbol)
+			symbol, st := parseKlineStream(s)
+			m.loadHistoricalKlines(apiClient, symbol, st)
+		}(stream)
 	}
 
 	wg.Wait()
-	return nil
+}
+
+// loadHistoricalKlines 获取单个币种指定周期的历史K线并写入缓存
+func (m *WSMonitor) loadHistoricalKlines(apiClient *APIClient, symbol, st string) {
+	klines, err := apiClient.GetKlines(symbol, st, 100)
+	if err != nil {
+		log.Printf("获取 %s 历史数据失败: %v", symbol, err)
+	} else if len(klines) > 0 {
+		m.getKlineDataMap(st).Store(symbol, klines)
+		log.Printf("已加载 %s 的历史K线数据-%s: %d 条", symbol, st, len(klines))
+	}
 }
 
 func (m *WSMonitor) Start(coins []string) {
@@ -173,13 +166,23 @@ func (m *WSMonitor) Start(coins []string) {
 		log.Fatalf("❌ 订阅币种交易对: %v", err)
 		return
 	}
+
+	// 断线重连后回填缺失的K线（逐笔成交无法回填，只标记统计缺口），并定期检查各流是否过时
+	m.combinedClient.SetOnReconnect(func() {
+		m.markTradeGaps()
+		m.liquidations.markGap(time.Now())
+		m.fillGaps()
+	})
+	go m.runHealthCheck()
+	go m.runStatsCleanup()
 }
 
 // subscribeSymbol 注册监听
 func (m *WSMonitor) subscribeSymbol(symbol, st string) []string {
 	var streams []string
-	stream := fmt.Sprintf("%s@kline_%s", strings.ToLower(symbol), st)
+	stream := klineStream(symbol, st)
 	ch := m.combinedClient.AddSubscriber(stream, 100)
+	m.streamSince.Store(stream, time.Now())
 	streams = append(streams, stream)
 	go m.handleKlineData(symbol, ch, st)
 
@@ -188,22 +191,249 @@ func (m *WSMonitor) subscribeSymbol(symbol, st string) []string {
 func (m *WSMonitor) subscribeAll() error {
 	// 执行批量订阅
 	log.Println("开始订阅所有交易对...")
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+
+	// 启动时的币种常驻订阅（持有一个引用，不会被清理）
 	for _, symbol := range m.symbols {
 		for _, st := range subKlineTime {
-			m.subscribeSymbol(symbol, st)
+			m.refCounts[klineStream(Normalize(symbol), st)]++
 		}
 	}
-	for _, st := range subKlineTime {
-		err := m.combinedClient.BatchSubscribeKlines(m.symbols, st)
-		if err != nil {
-			log.Fatalf("❌ 订阅3m K线: %v", err)
+	// 全市场强平订单流常驻订阅
+	m.refCounts[liquidationStream]++
+	// 连接前已通过 Subscribe/SubscribeDepth/SubscribeAggTrades 动态订阅的流一并订阅
+	var streams []string
+	for stream := range m.refCounts {
+		m.activeStreams.Store(stream, true)
+		streams = append(streams, m.addStreamSubscriber(stream))
+	}
+	batches := m.combinedClient.splitIntoBatches(streams, m.batchSize)
+	for i, batch := range batches {
+		log.Printf("订阅第 %d 批, 数量: %d", i+1, len(batch))
+		if err := m.combinedClient.subscribeStreams(batch); err != nil {
+			log.Fatalf("❌ 订阅K线: %v", err)
 			return err
 		}
+		// 批次间延迟，避免被限制
+		if i < len(batches)-1 {
+			time.Sleep(100 * time.Millisecond)
+		}
 	}
+	m.connected = true
 	log.Println("所有交易对订阅完成")
 	return nil
 }
 
+// Subscribe 动态订阅币种默认周期（3m/5m/15m/30m/1h/4h）的K线流，见 SubscribeIntervals
+func (m *WSMonitor) Subscribe(symbols []string) error {
+	return m.SubscribeIntervals(symbols, subKlineTime)
+}
+
+// Unsubscribe 释放币种默认周期K线流的订阅引用，见 UnsubscribeIntervals
+func (m *WSMonitor) Unsubscribe(symbols []string) {
+	m.UnsubscribeIntervals(symbols, subKlineTime)
+}
+
+// SubscribeIntervals 动态订阅币种指定周期的K线流（引用计数：每次成功的订阅需对应一次相同参数的 UnsubscribeIntervals，
+// 返回错误时不持有任何引用）
+// 新订阅的K线流先通过REST回填历史K线，再订阅WebSocket流
+func (m *WSMonitor) SubscribeIntervals(symbols, intervals []string) error {
+	for _, st := range intervals {
+		if _, ok := klineIntervals[st]; !ok {
+			return fmt.Errorf("不支持的K线周期: %s", st)
+		}
+	}
+
+	var streams []string
+	for _, symbol := range symbols {
+		for _, st := range intervals {
+			streams = append(streams, klineStream(Normalize(symbol), st))
+		}
+	}
+	return m.acquireStreams(streams)
+}
+
+// UnsubscribeIntervals 释放币种指定周期K线流的订阅引用，无人使用的流取消订阅并清理缓存
+func (m *WSMonitor) UnsubscribeIntervals(symbols, intervals []string) {
+	var streams []string
+	for _, symbol := range symbols {
+		for _, st := range intervals {
+			streams = append(streams, klineStream(Normalize(symbol), st))
+		}
+	}
+	m.releaseAndUnsubscribe(streams)
+}
+
+// acquireStreams 增加流的引用计数，新的K线流先回填历史K线再订阅
+// 返回错误时本次调用增加的引用已全部释放，调用方不能再调用对应的取消订阅（否则会释放其他调用方的引用）
+// subMu 只在修改引用计数和订阅状态时持有，REST回填和发送订阅请求在锁外进行，避免一个慢的币种阻塞其他订阅/取消订阅
+func (m *WSMonitor) acquireStreams(streams []string) error {
+	m.subMu.Lock()
+	var added, addedKlines []string
+	for _, stream := range streams {
+		m.refCounts[stream]++
+		if m.refCounts[stream] == 1 {
+			added = append(added, stream)
+			if isKlineStream(stream) {
+				addedKlines = append(addedKlines, stream)
+			}
+		}
+	}
+	m.subMu.Unlock()
+	if len(added) == 0 {
+		return nil
+	}
+
+	m.loadHistoricalData(addedKlines)
+
+	m.subMu.Lock()
+	// 回填期间可能已被释放（清理回填写入的缓存），或已由 subscribeAll/其他调用方激活
+	var activated []string
+	for _, stream := range added {
+		if _, active := m.activeStreams.Load(stream); active {
+			continue
+		}
+		if m.refCounts[stream] == 0 {
+			if isKlineStream(stream) {
+				symbol, st := parseKlineStream(stream)
+				m.getKlineDataMap(st).Delete(symbol)
+			}
+			continue
+		}
+		m.activeStreams.Store(stream, true)
+		activated = append(activated, stream)
+	}
+	if !m.connected || len(activated) == 0 {
+		// 组合流尚未连接时由 subscribeAll 统一订阅
+		m.subMu.Unlock()
+		return nil
+	}
+	for _, stream := range activated {
+		m.addStreamSubscriber(stream)
+	}
+	m.subMu.Unlock()
+
+	for _, batch := range m.combinedClient.splitIntoBatches(activated, m.batchSize) {
+		if err := m.combinedClient.subscribeStreams(batch); err != nil {
+			// 订阅失败时只释放本次调用增加的引用（其他调用方在此期间增加的引用保留）
+			m.releaseAndUnsubscribe(streams)
+			return fmt.Errorf("订阅 %v 失败: %w", activated, err)
+		}
+	}
+	log.Printf("✅ 动态订阅 %d 个行情流: %v", len(activated), activated)
+	return nil
+}
+
+// releaseAndUnsubscribe 释放流的引用，无人使用的流在 subMu 外发送取消订阅请求
+func (m *WSMonitor) releaseAndUnsubscribe(streams []string) {
+	m.subMu.Lock()
+	removed := m.releaseStreams(streams)
+	m.subMu.Unlock()
+
+	m.unsubscribeRemoved(removed)
+}
+
+// releaseStreams 减少流的引用计数，无人使用的流清理缓存，返回需要取消订阅的流（调用方持有 subMu）
+func (m *WSMonitor) releaseStreams(streams []string) []string {
+	var removed []string
+	for _, stream := range streams {
+		count, exists := m.refCounts[stream]
+		if !exists {
+			continue
+		}
+		if count > 1 {
+			m.refCounts[stream] = count - 1
+			continue
+		}
+		delete(m.refCounts, stream)
+		removed = append(removed, stream)
+	}
+	if len(removed) == 0 {
+		return nil
+	}
+
+	m.removeStreams(removed)
+	log.Printf("🧹 取消订阅 %d 个无人使用的行情流: %v", len(removed), removed)
+	return removed
+}
+
+// addStreamSubscriber 注册K线流、订单簿流、归集成交流或强平订单流的监听，返回流名称
+func (m *WSMonitor) addStreamSubscriber(stream string) string {
+	if stream == liquidationStream {
+		m.liquidations.start(time.Now())
+		ch := m.combinedClient.AddSubscriber(stream, 1000)
+		go m.handleLiquidationData(ch)
+		return stream
+	}
+	if symbol, isDepth := parseDepthStream(stream); isDepth {
+		ch := m.combinedClient.AddSubscriber(stream, 100)
+		go m.handleDepthData(symbol, ch)
+		return stream
+	}
+	if symbol, isAggTrade := parseAggTradeStream(stream); isAggTrade {
+		// 成交推送频繁，使用更大的缓冲区；统计从订阅时开始
+		tape := newTradeTape(time.Now())
+		m.tradeTapes.Store(symbol, tape)
+		ch := m.combinedClient.AddSubscriber(stream, 1000)
+		go m.handleAggTradeData(symbol, tape, ch)
+		return stream
+	}
+	symbol, st := parseKlineStream(stream)
+	return m.subscribeSymbol(symbol, st)[0]
+}
+
+// removeStreams 移除K线流/订单簿流/归集成交流的监听并清理缓存（调用方持有 subMu，取消订阅请求由 unsubscribeRemoved 在锁外发送）
+func (m *WSMonitor) removeStreams(streams []string) {
+	for _, stream := range streams {
+		m.activeStreams.Delete(stream)
+		m.combinedClient.RemoveSubscriber(stream)
+		if symbol, isDepth := parseDepthStream(stream); isDepth {
+			m.orderBooks.Delete(symbol)
+			continue
+		}
+		if symbol, isAggTrade := parseAggTradeStream(stream); isAggTrade {
+			m.tradeTapes.Delete(symbol)
+			continue
+		}
+		symbol, st := parseKlineStream(stream)
+		m.streamSince.Delete(stream)
+		m.getKlineDataMap(st).Delete(symbol)
+	}
+}
+
+// unsubscribeRemoved 发送取消订阅请求（不持有 subMu；发送前已被重新订阅的流跳过）
+func (m *WSMonitor) unsubscribeRemoved(streams []string) {
+	if len(streams) == 0 {
+		return
+	}
+
+	m.subMu.Lock()
+	connected := m.connected
+	pending := make([]string, 0, len(streams))
+	for _, stream := range streams {
+		if m.refCounts[stream] == 0 {
+			pending = append(pending, stream)
+		}
+	}
+	m.subMu.Unlock()
+	if !connected || len(pending) == 0 {
+		return
+	}
+
+	for _, batch := range m.combinedClient.splitIntoBatches(pending, m.batchSize) {
+		if err := m.combinedClient.unsubscribeStreams(batch); err != nil {
+			log.Printf("⚠️  取消订阅行情流失败: %v", err)
+		}
+	}
+}
+
+// isStreamActive 币种指定周期的K线流是否已订阅
+func (m *WSMonitor) isStreamActive(symbol, st string) bool {
+	_, active := m.activeStreams.Load(klineStream(symbol, st))
+	return active
+}
+
 func (m *WSMonitor) handleKlineData(symbol string, ch <-chan []byte, _time string) {
 	for data := range ch {
 		var klineData KlineWSData
@@ -211,6 +441,10 @@ func (m *WSMonitor) handleKlineData(symbol string, ch <-chan []byte, _time strin
 			log.Printf("解析Kline数据失败: %v", err)
 			continue
 		}
+		// 已取消订阅的流（通道关闭后仍在缓冲区中的消息）不再写入缓存
+		if !m.isStreamActive(symbol, _time) {
+			continue
+		}
 		m.processKlineUpdate(symbol, klineData, _time)
 	}
 }
@@ -231,7 +465,8 @@ func (m *WSMonitor) getKlineDataMap(_time string) *sync.Map {
 	case "4h":
 		klineDataMap = &m.klineDataMap4h
 	default:
-		klineDataMap = &sync.Map{}
+		value, _ := m.klineDataMaps.LoadOrStore(_time, &sync.Map{})
+		klineDataMap = value.(*sync.Map)
 	}
 	return klineDataMap
 }
@@ -252,6 +487,8 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 	kline.TakerBuyBaseVolume, _ = parseFloat(wsData.Kline.TakerBuyBaseVolume)
 	kline.TakerBuyQuoteVolume, _ = parseFloat(wsData.Kline.TakerBuyQuoteVolume)
 	// 更新K线数据
+	m.klineMu.Lock()
+	defer m.klineMu.Unlock()
 	var klineDataMap = m.getKlineDataMap(_time)
 	value, exists := klineDataMap.Load(symbol)
 	var klines []Kline
@@ -276,36 +513,57 @@ func (m *WSMonitor) processKlineUpdate(symbol string, wsData KlineWSData, _time
 	}
 
 	klineDataMap.Store(symbol, klines)
+	if _time == featureInterval {
+		m.updateFeatures(symbol, klines, wsData.Kline.IsFinal)
+	}
 }
 
 func (m *WSMonitor) GetCurrentKlines(symbol string, _time string) ([]Kline, error) {
 	// 对每一个进来的symbol检测是否存在内类 是否的话就订阅它
 	symbol = strings.ToUpper(symbol)
+	if klines, exists := m.copyCachedKlines(symbol, _time); exists {
+		return klines, nil
+	}
+
+	// 未订阅的币种（或Ws数据未初始化完成时）单独使用api获取 - 兼容性代码
+	// 不写入缓存：缓存只保存有WebSocket流更新的币种，需要持续使用的币种应通过 Subscribe 订阅
+	apiClient := NewAPIClient()
+	klines, err := apiClient.GetKlines(symbol, _time, 100)
+	if err != nil {
+		return nil, fmt.Errorf("获取%v分钟K线失败: %v", _time, err)
+	}
+	return klines, nil
+}
+
+// copyCachedKlines 返回缓存K线的副本（缓存中的切片会被WebSocket更新原地修改最后一根K线，复制时需持有 klineMu）
+func (m *WSMonitor) copyCachedKlines(symbol, _time string) ([]Kline, bool) {
+	m.klineMu.Lock()
+	defer m.klineMu.Unlock()
+
 	value, exists := m.getKlineDataMap(_time).Load(symbol)
 	if !exists {
-		// 如果Ws数据未初始化完成时,单独使用api获取 - 兼容性代码 (防止在未初始化完成是,已经有交易员运行)
-		apiClient := NewAPIClient()
-		klines, err := apiClient.GetKlines(symbol, _time, 100)
-		if err != nil {
-			return nil, fmt.Errorf("获取%v分钟K线失败: %v", _time, err)
-		}
-		// 存储数据到缓存
-		m.getKlineDataMap(_time).Store(symbol, klines)
-		// 动态订阅流
-		subStr := m.subscribeSymbol(symbol, _time)
-		subErr := m.combinedClient.subscribeStreams(subStr)
-		if subErr != nil {
-			log.Printf("⚠️  动态订阅%v分钟K线流失败: %v (数据已获取)", _time, subErr)
-		} else {
-			log.Printf("✅ 动态订阅流成功: %v", subStr)
-		}
-		// 返回成功获取的数据
-		return klines, nil
+		return nil, false
 	}
-	return value.([]Kline), nil
+	cached := value.([]Kline)
+	klines := make([]Kline, len(cached))
+	copy(klines, cached)
+	return klines, true
 }
 
+// Close 关闭WebSocket连接并停止警报分发
+// 先停止组合流（关闭订阅者通道，处理协程随之退出），再关闭 alertsChan；处理协程中仍在处理的消息产生的警报由 alertsClosed 丢弃
 func (m *WSMonitor) Close() {
+	m.subMu.Lock()
+	m.connected = false
+	m.subMu.Unlock()
+
 	m.wsClient.Close()
-	close(m.alertsChan)
+	m.combinedClient.Close()
+
+	m.alertsMu.Lock()
+	defer m.alertsMu.Unlock()
+	if !m.alertsClosed {
+		m.alertsClosed = true
+		close(m.alertsChan)
+	}
 }
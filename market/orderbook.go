@@ -0,0 +1,271 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 市价单方向（预估滑点使用）
+const (
+	SideBuy  = "buy"  // 买入（开多），吃卖盘
+	SideSell = "sell" // 卖出（开空），吃买盘
+)
+
+// depthLevels 订阅和获取的订单簿档位数量
+const depthLevels = 20
+
+// depthStreamSuffix 前20档订单簿流（每100毫秒推送一次完整快照）
+const depthStreamSuffix = "@depth20@100ms"
+
+// orderBookMaxAge WebSocket订单簿超过该时间没有更新时改用REST获取
+const orderBookMaxAge = 5 * time.Second
+
+// slippageReferenceUSD 行情数据中预估滑点使用的参考名义价值（USD）
+const slippageReferenceUSD = 10_000
+
+// BookLevel 订单簿档位
+type BookLevel struct {
+	Price    float64 `json:"price"`
+	Quantity float64 `json:"quantity"`
+}
+
+// OrderBook 本地L2订单簿（买盘价格从高到低，卖盘价格从低到高）
+type OrderBook struct {
+	Symbol    string      `json:"symbol"`
+	Bids      []BookLevel `json:"bids"`
+	Asks      []BookLevel `json:"asks"`
+	EventTime int64       `json:"event_time"` // 交易所推送时间（毫秒）
+	UpdatedAt time.Time   `json:"updated_at"` // 本地更新时间
+}
+
+// OrderBookMetrics 订单簿微观结构指标
+type OrderBookMetrics struct {
+	BestBid         float64   `json:"best_bid"`
+	BestAsk         float64   `json:"best_ask"`
+	SpreadPct       float64   `json:"spread_pct"`        // 买卖价差（相对中间价，%）
+	Imbalance5      float64   `json:"imbalance_5"`       // 前5档买卖量失衡（-1~1，正数表示买盘更强）
+	Imbalance20     float64   `json:"imbalance_20"`      // 前20档买卖量失衡
+	BidDepthUSD     float64   `json:"bid_depth_usd"`     // 前20档买盘名义价值
+	AskDepthUSD     float64   `json:"ask_depth_usd"`     // 前20档卖盘名义价值
+	SlippageBuyPct  float64   `json:"slippage_buy_pct"`  // 市价买入 10000 USD 的预估滑点（相对中间价，%；深度不足时为可见档位内的滑点）
+	SlippageSellPct float64   `json:"slippage_sell_pct"` // 市价卖出 10000 USD 的预估滑点
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// SlippageEstimate 市价单预估成交结果
+type SlippageEstimate struct {
+	AvgPrice    float64 // 预估成交均价
+	SlippagePct float64 // 成交均价相对中间价的滑点（%）
+	FilledUSD   float64 // 可见档位内能成交的名义价值
+}
+
+// newOrderBook 从交易所返回的 [价格, 数量] 字符串数组创建订单簿
+func newOrderBook(symbol string, bids, asks [][]string, eventTime int64) *OrderBook {
+	return &OrderBook{
+		Symbol:    strings.ToUpper(symbol),
+		Bids:      parseBookLevels(bids),
+		Asks:      parseBookLevels(asks),
+		EventTime: eventTime,
+		UpdatedAt: time.Now(),
+	}
+}
+
+// parseBookLevels 解析订单簿档位（忽略数量为0的档位）
+func parseBookLevels(raw [][]string) []BookLevel {
+	levels := make([]BookLevel, 0, len(raw))
+	for _, level := range raw {
+		if len(level) < 2 {
+			continue
+		}
+		price, err := strconv.ParseFloat(level[0], 64)
+		if err != nil {
+			continue
+		}
+		quantity, err := strconv.ParseFloat(level[1], 64)
+		if err != nil || quantity == 0 {
+			continue
+		}
+		levels = append(levels, BookLevel{Price: price, Quantity: quantity})
+	}
+	return levels
+}
+
+// MidPrice 中间价（任一侧为空时返回0）
+func (b *OrderBook) MidPrice() float64 {
+	if len(b.Bids) == 0 || len(b.Asks) == 0 {
+		return 0
+	}
+	return (b.Bids[0].Price + b.Asks[0].Price) / 2
+}
+
+// SpreadPct 买卖价差（相对中间价，%）
+func (b *OrderBook) SpreadPct() float64 {
+	mid := b.MidPrice()
+	if mid == 0 {
+		return 0
+	}
+	return (b.Asks[0].Price - b.Bids[0].Price) / mid * 100
+}
+
+// Imbalance 前 levels 档的买卖量失衡：(买量-卖量)/(买量+卖量)，范围 -1~1
+func (b *OrderBook) Imbalance(levels int) float64 {
+	bidQty, askQty := 0.0, 0.0
+	for i := 0; i < levels && i < len(b.Bids); i++ {
+		bidQty += b.Bids[i].Quantity
+	}
+	for i := 0; i < levels && i < len(b.Asks); i++ {
+		askQty += b.Asks[i].Quantity
+	}
+	if bidQty+askQty == 0 {
+		return 0
+	}
+	return (bidQty - askQty) / (bidQty + askQty)
+}
+
+// EstimateSlippage 按订单簿逐档成交预估市价单的成交均价和滑点（notionalUSD 为名义价值）
+// 可见档位不足以完全成交时返回错误，估算结果为可见档位内的部分
+func (b *OrderBook) EstimateSlippage(side string, notionalUSD float64) (*SlippageEstimate, error) {
+	mid := b.MidPrice()
+	if mid == 0 {
+		return nil, fmt.Errorf("%s 订单簿为空", b.Symbol)
+	}
+
+	levels := b.Asks
+	if side == SideSell {
+		levels = b.Bids
+	}
+
+	remaining := notionalUSD
+	cost, quantity := 0.0, 0.0
+	for _, level := range levels {
+		levelUSD := level.Price * level.Quantity
+		take := math.Min(remaining, levelUSD)
+		cost += take
+		quantity += take / level.Price
+		remaining -= take
+		if remaining <= 0 {
+			break
+		}
+	}
+	if quantity == 0 {
+		return nil, fmt.Errorf("%s 订单簿没有可成交的档位", b.Symbol)
+	}
+
+	avgPrice := cost / quantity
+	estimate := &SlippageEstimate{
+		AvgPrice:    avgPrice,
+		SlippagePct: math.Abs(avgPrice-mid) / mid * 100,
+		FilledUSD:   cost,
+	}
+	if remaining > 0 {
+		return estimate, fmt.Errorf("前%d档深度不足: 只能成交 %.0f / %.0f USD", len(levels), cost, notionalUSD)
+	}
+	return estimate, nil
+}
+
+// Metrics 计算订单簿微观结构指标
+func (b *OrderBook) Metrics() *OrderBookMetrics {
+	metrics := &OrderBookMetrics{
+		SpreadPct:   b.SpreadPct(),
+		Imbalance5:  b.Imbalance(5),
+		Imbalance20: b.Imbalance(depthLevels),
+		UpdatedAt:   b.UpdatedAt,
+	}
+	if len(b.Bids) > 0 {
+		metrics.BestBid = b.Bids[0].Price
+	}
+	if len(b.Asks) > 0 {
+		metrics.BestAsk = b.Asks[0].Price
+	}
+	for _, level := range b.Bids {
+		metrics.BidDepthUSD += level.Price * level.Quantity
+	}
+	for _, level := range b.Asks {
+		metrics.AskDepthUSD += level.Price * level.Quantity
+	}
+	if estimate, _ := b.EstimateSlippage(SideBuy, slippageReferenceUSD); estimate != nil {
+		metrics.SlippageBuyPct = estimate.SlippagePct
+	}
+	if estimate, _ := b.EstimateSlippage(SideSell, slippageReferenceUSD); estimate != nil {
+		metrics.SlippageSellPct = estimate.SlippagePct
+	}
+	return metrics
+}
+
+// depthStream 订单簿流名称（BTCUSDT -> btcusdt@depth20@100ms）
+func depthStream(symbol string) string {
+	return strings.ToLower(symbol) + depthStreamSuffix
+}
+
+// parseDepthStream 解析订单簿流名称（btcusdt@depth20@100ms -> BTCUSDT）
+func parseDepthStream(stream string) (string, bool) {
+	symbol, ok := strings.CutSuffix(stream, depthStreamSuffix)
+	return strings.ToUpper(symbol), ok
+}
+
+// SubscribeDepth 动态订阅币种的订单簿流（引用计数：每次成功的 SubscribeDepth 需对应一次 UnsubscribeDepth，返回错误时不持有任何引用）
+func (m *WSMonitor) SubscribeDepth(symbols []string) error {
+	streams := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = depthStream(Normalize(symbol))
+	}
+	return m.acquireStreams(streams)
+}
+
+// UnsubscribeDepth 释放币种订单簿流的订阅引用，无人使用的流取消订阅并清理本地订单簿
+func (m *WSMonitor) UnsubscribeDepth(symbols []string) {
+	streams := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = depthStream(Normalize(symbol))
+	}
+	m.releaseAndUnsubscribe(streams)
+}
+
+// handleDepthData 处理订单簿推送（每条消息都是前20档的完整快照，直接替换本地订单簿）
+func (m *WSMonitor) handleDepthData(symbol string, ch <-chan []byte) {
+	stream := depthStream(symbol)
+	for data := range ch {
+		var depth struct {
+			EventTime int64      `json:"E"`
+			Bids      [][]string `json:"b"`
+			Asks      [][]string `json:"a"`
+		}
+		if err := json.Unmarshal(data, &depth); err != nil {
+			log.Printf("解析深度数据失败: %v", err)
+			continue
+		}
+		// 已取消订阅的流（通道关闭后仍在缓冲区中的消息）不再写入
+		if _, active := m.activeStreams.Load(stream); !active {
+			continue
+		}
+		m.orderBooks.Store(symbol, newOrderBook(symbol, depth.Bids, depth.Asks, depth.EventTime))
+	}
+}
+
+// GetOrderBook 获取本地订单簿（未订阅或超过 orderBookMaxAge 未更新时返回 false）
+func (m *WSMonitor) GetOrderBook(symbol string) (*OrderBook, bool) {
+	value, exists := m.orderBooks.Load(Normalize(symbol))
+	if !exists {
+		return nil, false
+	}
+	book := value.(*OrderBook)
+	if time.Since(book.UpdatedAt) > orderBookMaxAge {
+		return nil, false
+	}
+	return book, true
+}
+
+// formatOrderBook 格式化订单簿指标（没有订单簿数据时返回空字符串）
+func formatOrderBook(metrics *OrderBookMetrics) string {
+	if metrics == nil {
+		return ""
+	}
+	return fmt.Sprintf("Order Book: spread=%.4f%%, imbalance(top5)=%.2f, imbalance(top20)=%.2f, depth(top20) bid=%.0f USD ask=%.0f USD, est. slippage for %d USD: buy=%.4f%% sell=%.4f%%\n\n",
+		metrics.SpreadPct, metrics.Imbalance5, metrics.Imbalance20, metrics.BidDepthUSD, metrics.AskDepthUSD,
+		slippageReferenceUSD, metrics.SlippageBuyPct, metrics.SlippageSellPct)
+}
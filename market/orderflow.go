@@ -0,0 +1,335 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// aggTradeStreamSuffix 归集成交流（每笔主动成交实时推送）
+const aggTradeStreamSuffix = "@aggTrade"
+
+// largeTradeUSD 大单阈值（单笔归集成交名义价值，USD）
+const largeTradeUSD = 100_000
+
+// tradeTapeMinutes 逐笔成交按分钟聚合后保留的分钟数（覆盖最长的统计窗口）
+const tradeTapeMinutes = 240
+
+// aggTradesRESTLimit 未订阅WebSocket时通过REST获取的最近成交笔数（接口上限）
+const aggTradesRESTLimit = 1000
+
+// 订单流数据来源
+const (
+	OrderFlowSourceWebSocket = "websocket" // 订阅的归集成交流（连续统计）
+	OrderFlowSourceREST      = "rest"      // 最近1000笔归集成交（只覆盖最近一段时间）
+)
+
+// orderFlowWindows 订单流统计窗口
+var orderFlowWindows = []struct {
+	Interval string
+	Minutes  int64
+}{
+	{"5m", 5},
+	{"15m", 15},
+	{"1h", 60},
+	{"4h", 240},
+}
+
+// cvdSeriesPoints CVD序列点数（每5分钟一个点，覆盖最近1小时）
+const cvdSeriesPoints = 12
+
+// AggTrade 归集成交
+type AggTrade struct {
+	Price      float64
+	Quantity   float64
+	Time       int64 // 成交时间（毫秒）
+	BuyerMaker bool  // 买方是挂单方，即主动卖出
+}
+
+// aggTradeMessage 归集成交的原始格式（REST与WebSocket字段相同）
+type aggTradeMessage struct {
+	Price      string `json:"p"`
+	Quantity   string `json:"q"`
+	Time       int64  `json:"T"`
+	BuyerMaker bool   `json:"m"`
+}
+
+func (msg aggTradeMessage) parse() (AggTrade, error) {
+	price, err := strconv.ParseFloat(msg.Price, 64)
+	if err != nil {
+		return AggTrade{}, err
+	}
+	quantity, err := strconv.ParseFloat(msg.Quantity, 64)
+	if err != nil {
+		return AggTrade{}, err
+	}
+	return AggTrade{Price: price, Quantity: quantity, Time: msg.Time, BuyerMaker: msg.BuyerMaker}, nil
+}
+
+// OrderFlowWindow 单个统计窗口内的主动买卖量
+type OrderFlowWindow struct {
+	Interval      string  `json:"interval"`
+	BuyVolumeUSD  float64 `json:"buy_volume_usd"`  // 主动买入名义价值
+	SellVolumeUSD float64 `json:"sell_volume_usd"` // 主动卖出名义价值
+	CVD           float64 `json:"cvd"`             // 累计成交量差（主动买入-主动卖出，USD）
+	CVDPct        float64 `json:"cvd_pct"`         // CVD 占总成交额的比例（%，正数表示买方主导）
+	Trades        int     `json:"trades"`
+	LargeBuys     int     `json:"large_buys"`  // 主动买入大单笔数
+	LargeSells    int     `json:"large_sells"` // 主动卖出大单笔数
+	Complete      bool    `json:"complete"`    // 统计是否覆盖整个窗口（刚订阅、断线重连或REST数据只覆盖部分时间时为 false）
+}
+
+// OrderFlowData 逐笔成交订单流数据
+type OrderFlowData struct {
+	Windows       []OrderFlowWindow `json:"windows"`
+	CVDSeries     []float64         `json:"cvd_series"`      // 最近1小时每5分钟的累计CVD（USD，从旧到新）
+	LargeTradeUSD float64           `json:"large_trade_usd"` // 大单阈值
+	Source        string            `json:"source"`          // websocket / rest
+	UpdatedAt     time.Time         `json:"updated_at"`
+}
+
+// flowBucket 一分钟内的主动买卖量
+type flowBucket struct {
+	Minute     int64 // 分钟序号（毫秒时间戳/60000）
+	BuyUSD     float64
+	SellUSD    float64
+	Trades     int
+	LargeBuys  int
+	LargeSells int
+}
+
+// tradeTape 按分钟聚合的逐笔成交
+type tradeTape struct {
+	mu        sync.Mutex
+	buckets   []flowBucket // 从旧到新
+	since     time.Time    // 连续统计的开始时间（之前的窗口不完整）
+	updatedAt time.Time
+}
+
+func newTradeTape(since time.Time) *tradeTape {
+	return &tradeTape{since: since}
+}
+
+// add 记录一笔成交（早于已保留范围的成交忽略）
+func (t *tradeTape) add(trade AggTrade) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	minute := trade.Time / 60000
+	n := len(t.buckets)
+	var bucket *flowBucket
+	switch {
+	case n == 0 || t.buckets[n-1].Minute < minute:
+		t.buckets = append(t.buckets, flowBucket{Minute: minute})
+		bucket = &t.buckets[n]
+		// 丢弃超出保留范围的分钟
+		drop := 0
+		for drop < len(t.buckets) && t.buckets[drop].Minute <= minute-tradeTapeMinutes {
+			drop++
+		}
+		if drop > 0 {
+			t.buckets = append(t.buckets[:0], t.buckets[drop:]...)
+			bucket = &t.buckets[len(t.buckets)-1]
+		}
+	default:
+		// 乱序到达的成交写入对应的分钟
+		for i := n - 1; i >= 0; i-- {
+			if t.buckets[i].Minute == minute {
+				bucket = &t.buckets[i]
+				break
+			}
+			if t.buckets[i].Minute < minute {
+				break
+			}
+		}
+		if bucket == nil {
+			return
+		}
+	}
+
+	notional := trade.Price * trade.Quantity
+	large := notional >= largeTradeUSD
+	if trade.BuyerMaker {
+		bucket.SellUSD += notional
+		if large {
+			bucket.LargeSells++
+		}
+	} else {
+		bucket.BuyUSD += notional
+		if large {
+			bucket.LargeBuys++
+		}
+	}
+	bucket.Trades++
+	t.updatedAt = time.Now()
+}
+
+// markGap 标记统计缺口（断线期间的成交无法回填，此前开始的窗口不再完整）
+func (t *tradeTape) markGap(at time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.since = at
+}
+
+// orderFlow 计算截至 now 的各窗口订单流
+func (t *tradeTape) orderFlow(now time.Time, source string) *OrderFlowData {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	nowMinute := now.UnixMilli() / 60000
+	flow := &OrderFlowData{
+		Windows:       make([]OrderFlowWindow, 0, len(orderFlowWindows)),
+		CVDSeries:     make([]float64, cvdSeriesPoints),
+		LargeTradeUSD: largeTradeUSD,
+		Source:        source,
+		UpdatedAt:     t.updatedAt,
+	}
+
+	for _, w := range orderFlowWindows {
+		start := nowMinute - w.Minutes + 1
+		window := OrderFlowWindow{
+			Interval: w.Interval,
+			Complete: !t.since.After(time.UnixMilli(start * 60000)),
+		}
+		for _, bucket := range t.buckets {
+			if bucket.Minute < start || bucket.Minute > nowMinute {
+				continue
+			}
+			window.BuyVolumeUSD += bucket.BuyUSD
+			window.SellVolumeUSD += bucket.SellUSD
+			window.Trades += bucket.Trades
+			window.LargeBuys += bucket.LargeBuys
+			window.LargeSells += bucket.LargeSells
+		}
+		window.CVD = window.BuyVolumeUSD - window.SellVolumeUSD
+		if total := window.BuyVolumeUSD + window.SellVolumeUSD; total > 0 {
+			window.CVDPct = window.CVD / total * 100
+		}
+		flow.Windows = append(flow.Windows, window)
+	}
+
+	// CVD序列：从1小时前开始累计，每5分钟取一个点
+	seriesStart := nowMinute - cvdSeriesPoints*5 + 1
+	for _, bucket := range t.buckets {
+		if bucket.Minute < seriesStart || bucket.Minute > nowMinute {
+			continue
+		}
+		for i := int((bucket.Minute - seriesStart) / 5); i < cvdSeriesPoints; i++ {
+			flow.CVDSeries[i] += bucket.BuyUSD - bucket.SellUSD
+		}
+	}
+	return flow
+}
+
+// aggTradeStream 归集成交流名称（BTCUSDT -> btcusdt@aggTrade）
+func aggTradeStream(symbol string) string {
+	return strings.ToLower(symbol) + aggTradeStreamSuffix
+}
+
+// parseAggTradeStream 解析归集成交流名称（btcusdt@aggTrade -> BTCUSDT）
+func parseAggTradeStream(stream string) (string, bool) {
+	symbol, ok := strings.CutSuffix(stream, aggTradeStreamSuffix)
+	return strings.ToUpper(symbol), ok
+}
+
+// SubscribeAggTrades 动态订阅币种的归集成交流（引用计数：每次成功的 SubscribeAggTrades 需对应一次 UnsubscribeAggTrades，返回错误时不持有任何引用）
+func (m *WSMonitor) SubscribeAggTrades(symbols []string) error {
+	streams := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = aggTradeStream(Normalize(symbol))
+	}
+	return m.acquireStreams(streams)
+}
+
+// UnsubscribeAggTrades 释放币种归集成交流的订阅引用，无人使用的流取消订阅并清理统计
+func (m *WSMonitor) UnsubscribeAggTrades(symbols []string) {
+	streams := make([]string, len(symbols))
+	for i, symbol := range symbols {
+		streams[i] = aggTradeStream(Normalize(symbol))
+	}
+	m.releaseAndUnsubscribe(streams)
+}
+
+// handleAggTradeData 处理归集成交推送，按分钟累计主动买卖量
+func (m *WSMonitor) handleAggTradeData(symbol string, tape *tradeTape, ch <-chan []byte) {
+	stream := aggTradeStream(symbol)
+	for data := range ch {
+		var msg aggTradeMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			log.Printf("解析归集成交数据失败: %v", err)
+			continue
+		}
+		// 已取消订阅的流（通道关闭后仍在缓冲区中的消息）不再统计
+		if _, active := m.activeStreams.Load(stream); !active {
+			continue
+		}
+		trade, err := msg.parse()
+		if err != nil {
+			log.Printf("解析归集成交数据失败: %v", err)
+			continue
+		}
+		tape.add(trade)
+	}
+}
+
+// markTradeGaps 断线重连后标记所有逐笔成交统计的缺口
+func (m *WSMonitor) markTradeGaps() {
+	now := time.Now()
+	m.tradeTapes.Range(func(_, value interface{}) bool {
+		value.(*tradeTape).markGap(now)
+		return true
+	})
+}
+
+// GetOrderFlow 获取订阅的归集成交流统计的订单流（未订阅时返回 false）
+func (m *WSMonitor) GetOrderFlow(symbol string) (*OrderFlowData, bool) {
+	value, exists := m.tradeTapes.Load(Normalize(symbol))
+	if !exists {
+		return nil, false
+	}
+	return value.(*tradeTape).orderFlow(time.Now(), OrderFlowSourceWebSocket), true
+}
+
+// orderFlowFromTrades 用一批成交（从旧到新）计算订单流，统计从第一笔成交开始
+func orderFlowFromTrades(trades []AggTrade, source string) *OrderFlowData {
+	since := time.Now()
+	if len(trades) > 0 {
+		since = time.UnixMilli(trades[0].Time)
+	}
+	tape := newTradeTape(since)
+	for _, trade := range trades {
+		tape.add(trade)
+	}
+	return tape.orderFlow(time.Now(), source)
+}
+
+// formatOrderFlow 格式化订单流数据（没有数据时返回空字符串）
+func formatOrderFlow(flow *OrderFlowData) string {
+	if flow == nil {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(fmt.Sprintf("Order Flow (aggressive trades, large trade >= %.0f USD):\n\n", flow.LargeTradeUSD))
+	for _, w := range flow.Windows {
+		partial := ""
+		if !w.Complete {
+			partial = " (partial window)"
+		}
+		sb.WriteString(fmt.Sprintf("%s: buy=%.0f USD, sell=%.0f USD, CVD=%+.0f USD (%+.1f%%), trades=%d, large buys=%d, large sells=%d%s\n",
+			w.Interval, w.BuyVolumeUSD, w.SellVolumeUSD, w.CVD, w.CVDPct, w.Trades, w.LargeBuys, w.LargeSells, partial))
+	}
+	if len(flow.CVDSeries) > 0 {
+		values := make([]string, len(flow.CVDSeries))
+		for i, v := range flow.CVDSeries {
+			values[i] = fmt.Sprintf("%.0f", v)
+		}
+		sb.WriteString(fmt.Sprintf("CVD (5m steps over last 1h, oldest → latest): [%s]\n", strings.Join(values, ", ")))
+	}
+	sb.WriteString("\n")
+	return sb.String()
+}
@@ -0,0 +1,663 @@
+package market
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"strings"
+)
+
+// 行情数据配置支持的指标
+const (
+	IndicatorEMA        = "ema"        // 指数移动平均（参数: 周期，可多个，默认 [20]）
+	IndicatorMACD       = "macd"       // MACD（参数: [快线周期, 慢线周期, 信号线周期]，默认 [12, 26, 9]，不指定信号线周期时只输出MACD线）
+	IndicatorRSI        = "rsi"        // RSI（参数: 周期，可多个，默认 [14]）
+	IndicatorATR        = "atr"        // ATR（参数: 周期，可多个，默认 [14]）
+	IndicatorSupertrend = "supertrend" // Supertrend（参数: [ATR周期, 乘数]，默认 [10, 3]）
+	IndicatorVolume     = "volume"     // 成交量比率：当前/平均（参数: [平均周期]，默认 [20]）
+	IndicatorBollinger  = "bollinger"  // 布林带（参数: [周期, 标准差倍数]，默认 [20, 2]）
+	IndicatorVWAP       = "vwap"       // VWAP（参数: 不指定为当日UTC 0点起，[N] 为锚定N小时前，锚定时间不超出K线窗口）
+	IndicatorStochRSI   = "stochrsi"   // 随机RSI（参数: [RSI周期, 随机周期, K平滑, D平滑]，默认 [14, 14, 3, 3]）
+	IndicatorADX        = "adx"        // ADX/DMI（参数: [周期]，默认 [14]）
+	IndicatorOBV        = "obv"        // 能量潮（参数: [变化回看K线数]，默认 [10]）
+	IndicatorIchimoku   = "ichimoku"   // 一目均衡表（参数: [转换线周期, 基准线周期, 先行带B周期]，默认 [9, 26, 52]）
+	IndicatorDonchian   = "donchian"   // 唐奇安通道（参数: [周期]，默认 [20]）
+)
+
+// indicatorDefaults 各指标的默认参数
+var indicatorDefaults = map[string][]float64{
+	IndicatorEMA:        {20},
+	IndicatorMACD:       {12, 26, 9},
+	IndicatorRSI:        {14},
+	IndicatorATR:        {14},
+	IndicatorSupertrend: {10, 3},
+	IndicatorVolume:     {20},
+	IndicatorBollinger:  {20, 2},
+	IndicatorVWAP:       {},
+	IndicatorStochRSI:   {14, 14, 3, 3},
+	IndicatorADX:        {14},
+	IndicatorOBV:        {10},
+	IndicatorIchimoku:   {9, 26, 52},
+	IndicatorDonchian:   {20},
+}
+
+// 预设的行情数据配置
+const (
+	ProfilePresetDefault = "default" // 原有格式（3分钟日内序列 + 4小时长期背景 + 多时间框架Supertrend）
+	ProfilePresetScalper = "scalper" // 短线：1m/5m/15m
+	ProfilePresetSwing   = "swing"   // 波段：1h/4h/1d
+)
+
+// maxProfileTimeframes 单个配置最多的时间框架数量
+const maxProfileTimeframes = 6
+
+// maxSeriesLength 序列最多输出的数据点数量
+const maxSeriesLength = 20
+
+// maxIndicatorPeriod 指标周期上限（每个时间框架获取 klineLimit 根K线，需要为序列留出数据）
+const maxIndicatorPeriod = klineLimit - maxSeriesLength
+
+// DataProfile 行情数据配置：选择的时间框架、各时间框架的指标及参数
+// 未配置时间框架时使用原有格式，配置后 Format 只输出选择的时间框架和指标
+type DataProfile struct {
+	Preset     string          `json:"preset,omitempty"` // 预设名称（default/scalper/swing，指定时间框架时忽略）
+	Timeframes []TimeframeSpec `json:"timeframes,omitempty"`
+}
+
+// TimeframeSpec 单个时间框架的配置
+type TimeframeSpec struct {
+	Interval   string          `json:"interval"`         // K线周期: 1m/3m/5m/15m/30m/1h/2h/4h/8h/12h/1d
+	Series     int             `json:"series,omitempty"` // 输出最近N个数据点的序列（0=只输出最新值）
+	Indicators []IndicatorSpec `json:"indicators"`
+}
+
+// IndicatorSpec 指标及参数
+type IndicatorSpec struct {
+	Type   string    `json:"type"`
+	Params []float64 `json:"params,omitempty"`
+}
+
+// TimeframeData 按行情数据配置计算的单个时间框架数据
+type TimeframeData struct {
+	Interval   string           `json:"interval"`
+	Closes     []float64        `json:"closes,omitempty"` // 最近N根K线的收盘价（oldest → latest）
+	Indicators []IndicatorValue `json:"indicators"`
+}
+
+// IndicatorValue 指标计算结果
+type IndicatorValue struct {
+	Name   string    `json:"name"`             // 指标名称（如 EMA(20)）
+	Value  float64   `json:"value"`            // 最新值
+	Series []float64 `json:"series,omitempty"` // 最近N个值（oldest → latest）
+	Trend  string    `json:"trend,omitempty"`  // Supertrend 趋势（up/down）
+	Signal string    `json:"signal,omitempty"` // Supertrend 信号（long/short/none）
+
+	Components []IndicatorComponent `json:"components,omitempty"` // 多值指标的各分量（如布林带上中下轨）
+	State      string               `json:"state,omitempty"`      // 状态（如一目均衡表中价格相对云层的位置，数据不足时为 insufficient_data）
+}
+
+// IndicatorComponent 多值指标的分量
+type IndicatorComponent struct {
+	Name  string  `json:"name"`
+	Value float64 `json:"value"`
+}
+
+// insufficientData K线数量不足以计算指标时的状态
+const insufficientData = "insufficient_data"
+
+// profilePresets 预设的时间框架配置
+var profilePresets = map[string][]TimeframeSpec{
+	ProfilePresetScalper: {
+		{Interval: "1m", Series: 10, Indicators: []IndicatorSpec{
+			{Type: IndicatorEMA, Params: []float64{9, 21}},
+			{Type: IndicatorRSI, Params: []float64{7}},
+			{Type: IndicatorMACD},
+			{Type: IndicatorVolume},
+		}},
+		{Interval: "5m", Indicators: []IndicatorSpec{
+			{Type: IndicatorEMA, Params: []float64{20}},
+			{Type: IndicatorRSI, Params: []float64{14}},
+			{Type: IndicatorSupertrend},
+		}},
+		{Interval: "15m", Indicators: []IndicatorSpec{
+			{Type: IndicatorEMA, Params: []float64{20, 50}},
+			{Type: IndicatorATR},
+			{Type: IndicatorSupertrend},
+		}},
+	},
+	ProfilePresetSwing: {
+		{Interval: "1h", Indicators: []IndicatorSpec{
+			{Type: IndicatorEMA, Params: []float64{20, 50}},
+			{Type: IndicatorRSI},
+			{Type: IndicatorMACD},
+			{Type: IndicatorSupertrend},
+		}},
+		{Interval: "4h", Series: 10, Indicators: []IndicatorSpec{
+			{Type: IndicatorEMA, Params: []float64{20, 50}},
+			{Type: IndicatorRSI},
+			{Type: IndicatorATR},
+			{Type: IndicatorSupertrend},
+		}},
+		{Interval: "1d", Series: 10, Indicators: []IndicatorSpec{
+			{Type: IndicatorEMA, Params: []float64{20, 50}},
+			{Type: IndicatorRSI},
+			{Type: IndicatorATR},
+			{Type: IndicatorSupertrend},
+			{Type: IndicatorVolume},
+		}},
+	},
+}
+
+// ParseDataProfile 解析并校验数据库中保存的行情数据配置（空字符串或默认配置返回 nil，表示使用原有格式）
+func ParseDataProfile(raw string) (*DataProfile, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+
+	var profile DataProfile
+	if err := json.Unmarshal([]byte(raw), &profile); err != nil {
+		return nil, fmt.Errorf("解析行情数据配置失败: %w", err)
+	}
+	if err := profile.Validate(); err != nil {
+		return nil, err
+	}
+	if profile.IsDefault() {
+		return nil, nil
+	}
+	return &profile, nil
+}
+
+// Validate 校验行情数据配置（展开预设，补全指标的默认参数）
+func (p *DataProfile) Validate() error {
+	if len(p.Timeframes) == 0 {
+		switch p.Preset {
+		case "", ProfilePresetDefault:
+			p.Preset = ProfilePresetDefault
+			return nil
+		}
+		preset, ok := profilePresets[p.Preset]
+		if !ok {
+			return fmt.Errorf("不支持的预设配置: %s（可选 default/scalper/swing）", p.Preset)
+		}
+		p.Timeframes = clonePreset(preset)
+		return nil
+	}
+	p.Preset = ""
+
+	if len(p.Timeframes) > maxProfileTimeframes {
+		return fmt.Errorf("时间框架数量过多: %d（最多 %d 个）", len(p.Timeframes), maxProfileTimeframes)
+	}
+	seen := make(map[string]bool)
+	for i := range p.Timeframes {
+		spec := &p.Timeframes[i]
+		if _, ok := klineIntervals[spec.Interval]; !ok {
+			return fmt.Errorf("不支持的K线周期: %s（可选 1m/3m/5m/15m/30m/1h/2h/4h/8h/12h/1d）", spec.Interval)
+		}
+		if seen[spec.Interval] {
+			return fmt.Errorf("时间框架重复: %s", spec.Interval)
+		}
+		seen[spec.Interval] = true
+		if spec.Series < 0 || spec.Series > maxSeriesLength {
+			return fmt.Errorf("%s 序列长度必须在 0-%d 之间", spec.Interval, maxSeriesLength)
+		}
+		for j := range spec.Indicators {
+			if err := spec.Indicators[j].validate(); err != nil {
+				return fmt.Errorf("%s %w", spec.Interval, err)
+			}
+		}
+	}
+	return nil
+}
+
+// validate 校验指标参数（未指定参数时使用默认值）
+func (s *IndicatorSpec) validate() error {
+	s.Type = strings.ToLower(s.Type)
+	defaults, ok := indicatorDefaults[s.Type]
+	if !ok {
+		return fmt.Errorf("不支持的指标: %s（可选 ema/macd/rsi/atr/supertrend/volume/bollinger/vwap/stochrsi/adx/obv/ichimoku/donchian）", s.Type)
+	}
+	if len(s.Params) == 0 {
+		s.Params = append([]float64(nil), defaults...)
+	}
+
+	switch s.Type {
+	case IndicatorEMA, IndicatorRSI, IndicatorATR:
+		return validatePeriods(s.Type, s.Params...)
+	case IndicatorMACD:
+		if len(s.Params) != 2 && len(s.Params) != 3 {
+			return fmt.Errorf("macd 参数应为 [快线周期, 慢线周期, 信号线周期]")
+		}
+		if err := validatePeriods(s.Type, s.Params...); err != nil {
+			return err
+		}
+		if s.Params[0] >= s.Params[1] {
+			return fmt.Errorf("macd 快线周期必须小于慢线周期")
+		}
+		if len(s.Params) == 3 && s.Params[1]+s.Params[2] > maxIndicatorPeriod {
+			return fmt.Errorf("macd 慢线周期与信号线周期之和不能超过 %d", maxIndicatorPeriod)
+		}
+	case IndicatorSupertrend:
+		if len(s.Params) != 2 {
+			return fmt.Errorf("supertrend 参数应为 [ATR周期, 乘数]")
+		}
+		if err := validatePeriods(s.Type, s.Params[0]); err != nil {
+			return err
+		}
+		if s.Params[1] <= 0 || s.Params[1] > 10 {
+			return fmt.Errorf("supertrend 乘数必须在 0-10 之间")
+		}
+	case IndicatorBollinger:
+		if len(s.Params) != 2 {
+			return fmt.Errorf("bollinger 参数应为 [周期, 标准差倍数]")
+		}
+		if err := validatePeriods(s.Type, s.Params[0]); err != nil {
+			return err
+		}
+		if s.Params[1] <= 0 || s.Params[1] > 5 {
+			return fmt.Errorf("bollinger 标准差倍数必须在 0-5 之间")
+		}
+	case IndicatorVWAP:
+		if len(s.Params) > 1 {
+			return fmt.Errorf("vwap 参数应为空（当日VWAP）或 [锚定小时数]")
+		}
+		if len(s.Params) == 1 && (s.Params[0] <= 0 || s.Params[0] > 720) {
+			return fmt.Errorf("vwap 锚定小时数必须在 0-720 之间")
+		}
+	case IndicatorStochRSI:
+		if len(s.Params) != 4 {
+			return fmt.Errorf("stochrsi 参数应为 [RSI周期, 随机周期, K平滑, D平滑]")
+		}
+		if err := validatePeriods(s.Type, s.Params...); err != nil {
+			return err
+		}
+		if s.Params[0]+s.Params[1]+s.Params[2]+s.Params[3]-2 > maxIndicatorPeriod {
+			return fmt.Errorf("stochrsi 周期之和过大（需要的K线数量不能超过 %d）", maxIndicatorPeriod)
+		}
+	case IndicatorADX:
+		if len(s.Params) != 1 {
+			return fmt.Errorf("adx 参数应为 [周期]")
+		}
+		if err := validatePeriods(s.Type, s.Params[0]); err != nil {
+			return err
+		}
+		if 2*s.Params[0]+1 > maxIndicatorPeriod {
+			return fmt.Errorf("adx 周期不能超过 %d", (maxIndicatorPeriod-1)/2)
+		}
+	case IndicatorIchimoku:
+		if len(s.Params) != 3 {
+			return fmt.Errorf("ichimoku 参数应为 [转换线周期, 基准线周期, 先行带B周期]")
+		}
+		if err := validatePeriods(s.Type, s.Params...); err != nil {
+			return err
+		}
+		if s.Params[0] >= s.Params[1] || s.Params[1] >= s.Params[2] {
+			return fmt.Errorf("ichimoku 周期必须递增（转换线 < 基准线 < 先行带B）")
+		}
+		if s.Params[1]+s.Params[2] > klineLimit {
+			return fmt.Errorf("ichimoku 基准线周期与先行带B周期之和不能超过 %d", klineLimit)
+		}
+	case IndicatorVolume, IndicatorOBV, IndicatorDonchian:
+		if len(s.Params) != 1 {
+			return fmt.Errorf("%s 参数应为 [周期]", s.Type)
+		}
+		return validatePeriods(s.Type, s.Params[0])
+	}
+	return nil
+}
+
+// validatePeriods 校验指标周期为 2 到 maxIndicatorPeriod 之间的整数
+func validatePeriods(indicator string, periods ...float64) error {
+	for _, period := range periods {
+		if period != math.Trunc(period) || period < 2 || period > maxIndicatorPeriod {
+			return fmt.Errorf("%s 周期必须是 2-%d 之间的整数: %v", indicator, maxIndicatorPeriod, period)
+		}
+	}
+	return nil
+}
+
+// clonePreset 复制预设配置（避免修改共享的预设）
+func clonePreset(preset []TimeframeSpec) []TimeframeSpec {
+	timeframes := make([]TimeframeSpec, len(preset))
+	for i, spec := range preset {
+		timeframes[i] = spec
+		timeframes[i].Indicators = make([]IndicatorSpec, len(spec.Indicators))
+		for j, indicator := range spec.Indicators {
+			indicator.Params = append([]float64(nil), indicator.Params...)
+			_ = indicator.validate() // 补全预设指标的默认参数
+			timeframes[i].Indicators[j] = indicator
+		}
+	}
+	return timeframes
+}
+
+// IsDefault 是否使用原有格式（nil 或未配置时间框架）
+func (p *DataProfile) IsDefault() bool {
+	return p == nil || len(p.Timeframes) == 0
+}
+
+// String 序列化为JSON（保存到数据库）
+func (p *DataProfile) String() string {
+	data, _ := json.Marshal(p)
+	return string(data)
+}
+
+// StreamIntervals WebSocket需要订阅的K线周期（原有数据使用的周期 + 配置中额外的周期）
+func (p *DataProfile) StreamIntervals() []string {
+	intervals := append([]string(nil), subKlineTime...)
+	if p.IsDefault() {
+		return intervals
+	}
+	for _, spec := range p.Timeframes {
+		exists := false
+		for _, interval := range intervals {
+			if interval == spec.Interval {
+				exists = true
+				break
+			}
+		}
+		if !exists {
+			intervals = append(intervals, spec.Interval)
+		}
+	}
+	return intervals
+}
+
+// GetWithProfile 获取市场数据，并按行情数据配置计算选择的时间框架和指标（profile 为 nil 时与 GetWithProvider 相同）
+func GetWithProfile(provider MarketDataProvider, symbol string, profile *DataProfile) (*Data, error) {
+	data, err := GetWithProvider(provider, symbol)
+	if err != nil || profile.IsDefault() {
+		return data, err
+	}
+
+	for _, spec := range profile.Timeframes {
+		klines, err := provider.GetKlines(data.Symbol, spec.Interval, klineLimit)
+		if err != nil {
+			return nil, fmt.Errorf("获取%s K线失败: %v", spec.Interval, err)
+		}
+		data.Timeframes = append(data.Timeframes, calculateTimeframe(spec, klines, data.CurrentPrice))
+	}
+	return data, nil
+}
+
+// calculateTimeframe 计算单个时间框架的收盘价序列和指标
+func calculateTimeframe(spec TimeframeSpec, klines []Kline, currentPrice float64) *TimeframeData {
+	data := &TimeframeData{Interval: spec.Interval}
+
+	start := len(klines) - spec.Series
+	if start < 0 {
+		start = 0
+	}
+	if spec.Series > 0 {
+		for i := start; i < len(klines); i++ {
+			data.Closes = append(data.Closes, klines[i].Close)
+		}
+	}
+
+	// series 计算指标在最近N个数据点的值（数据不足的点跳过）
+	series := func(minLen int, calc func([]Kline) float64) []float64 {
+		if spec.Series == 0 {
+			return nil
+		}
+		values := make([]float64, 0, spec.Series)
+		for i := start; i < len(klines); i++ {
+			if i+1 >= minLen {
+				values = append(values, calc(klines[:i+1]))
+			}
+		}
+		return values
+	}
+
+	for _, indicator := range spec.Indicators {
+		switch indicator.Type {
+		case IndicatorEMA:
+			for _, param := range indicator.Params {
+				period := int(param)
+				calc := func(k []Kline) float64 { return calculateEMA(k, period) }
+				data.Indicators = append(data.Indicators, IndicatorValue{
+					Name:   fmt.Sprintf("EMA(%d)", period),
+					Value:  calc(klines),
+					Series: series(period, calc),
+				})
+			}
+		case IndicatorRSI:
+			for _, param := range indicator.Params {
+				period := int(param)
+				calc := func(k []Kline) float64 { return calculateRSI(k, period) }
+				data.Indicators = append(data.Indicators, IndicatorValue{
+					Name:   fmt.Sprintf("RSI(%d)", period),
+					Value:  calc(klines),
+					Series: series(period+1, calc),
+				})
+			}
+		case IndicatorATR:
+			for _, param := range indicator.Params {
+				period := int(param)
+				calc := func(k []Kline) float64 { return calculateATR(k, period) }
+				data.Indicators = append(data.Indicators, IndicatorValue{
+					Name:   fmt.Sprintf("ATR(%d)", period),
+					Value:  calc(klines),
+					Series: series(period+1, calc),
+				})
+			}
+		case IndicatorMACD:
+			fast, slow := int(indicator.Params[0]), int(indicator.Params[1])
+			calc := func(k []Kline) float64 {
+				if len(k) < slow {
+					return 0
+				}
+				return calculateEMA(k, fast) - calculateEMA(k, slow)
+			}
+			value := IndicatorValue{
+				Name:   fmt.Sprintf("MACD(%d,%d)", fast, slow),
+				Value:  calc(klines),
+				Series: series(slow, calc),
+			}
+			if len(indicator.Params) == 3 {
+				signal := int(indicator.Params[2])
+				value.Name = fmt.Sprintf("MACD(%d,%d,%d)", fast, slow, signal)
+				if macd := calculateMACDLines(klines, fast, slow, signal); macd != nil {
+					value.Components = []IndicatorComponent{
+						{Name: "macd", Value: macd.MACD},
+						{Name: "signal", Value: macd.Signal},
+						{Name: "histogram", Value: macd.Histogram},
+					}
+				}
+			}
+			data.Indicators = append(data.Indicators, value)
+		case IndicatorSupertrend:
+			period, multiplier := int(indicator.Params[0]), indicator.Params[1]
+			st := calculateSupertrend(klines, period, multiplier, currentPrice)
+			data.Indicators = append(data.Indicators, IndicatorValue{
+				Name:   fmt.Sprintf("Supertrend(%d,%g)", period, multiplier),
+				Value:  st.Value,
+				Trend:  st.Trend,
+				Signal: st.Signal,
+			})
+		case IndicatorVolume:
+			period := int(indicator.Params[0])
+			calc := func(k []Kline) float64 { return calculateVolumeRatio(k, period) }
+			data.Indicators = append(data.Indicators, IndicatorValue{
+				Name:   fmt.Sprintf("VolumeRatio(%d)", period),
+				Value:  calc(klines),
+				Series: series(period+1, calc),
+			})
+		case IndicatorBollinger:
+			period, multiplier := int(indicator.Params[0]), indicator.Params[1]
+			value := IndicatorValue{Name: fmt.Sprintf("Bollinger(%d,%g)", period, multiplier), State: insufficientData}
+			if bb := calculateBollinger(klines, period, multiplier); bb != nil {
+				calc := func(k []Kline) float64 { return calculateBollinger(k, period, multiplier).PercentB }
+				value.Value, value.State = bb.PercentB, ""
+				value.Series = series(period, calc)
+				value.Components = []IndicatorComponent{
+					{Name: "upper", Value: bb.Upper},
+					{Name: "middle", Value: bb.Middle},
+					{Name: "lower", Value: bb.Lower},
+					{Name: "width_pct", Value: bb.WidthPct},
+					{Name: "percent_b", Value: bb.PercentB},
+				}
+			}
+			data.Indicators = append(data.Indicators, value)
+		case IndicatorVWAP:
+			if len(klines) == 0 {
+				continue
+			}
+			value := IndicatorValue{Name: "VWAP(session)"}
+			anchor := sessionStart(klines)
+			if len(indicator.Params) == 1 {
+				hours := indicator.Params[0]
+				value.Name = fmt.Sprintf("VWAP(anchored %gh)", hours)
+				anchor = klines[len(klines)-1].OpenTime - int64(hours*float64(3600_000))
+			}
+			value.Value = calculateVWAP(klines, anchor)
+			data.Indicators = append(data.Indicators, value)
+		case IndicatorStochRSI:
+			p := make([]int, len(indicator.Params))
+			for i, param := range indicator.Params {
+				p[i] = int(param)
+			}
+			value := IndicatorValue{Name: fmt.Sprintf("StochRSI(%d,%d,%d,%d)", p[0], p[1], p[2], p[3]), State: insufficientData}
+			if st := calculateStochRSI(klines, p[0], p[1], p[2], p[3]); st != nil {
+				calc := func(k []Kline) float64 { return calculateStochRSI(k, p[0], p[1], p[2], p[3]).K }
+				value.Value, value.State = st.K, ""
+				value.Series = series(p[0]+p[1]+p[2]+p[3]-2, calc)
+				value.Components = []IndicatorComponent{{Name: "k", Value: st.K}, {Name: "d", Value: st.D}}
+			}
+			data.Indicators = append(data.Indicators, value)
+		case IndicatorADX:
+			period := int(indicator.Params[0])
+			value := IndicatorValue{Name: fmt.Sprintf("ADX(%d)", period), State: insufficientData}
+			if adx := calculateADX(klines, period); adx != nil {
+				calc := func(k []Kline) float64 { return calculateADX(k, period).ADX }
+				value.Value, value.State = adx.ADX, ""
+				value.Series = series(2*period+1, calc)
+				value.Components = []IndicatorComponent{
+					{Name: "adx", Value: adx.ADX},
+					{Name: "+di", Value: adx.PlusDI},
+					{Name: "-di", Value: adx.MinusDI},
+				}
+			}
+			data.Indicators = append(data.Indicators, value)
+		case IndicatorOBV:
+			lookback := int(indicator.Params[0])
+			obv, change := calculateOBV(klines, lookback)
+			calc := func(k []Kline) float64 {
+				obv, _ := calculateOBV(k, lookback)
+				return obv
+			}
+			data.Indicators = append(data.Indicators, IndicatorValue{
+				Name:   fmt.Sprintf("OBV(%d)", lookback),
+				Value:  obv,
+				Series: series(2, calc),
+				Components: []IndicatorComponent{
+					{Name: "obv", Value: obv},
+					{Name: "change", Value: change},
+				},
+			})
+		case IndicatorIchimoku:
+			tenkan, kijun, senkou := int(indicator.Params[0]), int(indicator.Params[1]), int(indicator.Params[2])
+			value := IndicatorValue{Name: fmt.Sprintf("Ichimoku(%d,%d,%d)", tenkan, kijun, senkou), State: insufficientData}
+			if ichimoku := calculateIchimoku(klines, tenkan, kijun, senkou); ichimoku != nil {
+				value.Value = ichimoku.Tenkan
+				value.State = ichimoku.CloudPosition + "_cloud"
+				value.Components = []IndicatorComponent{
+					{Name: "tenkan", Value: ichimoku.Tenkan},
+					{Name: "kijun", Value: ichimoku.Kijun},
+					{Name: "senkou_a", Value: ichimoku.SenkouA},
+					{Name: "senkou_b", Value: ichimoku.SenkouB},
+				}
+			}
+			data.Indicators = append(data.Indicators, value)
+		case IndicatorDonchian:
+			period := int(indicator.Params[0])
+			value := IndicatorValue{Name: fmt.Sprintf("Donchian(%d)", period), State: insufficientData}
+			if dc := calculateDonchian(klines, period); dc != nil {
+				value.Value, value.State = dc.Middle, ""
+				value.Components = []IndicatorComponent{
+					{Name: "upper", Value: dc.Upper},
+					{Name: "middle", Value: dc.Middle},
+					{Name: "lower", Value: dc.Lower},
+				}
+			}
+			data.Indicators = append(data.Indicators, value)
+		}
+	}
+	return data
+}
+
+// calculateVolumeRatio 计算最新K线成交量与之前 period 根K线平均成交量的比率
+func calculateVolumeRatio(klines []Kline, period int) float64 {
+	if len(klines) <= period {
+		return 0
+	}
+	sum := 0.0
+	for i := len(klines) - 1 - period; i < len(klines)-1; i++ {
+		sum += klines[i].Volume
+	}
+	avgVol := sum / float64(period)
+	if avgVol == 0 {
+		return 0
+	}
+	return klines[len(klines)-1].Volume / avgVol
+}
+
+// formatWithProfile 按行情数据配置格式化输出（只输出选择的时间框架和指标）
+func formatWithProfile(data *Data) string {
+	var sb strings.Builder
+
+	sb.WriteString(fmt.Sprintf("current_price = %.2f, price_change_1h = %.2f%%, price_change_4h = %.2f%%\n\n",
+		data.CurrentPrice, data.PriceChange1h, data.PriceChange4h))
+
+	sb.WriteString(fmt.Sprintf("In addition, here is the latest %s open interest and funding rate for perps:\n\n",
+		data.Symbol))
+
+	if data.OpenInterest != nil {
+		sb.WriteString(fmt.Sprintf("Open Interest: Latest: %.2f Average: %.2f\n\n",
+			data.OpenInterest.Latest, data.OpenInterest.Average))
+	}
+
+	sb.WriteString(fmt.Sprintf("Funding Rate: %.2e\n\n", data.FundingRate))
+
+	sb.WriteString(formatFunding(data.Funding))
+	sb.WriteString(formatPositioning(data.Positioning))
+
+	sb.WriteString(formatOrderBook(data.OrderBook))
+	sb.WriteString(formatOrderFlow(data.OrderFlow))
+	sb.WriteString(formatLiquidations(data.Liquidations))
+
+	for _, tf := range data.Timeframes {
+		if len(tf.Closes) > 0 {
+			sb.WriteString(fmt.Sprintf("%s timeframe (oldest → latest):\n\n", tf.Interval))
+			sb.WriteString(fmt.Sprintf("Close prices: %s\n\n", formatFloatSlice(tf.Closes)))
+		} else {
+			sb.WriteString(fmt.Sprintf("%s timeframe (latest values):\n\n", tf.Interval))
+		}
+
+		for _, indicator := range tf.Indicators {
+			switch {
+			case indicator.Trend != "":
+				sb.WriteString(fmt.Sprintf("%s: Trend=%s, Signal=%s, Value=%.4f\n\n",
+					indicator.Name, indicator.Trend, indicator.Signal, indicator.Value))
+			case len(indicator.Components) == 0 && indicator.State != "":
+				sb.WriteString(fmt.Sprintf("%s: state=%s\n\n", indicator.Name, indicator.State))
+			case len(indicator.Components) > 0:
+				parts := make([]string, 0, len(indicator.Components)+1)
+				for _, component := range indicator.Components {
+					parts = append(parts, fmt.Sprintf("%s=%.4f", component.Name, component.Value))
+				}
+				if indicator.State != "" {
+					parts = append(parts, "state="+indicator.State)
+				}
+				sb.WriteString(fmt.Sprintf("%s: %s\n\n", indicator.Name, strings.Join(parts, ", ")))
+				if len(indicator.Series) > 0 {
+					sb.WriteString(fmt.Sprintf("%s series: %s\n\n", indicator.Name, formatFloatSlice(indicator.Series)))
+				}
+			case len(indicator.Series) > 0:
+				sb.WriteString(fmt.Sprintf("%s: %s\n\n", indicator.Name, formatFloatSlice(indicator.Series)))
+			default:
+				sb.WriteString(fmt.Sprintf("%s = %.3f\n\n", indicator.Name, indicator.Value))
+			}
+		}
+	}
+
+	return sb.String()
+}
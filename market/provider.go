@@ -0,0 +1,311 @@
+package market
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+)
+
+// 行情数据源名称（与交易员的交易平台一致）
+const (
+	ProviderBinance     = "binance"
+	ProviderHyperliquid = "hyperliquid"
+	ProviderAster       = "aster"
+)
+
+// asterBaseURL Aster合约接口地址（与币安合约接口兼容）
+const asterBaseURL = "https://fapi.asterdex.com"
+
+// klineLimit 每个时间框架获取的K线数量
+const klineLimit = 100
+
+// symbolsCacheTTL 上架币种列表缓存有效期
+const symbolsCacheTTL = 30 * time.Minute
+
+// klineIntervals K线周期对应的时长
+var klineIntervals = map[string]time.Duration{
+	"1m":  time.Minute,
+	"3m":  3 * time.Minute,
+	"5m":  5 * time.Minute,
+	"15m": 15 * time.Minute,
+	"30m": 30 * time.Minute,
+	"1h":  time.Hour,
+	"2h":  2 * time.Hour,
+	"4h":  4 * time.Hour,
+	"8h":  8 * time.Hour,
+	"12h": 12 * time.Hour,
+	"1d":  24 * time.Hour,
+}
+
+// MarketDataProvider 行情数据源（不同交易所的价格、资金费率和上架币种不同）
+type MarketDataProvider interface {
+	// Name 数据源名称（binance/hyperliquid/aster）
+	Name() string
+	// GetKlines 获取最近 limit 根K线（symbol 为 USDT 交易对格式，如 BTCUSDT）
+	GetKlines(symbol, interval string, limit int) ([]Kline, error)
+	// GetOpenInterest 获取持仓量（以币计）
+	GetOpenInterest(symbol string) (*OIData, error)
+	// GetFundingRate 获取资金费率（统一换算为8小时费率，与币安一致）
+	GetFundingRate(symbol string) (float64, error)
+	// HasSymbol 交易所是否上架了该币种的永续合约
+	HasSymbol(symbol string) (bool, error)
+	// GetOrderBook 获取前20档订单簿
+	GetOrderBook(symbol string) (*OrderBook, error)
+	// GetOrderFlow 获取逐笔成交统计的订单流（主动买卖量、CVD、大单笔数）
+	GetOrderFlow(symbol string) (*OrderFlowData, error)
+	// GetLiquidations 获取最近的强平统计（按方向和窗口汇总的强平名义价值及强平聚集区）
+	GetLiquidations(symbol string) (*LiquidationData, error)
+	// GetFunding 获取资金费率数据（预测费率、下次结算时间和结算历史，带缓存）
+	GetFunding(symbol string) (*FundingData, error)
+	// GetPositioning 获取大户多空比和主动买卖比
+	GetPositioning(symbol string) (*PositioningData, error)
+}
+
+var (
+	providers   = make(map[string]MarketDataProvider)
+	providersMu sync.Mutex
+)
+
+// ProviderFor 获取交易平台对应的行情数据源（同一交易所共享实例，未知平台使用币安）
+func ProviderFor(exchange string, testnet bool) MarketDataProvider {
+	key := exchange
+	switch exchange {
+	case ProviderHyperliquid:
+		if testnet {
+			key += "-testnet"
+		}
+	case ProviderAster:
+	default:
+		exchange, key = ProviderBinance, ProviderBinance
+	}
+
+	providersMu.Lock()
+	defer providersMu.Unlock()
+
+	if provider, ok := providers[key]; ok {
+		return provider
+	}
+
+	var provider MarketDataProvider
+	switch exchange {
+	case ProviderHyperliquid:
+		provider = newHyperliquidProvider(testnet)
+	case ProviderAster:
+		provider = newFapiProvider(ProviderAster, asterBaseURL)
+	default:
+		provider = &binanceProvider{fapiProvider: newFapiProvider(ProviderBinance, baseURL)}
+	}
+	providers[key] = provider
+	return provider
+}
+
+// fapiProvider 币安兼容合约接口的行情数据源（Aster直接使用，币安在此基础上优先读取WebSocket缓存）
+type fapiProvider struct {
+	name   string
+	client *APIClient
+
+	symbols          map[string]bool
+	symbolsFetchedAt time.Time
+	symbolsMu        sync.Mutex
+
+	listingTimes     map[string]time.Time
+	listingFetchedAt time.Time
+	listingMu        sync.Mutex
+
+	funding fundingCache
+}
+
+func newFapiProvider(name, baseURL string) *fapiProvider {
+	return &fapiProvider{name: name, client: newAPIClientWithBaseURL(baseURL)}
+}
+
+func (p *fapiProvider) Name() string {
+	return p.name
+}
+
+func (p *fapiProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	klines, err := p.client.GetKlines(symbol, interval, limit)
+	if err != nil {
+		return nil, fmt.Errorf("获取%s K线失败: %w", p.name, err)
+	}
+	if len(klines) == 0 {
+		return nil, fmt.Errorf("%s 没有 %s 的%s K线数据", p.name, symbol, interval)
+	}
+	return klines, nil
+}
+
+func (p *fapiProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	oi, err := p.client.GetOpenInterest(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("获取%s持仓量失败: %w", p.name, err)
+	}
+	return oi, nil
+}
+
+func (p *fapiProvider) GetFundingRate(symbol string) (float64, error) {
+	funding, err := p.GetFunding(symbol)
+	if err != nil {
+		return 0, err
+	}
+	return funding.PredictedRate, nil
+}
+
+// GetFunding 预测费率缓存 fundingCacheTTL，结算历史在下次结算前复用缓存
+func (p *fapiProvider) GetFunding(symbol string) (*FundingData, error) {
+	cached := p.funding.get(symbol)
+	if cached != nil && time.Since(cached.UpdatedAt) < fundingCacheTTL {
+		return cached, nil
+	}
+
+	premium, err := p.client.GetPremiumIndex(symbol)
+	if err != nil {
+		return nil, fmt.Errorf("获取%s资金费率失败: %w", p.name, err)
+	}
+
+	history, intervalHours := []FundingSettlement(nil), float64(defaultFundingIntervalHours)
+	if cached != nil && len(cached.History) > 0 && time.Now().Before(cached.NextFundingTime) {
+		// 上次获取后还没有新的结算
+		history, intervalHours = cached.History, cached.IntervalHours
+	} else if raw, err := p.client.GetFundingHistory(symbol, fundingHistoryLimit); err != nil {
+		log.Printf("⚠️  获取%s %s资金费结算历史失败: %v", p.name, symbol, err)
+	} else {
+		intervalHours = fundingInterval(raw)
+		history = normalizeFundingHistory(raw, intervalHours)
+	}
+
+	funding := newFundingData(premium.FundingRate, time.UnixMilli(premium.NextFundingTime), intervalHours, history)
+	funding.MarkPrice = premium.MarkPrice
+	funding.IndexPrice = premium.IndexPrice
+	if premium.IndexPrice > 0 {
+		funding.PremiumPct = (premium.MarkPrice - premium.IndexPrice) / premium.IndexPrice * 100
+	}
+	p.funding.put(symbol, funding)
+	return funding, nil
+}
+
+func (p *fapiProvider) GetPositioning(symbol string) (*PositioningData, error) {
+	return nil, fmt.Errorf("%s 行情不支持 %s 的多空比数据", p.name, symbol)
+}
+
+func (p *fapiProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	book, err := p.client.GetDepth(symbol, depthLevels)
+	if err != nil {
+		return nil, fmt.Errorf("获取%s订单簿失败: %w", p.name, err)
+	}
+	return book, nil
+}
+
+func (p *fapiProvider) GetOrderFlow(symbol string) (*OrderFlowData, error) {
+	trades, err := p.client.GetAggTrades(symbol, aggTradesRESTLimit)
+	if err != nil {
+		return nil, fmt.Errorf("获取%s归集成交失败: %w", p.name, err)
+	}
+	if len(trades) == 0 {
+		return nil, fmt.Errorf("%s 没有 %s 的成交数据", p.name, symbol)
+	}
+	return orderFlowFromTrades(trades, OrderFlowSourceREST), nil
+}
+
+func (p *fapiProvider) GetLiquidations(symbol string) (*LiquidationData, error) {
+	return nil, fmt.Errorf("%s 行情不支持 %s 的强平统计", p.name, symbol)
+}
+
+func (p *fapiProvider) HasSymbol(symbol string) (bool, error) {
+	p.symbolsMu.Lock()
+	defer p.symbolsMu.Unlock()
+
+	if p.symbols == nil || time.Since(p.symbolsFetchedAt) > symbolsCacheTTL {
+		exchangeInfo, err := p.client.GetExchangeInfo()
+		if err != nil {
+			return false, fmt.Errorf("获取%s交易对信息失败: %w", p.name, err)
+		}
+		symbols := make(map[string]bool, len(exchangeInfo.Symbols))
+		for _, info := range exchangeInfo.Symbols {
+			if info.Status == "TRADING" && info.ContractType == "PERPETUAL" {
+				symbols[info.Symbol] = true
+			}
+		}
+		p.symbols = symbols
+		p.symbolsFetchedAt = time.Now()
+	}
+
+	return p.symbols[strings.ToUpper(symbol)], nil
+}
+
+// binanceProvider 币安行情数据源（K线优先读取 WSMonitorCli 的WebSocket缓存）
+type binanceProvider struct {
+	*fapiProvider
+
+	positioning positioningCache
+}
+
+func (p *binanceProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	if WSMonitorCli == nil {
+		return p.fapiProvider.GetKlines(symbol, interval, limit)
+	}
+	// 缓存不存在或K线数量不足 limit 时通过REST按请求的数量获取
+	klines, exists := WSMonitorCli.copyCachedKlines(strings.ToUpper(symbol), interval)
+	if !exists || len(klines) < limit {
+		return p.fapiProvider.GetKlines(symbol, interval, limit)
+	}
+	// 缓存保存的K线数量可能多于请求的数量，只返回最近 limit 根
+	if limit > 0 && len(klines) > limit {
+		klines = klines[len(klines)-limit:]
+	}
+	return klines, nil
+}
+
+// GetOrderBook 优先使用 WSMonitorCli 订阅的本地订单簿，未订阅或过时时通过REST获取
+func (p *binanceProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	if WSMonitorCli != nil {
+		if book, ok := WSMonitorCli.GetOrderBook(symbol); ok {
+			return book, nil
+		}
+	}
+	return p.fapiProvider.GetOrderBook(symbol)
+}
+
+// GetOrderFlow 优先使用 WSMonitorCli 订阅的归集成交流统计，未订阅时通过REST获取最近成交
+func (p *binanceProvider) GetOrderFlow(symbol string) (*OrderFlowData, error) {
+	if WSMonitorCli != nil {
+		if flow, ok := WSMonitorCli.GetOrderFlow(symbol); ok {
+			return flow, nil
+		}
+	}
+	return p.fapiProvider.GetOrderFlow(symbol)
+}
+
+// GetLiquidations 使用 WSMonitorCli 订阅的全市场强平订单流统计（币安没有可用的强平历史REST接口）
+func (p *binanceProvider) GetLiquidations(symbol string) (*LiquidationData, error) {
+	if WSMonitorCli != nil {
+		if data, ok := WSMonitorCli.GetLiquidations(symbol); ok {
+			return data, nil
+		}
+	}
+	return nil, fmt.Errorf("强平订单流未订阅，无法获取 %s 的强平统计", symbol)
+}
+
+// GetPositioning 获取最近5分钟的大户持仓多空比和主动买卖比（缓存 positioningCacheTTL）
+func (p *binanceProvider) GetPositioning(symbol string) (*PositioningData, error) {
+	if cached := p.positioning.get(symbol); cached != nil && time.Since(cached.UpdatedAt) < positioningCacheTTL {
+		return cached, nil
+	}
+
+	positioning := &PositioningData{Period: positioningPeriod, UpdatedAt: time.Now()}
+	var err error
+	positioning.TopTraderLongShortRatio, positioning.TopTraderLongPct, positioning.TopTraderShortPct, err =
+		p.client.GetTopLongShortPositionRatio(symbol, positioningPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("获取大户多空比失败: %w", err)
+	}
+	positioning.TakerBuySellRatio, positioning.TakerBuyVolume, positioning.TakerSellVolume, err =
+		p.client.GetTakerLongShortRatio(symbol, positioningPeriod)
+	if err != nil {
+		return nil, fmt.Errorf("获取主动买卖比失败: %w", err)
+	}
+
+	p.positioning.put(symbol, positioning)
+	return positioning, nil
+}
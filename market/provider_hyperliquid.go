@@ -0,0 +1,359 @@
+package market
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Hyperliquid 行情接口地址
+const (
+	hyperliquidMainnetURL = "https://api.hyperliquid.xyz"
+	hyperliquidTestnetURL = "https://api.hyperliquid-testnet.xyz"
+)
+
+// hyperliquidCtxCacheTTL 资产上下文（资金费率、持仓量）缓存有效期，一次请求返回所有币种
+const hyperliquidCtxCacheTTL = 30 * time.Second
+
+// hyperliquidCandle candleSnapshot 响应中的K线
+type hyperliquidCandle struct {
+	OpenTime  int64  `json:"t"`
+	CloseTime int64  `json:"T"`
+	Open      string `json:"o"`
+	High      string `json:"h"`
+	Low       string `json:"l"`
+	Close     string `json:"c"`
+	Volume    string `json:"v"`
+	Trades    int    `json:"n"`
+}
+
+// hyperliquidAssetCtx 单个币种的资产上下文
+type hyperliquidAssetCtx struct {
+	Funding      float64 // 1小时资金费率
+	OpenInterest float64 // 持仓量（以币计）
+	MarkPrice    float64
+	OraclePrice  float64
+}
+
+// hyperliquidFundingIntervalHours Hyperliquid 每小时结算资金费
+const hyperliquidFundingIntervalHours = 1
+
+// hyperliquidProvider Hyperliquid 行情数据源（通过 info 接口轮询K线和资产上下文）
+type hyperliquidProvider struct {
+	url    string
+	client *http.Client
+
+	ctxs          map[string]*hyperliquidAssetCtx // 币种 -> 资产上下文（币种为 Hyperliquid 格式，如 BTC）
+	ctxsFetchedAt time.Time
+	ctxsMu        sync.Mutex
+
+	funding fundingCache
+}
+
+func newHyperliquidProvider(testnet bool) *hyperliquidProvider {
+	url := hyperliquidMainnetURL
+	if testnet {
+		url = hyperliquidTestnetURL
+	}
+	return &hyperliquidProvider{
+		url:    url,
+		client: &http.Client{Timeout: 30 * time.Second},
+	}
+}
+
+func (p *hyperliquidProvider) Name() string {
+	return ProviderHyperliquid
+}
+
+func (p *hyperliquidProvider) GetKlines(symbol, interval string, limit int) ([]Kline, error) {
+	duration, ok := klineIntervals[interval]
+	if !ok {
+		return nil, fmt.Errorf("不支持的K线周期: %s", interval)
+	}
+
+	endTime := time.Now()
+	startTime := endTime.Add(-time.Duration(limit) * duration)
+	body, err := p.post(map[string]any{
+		"type": "candleSnapshot",
+		"req": map[string]any{
+			"coin":      hyperliquidCoin(symbol),
+			"interval":  interval,
+			"startTime": startTime.UnixMilli(),
+			"endTime":   endTime.UnixMilli(),
+		},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("获取Hyperliquid K线失败: %w", err)
+	}
+
+	var candles []hyperliquidCandle
+	if err := json.Unmarshal(body, &candles); err != nil {
+		return nil, fmt.Errorf("解析Hyperliquid K线失败: %w", err)
+	}
+	if len(candles) == 0 {
+		return nil, fmt.Errorf("Hyperliquid 没有 %s 的%s K线数据", symbol, interval)
+	}
+
+	klines := make([]Kline, 0, len(candles))
+	for _, candle := range candles {
+		kline := Kline{
+			OpenTime:  candle.OpenTime,
+			CloseTime: candle.CloseTime,
+			Trades:    candle.Trades,
+		}
+		kline.Open, _ = strconv.ParseFloat(candle.Open, 64)
+		kline.High, _ = strconv.ParseFloat(candle.High, 64)
+		kline.Low, _ = strconv.ParseFloat(candle.Low, 64)
+		kline.Close, _ = strconv.ParseFloat(candle.Close, 64)
+		kline.Volume, _ = strconv.ParseFloat(candle.Volume, 64)
+		kline.QuoteVolume = kline.Volume * kline.Close // 近似值（接口不返回成交额）
+		klines = append(klines, kline)
+	}
+	return klines, nil
+}
+
+func (p *hyperliquidProvider) GetOpenInterest(symbol string) (*OIData, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+	return &OIData{
+		Latest:  ctx.OpenInterest,
+		Average: ctx.OpenInterest * 0.999, // 近似平均值
+	}, nil
+}
+
+func (p *hyperliquidProvider) GetFundingRate(symbol string) (float64, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return 0, err
+	}
+	// Hyperliquid 每小时结算资金费，换算为8小时费率以便与币安数据口径一致
+	return ctx.Funding * 8, nil
+}
+
+func (p *hyperliquidProvider) GetOrderBook(symbol string) (*OrderBook, error) {
+	body, err := p.post(map[string]any{"type": "l2Book", "coin": hyperliquidCoin(symbol)})
+	if err != nil {
+		return nil, fmt.Errorf("获取Hyperliquid订单簿失败: %w", err)
+	}
+
+	// 响应格式: levels[0] 为买盘（价格从高到低），levels[1] 为卖盘（价格从低到高）
+	var book struct {
+		Time   int64 `json:"time"`
+		Levels [][]struct {
+			Px string `json:"px"`
+			Sz string `json:"sz"`
+		} `json:"levels"`
+	}
+	if err := json.Unmarshal(body, &book); err != nil {
+		return nil, fmt.Errorf("解析Hyperliquid订单簿失败: %w", err)
+	}
+	if len(book.Levels) < 2 {
+		return nil, fmt.Errorf("Hyperliquid 没有 %s 的订单簿数据", symbol)
+	}
+
+	sides := make([][][]string, 2)
+	for i := range sides {
+		for j, level := range book.Levels[i] {
+			if j >= depthLevels {
+				break
+			}
+			sides[i] = append(sides[i], []string{level.Px, level.Sz})
+		}
+	}
+	return newOrderBook(symbol, sides[0], sides[1], book.Time), nil
+}
+
+// GetOrderFlow Hyperliquid 的 info 接口不提供可统计窗口的成交历史
+func (p *hyperliquidProvider) GetOrderFlow(symbol string) (*OrderFlowData, error) {
+	return nil, fmt.Errorf("Hyperliquid 行情不支持 %s 的订单流统计", symbol)
+}
+
+// GetFunding 当前费率来自资产上下文（缓存30秒），结算历史在下个整点前复用缓存
+func (p *hyperliquidProvider) GetFunding(symbol string) (*FundingData, error) {
+	ctx, err := p.assetCtx(symbol)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	cached := p.funding.get(symbol)
+	if cached != nil && now.Sub(cached.UpdatedAt) < hyperliquidCtxCacheTTL {
+		return cached, nil
+	}
+
+	var history []FundingSettlement
+	if cached != nil && len(cached.History) > 0 && now.Before(cached.NextFundingTime) {
+		history = cached.History
+	} else {
+		history, err = p.fundingHistory(symbol, now.Add(-fundingHistoryLimit*time.Hour))
+		if err != nil {
+			log.Printf("⚠️  获取Hyperliquid %s资金费结算历史失败: %v", symbol, err)
+		}
+	}
+
+	funding := newFundingData(ctx.Funding, now.Truncate(time.Hour).Add(time.Hour), hyperliquidFundingIntervalHours, history)
+	funding.MarkPrice = ctx.MarkPrice
+	funding.IndexPrice = ctx.OraclePrice
+	if ctx.OraclePrice > 0 {
+		funding.PremiumPct = (ctx.MarkPrice - ctx.OraclePrice) / ctx.OraclePrice * 100
+	}
+	p.funding.put(symbol, funding)
+	return funding, nil
+}
+
+// fundingHistory 获取 startTime 以来的资金费结算（从旧到新，换算为8小时口径）
+func (p *hyperliquidProvider) fundingHistory(symbol string, startTime time.Time) ([]FundingSettlement, error) {
+	body, err := p.post(map[string]any{
+		"type":      "fundingHistory",
+		"coin":      hyperliquidCoin(symbol),
+		"startTime": startTime.UnixMilli(),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var result []struct {
+		FundingRate string `json:"fundingRate"`
+		Time        int64  `json:"time"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析Hyperliquid资金费历史失败: %w", err)
+	}
+
+	history := make([]FundingSettlement, 0, len(result))
+	for _, item := range result {
+		settlement := FundingSettlement{Time: time.UnixMilli(item.Time)}
+		settlement.Rate, _ = strconv.ParseFloat(item.FundingRate, 64)
+		history = append(history, settlement)
+	}
+	return normalizeFundingHistory(history, hyperliquidFundingIntervalHours), nil
+}
+
+// GetPositioning Hyperliquid 不提供多空比数据
+func (p *hyperliquidProvider) GetPositioning(symbol string) (*PositioningData, error) {
+	return nil, fmt.Errorf("Hyperliquid 行情不支持 %s 的多空比数据", symbol)
+}
+
+// GetLiquidations Hyperliquid 的 info 接口不提供强平订单
+func (p *hyperliquidProvider) GetLiquidations(symbol string) (*LiquidationData, error) {
+	return nil, fmt.Errorf("Hyperliquid 行情不支持 %s 的强平统计", symbol)
+}
+
+func (p *hyperliquidProvider) HasSymbol(symbol string) (bool, error) {
+	ctxs, err := p.assetCtxs()
+	if err != nil {
+		return false, err
+	}
+	_, ok := ctxs[hyperliquidCoin(symbol)]
+	return ok, nil
+}
+
+// assetCtx 获取单个币种的资产上下文
+func (p *hyperliquidProvider) assetCtx(symbol string) (*hyperliquidAssetCtx, error) {
+	ctxs, err := p.assetCtxs()
+	if err != nil {
+		return nil, err
+	}
+	ctx, ok := ctxs[hyperliquidCoin(symbol)]
+	if !ok {
+		return nil, fmt.Errorf("Hyperliquid 未上架 %s", symbol)
+	}
+	return ctx, nil
+}
+
+// assetCtxs 获取所有币种的资产上下文（metaAndAssetCtxs，缓存30秒）
+func (p *hyperliquidProvider) assetCtxs() (map[string]*hyperliquidAssetCtx, error) {
+	p.ctxsMu.Lock()
+	defer p.ctxsMu.Unlock()
+
+	if p.ctxs != nil && time.Since(p.ctxsFetchedAt) < hyperliquidCtxCacheTTL {
+		return p.ctxs, nil
+	}
+
+	body, err := p.post(map[string]any{"type": "metaAndAssetCtxs"})
+	if err != nil {
+		return nil, fmt.Errorf("获取Hyperliquid资产信息失败: %w", err)
+	}
+
+	// 响应格式: [meta, assetCtxs]，assetCtxs 与 meta.universe 按下标对应
+	var result []json.RawMessage
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("解析Hyperliquid资产信息失败: %w", err)
+	}
+	if len(result) < 2 {
+		return nil, fmt.Errorf("Hyperliquid资产信息格式错误: 期望2个元素，实际%d个", len(result))
+	}
+
+	var meta struct {
+		Universe []struct {
+			Name       string `json:"name"`
+			IsDelisted bool   `json:"isDelisted"`
+		} `json:"universe"`
+	}
+	if err := json.Unmarshal(result[0], &meta); err != nil {
+		return nil, fmt.Errorf("解析Hyperliquid meta失败: %w", err)
+	}
+	var rawCtxs []struct {
+		Funding      string `json:"funding"`
+		OpenInterest string `json:"openInterest"`
+		MarkPx       string `json:"markPx"`
+		OraclePx     string `json:"oraclePx"`
+	}
+	if err := json.Unmarshal(result[1], &rawCtxs); err != nil {
+		return nil, fmt.Errorf("解析Hyperliquid资产上下文失败: %w", err)
+	}
+
+	ctxs := make(map[string]*hyperliquidAssetCtx, len(meta.Universe))
+	for i, asset := range meta.Universe {
+		if asset.IsDelisted || i >= len(rawCtxs) {
+			continue
+		}
+		ctx := &hyperliquidAssetCtx{}
+		ctx.Funding, _ = strconv.ParseFloat(rawCtxs[i].Funding, 64)
+		ctx.OpenInterest, _ = strconv.ParseFloat(rawCtxs[i].OpenInterest, 64)
+		ctx.MarkPrice, _ = strconv.ParseFloat(rawCtxs[i].MarkPx, 64)
+		ctx.OraclePrice, _ = strconv.ParseFloat(rawCtxs[i].OraclePx, 64)
+		ctxs[asset.Name] = ctx
+	}
+
+	p.ctxs = ctxs
+	p.ctxsFetchedAt = time.Now()
+	return ctxs, nil
+}
+
+// post 请求 Hyperliquid info 接口
+func (p *hyperliquidProvider) post(payload map[string]any) ([]byte, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Post(p.url+"/info", "application/json", bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+	return body, nil
+}
+
+// hyperliquidCoin 转换为 Hyperliquid 币种格式（BTCUSDT -> BTC）
+func hyperliquidCoin(symbol string) string {
+	return strings.TrimSuffix(strings.ToUpper(symbol), "USDT")
+}
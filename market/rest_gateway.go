@@ -0,0 +1,335 @@
+package market
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// restWeightLimit 币安合约接口每分钟的IP请求权重上限
+const restWeightLimit = 2400
+
+// restWeightReserve 为交易接口等其他请求预留的权重比例（行情请求超过上限的90%时等待下一分钟）
+const restWeightReserve = 0.1
+
+// restDefaultRetryAfter 429/418 响应没有 Retry-After 时的退避时间
+const restDefaultRetryAfter = time.Minute
+
+// restMaxStaleAge 请求失败或退避时仍可返回的过期缓存的最长时间（随 StaleDataError 返回）
+const restMaxStaleAge = 5 * time.Minute
+
+// restCacheCleanupSize 缓存条目超过该数量时清理过期太久的条目
+const restCacheCleanupSize = 5000
+
+// restEndpoint 行情接口的缓存有效期和请求权重
+type restEndpoint struct {
+	TTL    time.Duration
+	Weight func(params map[string]string) int
+}
+
+// fixedWeight 固定权重
+func fixedWeight(weight int) func(map[string]string) int {
+	return func(map[string]string) int { return weight }
+}
+
+// symbolWeight 按是否指定交易对计算权重（不指定 symbol 时返回全部交易对，权重更高）
+func symbolWeight(withSymbol, withoutSymbol int) func(map[string]string) int {
+	return func(params map[string]string) int {
+		if params["symbol"] == "" {
+			return withoutSymbol
+		}
+		return withSymbol
+	}
+}
+
+// StaleDataError 请求失败或退避时返回了超过缓存有效期的数据
+// get 同时返回过期的响应体和该错误，调用方可用 errors.As 判断后决定是否使用过期数据
+type StaleDataError struct {
+	Path string
+	Age  time.Duration // 数据距上次成功获取的时间
+	Err  error         // 本次请求失败的原因
+}
+
+func (e *StaleDataError) Error() string {
+	return fmt.Sprintf("%s 数据已过期 %s（请求失败: %v）", e.Path, e.Age.Round(time.Second), e.Err)
+}
+
+func (e *StaleDataError) Unwrap() error {
+	return e.Err
+}
+
+// IsStaleData 错误是否为 StaleDataError（数据可用但已过期）
+func IsStaleData(err error) bool {
+	var stale *StaleDataError
+	return errors.As(err, &stale)
+}
+
+// restEndpoints 各接口的缓存有效期和权重（未列出的接口不缓存，权重按1计）
+// 权重参考币安U本位合约接口文档；/futures/data 接口有单独的频率限制，不计入分钟权重
+var restEndpoints = map[string]restEndpoint{
+	"/fapi/v1/klines": {TTL: 10 * time.Second, Weight: func(params map[string]string) int {
+		limit, _ := strconv.Atoi(params["limit"])
+		switch {
+		case limit < 100:
+			return 1
+		case limit < 500:
+			return 2
+		case limit <= 1000:
+			return 5
+		default:
+			return 10
+		}
+	}},
+	"/fapi/v1/depth": {TTL: time.Second, Weight: func(params map[string]string) int {
+		limit, _ := strconv.Atoi(params["limit"])
+		switch {
+		case limit <= 50:
+			return 2
+		case limit <= 100:
+			return 5
+		case limit <= 500:
+			return 10
+		default:
+			return 20
+		}
+	}},
+	"/fapi/v1/aggTrades":                      {TTL: 3 * time.Second, Weight: fixedWeight(20)},
+	"/fapi/v1/exchangeInfo":                   {TTL: 10 * time.Minute, Weight: fixedWeight(1)},
+	"/fapi/v1/ticker/price":                   {TTL: 2 * time.Second, Weight: symbolWeight(1, 2)},
+	"/fapi/v1/ticker/24hr":                    {TTL: time.Minute, Weight: symbolWeight(1, 40)},
+	"/fapi/v1/ticker/bookTicker":              {TTL: 2 * time.Second, Weight: symbolWeight(2, 5)},
+	"/fapi/v1/openInterest":                   {TTL: 30 * time.Second, Weight: fixedWeight(1)},
+	"/fapi/v1/premiumIndex":                   {TTL: 30 * time.Second, Weight: fixedWeight(1)},
+	"/fapi/v1/fundingRate":                    {TTL: time.Minute, Weight: fixedWeight(1)},
+	"/futures/data/topLongShortPositionRatio": {TTL: time.Minute, Weight: fixedWeight(0)},
+	"/futures/data/takerlongshortRatio":       {TTL: time.Minute, Weight: fixedWeight(0)},
+}
+
+// RESTStats 行情REST接口的缓存和限流统计
+type RESTStats struct {
+	BaseURL      string    `json:"base_url"`
+	UsedWeight   int       `json:"used_weight"`   // 当前分钟已用权重（以交易所返回的 X-MBX-USED-WEIGHT-1M 为准）
+	WeightLimit  int       `json:"weight_limit"`  // 每分钟权重上限
+	Requests     int64     `json:"requests"`      // 实际发出的请求数
+	CacheHits    int64     `json:"cache_hits"`    // 命中缓存的请求数
+	Coalesced    int64     `json:"coalesced"`     // 合并到进行中请求的请求数
+	StaleServed  int64     `json:"stale_served"`  // 请求失败或限流时返回过期缓存（附带 StaleDataError）的次数
+	Throttled    int64     `json:"throttled"`     // 因权重接近上限而等待的次数
+	BackoffUntil time.Time `json:"backoff_until"` // 429/418 后暂停请求直到该时间
+	BackoffCode  int       `json:"backoff_code"`  // 最近一次触发退避的状态码
+}
+
+// restCacheEntry 缓存的响应
+type restCacheEntry struct {
+	body      []byte
+	fetchedAt time.Time
+}
+
+// restGateway 同一接口地址共享的行情REST请求层（缓存、合并相同请求、权重跟踪和限流退避）
+type restGateway struct {
+	baseURL string
+	client  *http.Client
+	group   singleflight.Group
+
+	mu           sync.Mutex
+	cache        map[string]restCacheEntry
+	usedWeight   int
+	weightMinute int64 // usedWeight 所属的分钟（Unix秒/60）
+	backoffUntil time.Time
+	backoffCode  int
+	stats        RESTStats
+}
+
+var (
+	restGateways   = make(map[string]*restGateway)
+	restGatewaysMu sync.Mutex
+)
+
+// restGatewayFor 获取接口地址对应的共享请求层（同一地址的所有 APIClient 共用权重和缓存）
+func restGatewayFor(baseURL string) *restGateway {
+	restGatewaysMu.Lock()
+	defer restGatewaysMu.Unlock()
+
+	if gateway, ok := restGateways[baseURL]; ok {
+		return gateway
+	}
+	gateway := &restGateway{
+		baseURL: baseURL,
+		client:  &http.Client{Timeout: 30 * time.Second},
+		cache:   make(map[string]restCacheEntry),
+	}
+	restGateways[baseURL] = gateway
+	return gateway
+}
+
+// GetRESTStats 获取所有行情REST请求层的统计
+func GetRESTStats() []RESTStats {
+	restGatewaysMu.Lock()
+	gateways := make([]*restGateway, 0, len(restGateways))
+	for _, gateway := range restGateways {
+		gateways = append(gateways, gateway)
+	}
+	restGatewaysMu.Unlock()
+
+	stats := make([]RESTStats, 0, len(gateways))
+	for _, gateway := range gateways {
+		stats = append(stats, gateway.snapshot())
+	}
+	sort.Slice(stats, func(i, j int) bool { return stats[i].BaseURL < stats[j].BaseURL })
+	return stats
+}
+
+func (g *restGateway) snapshot() RESTStats {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+
+	stats := g.stats
+	stats.BaseURL = g.baseURL
+	stats.WeightLimit = restWeightLimit
+	if g.weightMinute == time.Now().Unix()/60 {
+		stats.UsedWeight = g.usedWeight
+	}
+	stats.BackoffUntil = g.backoffUntil
+	stats.BackoffCode = g.backoffCode
+	return stats
+}
+
+// get 请求GET接口：缓存未过期时直接返回，相同请求合并为一次
+// 失败或限流时有 restMaxStaleAge 内的缓存则返回过期缓存和 StaleDataError，不会把过期数据当作最新数据返回
+func (g *restGateway) get(path string, params map[string]string) ([]byte, error) {
+	query := url.Values{}
+	for key, value := range params {
+		query.Set(key, value)
+	}
+	key := path + "?" + query.Encode()
+
+	endpoint, ok := restEndpoints[path]
+	if !ok {
+		endpoint = restEndpoint{Weight: fixedWeight(1)}
+	}
+
+	g.mu.Lock()
+	entry, cached := g.cache[key]
+	if cached && time.Since(entry.fetchedAt) < endpoint.TTL {
+		g.stats.CacheHits++
+		g.mu.Unlock()
+		return entry.body, nil
+	}
+	g.mu.Unlock()
+
+	leader := false
+	value, err, shared := g.group.Do(key, func() (interface{}, error) {
+		leader = true
+		return g.fetch(path, query, endpoint.Weight(params))
+	})
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if shared && !leader {
+		g.stats.Coalesced++
+	}
+	if err != nil {
+		if age := time.Since(entry.fetchedAt); cached && age < restMaxStaleAge {
+			g.stats.StaleServed++
+			return entry.body, &StaleDataError{Path: path, Age: age, Err: err}
+		}
+		return nil, err
+	}
+
+	body := value.([]byte)
+	if endpoint.TTL > 0 {
+		g.cache[key] = restCacheEntry{body: body, fetchedAt: time.Now()}
+		if len(g.cache) > restCacheCleanupSize {
+			for key, entry := range g.cache {
+				if time.Since(entry.fetchedAt) > restMaxStaleAge {
+					delete(g.cache, key)
+				}
+			}
+		}
+	}
+	return body, nil
+}
+
+// fetch 发出请求，并根据响应头更新已用权重、根据 429/418 设置退避
+func (g *restGateway) fetch(path string, query url.Values, weight int) ([]byte, error) {
+	if err := g.reserveWeight(weight); err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Get(g.baseURL + path + "?" + query.Encode())
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.stats.Requests++
+	if used, err := strconv.Atoi(resp.Header.Get("X-MBX-USED-WEIGHT-1M")); err == nil {
+		g.usedWeight = used
+		g.weightMinute = time.Now().Unix() / 60
+	}
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return body, nil
+	case http.StatusTooManyRequests, http.StatusTeapot:
+		// 429: 超过频率限制；418: 429 后继续请求被封禁IP
+		retryAfter := restDefaultRetryAfter
+		if seconds, err := strconv.Atoi(resp.Header.Get("Retry-After")); err == nil && seconds > 0 {
+			retryAfter = time.Duration(seconds) * time.Second
+		}
+		g.backoffUntil = time.Now().Add(retryAfter)
+		g.backoffCode = resp.StatusCode
+		log.Printf("🚦 %s 返回 %d，暂停行情REST请求 %s", g.baseURL, resp.StatusCode, retryAfter)
+		return nil, fmt.Errorf("status %d: 请求频率超限，%s 后重试", resp.StatusCode, retryAfter)
+	default:
+		return nil, fmt.Errorf("status %d: %s", resp.StatusCode, string(body))
+	}
+}
+
+// reserveWeight 预留请求权重：退避期间直接返回错误，当前分钟权重接近上限时等待到下一分钟
+func (g *restGateway) reserveWeight(weight int) error {
+	limit := int(restWeightLimit * (1 - restWeightReserve))
+	for {
+		g.mu.Lock()
+		now := time.Now()
+		if now.Before(g.backoffUntil) {
+			until := g.backoffUntil
+			g.mu.Unlock()
+			return fmt.Errorf("行情REST请求退避中，%s 后恢复", until.Sub(now).Round(time.Second))
+		}
+
+		minute := now.Unix() / 60
+		if g.weightMinute != minute {
+			g.weightMinute = minute
+			g.usedWeight = 0
+		}
+		if g.usedWeight+weight <= limit {
+			g.usedWeight += weight
+			g.mu.Unlock()
+			return nil
+		}
+
+		g.stats.Throttled++
+		used := g.usedWeight
+		wait := time.Unix((minute+1)*60, 0).Sub(now)
+		g.mu.Unlock()
+		log.Printf("⏳ %s 本分钟请求权重已用 %d/%d，等待 %s", g.baseURL, used, restWeightLimit, wait.Round(time.Second))
+		time.Sleep(wait)
+	}
+}
@@ -4,46 +4,53 @@ import "time"
 
 // Data 市场数据结构
 type Data struct {
-	Symbol            string
-	CurrentPrice      float64
-	PriceChange1h     float64 // 1小时价格变化百分比
-	PriceChange4h     float64 // 4小时价格变化百分比
-	CurrentEMA20      float64
-	CurrentMACD       float64
-	CurrentRSI7       float64
-	OpenInterest      *OIData
-	FundingRate       float64
-	IntradaySeries    *IntradayData
-	LongerTermContext *LongerTermData
-	SupertrendData    *SupertrendMultiTimeframe // Supertrend 多时间框架数据
-	VolumePriceData   *VolumePriceData          // 量价关系数据
+	Symbol            string                    `json:"symbol"`
+	CurrentPrice      float64                   `json:"current_price"`
+	PriceChange1h     float64                   `json:"price_change_1h"` // 1小时价格变化百分比
+	PriceChange4h     float64                   `json:"price_change_4h"` // 4小时价格变化百分比
+	CurrentEMA20      float64                   `json:"current_ema20"`
+	CurrentMACD       float64                   `json:"current_macd"`
+	CurrentRSI7       float64                   `json:"current_rsi7"`
+	OpenInterest      *OIData                   `json:"open_interest"`
+	FundingRate       float64                   `json:"funding_rate"`
+	IntradaySeries    *IntradayData             `json:"intraday_series"`
+	LongerTermContext *LongerTermData           `json:"longer_term_context"`
+	SupertrendData    *SupertrendMultiTimeframe `json:"supertrend"`             // Supertrend 多时间框架数据
+	VolumePriceData   *VolumePriceData          `json:"volume_price"`           // 量价关系数据
+	Technical         *TechnicalIndicators      `json:"technical"`              // 扩展技术指标（布林带、VWAP、随机RSI、ADX、OBV、一目均衡表、唐奇安通道、MACD信号线）
+	OrderBook         *OrderBookMetrics         `json:"order_book,omitempty"`   // 订单簿微观结构指标（价差、买卖失衡、预估滑点，获取失败时为空）
+	OrderFlow         *OrderFlowData            `json:"order_flow,omitempty"`   // 逐笔成交订单流（主动买卖量、CVD、大单笔数，获取失败时为空）
+	Liquidations      *LiquidationData          `json:"liquidations,omitempty"` // 强平统计（按方向和窗口汇总及强平聚集区，仅币安行情）
+	Funding           *FundingData              `json:"funding,omitempty"`      // 资金费率详情（预测费率、下次结算时间、结算历史，获取失败时为空）
+	Positioning       *PositioningData          `json:"positioning,omitempty"`  // 大户多空比和主动买卖比（仅币安行情）
+	Timeframes        []*TimeframeData          `json:"timeframes,omitempty"`   // 行情数据配置选择的时间框架及指标（未配置时为空）
 }
 
 // OIData Open Interest数据
 type OIData struct {
-	Latest  float64
-	Average float64
+	Latest  float64 `json:"latest"`
+	Average float64 `json:"average"`
 }
 
 // IntradayData 日内数据(3分钟间隔)
 type IntradayData struct {
-	MidPrices   []float64
-	EMA20Values []float64
-	MACDValues  []float64
-	RSI7Values  []float64
-	RSI14Values []float64
+	MidPrices   []float64 `json:"mid_prices"`
+	EMA20Values []float64 `json:"ema20_values"`
+	MACDValues  []float64 `json:"macd_values"`
+	RSI7Values  []float64 `json:"rsi7_values"`
+	RSI14Values []float64 `json:"rsi14_values"`
 }
 
 // LongerTermData 长期数据(4小时时间框架)
 type LongerTermData struct {
-	EMA20         float64
-	EMA50         float64
-	ATR3          float64
-	ATR14         float64
-	CurrentVolume float64
-	AverageVolume float64
-	MACDValues    []float64
-	RSI14Values   []float64
+	EMA20         float64   `json:"ema20"`
+	EMA50         float64   `json:"ema50"`
+	ATR3          float64   `json:"atr3"`
+	ATR14         float64   `json:"atr14"`
+	CurrentVolume float64   `json:"current_volume"`
+	AverageVolume float64   `json:"average_volume"`
+	MACDValues    []float64 `json:"macd_values"`
+	RSI14Values   []float64 `json:"rsi14_values"`
 }
 
 // Binance API 响应结构
@@ -59,6 +66,7 @@ type SymbolInfo struct {
 	ContractType      string `json:"contractType"`
 	PricePrecision    int    `json:"pricePrecision"`
 	QuantityPrecision int    `json:"quantityPrecision"`
+	OnboardDate       int64  `json:"onboardDate"` // 上线时间（毫秒）
 }
 
 type Kline struct {
@@ -0,0 +1,82 @@
+package market
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+)
+
+// listingCacheTTL 上线时间缓存有效期（exchangeInfo 数据量大，不需要每个周期请求）
+const listingCacheTTL = 6 * time.Hour
+
+// LiquidityStatsProvider 能提供24小时成交额、买卖价差和上线时间的行情数据源（用于候选币种筛选）
+// 币安兼容接口的数据源（币安、Aster）实现该接口
+type LiquidityStatsProvider interface {
+	// Get24hQuoteVolume 获取24小时成交额（USDT）
+	Get24hQuoteVolume(symbol string) (float64, error)
+	// GetSpreadPct 获取当前最优买卖价差（占中间价的百分比）
+	GetSpreadPct(symbol string) (float64, error)
+	// GetListingTime 获取合约上线时间
+	GetListingTime(symbol string) (time.Time, error)
+}
+
+// Get24hQuoteVolume 获取24小时成交额（USDT）
+func (p *fapiProvider) Get24hQuoteVolume(symbol string) (float64, error) {
+	var ticker Ticker24hr
+	if err := p.client.getJSON("/fapi/v1/ticker/24hr", map[string]string{"symbol": symbol}, &ticker); err != nil {
+		return 0, err
+	}
+
+	quoteVolume, err := strconv.ParseFloat(ticker.QuoteVolume, 64)
+	if err != nil {
+		return 0, fmt.Errorf("解析24小时成交额失败: %w", err)
+	}
+	return quoteVolume, nil
+}
+
+// GetSpreadPct 获取当前最优买卖价差（占中间价的百分比）
+func (p *fapiProvider) GetSpreadPct(symbol string) (float64, error) {
+	var result struct {
+		Symbol   string `json:"symbol"`
+		BidPrice string `json:"bidPrice"`
+		AskPrice string `json:"askPrice"`
+	}
+	if err := p.client.getJSON("/fapi/v1/ticker/bookTicker", map[string]string{"symbol": symbol}, &result); err != nil {
+		return 0, err
+	}
+
+	bid, _ := strconv.ParseFloat(result.BidPrice, 64)
+	ask, _ := strconv.ParseFloat(result.AskPrice, 64)
+	if bid <= 0 || ask <= 0 {
+		return 0, fmt.Errorf("%s 盘口价格无效 (bid=%s ask=%s)", symbol, result.BidPrice, result.AskPrice)
+	}
+	mid := (bid + ask) / 2
+	return (ask - bid) / mid * 100, nil
+}
+
+// GetListingTime 获取合约上线时间（exchangeInfo 缓存6小时）
+func (p *fapiProvider) GetListingTime(symbol string) (time.Time, error) {
+	p.listingMu.Lock()
+	defer p.listingMu.Unlock()
+
+	if p.listingTimes == nil || time.Since(p.listingFetchedAt) > listingCacheTTL {
+		exchangeInfo, err := p.client.GetExchangeInfo()
+		if err != nil {
+			return time.Time{}, fmt.Errorf("获取%s交易对信息失败: %w", p.name, err)
+		}
+		times := make(map[string]time.Time, len(exchangeInfo.Symbols))
+		for _, info := range exchangeInfo.Symbols {
+			if info.OnboardDate > 0 {
+				times[info.Symbol] = time.UnixMilli(info.OnboardDate)
+			}
+		}
+		p.listingTimes = times
+		p.listingFetchedAt = time.Now()
+	}
+
+	listedAt, ok := p.listingTimes[symbol]
+	if !ok {
+		return time.Time{}, fmt.Errorf("%s 没有 %s 的上线时间信息", p.name, symbol)
+	}
+	return listedAt, nil
+}
@@ -194,7 +194,22 @@ func (w *WSClient) handleReconnect() {
 	if err := w.Connect(); err != nil {
 		log.Printf("重新连接失败: %v", err)
 		go w.handleReconnect()
+		return
+	}
+
+	// 新连接不会保留之前的订阅，需要重新订阅
+	w.mu.RLock()
+	streams := make([]string, 0, len(w.subscribers))
+	for stream := range w.subscribers {
+		streams = append(streams, stream)
+	}
+	w.mu.RUnlock()
+	for _, stream := range streams {
+		if err := w.subscribe(stream); err != nil {
+			log.Printf("⚠️  重连后恢复订阅 %s 失败: %v", stream, err)
+		}
 	}
+	log.Printf("🔌 WebSocket已重连，恢复订阅 %d 个流", len(streams))
 }
 
 func (w *WSClient) AddSubscriber(stream string, bufferSize int) <-chan []byte {
@@ -3,6 +3,7 @@ package mcp
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -20,6 +21,19 @@ const (
 	ProviderCustom   Provider = "custom"
 )
 
+// 输出模式（按AI模型配置）
+const (
+	OutputModeText       = "text"        // 普通文本输出（思维链 + JSON数组，靠文本解析）
+	OutputModeJSONObject = "json_object" // response_format: json_object（DeepSeek/Qwen/OpenAI均支持）
+	OutputModeJSONSchema = "json_schema" // response_format: json_schema（OpenAI等支持结构化输出的提供商）
+)
+
+// Message 对话消息（用于多轮对话）
+type Message struct {
+	Role    string `json:"role"` // "system", "user" 或 "assistant"
+	Content string `json:"content"`
+}
+
 // Client AI API配置
 type Client struct {
 	Provider   Provider
@@ -27,16 +41,33 @@ type Client struct {
 	BaseURL    string
 	Model      string
 	Timeout    time.Duration
-	UseFullURL bool // 是否使用完整URL（不添加/chat/completions）
+	UseFullURL bool   // 是否使用完整URL（不添加/chat/completions）
+	OutputMode string // 输出模式: text / json_object / json_schema
+
+	MaxTokens     int // 最大输出token数
+	ContextWindow int // 上下文窗口（token，0表示按模型名自动识别）
 }
 
 func New() *Client {
 	// 默认配置
 	return &Client{
-		Provider: ProviderDeepSeek,
-		BaseURL:  "https://api.deepseek.com/v1",
-		Model:    "deepseek-chat",
-		Timeout:  120 * time.Second, // 增加到120秒，因为AI需要分析大量数据
+		Provider:   ProviderDeepSeek,
+		BaseURL:    "https://api.deepseek.com/v1",
+		Model:      "deepseek-chat",
+		Timeout:    120 * time.Second, // 增加到120秒，因为AI需要分析大量数据
+		OutputMode: OutputModeText,
+		MaxTokens:  DefaultMaxTokens,
+	}
+}
+
+// SetOutputMode 设置输出模式（空值或未知值按普通文本处理）
+func (client *Client) SetOutputMode(mode string) {
+	switch mode {
+	case OutputModeJSONObject, OutputModeJSONSchema:
+		client.OutputMode = mode
+		log.Printf("🔧 [MCP] 启用结构化输出: %s", mode)
+	default:
+		client.OutputMode = OutputModeText
 	}
 }
 
@@ -118,6 +149,26 @@ func (client *Client) SetClient(Client Client) {
 
 // CallWithMessages 使用 system + user prompt 调用AI API（推荐）
 func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string, error) {
+	messages := []Message{}
+
+	// 如果有 system prompt，添加 system message
+	if systemPrompt != "" {
+		messages = append(messages, Message{Role: "system", Content: systemPrompt})
+	}
+
+	// 添加 user message
+	messages = append(messages, Message{Role: "user", Content: userPrompt})
+
+	return client.CallWithConversation(messages)
+}
+
+// CallWithConversation 使用完整的多轮对话调用AI API（用于决策修复等需要上下文的场景）
+func (client *Client) CallWithConversation(messages []Message) (string, error) {
+	return client.CallWithResponseFormat(messages, nil)
+}
+
+// CallWithResponseFormat 使用指定的 response_format 调用AI API（nil 表示普通文本输出）
+func (client *Client) CallWithResponseFormat(messages []Message, responseFormat map[string]interface{}) (string, error) {
 	if client.APIKey == "" {
 		return "", fmt.Errorf("AI API密钥未设置，请先调用 SetDeepSeekAPIKey() 或 SetQwenAPIKey()")
 	}
@@ -131,7 +182,7 @@ func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string,
 			fmt.Printf("⚠️  AI API调用失败，正在重试 (%d/%d)...\n", attempt, maxRetries)
 		}
 
-		result, err := client.callOnce(systemPrompt, userPrompt)
+		result, err := client.callOnce(messages, responseFormat)
 		if err == nil {
 			if attempt > 1 {
 				fmt.Printf("✓ AI API重试成功\n")
@@ -157,44 +208,34 @@ func (client *Client) CallWithMessages(systemPrompt, userPrompt string) (string,
 }
 
 // callOnce 单次调用AI API（内部使用）
-func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error) {
+func (client *Client) callOnce(messages []Message, responseFormat map[string]interface{}) (string, error) {
 	// 打印当前 AI 配置
 	log.Printf("📡 [MCP] AI 请求配置:")
 	log.Printf("   Provider: %s", client.Provider)
 	log.Printf("   BaseURL: %s", client.BaseURL)
 	log.Printf("   Model: %s", client.Model)
 	log.Printf("   UseFullURL: %v", client.UseFullURL)
+	if responseFormat != nil {
+		log.Printf("   ResponseFormat: %v", responseFormat["type"])
+	}
+	log.Printf("   Prompt Tokens(估算): %d / 输入预算 %d", EstimateMessagesTokens(messages), client.InputTokenBudget())
 	if len(client.APIKey) > 8 {
 		log.Printf("   API Key: %s...%s", client.APIKey[:4], client.APIKey[len(client.APIKey)-4:])
 	}
 
-	// 构建 messages 数组
-	messages := []map[string]string{}
-
-	// 如果有 system prompt，添加 system message
-	if systemPrompt != "" {
-		messages = append(messages, map[string]string{
-			"role":    "system",
-			"content": systemPrompt,
-		})
-	}
-
-	// 添加 user message
-	messages = append(messages, map[string]string{
-		"role":    "user",
-		"content": userPrompt,
-	})
-
 	// 构建请求体
 	requestBody := map[string]interface{}{
 		"model":       client.Model,
 		"messages":    messages,
 		"temperature": 0.5, // 降低temperature以提高JSON格式稳定性
-		"max_tokens":  2000,
+		"max_tokens":  client.MaxTokens,
 	}
 
-	// 注意：response_format 参数仅 OpenAI 支持，DeepSeek/Qwen 不支持
-	// 我们通过强化 prompt 和后处理来确保 JSON 格式正确
+	// 注意：response_format 需要提供商支持（json_schema 主要是 OpenAI 兼容接口）
+	// 未配置时通过强化 prompt 和后处理来确保 JSON 格式正确
+	if responseFormat != nil {
+		requestBody["response_format"] = responseFormat
+	}
 
 	jsonData, err := json.Marshal(requestBody)
 	if err != nil {
@@ -246,7 +287,7 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("API返回错误 (status %d): %s", resp.StatusCode, string(body))
+		return "", &APIError{StatusCode: resp.StatusCode, Body: string(body)}
 	}
 
 	// 解析响应
@@ -269,6 +310,44 @@ func (client *Client) callOnce(systemPrompt, userPrompt string) (string, error)
 	return result.Choices[0].Message.Content, nil
 }
 
+// APIError AI API返回的非200响应
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("API返回错误 (status %d): %s", e.StatusCode, e.Body)
+}
+
+// responseFormatUnsupportedHints 提供商不支持 response_format 时错误信息中常见的内容（小写）
+var responseFormatUnsupportedHints = []string{
+	"response_format",
+	"json_schema",
+	"json_object",
+	"not supported",
+	"unsupported",
+}
+
+// IsResponseFormatUnsupported 判断错误是否表示提供商或模型不支持请求的 response_format
+// 只有 400/422 且错误信息提到 response_format 或不支持时才成立，网络错误、超时、鉴权失败、限流和5xx都不算
+func IsResponseFormatUnsupported(err error) bool {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	if apiErr.StatusCode != http.StatusBadRequest && apiErr.StatusCode != http.StatusUnprocessableEntity {
+		return false
+	}
+	body := strings.ToLower(apiErr.Body)
+	for _, hint := range responseFormatUnsupportedHints {
+		if strings.Contains(body, hint) {
+			return true
+		}
+	}
+	return false
+}
+
 // isRetryableError 判断错误是否可重试
 func isRetryableError(err error) bool {
 	errStr := err.Error()
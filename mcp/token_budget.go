@@ -0,0 +1,105 @@
+package mcp
+
+import (
+	"log"
+	"strings"
+	"unicode"
+)
+
+const (
+	// DefaultMaxTokens 默认的最大输出token数
+	DefaultMaxTokens = 2000
+	// MinContextWindow 允许配置的最小上下文窗口
+	MinContextWindow = 4096
+	// defaultContextWindow 无法识别模型时使用的上下文窗口（保守值）
+	defaultContextWindow = 32768
+)
+
+// modelContextWindows 常见模型的上下文窗口（按模型名前缀匹配，越具体的前缀越靠前）
+var modelContextWindows = []struct {
+	prefix string
+	tokens int
+}{
+	{"deepseek-reasoner", 65536},
+	{"deepseek", 65536},
+	{"qwen-max", 32768},
+	{"qwen-plus", 131072},
+	{"qwen-turbo", 131072},
+	{"qwen", 32768},
+	{"gpt-4o", 128000},
+	{"gpt-4.1", 128000},
+	{"gpt-4-turbo", 128000},
+	{"gpt-4-0125-preview", 128000},
+	{"gpt-4-1106-preview", 128000},
+	{"gpt-4", 8192},
+	{"gpt-3.5", 16385},
+	{"claude", 200000},
+	{"gemini", 128000},
+}
+
+// SetContextWindow 设置上下文窗口大小（token，0表示按模型名自动识别）
+func (client *Client) SetContextWindow(tokens int) {
+	if tokens > 0 && tokens < MinContextWindow {
+		log.Printf("⚠️  [MCP] 上下文窗口 %d 过小，使用最小值 %d", tokens, MinContextWindow)
+		tokens = MinContextWindow
+	}
+	client.ContextWindow = tokens
+	if tokens > 0 {
+		log.Printf("🔧 [MCP] 上下文窗口: %d tokens", tokens)
+	}
+}
+
+// GetContextWindow 获取当前模型的上下文窗口（优先使用配置值，其次按模型名识别）
+func (client *Client) GetContextWindow() int {
+	if client.ContextWindow > 0 {
+		return client.ContextWindow
+	}
+
+	model := strings.ToLower(client.Model)
+	for _, entry := range modelContextWindows {
+		if strings.HasPrefix(model, entry.prefix) {
+			return entry.tokens
+		}
+	}
+	return defaultContextWindow
+}
+
+// InputTokenBudget 输入（system + user prompt）可用的token预算
+// 上下文窗口扣除最大输出token数，并预留5%的估算误差
+func (client *Client) InputTokenBudget() int {
+	window := client.GetContextWindow()
+	maxTokens := client.MaxTokens
+	if maxTokens <= 0 {
+		maxTokens = DefaultMaxTokens
+	}
+	return window - maxTokens - window/20
+}
+
+// EstimateTokens 估算文本的token数（不依赖具体分词器的保守估算）
+// 中日韩字符按每字1个token计算，其余字符约每3个字符1个token（数字密集的行情数据比英文单词更耗token）
+func EstimateTokens(text string) int {
+	cjk := 0
+	other := 0
+	for _, r := range text {
+		switch {
+		case unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+			unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r):
+			cjk++
+		case r > unicode.MaxASCII:
+			// emoji、全角标点等通常占1-2个token
+			cjk++
+		default:
+			other++
+		}
+	}
+	return cjk + (other+2)/3
+}
+
+// EstimateMessagesTokens 估算多条消息的token数（每条消息额外计入角色等格式开销）
+func EstimateMessagesTokens(messages []Message) int {
+	total := 0
+	for _, msg := range messages {
+		total += EstimateTokens(msg.Content) + 4
+	}
+	return total
+}
@@ -0,0 +1,202 @@
+package replay
+
+import (
+	"fmt"
+	"nofx/decision"
+	"nofx/market"
+	"sync"
+	"time"
+)
+
+// 假设结果类型
+const (
+	ResultTakeProfit = "take_profit" // 开仓后先触发止盈
+	ResultStopLoss   = "stop_loss"   // 开仓后先触发止损
+	ResultOpen       = "open"        // 观察期内未触发止盈止损，按期末价格计算
+	ResultClosed     = "closed"      // 平仓决策：收益为平仓后放弃的价格变动（正数表示平仓正确）
+	ResultNone       = "none"        // hold/wait：不产生收益
+	ResultNoData     = "no_data"     // 没有获取到后续价格
+)
+
+// Outcome 决策在随后价格路径上的假设结果
+type Outcome struct {
+	Result         string  `json:"result"`           // 结果类型
+	EntryPrice     float64 `json:"entry_price"`      // 决策时价格
+	ExitPrice      float64 `json:"exit_price"`       // 假设离场价格
+	ExitTime       int64   `json:"exit_time"`        // 假设离场时间（毫秒）
+	PnLPct         float64 `json:"pnl_pct"`          // 收益率（%，未乘杠杆）
+	PnLUSD         float64 `json:"pnl_usd"`          // 收益（USDT，开仓按仓位价值、平仓按持仓数量计算）
+	PriceChangePct float64 `json:"price_change_pct"` // 观察期内的价格变化（%）
+	MaxFavorable   float64 `json:"max_favorable"`    // 观察期内最大有利变动（%）
+	MaxAdverse     float64 `json:"max_adverse"`      // 观察期内最大不利变动（%）
+}
+
+// DecisionOutcome 决策及其假设结果
+type DecisionOutcome struct {
+	Decision decision.Decision `json:"decision"`
+	Outcome  *Outcome          `json:"outcome"`
+}
+
+// PricePath 周期之后的价格路径（按币种缓存K线）
+type PricePath struct {
+	client   *market.APIClient
+	interval string
+	horizon  time.Duration
+
+	mu    sync.Mutex
+	cache map[string][]market.Kline
+}
+
+// NewPricePath 创建价格路径获取器
+func NewPricePath(interval string, horizon time.Duration) *PricePath {
+	return &PricePath{
+		client:   market.NewAPIClient(),
+		interval: interval,
+		horizon:  horizon,
+		cache:    make(map[string][]market.Kline),
+	}
+}
+
+// Klines 获取币种从指定时间开始、观察期内的K线
+func (p *PricePath) Klines(symbol string, start time.Time) ([]market.Kline, error) {
+	key := fmt.Sprintf("%s@%d", symbol, start.Unix())
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if klines, ok := p.cache[key]; ok {
+		return klines, nil
+	}
+
+	step, err := intervalDuration(p.interval)
+	if err != nil {
+		return nil, err
+	}
+	limit := int(p.horizon/step) + 1
+	if limit > 1500 {
+		limit = 1500
+	}
+
+	klines, err := p.client.GetKlinesFrom(symbol, p.interval, start.UnixMilli(), limit)
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s K线失败: %w", symbol, err)
+	}
+
+	// 只保留观察期内的K线
+	end := start.Add(p.horizon).UnixMilli()
+	var path []market.Kline
+	for _, k := range klines {
+		if k.OpenTime >= end {
+			break
+		}
+		path = append(path, k)
+	}
+	p.cache[key] = path
+	return path, nil
+}
+
+// Evaluate 计算决策在价格路径上的假设结果
+// 开仓：按止损/止盈先触发者离场（同一根K线同时触发时按止损计算），都未触发按期末收盘价
+// 平仓：收益为平仓后原方向放弃的价格变动取反（正数表示平仓避免了亏损），quantity 为平仓的持仓数量
+func Evaluate(d decision.Decision, entryPrice, quantity float64, klines []market.Kline) *Outcome {
+	if len(klines) == 0 {
+		return &Outcome{Result: ResultNoData}
+	}
+	if entryPrice <= 0 {
+		entryPrice = klines[0].Open
+	}
+
+	last := klines[len(klines)-1]
+	outcome := &Outcome{
+		EntryPrice:     entryPrice,
+		ExitPrice:      last.Close,
+		ExitTime:       last.CloseTime,
+		PriceChangePct: (last.Close - entryPrice) / entryPrice * 100,
+	}
+
+	direction := 0.0
+	switch d.Action {
+	case "open_long", "close_long":
+		direction = 1
+	case "open_short", "close_short":
+		direction = -1
+	default:
+		outcome.Result = ResultNone
+		outcome.ExitPrice = entryPrice
+		return outcome
+	}
+
+	for _, k := range klines {
+		outcome.MaxFavorable = max(outcome.MaxFavorable, favorable(direction, entryPrice, k.High, k.Low))
+		outcome.MaxAdverse = min(outcome.MaxAdverse, adverse(direction, entryPrice, k.High, k.Low))
+	}
+
+	if d.Action == "close_long" || d.Action == "close_short" {
+		outcome.Result = ResultClosed
+		outcome.PnLPct = -direction * outcome.PriceChangePct
+		outcome.PnLUSD = -direction * (outcome.ExitPrice - entryPrice) * quantity
+		return outcome
+	}
+
+	outcome.Result = ResultOpen
+	for _, k := range klines {
+		stopHit := d.StopLoss > 0 && ((direction > 0 && k.Low <= d.StopLoss) || (direction < 0 && k.High >= d.StopLoss))
+		takeHit := d.TakeProfit > 0 && ((direction > 0 && k.High >= d.TakeProfit) || (direction < 0 && k.Low <= d.TakeProfit))
+		if stopHit {
+			outcome.Result = ResultStopLoss
+			outcome.ExitPrice = d.StopLoss
+			outcome.ExitTime = k.CloseTime
+			break
+		}
+		if takeHit {
+			outcome.Result = ResultTakeProfit
+			outcome.ExitPrice = d.TakeProfit
+			outcome.ExitTime = k.CloseTime
+			break
+		}
+	}
+
+	outcome.PnLPct = direction * (outcome.ExitPrice - entryPrice) / entryPrice * 100
+	outcome.PnLUSD = d.PositionSizeUSD * outcome.PnLPct / 100
+	return outcome
+}
+
+// favorable 单根K线相对入场价的最大有利变动（%）
+func favorable(direction, entry, high, low float64) float64 {
+	if direction > 0 {
+		return (high - entry) / entry * 100
+	}
+	return (entry - low) / entry * 100
+}
+
+// adverse 单根K线相对入场价的最大不利变动（%，负数）
+func adverse(direction, entry, high, low float64) float64 {
+	if direction > 0 {
+		return (low - entry) / entry * 100
+	}
+	return (entry - high) / entry * 100
+}
+
+// intervalDuration K线周期对应的时长
+func intervalDuration(interval string) (time.Duration, error) {
+	switch interval {
+	case "1m":
+		return time.Minute, nil
+	case "3m":
+		return 3 * time.Minute, nil
+	case "5m":
+		return 5 * time.Minute, nil
+	case "15m":
+		return 15 * time.Minute, nil
+	case "30m":
+		return 30 * time.Minute, nil
+	case "1h":
+		return time.Hour, nil
+	case "2h":
+		return 2 * time.Hour, nil
+	case "4h":
+		return 4 * time.Hour, nil
+	case "1d":
+		return 24 * time.Hour, nil
+	}
+	return 0, fmt.Errorf("不支持的K线周期: %s", interval)
+}
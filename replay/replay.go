@@ -0,0 +1,367 @@
+package replay
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"nofx/config"
+	"nofx/decision"
+	"nofx/logger"
+	"nofx/mcp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// 默认观察期和K线周期
+const (
+	DefaultHorizon  = 4 * time.Hour
+	DefaultInterval = "15m"
+)
+
+// Config 重放配置
+type Config struct {
+	TraderName      string                 // 交易员名称
+	DecisionLogger  *logger.DecisionLogger // 交易员的决策日志（读取市场数据快照）
+	BTCETHLeverage  int                    // BTC/ETH杠杆上限（决策验证使用）
+	AltcoinLeverage int                    // 山寨币杠杆上限
+	Locale          string                 // 提示词语言（记录中没有保存语言时使用，重新渲染模板和验证错误使用）
+	Client          *mcp.Client            // 重放使用的AI模型
+	ModelName       string                 // 重放模型名称（报告展示）
+	Options         decision.ReplayOptions // 替换的提示词模板
+	Horizon         time.Duration          // 假设结果的观察期
+	Interval        string                 // 价格路径的K线周期
+}
+
+// SymbolDiff 同一币种原始决策与重放决策的对比
+type SymbolDiff struct {
+	Symbol         string  `json:"symbol"`
+	OriginalAction string  `json:"original_action"` // 原始决策动作（无决策为空）
+	ReplayedAction string  `json:"replayed_action"` // 重放决策动作（无决策为空）
+	Same           bool    `json:"same"`            // 动作是否一致
+	OriginalPnLUSD float64 `json:"original_pnl_usd"`
+	ReplayedPnLUSD float64 `json:"replayed_pnl_usd"`
+}
+
+// CycleComparison 单个周期的对比结果
+type CycleComparison struct {
+	CycleNumber  int                `json:"cycle_number"`
+	Timestamp    time.Time          `json:"timestamp"`
+	Template     string             `json:"template"`      // 原始使用的模板
+	ReplayedWith string             `json:"replayed_with"` // 重放使用的模板
+	Original     []*DecisionOutcome `json:"original"`
+	Replayed     []*DecisionOutcome `json:"replayed"`
+	Diffs        []*SymbolDiff      `json:"diffs"`
+	CoTTrace     string             `json:"cot_trace"`            // 重放的思维链
+	RawResponse  string             `json:"raw_response"`         // 重放的AI原始输出
+	Error        string             `json:"error,omitempty"`      // 重放失败原因
+	Rejected     []string           `json:"rejected,omitempty"`   // 重放中未通过验证的决策
+	OriginalPnL  float64            `json:"original_pnl_usd"`     // 原始决策假设收益合计
+	ReplayedPnL  float64            `json:"replayed_pnl_usd"`     // 重放决策假设收益合计
+	AgreedCount  int                `json:"agreed_count"`         // 动作一致的币种数
+	PricePathErr string             `json:"price_path,omitempty"` // 获取价格路径失败的说明
+}
+
+// Summary 重放汇总
+type Summary struct {
+	Cycles         int     `json:"cycles"`           // 重放的周期数
+	Failed         int     `json:"failed"`           // 重放失败的周期数
+	AgreementRate  float64 `json:"agreement_rate"`   // 动作一致率（%）
+	OriginalPnLUSD float64 `json:"original_pnl_usd"` // 原始决策假设收益合计
+	ReplayedPnLUSD float64 `json:"replayed_pnl_usd"` // 重放决策假设收益合计
+}
+
+// Report 重放报告
+type Report struct {
+	TraderName string             `json:"trader_name"`
+	Model      string             `json:"model"`
+	Horizon    string             `json:"horizon"`
+	Interval   string             `json:"interval"`
+	Cycles     []*CycleComparison `json:"cycles"`
+	Summary    Summary            `json:"summary"`
+}
+
+// NewAIClient 根据AI模型配置创建重放使用的AI客户端（外部决策服务不支持prompt重放）
+func NewAIClient(model *config.AIModelConfig) (*mcp.Client, error) {
+	client := mcp.New()
+	switch model.Provider {
+	case "external":
+		return nil, fmt.Errorf("外部决策服务不使用prompt，无法重放")
+	case "custom":
+		client.SetCustomAPI(model.CustomAPIURL, model.APIKey, model.CustomModelName)
+	case "qwen":
+		client.SetQwenAPIKey(model.APIKey, model.CustomAPIURL, model.CustomModelName)
+	default:
+		client.SetDeepSeekAPIKey(model.APIKey, model.CustomAPIURL, model.CustomModelName)
+	}
+	client.SetOutputMode(model.OutputMode)
+	client.SetContextWindow(model.ContextWindow)
+	if model.TimeoutSeconds > 0 {
+		client.Timeout = time.Duration(model.TimeoutSeconds) * time.Second
+	}
+	return client, nil
+}
+
+// FindAIModel 按ID（或provider）查找AI模型配置
+func FindAIModel(models []*config.AIModelConfig, id string) *config.AIModelConfig {
+	for _, model := range models {
+		if model.ID == id {
+			return model
+		}
+	}
+	for _, model := range models {
+		if model.Provider == id {
+			return model
+		}
+	}
+	return nil
+}
+
+// FindTrader 按ID查找交易员配置（GetTraderConfig 不包含杠杆等策略字段，重放需要完整配置）
+func FindTrader(traders []*config.TraderRecord, id string) *config.TraderRecord {
+	for _, trader := range traders {
+		if trader.ID == id {
+			return trader
+		}
+	}
+	return nil
+}
+
+// NewDecisionLogger 打开交易员的决策日志（与交易员运行时使用同一目录）
+func NewDecisionLogger(traderID string) *logger.DecisionLogger {
+	return logger.NewDecisionLogger(fmt.Sprintf("decision_logs/%s", traderID))
+}
+
+// LoadRecords 读取决策记录并按周期筛选
+// from/to > 0 时按周期编号范围筛选（同一编号出现多次时全部保留），否则取最近 last 条
+func LoadRecords(decisionLogger *logger.DecisionLogger, from, to, last int) ([]*logger.DecisionRecord, error) {
+	records, err := decisionLogger.GetLatestRecords(100000)
+	if err != nil {
+		return nil, fmt.Errorf("读取决策记录失败: %w", err)
+	}
+	return SelectRecords(records, from, to, last), nil
+}
+
+// SelectRecords 按周期编号范围或最近N条筛选可重放的记录（缺少输入prompt的记录会被跳过）
+func SelectRecords(records []*logger.DecisionRecord, from, to, last int) []*logger.DecisionRecord {
+	var selected []*logger.DecisionRecord
+	for _, record := range records {
+		if record.InputPrompt == "" {
+			continue
+		}
+		if from > 0 && record.CycleNumber < from {
+			continue
+		}
+		if to > 0 && record.CycleNumber > to {
+			continue
+		}
+		selected = append(selected, record)
+	}
+
+	if from <= 0 && to <= 0 {
+		if last <= 0 {
+			last = 1
+		}
+		if len(selected) > last {
+			selected = selected[len(selected)-last:]
+		}
+	}
+	return selected
+}
+
+// Run 依次重放决策记录并对比原始决策与重放决策的假设结果
+func Run(cfg *Config, records []*logger.DecisionRecord) (*Report, error) {
+	if cfg.Client == nil {
+		return nil, fmt.Errorf("未配置重放使用的AI模型")
+	}
+	if cfg.Horizon <= 0 {
+		cfg.Horizon = DefaultHorizon
+	}
+	if cfg.Interval == "" {
+		cfg.Interval = DefaultInterval
+	}
+	if _, err := intervalDuration(cfg.Interval); err != nil {
+		return nil, err
+	}
+
+	report := &Report{
+		TraderName: cfg.TraderName,
+		Model:      cfg.ModelName,
+		Horizon:    cfg.Horizon.String(),
+		Interval:   cfg.Interval,
+		Cycles:     []*CycleComparison{},
+	}
+	prices := NewPricePath(cfg.Interval, cfg.Horizon)
+
+	agreed, compared := 0, 0
+	for i, record := range records {
+		log.Printf("🔁 重放周期 #%d (%s) [%d/%d]", record.CycleNumber, record.Timestamp.Format("2006-01-02 15:04:05"), i+1, len(records))
+
+		var snapshot *logger.MarketSnapshot
+		if cfg.DecisionLogger != nil {
+			if s, err := cfg.DecisionLogger.LoadRecordSnapshot(record); err == nil {
+				snapshot = s
+			}
+		}
+		comparison := replayRecord(cfg, record, snapshot, prices)
+		report.Cycles = append(report.Cycles, comparison)
+
+		report.Summary.Cycles++
+		if comparison.Error != "" {
+			report.Summary.Failed++
+			continue
+		}
+		report.Summary.OriginalPnLUSD += comparison.OriginalPnL
+		report.Summary.ReplayedPnLUSD += comparison.ReplayedPnL
+		agreed += comparison.AgreedCount
+		compared += len(comparison.Diffs)
+	}
+	if compared > 0 {
+		report.Summary.AgreementRate = float64(agreed) / float64(compared) * 100
+	}
+
+	return report, nil
+}
+
+// replayRecord 重放单个周期
+func replayRecord(cfg *Config, record *logger.DecisionRecord, snapshot *logger.MarketSnapshot, prices *PricePath) *CycleComparison {
+	comparison := &CycleComparison{
+		CycleNumber: record.CycleNumber,
+		Timestamp:   record.Timestamp,
+		Template:    record.PromptTemplate,
+	}
+
+	// 原始决策（通过验证的决策）
+	var original []decision.Decision
+	if record.DecisionJSON != "" {
+		if err := json.Unmarshal([]byte(record.DecisionJSON), &original); err != nil {
+			log.Printf("⚠️  周期 #%d 原始决策解析失败: %v", record.CycleNumber, err)
+		}
+	}
+
+	input := &decision.ReplayInput{
+		TraderName:      cfg.TraderName,
+		SystemPrompt:    record.SystemPrompt,
+		UserPrompt:      record.InputPrompt,
+		AccountEquity:   record.AccountState.TotalBalance,
+		BTCETHLeverage:  cfg.BTCETHLeverage,
+		AltcoinLeverage: cfg.AltcoinLeverage,
+		Locale:          cfg.Locale,
+	}
+	// 使用周期记录中保存的语言，交易员之后修改语言不影响重放
+	if record.Locale != "" {
+		input.Locale = record.Locale
+	}
+	replayed, err := decision.ReplayDecision(input, cfg.Client, cfg.Options)
+	if replayed != nil {
+		comparison.ReplayedWith = replayed.TemplateName
+		comparison.CoTTrace = replayed.CoTTrace
+		comparison.RawResponse = replayed.RawResponse
+		for _, rejected := range replayed.RejectedDecisions {
+			comparison.Rejected = append(comparison.Rejected, fmt.Sprintf("%s %s: %s", rejected.Decision.Symbol, rejected.Decision.Action, rejected.Error))
+		}
+	}
+	if err != nil {
+		comparison.Error = err.Error()
+		comparison.Original = evaluateAll(original, record, snapshot, prices, comparison)
+		return comparison
+	}
+
+	comparison.Original = evaluateAll(original, record, snapshot, prices, comparison)
+	comparison.Replayed = evaluateAll(replayed.Decisions, record, snapshot, prices, comparison)
+	comparison.Diffs = diffDecisions(comparison.Original, comparison.Replayed)
+	for _, o := range comparison.Original {
+		comparison.OriginalPnL += o.Outcome.PnLUSD
+	}
+	for _, r := range comparison.Replayed {
+		comparison.ReplayedPnL += r.Outcome.PnLUSD
+	}
+	for _, diff := range comparison.Diffs {
+		if diff.Same {
+			comparison.AgreedCount++
+		}
+	}
+	return comparison
+}
+
+// evaluateAll 计算一组决策的假设结果（入场价优先使用快照中的决策时价格）
+func evaluateAll(decisions []decision.Decision, record *logger.DecisionRecord, snapshot *logger.MarketSnapshot, prices *PricePath, comparison *CycleComparison) []*DecisionOutcome {
+	outcomes := make([]*DecisionOutcome, 0, len(decisions))
+	for _, d := range decisions {
+		entryPrice := 0.0
+		if snapshot != nil {
+			if data, ok := snapshot.MarketData[d.Symbol]; ok && data != nil {
+				entryPrice = data.CurrentPrice
+			}
+		}
+
+		klines, err := prices.Klines(d.Symbol, record.Timestamp)
+		if err != nil {
+			comparison.PricePathErr = err.Error()
+		}
+		outcomes = append(outcomes, &DecisionOutcome{
+			Decision: d,
+			Outcome:  Evaluate(d, entryPrice, closeQuantity(d, record), klines),
+		})
+	}
+	return outcomes
+}
+
+// closeQuantity 平仓决策对应的持仓数量（取自周期记录中的持仓快照，非平仓决策或找不到持仓时为0）
+func closeQuantity(d decision.Decision, record *logger.DecisionRecord) float64 {
+	var side string
+	switch d.Action {
+	case "close_long":
+		side = "long"
+	case "close_short":
+		side = "short"
+	default:
+		return 0
+	}
+	for _, pos := range record.Positions {
+		if pos.Symbol == d.Symbol && strings.EqualFold(pos.Side, side) {
+			return math.Abs(pos.PositionAmt)
+		}
+	}
+	return 0
+}
+
+// diffDecisions 按币种对比原始决策与重放决策
+func diffDecisions(original, replayed []*DecisionOutcome) []*SymbolDiff {
+	diffs := make(map[string]*SymbolDiff)
+	getDiff := func(symbol string) *SymbolDiff {
+		if _, exists := diffs[symbol]; !exists {
+			diffs[symbol] = &SymbolDiff{Symbol: symbol}
+		}
+		return diffs[symbol]
+	}
+
+	for _, o := range original {
+		diff := getDiff(o.Decision.Symbol)
+		diff.OriginalAction = o.Decision.Action
+		diff.OriginalPnLUSD += o.Outcome.PnLUSD
+	}
+	for _, r := range replayed {
+		diff := getDiff(r.Decision.Symbol)
+		diff.ReplayedAction = r.Decision.Action
+		diff.ReplayedPnLUSD += r.Outcome.PnLUSD
+	}
+
+	result := make([]*SymbolDiff, 0, len(diffs))
+	for _, diff := range diffs {
+		diff.Same = normalizeAction(diff.OriginalAction) == normalizeAction(diff.ReplayedAction)
+		result = append(result, diff)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Symbol < result[j].Symbol })
+	return result
+}
+
+// normalizeAction 对比时把 hold/wait/无决策 视为同一种"不操作"
+func normalizeAction(action string) string {
+	switch action {
+	case "", "hold", "wait":
+		return "none"
+	}
+	return action
+}
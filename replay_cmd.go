@@ -0,0 +1,170 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"nofx/config"
+	"nofx/decision"
+	"nofx/manager"
+	"nofx/replay"
+	"os"
+	"strings"
+	"time"
+)
+
+// runReplayCommand 决策重放命令行工具：把历史周期重新提交给其他AI模型或模板，并排对比决策及假设结果
+// 用法: nofx replay -trader <id> [-model <ai_model_id>] [-template <name>] [-from N -to M | -last N] [-horizon 4h] [-interval 15m] [-json]
+func runReplayCommand(args []string) int {
+	fs := flag.NewFlagSet("replay", flag.ContinueOnError)
+	dbPath := fs.String("db", "config.db", "配置数据库路径")
+	userID := fs.String("user", "default", "交易员所属用户ID")
+	traderID := fs.String("trader", "", "交易员ID（必填）")
+	modelID := fs.String("model", "", "重放使用的AI模型ID（为空使用交易员当前模型）")
+	templateName := fs.String("template", "", "重放使用的提示词模板（为空使用记录中的原始系统提示词）")
+	customPrompt := fs.String("custom-prompt", "", "追加的自定义prompt")
+	from := fs.Int("from", 0, "起始周期编号")
+	to := fs.Int("to", 0, "结束周期编号")
+	last := fs.Int("last", 1, "未指定周期范围时重放最近N个周期")
+	horizon := fs.Duration("horizon", replay.DefaultHorizon, "假设结果的观察期")
+	interval := fs.String("interval", replay.DefaultInterval, "价格路径的K线周期")
+	asJSON := fs.Bool("json", false, "以JSON输出完整报告")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *traderID == "" {
+		fmt.Fprintln(os.Stderr, "❌ 请使用 -trader 指定交易员ID")
+		fs.Usage()
+		return 2
+	}
+
+	database, err := config.NewDatabase(*dbPath)
+	if err != nil {
+		log.Printf("❌ 初始化数据库失败: %v", err)
+		return 1
+	}
+	defer database.Close()
+
+	traders, err := database.GetTraders(*userID)
+	if err != nil {
+		log.Printf("❌ 获取交易员列表失败: %v", err)
+		return 1
+	}
+	traderRecord := replay.FindTrader(traders, *traderID)
+	if traderRecord == nil {
+		log.Printf("❌ 交易员 %s 不存在", *traderID)
+		return 1
+	}
+	if *modelID == "" {
+		*modelID = traderRecord.AIModelID
+	}
+	models, err := database.GetAIModels(*userID)
+	if err != nil {
+		log.Printf("❌ 获取AI模型配置失败: %v", err)
+		return 1
+	}
+	model := replay.FindAIModel(models, *modelID)
+	if model == nil {
+		log.Printf("❌ AI模型 %s 不存在", *modelID)
+		return 1
+	}
+	client, err := replay.NewAIClient(model)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return 1
+	}
+
+	options := decision.ReplayOptions{TemplateName: *templateName, CustomPrompt: *customPrompt}
+	if *templateName != "" {
+		if options.PromptTemplate, err = manager.LoadPromptTemplate(database, *userID, *templateName, 0); err != nil {
+			log.Printf("❌ 加载提示词模板失败: %v", err)
+			return 1
+		}
+	}
+
+	decisionLogger := replay.NewDecisionLogger(*traderID)
+	records, err := replay.LoadRecords(decisionLogger, *from, *to, *last)
+	if err != nil {
+		log.Printf("❌ %v", err)
+		return 1
+	}
+	if len(records) == 0 {
+		log.Printf("⚠️  没有可重放的决策记录")
+		return 1
+	}
+
+	report, err := replay.Run(&replay.Config{
+		TraderName:      traderRecord.Name,
+		DecisionLogger:  decisionLogger,
+		BTCETHLeverage:  traderRecord.BTCETHLeverage,
+		AltcoinLeverage: traderRecord.AltcoinLeverage,
+		Locale:          traderRecord.Locale,
+		Client:          client,
+		ModelName:       model.ID,
+		Options:         options,
+		Horizon:         *horizon,
+		Interval:        *interval,
+	}, records)
+	if err != nil {
+		log.Printf("❌ 重放失败: %v", err)
+		return 1
+	}
+
+	if *asJSON {
+		data, _ := json.MarshalIndent(report, "", "  ")
+		fmt.Println(string(data))
+		return 0
+	}
+	printReplayReport(report)
+	return 0
+}
+
+// printReplayReport 以表格形式并排输出原始决策与重放决策
+func printReplayReport(report *replay.Report) {
+	fmt.Println()
+	fmt.Printf("🔁 决策重放: %s | 模型: %s | 观察期: %s (%s K线)\n", report.TraderName, report.Model, report.Horizon, report.Interval)
+	fmt.Println(strings.Repeat("=", 78))
+
+	for _, cycle := range report.Cycles {
+		fmt.Printf("周期 #%d  %s  模板: %s → %s\n", cycle.CycleNumber, cycle.Timestamp.Format(time.DateTime), cycle.Template, cycle.ReplayedWith)
+		if cycle.Error != "" {
+			fmt.Printf("  ❌ 重放失败: %s\n\n", cycle.Error)
+			continue
+		}
+		if len(cycle.Diffs) == 0 {
+			fmt.Println("  (原始与重放均无决策)")
+		} else {
+			fmt.Printf("  %-12s %-14s %10s   %-14s %10s\n", "币种", "原始决策", "假设收益", "重放决策", "假设收益")
+			for _, diff := range cycle.Diffs {
+				mark := "≠"
+				if diff.Same {
+					mark = "="
+				}
+				fmt.Printf("  %-12s %-14s %+10.2f %s %-14s %+10.2f\n",
+					diff.Symbol, actionLabel(diff.OriginalAction), diff.OriginalPnLUSD,
+					mark, actionLabel(diff.ReplayedAction), diff.ReplayedPnLUSD)
+			}
+		}
+		for _, rejected := range cycle.Rejected {
+			fmt.Printf("  ⚠️  重放决策未通过验证: %s\n", rejected)
+		}
+		if cycle.PricePathErr != "" {
+			fmt.Printf("  ⚠️  %s\n", cycle.PricePathErr)
+		}
+		fmt.Printf("  合计: 原始 %+.2f USDT | 重放 %+.2f USDT\n\n", cycle.OriginalPnL, cycle.ReplayedPnL)
+	}
+
+	fmt.Println(strings.Repeat("=", 78))
+	fmt.Printf("周期: %d (失败 %d) | 动作一致率: %.1f%% | 假设收益: 原始 %+.2f USDT vs 重放 %+.2f USDT\n",
+		report.Summary.Cycles, report.Summary.Failed, report.Summary.AgreementRate,
+		report.Summary.OriginalPnLUSD, report.Summary.ReplayedPnLUSD)
+}
+
+// actionLabel 无决策时显示为 "-"
+func actionLabel(action string) string {
+	if action == "" {
+		return "-"
+	}
+	return action
+}
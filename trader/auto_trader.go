@@ -2,6 +2,7 @@ package trader
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"nofx/decision"
@@ -48,6 +49,9 @@ type AutoTraderConfig struct {
 	CustomAPIURL    string
 	CustomAPIKey    string
 	CustomModelName string
+	AIOutputMode    string        // 输出模式: text/json_object/json_schema（按AI模型配置）
+	AIContextWindow int           // 上下文窗口（token，0=按模型名自动识别），用于控制输入prompt的token预算
+	AITimeout       time.Duration // AI / 外部决策服务请求超时（0=使用默认值）
 
 	// 扫描配置
 	ScanInterval time.Duration // 扫描间隔（建议3分钟）
@@ -73,6 +77,13 @@ type AutoTraderConfig struct {
 
 	// 系统提示词模板
 	SystemPromptTemplate string // 系统提示词模板名称（如 "default", "aggressive"）
+
+	// 决策修复配置
+	AIRepairRounds int // 决策验证失败时让AI自我修复的轮数（0=不修复）
+
+	// 行情数据保护
+	BlockStaleOpens bool    // 行情数据过时（WebSocket流断开）时禁止开新仓
+	MaxSlippagePct  float64 // 市价开仓允许的最大预估滑点（%，0=不限制）
 }
 
 // AutoTrader 自动交易器
@@ -84,14 +95,31 @@ type AutoTrader struct {
 	config                AutoTraderConfig
 	trader                Trader // 使用Trader接口（支持多平台）
 	mcpClient             *mcp.Client
-	decisionLogger        *logger.DecisionLogger // 决策日志记录器
+	externalProvider      *decision.ExternalProvider // 外部HTTP决策服务（AI模型为 external 时使用）
+	marketProvider        market.MarketDataProvider  // 行情数据源（与交易平台一致）
+	decisionLogger        *logger.DecisionLogger     // 决策日志记录器
 	initialBalance        float64
 	dailyPnL              float64
-	customPrompt          string   // 自定义交易策略prompt
-	overrideBasePrompt    bool     // 是否覆盖基础prompt
-	systemPromptTemplate  string   // 系统提示词模板名称
-	defaultCoins          []string // 默认币种列表（从数据库获取）
-	tradingCoins          []string // 实际交易币种列表
+	customPrompt          string                       // 自定义交易策略prompt
+	overrideBasePrompt    bool                         // 是否覆盖基础prompt
+	systemPromptTemplate  string                       // 系统提示词模板名称
+	promptTemplate        *decision.PromptTemplate     // 数据库版本化提示词模板（为空时使用文件模板）
+	promptExperiment      *decision.PromptExperiment   // 提示词A/B实验（为空表示未启用）
+	experimentCalls       int                          // 当前实验已发送给AI的周期数（用于轮流分配变体，更换实验时清零）
+	signalAnalyzers       []decision.SignalAnalyzer    // 启用的信号分析器（为空表示使用默认分析器）
+	decisionMode          string                       // 决策方式: ai/rule/ai_rule_fallback
+	ruleStrategy          *decision.RuleStrategy       // 规则策略（规则模式及AI回退时使用）
+	locale                string                       // 提示词语言: zh-CN/en
+	changeDetector        *decision.ChangeDetector     // 市场状态变化检测（为空表示每个周期都调用AI）
+	universeFilter        *decision.UniverseFilter     // 候选币种筛选条件
+	dataProfile           *market.DataProfile          // 行情数据配置（为空表示使用原有格式）
+	alertTrigger          *decision.AlertTriggerConfig // 行情警报配置（为空表示不使用警报）
+	cycleTrigger          string                       // 提前执行本周期的原因（记录到决策日志）
+	lastCycleTime         time.Time                    // 上个周期开始的时间
+	subscribedCoins       map[string]bool              // 已通过 WSMonitor 订阅K线流的币种
+	subscribedIntervals   []string                     // 已订阅K线流的周期（行情数据配置变化时重新订阅）
+	defaultCoins          []string                     // 默认币种列表（从数据库获取）
+	tradingCoins          []string                     // 实际交易币种列表
 	lastResetTime         time.Time
 	stopUntil             time.Time
 	isRunning             bool
@@ -118,9 +146,14 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 	}
 
 	mcpClient := mcp.New()
+	var externalProvider *decision.ExternalProvider
 
 	// 初始化AI
-	if config.AIModel == "custom" {
+	if config.AIModel == "external" {
+		// 使用外部HTTP决策服务（不调用LLM）
+		externalProvider = decision.NewExternalProvider(config.CustomAPIURL, config.CustomAPIKey, config.CustomModelName, config.AITimeout)
+		log.Printf("🌐 [%s] 使用外部决策服务: %s (超时: %v, 协议版本: %s)", config.Name, config.CustomAPIURL, externalProvider.Timeout, decision.ExternalSchemaVersion)
+	} else if config.AIModel == "custom" {
 		// 使用自定义API
 		mcpClient.SetCustomAPI(config.CustomAPIURL, config.CustomAPIKey, config.CustomModelName)
 		log.Printf("🤖 [%s] 使用自定义AI API: %s (模型: %s)", config.Name, config.CustomAPIURL, config.CustomModelName)
@@ -142,6 +175,13 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		}
 	}
 
+	// 设置结构化输出模式
+	mcpClient.SetOutputMode(config.AIOutputMode)
+	mcpClient.SetContextWindow(config.AIContextWindow)
+	if config.AITimeout > 0 {
+		mcpClient.Timeout = config.AITimeout
+	}
+
 	// 初始化币种池API
 	if config.CoinPoolAPIURL != "" {
 		pool.SetCoinPoolAPI(config.CoinPoolAPIURL)
@@ -203,12 +243,18 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		name:                  config.Name,
 		aiModel:               config.AIModel,
 		exchange:              config.Exchange,
+		marketProvider:        market.ProviderFor(config.Exchange, config.HyperliquidTestnet),
 		config:                config,
 		trader:                trader,
 		mcpClient:             mcpClient,
+		externalProvider:      externalProvider,
 		decisionLogger:        decisionLogger,
 		initialBalance:        config.InitialBalance,
 		systemPromptTemplate:  systemPromptTemplate,
+		decisionMode:          decision.DecisionModeAI,
+		ruleStrategy:          decision.DefaultRuleStrategy(),
+		locale:                decision.DefaultLocale,
+		universeFilter:        decision.DefaultUniverseFilter(),
 		defaultCoins:          config.DefaultCoins,
 		tradingCoins:          config.TradingCoins,
 		lastResetTime:         time.Now(),
@@ -216,6 +262,7 @@ func NewAutoTrader(config AutoTraderConfig) (*AutoTrader, error) {
 		callCount:             0,
 		isRunning:             false,
 		positionFirstSeenTime: make(map[string]int64),
+		subscribedCoins:       make(map[string]bool),
 	}, nil
 }
 
@@ -230,6 +277,14 @@ func (at *AutoTrader) Run() error {
 	ticker := time.NewTicker(at.config.ScanInterval)
 	defer ticker.Stop()
 
+	// 行情警报只来自币安WebSocket行情（未启用警报配置时忽略收到的警报）
+	var alerts <-chan market.Alert
+	if market.WSMonitorCli != nil && at.marketProvider.Name() == market.ProviderBinance {
+		ch, cancel := market.WSMonitorCli.SubscribeAlerts(100)
+		defer cancel()
+		alerts = ch
+	}
+
 	// 首次立即执行
 	if err := at.runCycle(); err != nil {
 		log.Printf("❌ 执行失败: %v", err)
@@ -241,9 +296,27 @@ func (at *AutoTrader) Run() error {
 			if err := at.runCycle(); err != nil {
 				log.Printf("❌ 执行失败: %v", err)
 			}
+		case alert, ok := <-alerts:
+			if !ok {
+				alerts = nil
+				continue
+			}
+			if !at.shouldTriggerOnAlert(alert) {
+				continue
+			}
+			log.Printf("🚨 [%s] 行情警报: %s，提前执行交易周期", at.name, alert.Message)
+			at.cycleTrigger = fmt.Sprintf("🚨 行情警报触发: %s", alert.Message)
+			if at.changeDetector != nil {
+				at.changeDetector.Reset() // 警报触发的周期始终调用AI
+			}
+			if err := at.runCycle(); err != nil {
+				log.Printf("❌ 执行失败: %v", err)
+			}
+			ticker.Reset(at.config.ScanInterval)
 		}
 	}
 
+	at.releaseMarketSubscriptions()
 	return nil
 }
 
@@ -257,15 +330,20 @@ func (at *AutoTrader) Stop() {
 func (at *AutoTrader) runCycle() error {
 	at.callCount++
 
-	log.Printf("\n" + strings.Repeat("=", 70))
+	log.Print("\n" + strings.Repeat("=", 70))
 	log.Printf("⏰ %s - AI决策周期 #%d", time.Now().Format("2006-01-02 15:04:05"), at.callCount)
-	log.Printf(strings.Repeat("=", 70))
+	log.Print(strings.Repeat("=", 70))
 
 	// 创建决策记录
 	record := &logger.DecisionRecord{
 		ExecutionLog: []string{},
 		Success:      true,
 	}
+	at.lastCycleTime = time.Now()
+	if at.cycleTrigger != "" {
+		record.ExecutionLog = append(record.ExecutionLog, at.cycleTrigger)
+		at.cycleTrigger = ""
+	}
 
 	// 1. 检查是否需要停止交易
 	if time.Now().Before(at.stopUntil) {
@@ -292,6 +370,7 @@ func (at *AutoTrader) runCycle() error {
 		at.decisionLogger.LogDecision(record)
 		return fmt.Errorf("构建交易上下文失败: %w", err)
 	}
+	at.syncMarketSubscriptions(ctx)
 
 	// 保存账户状态快照
 	record.AccountState = logger.AccountSnapshot{
@@ -313,6 +392,7 @@ func (at *AutoTrader) runCycle() error {
 			UnrealizedProfit: pos.UnrealizedPnL,
 			Leverage:         float64(pos.Leverage),
 			LiquidationPrice: pos.LiquidationPrice,
+			FundingCost:      pos.FundingCost,
 		})
 	}
 
@@ -324,47 +404,129 @@ func (at *AutoTrader) runCycle() error {
 	log.Printf("📊 账户净值: %.2f USDT | 可用: %.2f USDT | 持仓: %d",
 		ctx.Account.TotalEquity, ctx.Account.AvailableBalance, ctx.Account.PositionCount)
 
-	// 4. 调用AI获取完整决策
-	log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", at.systemPromptTemplate)
-	decision, err := decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, at.customPrompt, at.overrideBasePrompt, at.systemPromptTemplate)
+	// 持仓不变且市场状态无明显变化时跳过AI调用（规则模式不调用AI，无需检测）
+	if detector := at.changeDetector; detector != nil && at.GetDecisionMode() != decision.DecisionModeRule {
+		changed, reason := true, ""
+		if err := decision.FetchMarketData(ctx); err != nil {
+			reason = fmt.Sprintf("预先获取市场数据失败: %v", err)
+			ctx.MarketDataMap = nil // 获取决策时重新请求
+		} else {
+			changed, reason = detector.Check(ctx)
+		}
+		if !changed {
+			log.Printf("💤 %s，跳过AI调用", reason)
+			record.DecisionSource = decision.DecisionSourceNoChange
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("💤 %s，跳过AI调用", reason))
+			record.MarketSnapshot = buildMarketSnapshot(ctx)
+			record.RejectedCoins = buildCoinRejections(ctx)
+			record.SkippedFilters = ctx.SkippedFilters
+			at.decisionLogger.LogDecision(record)
+			return nil
+		}
+		log.Printf("🔔 市场状态变化检测: %s，调用AI", reason)
+	}
+
+	// 4. 获取完整决策（AI决策时启用提示词实验则按变体替换模板和自定义prompt；规则模式不调用AI）
+	templateName := at.systemPromptTemplate
+	customPrompt := at.customPrompt
+	overrideBasePrompt := at.overrideBasePrompt
+	if experiment := at.promptExperiment; experiment != nil && at.GetDecisionMode() != decision.DecisionModeRule {
+		// 按实验自身的计数分配变体：风控暂停、无变化跳过等未调用AI的周期不参与分配
+		if variant := experiment.SelectVariant(at.experimentCalls + 1); variant != nil {
+			at.experimentCalls++
+			if variant.Template != "" && variant.Template != templateName {
+				// 变体使用其他模板：使用文件模板，不使用交易员的数据库模板
+				templateName = variant.Template
+				ctx.PromptTemplate = nil
+			}
+			// 变体未设置自定义prompt时保留交易员的自定义prompt（只改模板的变体不引入第二个变量）
+			if variant.CustomPrompt != "" {
+				customPrompt = variant.CustomPrompt
+				overrideBasePrompt = variant.OverrideBasePrompt
+			}
+			record.PromptVariant = variant.Name
+			log.Printf("🧪 提示词实验(%s): 本周期使用变体 %s", experiment.Mode, variant.Name)
+		}
+	}
+
+	decision, err := at.getDecision(ctx, customPrompt, overrideBasePrompt, templateName)
 
 	// 即使有错误，也保存思维链、决策和输入prompt（用于debug）
 	if decision != nil {
 		record.SystemPrompt = decision.SystemPrompt // 保存系统提示词
+		record.PromptTemplate = decision.TemplateName
+		record.Locale = ctx.Locale
+		record.PromptVersion = decision.TemplateVersion
+		record.DecisionSource = decision.Source
 		record.InputPrompt = decision.UserPrompt
+		if decision.PromptBudget != nil {
+			record.PromptTokens = decision.PromptBudget.TotalTokens()
+			record.PromptCuts = decision.PromptBudget.Cuts
+		}
+		for _, signal := range decision.Signals {
+			record.Signals = append(record.Signals, logger.SignalRecord{
+				Analyzer:  signal.Analyzer,
+				Symbol:    signal.Symbol,
+				Direction: signal.Direction,
+				Strength:  signal.Strength,
+				Message:   signal.Message,
+				Values:    signal.Values,
+			})
+		}
 		record.CoTTrace = decision.CoTTrace
+		record.RawResponse = decision.RawResponse
+		record.RepairedResponses = decision.RepairedResponses
 		if len(decision.Decisions) > 0 {
 			decisionJSON, _ := json.MarshalIndent(decision.Decisions, "", "  ")
 			record.DecisionJSON = string(decisionJSON)
 		}
+		for _, rejected := range decision.RejectedDecisions {
+			rejectedJSON, _ := json.Marshal(rejected.Decision)
+			record.RejectedDecisions = append(record.RejectedDecisions, logger.RejectedDecision{
+				Symbol:       rejected.Decision.Symbol,
+				Action:       rejected.Decision.Action,
+				DecisionJSON: string(rejectedJSON),
+				Error:        rejected.Error,
+			})
+			record.ExecutionLog = append(record.ExecutionLog, fmt.Sprintf("⚠️ %s %s 未通过验证已丢弃: %s", rejected.Decision.Symbol, rejected.Decision.Action, rejected.Error))
+		}
 	}
 
+	// 保存本周期的结构化市场数据（用于特征分析和重放）
+	record.MarketSnapshot = buildMarketSnapshot(ctx)
+	record.RejectedCoins = buildCoinRejections(ctx)
+	record.SkippedFilters = ctx.SkippedFilters
+
 	if err != nil {
 		record.Success = false
 		record.ErrorMessage = fmt.Sprintf("获取AI决策失败: %v", err)
+		if at.changeDetector != nil {
+			at.changeDetector.Reset() // 下个周期重新调用AI
+		}
 
 		// 打印系统提示词和AI思维链（即使有错误，也要输出以便调试）
 		if decision != nil {
 			if decision.SystemPrompt != "" {
-				log.Printf("\n" + strings.Repeat("=", 70))
-				log.Printf("📋 系统提示词 [模板: %s] (错误情况)", at.systemPromptTemplate)
+				log.Print("\n" + strings.Repeat("=", 70))
+				log.Printf("📋 系统提示词 [模板: %s] (错误情况)", templateName)
 				log.Println(strings.Repeat("=", 70))
 				log.Println(decision.SystemPrompt)
-				log.Printf(strings.Repeat("=", 70) + "\n")
+				log.Print(strings.Repeat("=", 70) + "\n")
 			}
 
 			if decision.CoTTrace != "" {
-				log.Printf("\n" + strings.Repeat("-", 70))
+				log.Print("\n" + strings.Repeat("-", 70))
 				log.Println("💭 AI思维链分析（错误情况）:")
 				log.Println(strings.Repeat("-", 70))
 				log.Println(decision.CoTTrace)
-				log.Printf(strings.Repeat("-", 70) + "\n")
+				log.Print(strings.Repeat("-", 70) + "\n")
 			}
 		}
 
 		at.decisionLogger.LogDecision(record)
 		return fmt.Errorf("获取AI决策失败: %w", err)
 	}
+	at.updateChangeDetector(ctx, decision)
 
 	// // 5. 打印系统提示词
 	// log.Printf("\n" + strings.Repeat("=", 70))
@@ -509,6 +671,12 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		}
 		updateTime := at.positionFirstSeenTime[posKey]
 
+		// 按资金费结算历史估算持仓期间的资金费（失败时为0）
+		fundingCost := 0.0
+		if funding, err := at.marketProvider.GetFunding(symbol); err == nil {
+			fundingCost = market.EstimateFundingCost(funding, side, quantity, markPrice, time.UnixMilli(updateTime))
+		}
+
 		positionInfos = append(positionInfos, decision.PositionInfo{
 			Symbol:           symbol,
 			Side:             side,
@@ -521,6 +689,7 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 			LiquidationPrice: liquidationPrice,
 			MarginUsed:       marginUsed,
 			UpdateTime:       updateTime,
+			FundingCost:      fundingCost,
 		})
 	}
 
@@ -536,6 +705,9 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 	if err != nil {
 		return nil, fmt.Errorf("获取候选币种失败: %w", err)
 	}
+	if at.alertTrigger != nil && at.marketProvider.Name() == market.ProviderBinance {
+		candidateCoins = decision.AppendAlertCandidates(candidateCoins, at.alertTrigger, market.WSMonitorCli)
+	}
 
 	// 4. 计算总盈亏
 	totalPnL := totalEquity - at.initialBalance
@@ -565,6 +737,10 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		CallCount:       at.callCount,
 		BTCETHLeverage:  at.config.BTCETHLeverage,  // 使用配置的杠杆倍数
 		AltcoinLeverage: at.config.AltcoinLeverage, // 使用配置的杠杆倍数
+		MaxRepairRounds: at.config.AIRepairRounds,  // 决策修复轮数
+		TraderName:      at.name,                   // 交易员名称（提示词模板变量）
+		PromptTemplate:  at.promptTemplate,         // 数据库版本化提示词模板
+		SignalAnalyzers: at.signalAnalyzers,        // 启用的信号分析器
 		Account: decision.AccountInfo{
 			TotalEquity:      totalEquity,
 			AvailableBalance: availableBalance,
@@ -576,6 +752,10 @@ func (at *AutoTrader) buildTradingContext() (*decision.Context, error) {
 		},
 		Positions:      positionInfos,
 		CandidateCoins: candidateCoins,
+		Locale:         at.GetLocale(),
+		MarketProvider: at.marketProvider,
+		UniverseFilter: at.universeFilter,
+		DataProfile:    at.dataProfile,
 		Performance:    performance, // 添加历史表现分析
 	}
 
@@ -605,6 +785,10 @@ func (at *AutoTrader) executeDecisionWithRecord(decision *decision.Decision, act
 func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	log.Printf("  📈 开多仓: %s", decision.Symbol)
 
+	if err := at.checkMarketDataFresh(decision.Symbol); err != nil {
+		return err
+	}
+
 	// ⚠️ 关键：检查是否已有同币种同方向持仓，如果有则拒绝开仓（防止仓位叠加超限）
 	positions, err := at.trader.GetPositions()
 	if err == nil {
@@ -616,7 +800,7 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.GetWithProvider(at.marketProvider, decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -626,6 +810,11 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 	actionRecord.Quantity = quantity
 	actionRecord.Price = marketData.CurrentPrice
 
+	// 按订单簿预估滑点
+	if err := at.checkSlippage(decision.Symbol, market.SideBuy, decision.PositionSizeUSD); err != nil {
+		return err
+	}
+
 	// 设置仓位模式
 	if err := at.trader.SetMarginMode(decision.Symbol, at.config.IsCrossMargin); err != nil {
 		log.Printf("  ⚠️ 设置仓位模式失败: %v", err)
@@ -664,6 +853,10 @@ func (at *AutoTrader) executeOpenLongWithRecord(decision *decision.Decision, act
 func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, actionRecord *logger.DecisionAction) error {
 	log.Printf("  📉 开空仓: %s", decision.Symbol)
 
+	if err := at.checkMarketDataFresh(decision.Symbol); err != nil {
+		return err
+	}
+
 	// ⚠️ 关键：检查是否已有同币种同方向持仓，如果有则拒绝开仓（防止仓位叠加超限）
 	positions, err := at.trader.GetPositions()
 	if err == nil {
@@ -675,7 +868,7 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	}
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.GetWithProvider(at.marketProvider, decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -685,6 +878,11 @@ func (at *AutoTrader) executeOpenShortWithRecord(decision *decision.Decision, ac
 	actionRecord.Quantity = quantity
 	actionRecord.Price = marketData.CurrentPrice
 
+	// 按订单簿预估滑点
+	if err := at.checkSlippage(decision.Symbol, market.SideSell, decision.PositionSizeUSD); err != nil {
+		return err
+	}
+
 	// 设置仓位模式
 	if err := at.trader.SetMarginMode(decision.Symbol, at.config.IsCrossMargin); err != nil {
 		log.Printf("  ⚠️ 设置仓位模式失败: %v", err)
@@ -724,7 +922,7 @@ func (at *AutoTrader) executeCloseLongWithRecord(decision *decision.Decision, ac
 	log.Printf("  🔄 平多仓: %s", decision.Symbol)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.GetWithProvider(at.marketProvider, decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -750,7 +948,7 @@ func (at *AutoTrader) executeCloseShortWithRecord(decision *decision.Decision, a
 	log.Printf("  🔄 平空仓: %s", decision.Symbol)
 
 	// 获取当前价格
-	marketData, err := market.Get(decision.Symbol)
+	marketData, err := market.GetWithProvider(at.marketProvider, decision.Symbol)
 	if err != nil {
 		return err
 	}
@@ -806,6 +1004,341 @@ func (at *AutoTrader) SetSystemPromptTemplate(templateName string) {
 	at.systemPromptTemplate = templateName
 }
 
+// SetPromptTemplate 设置数据库版本化提示词模板（nil 表示使用文件模板）
+func (at *AutoTrader) SetPromptTemplate(promptTemplate *decision.PromptTemplate) {
+	at.promptTemplate = promptTemplate
+}
+
+// SetPromptExperiment 设置提示词A/B实验（nil 表示停止实验）
+func (at *AutoTrader) SetPromptExperiment(experiment *decision.PromptExperiment) {
+	at.promptExperiment = experiment
+	at.experimentCalls = 0
+}
+
+// SetSignalAnalyzers 设置启用的信号分析器（nil 表示使用默认分析器）
+func (at *AutoTrader) SetSignalAnalyzers(analyzers []decision.SignalAnalyzer) {
+	at.signalAnalyzers = analyzers
+}
+
+// SetDecisionMode 设置决策方式及规则策略（strategy 为 nil 时使用默认规则策略）
+func (at *AutoTrader) SetDecisionMode(mode string, strategy *decision.RuleStrategy) {
+	if mode == "" {
+		mode = decision.DecisionModeAI
+	}
+	if strategy == nil {
+		strategy = decision.DefaultRuleStrategy()
+	}
+	at.decisionMode = mode
+	at.ruleStrategy = strategy
+}
+
+// GetDecisionMode 获取当前决策方式
+func (at *AutoTrader) GetDecisionMode() string {
+	if at.decisionMode == "" {
+		return decision.DecisionModeAI
+	}
+	return at.decisionMode
+}
+
+// GetRuleStrategy 获取当前规则策略
+func (at *AutoTrader) GetRuleStrategy() *decision.RuleStrategy {
+	return at.ruleStrategy
+}
+
+// SetLocale 设置提示词语言（空字符串表示默认语言）
+func (at *AutoTrader) SetLocale(locale string) {
+	at.locale = decision.NormalizeLocale(locale)
+}
+
+// GetLocale 获取当前提示词语言
+func (at *AutoTrader) GetLocale() string {
+	if at.locale == "" {
+		return decision.DefaultLocale
+	}
+	return at.locale
+}
+
+// SetChangeDetector 设置市场状态变化检测（config 为 nil 表示停用）
+func (at *AutoTrader) SetChangeDetector(config *decision.ChangeDetectorConfig) {
+	if config == nil {
+		at.changeDetector = nil
+		return
+	}
+	at.changeDetector = decision.NewChangeDetector(config)
+}
+
+// GetChangeDetectorConfig 获取市场状态变化检测配置（未启用时返回nil）
+func (at *AutoTrader) GetChangeDetectorConfig() *decision.ChangeDetectorConfig {
+	if at.changeDetector == nil {
+		return nil
+	}
+	return at.changeDetector.Config()
+}
+
+// updateChangeDetector 记录本次调用AI时的市场状态（规则回退等未调用AI的决策不作为比较基准）
+func (at *AutoTrader) updateChangeDetector(ctx *decision.Context, fullDecision *decision.FullDecision) {
+	if at.changeDetector == nil {
+		return
+	}
+	switch fullDecision.Source {
+	case decision.DecisionSourceAI, decision.DecisionSourceExternal:
+		at.changeDetector.Update(ctx)
+	default:
+		at.changeDetector.Reset()
+	}
+}
+
+// syncMarketSubscriptions 按本周期需要的币种（持仓+候选）更新K线流、订单簿流和归集成交流订阅，不再需要的币种释放引用
+// 只有币安行情使用 WSMonitor 的WebSocket缓存
+func (at *AutoTrader) syncMarketSubscriptions(ctx *decision.Context) {
+	if at.marketProvider.Name() != market.ProviderBinance || market.WSMonitorCli == nil {
+		return
+	}
+
+	// 行情数据配置的周期变化时释放原有订阅，按新的周期重新订阅
+	intervals := at.dataProfile.StreamIntervals()
+	if strings.Join(intervals, ",") != strings.Join(at.subscribedIntervals, ",") {
+		at.releaseMarketSubscriptions()
+		at.subscribedIntervals = intervals
+	}
+
+	wanted := make(map[string]bool)
+	for _, pos := range ctx.Positions {
+		wanted[market.Normalize(pos.Symbol)] = true
+	}
+	for _, coin := range ctx.CandidateCoins {
+		wanted[market.Normalize(coin.Symbol)] = true
+	}
+
+	var added, removed []string
+	for symbol := range wanted {
+		if !at.subscribedCoins[symbol] {
+			added = append(added, symbol)
+		}
+	}
+	for symbol := range at.subscribedCoins {
+		if !wanted[symbol] {
+			removed = append(removed, symbol)
+		}
+	}
+
+	if len(removed) > 0 {
+		market.WSMonitorCli.UnsubscribeIntervals(removed, at.subscribedIntervals)
+		market.WSMonitorCli.UnsubscribeDepth(removed)
+		market.WSMonitorCli.UnsubscribeAggTrades(removed)
+		for _, symbol := range removed {
+			delete(at.subscribedCoins, symbol)
+		}
+	}
+	if len(added) > 0 {
+		// 订阅失败的调用不持有引用（WSMonitor 已回滚），只需释放之前成功步骤的引用；
+		// added 未记入 subscribedCoins，下个周期会重新订阅
+		if err := market.WSMonitorCli.SubscribeIntervals(added, at.subscribedIntervals); err != nil {
+			log.Printf("⚠️  [%s] 订阅K线流失败（本周期使用REST获取）: %v", at.name, err)
+			return
+		}
+		if err := market.WSMonitorCli.SubscribeDepth(added); err != nil {
+			market.WSMonitorCli.UnsubscribeIntervals(added, at.subscribedIntervals)
+			log.Printf("⚠️  [%s] 订阅订单簿流失败（本周期使用REST获取）: %v", at.name, err)
+			return
+		}
+		if err := market.WSMonitorCli.SubscribeAggTrades(added); err != nil {
+			market.WSMonitorCli.UnsubscribeDepth(added)
+			market.WSMonitorCli.UnsubscribeIntervals(added, at.subscribedIntervals)
+			log.Printf("⚠️  [%s] 订阅归集成交流失败（本周期使用REST获取）: %v", at.name, err)
+			return
+		}
+		for _, symbol := range added {
+			at.subscribedCoins[symbol] = true
+		}
+	}
+}
+
+// checkMarketDataFresh 开仓前检查行情数据是否过时（启用 BlockStaleOpens 时，过时则拒绝开仓）
+func (at *AutoTrader) checkMarketDataFresh(symbol string) error {
+	if !at.config.BlockStaleOpens || at.marketProvider.Name() != market.ProviderBinance || market.WSMonitorCli == nil {
+		return nil
+	}
+	if reason := market.WSMonitorCli.StaleReason(symbol); reason != "" {
+		return fmt.Errorf("❌ %s 行情数据过时（%s），拒绝开仓", symbol, reason)
+	}
+	return nil
+}
+
+// checkSlippage 开仓前按订单簿预估市价单滑点（设置 MaxSlippagePct 时，超过上限或深度不足则拒绝开仓）
+// 获取订单簿失败时不阻止开仓
+func (at *AutoTrader) checkSlippage(symbol, side string, notionalUSD float64) error {
+	book, err := at.marketProvider.GetOrderBook(symbol)
+	if err != nil {
+		log.Printf("  ⚠️ 获取%s订单簿失败，跳过滑点检查: %v", symbol, err)
+		return nil
+	}
+
+	estimate, err := book.EstimateSlippage(side, notionalUSD)
+	if err != nil {
+		if at.config.MaxSlippagePct > 0 {
+			return fmt.Errorf("❌ %s 订单簿无法预估滑点（%v），拒绝开仓", symbol, err)
+		}
+		log.Printf("  ⚠️ %s 预估滑点: %v", symbol, err)
+		return nil
+	}
+
+	log.Printf("  📖 %s 预估成交均价 %.4f，滑点 %.4f%%（名义价值 %.0f USD）", symbol, estimate.AvgPrice, estimate.SlippagePct, notionalUSD)
+	if at.config.MaxSlippagePct > 0 && estimate.SlippagePct > at.config.MaxSlippagePct {
+		return fmt.Errorf("❌ %s 预估滑点 %.4f%% 超过上限 %.4f%%，拒绝开仓", symbol, estimate.SlippagePct, at.config.MaxSlippagePct)
+	}
+	return nil
+}
+
+// releaseMarketSubscriptions 释放交易员持有的全部K线流、订单簿流和归集成交流订阅（停止运行时调用）
+func (at *AutoTrader) releaseMarketSubscriptions() {
+	if len(at.subscribedCoins) == 0 || market.WSMonitorCli == nil {
+		return
+	}
+
+	symbols := make([]string, 0, len(at.subscribedCoins))
+	for symbol := range at.subscribedCoins {
+		symbols = append(symbols, symbol)
+	}
+	market.WSMonitorCli.UnsubscribeIntervals(symbols, at.subscribedIntervals)
+	market.WSMonitorCli.UnsubscribeDepth(symbols)
+	market.WSMonitorCli.UnsubscribeAggTrades(symbols)
+	at.subscribedCoins = make(map[string]bool)
+}
+
+// SetUniverseFilter 设置候选币种筛选条件（nil 表示使用默认条件）
+func (at *AutoTrader) SetUniverseFilter(filter *decision.UniverseFilter) {
+	if filter == nil {
+		filter = decision.DefaultUniverseFilter()
+	}
+	at.universeFilter = filter
+}
+
+// GetUniverseFilter 获取候选币种筛选条件
+func (at *AutoTrader) GetUniverseFilter() *decision.UniverseFilter {
+	return at.universeFilter
+}
+
+// SetDataProfile 设置行情数据配置（nil 表示使用原有格式）
+func (at *AutoTrader) SetDataProfile(profile *market.DataProfile) {
+	if profile.IsDefault() {
+		profile = nil
+	}
+	at.dataProfile = profile
+}
+
+// GetDataProfile 获取行情数据配置（nil 表示使用原有格式）
+func (at *AutoTrader) GetDataProfile() *market.DataProfile {
+	return at.dataProfile
+}
+
+// SetAlertTrigger 设置行情警报配置（nil 表示不使用警报）
+func (at *AutoTrader) SetAlertTrigger(config *decision.AlertTriggerConfig) {
+	at.alertTrigger = config
+}
+
+// GetAlertTrigger 获取行情警报配置（未启用时返回nil）
+func (at *AutoTrader) GetAlertTrigger() *decision.AlertTriggerConfig {
+	return at.alertTrigger
+}
+
+// shouldTriggerOnAlert 警报是否需要提前执行交易周期：持仓/候选币种的警报，或评分达到加入候选条件的币种的警报
+func (at *AutoTrader) shouldTriggerOnAlert(alert market.Alert) bool {
+	cfg := at.alertTrigger
+	if cfg == nil || !cfg.TriggerCycle || !cfg.Accepts(alert.Type) {
+		return false
+	}
+	if time.Since(at.lastCycleTime) < cfg.Cooldown() {
+		return false
+	}
+	if at.subscribedCoins[alert.Symbol] {
+		return true
+	}
+	return cfg.AddCandidates && market.WSMonitorCli.GetSymbolScore(alert.Symbol) >= cfg.MinScore
+}
+
+// buildCoinRejections 转换本周期未进入候选的币种及原因（用于决策日志）
+func buildCoinRejections(ctx *decision.Context) []logger.CoinRejection {
+	if len(ctx.RejectedCoins) == 0 {
+		return nil
+	}
+	rejections := make([]logger.CoinRejection, 0, len(ctx.RejectedCoins))
+	for _, rejected := range ctx.RejectedCoins {
+		rejections = append(rejections, logger.CoinRejection{Symbol: rejected.Symbol, Reason: rejected.Reason})
+	}
+	return rejections
+}
+
+// buildMarketSnapshot 从交易上下文生成市场数据快照（未获取到市场数据时返回nil）
+func buildMarketSnapshot(ctx *decision.Context) *logger.MarketSnapshot {
+	if len(ctx.MarketDataMap) == 0 {
+		return nil
+	}
+
+	snapshot := &logger.MarketSnapshot{
+		CurrentTime:    ctx.CurrentTime,
+		RuntimeMinutes: ctx.RuntimeMinutes,
+		CallCount:      ctx.CallCount,
+		CandidateCoins: make([]logger.CandidateSnapshot, 0, len(ctx.CandidateCoins)),
+		MarketData:     ctx.MarketDataMap,
+	}
+	for _, coin := range ctx.CandidateCoins {
+		snapshot.CandidateCoins = append(snapshot.CandidateCoins, logger.CandidateSnapshot{
+			Symbol:  coin.Symbol,
+			Sources: coin.Sources,
+		})
+	}
+	if len(ctx.OITopDataMap) > 0 {
+		snapshot.OITop = make(map[string]*logger.OITopSnapshot, len(ctx.OITopDataMap))
+		for symbol, oi := range ctx.OITopDataMap {
+			snapshot.OITop[symbol] = &logger.OITopSnapshot{
+				Rank:              oi.Rank,
+				OIDeltaPercent:    oi.OIDeltaPercent,
+				OIDeltaValue:      oi.OIDeltaValue,
+				PriceDeltaPercent: oi.PriceDeltaPercent,
+				NetLong:           oi.NetLong,
+				NetShort:          oi.NetShort,
+			}
+		}
+	}
+	return snapshot
+}
+
+// getDecision 按决策方式获取本周期决策（AI或外部决策服务 / 规则策略 / AI不可用时回退到规则策略）
+func (at *AutoTrader) getDecision(ctx *decision.Context, customPrompt string, overrideBasePrompt bool, templateName string) (*decision.FullDecision, error) {
+	mode := at.GetDecisionMode()
+	if mode == decision.DecisionModeRule {
+		log.Printf("📐 正在按规则策略决策... [策略: %s]", at.ruleStrategy.Name)
+		return decision.GetRuleBasedDecision(ctx, at.ruleStrategy)
+	}
+
+	var fullDecision *decision.FullDecision
+	var err error
+	if at.externalProvider != nil {
+		log.Printf("🌐 正在请求外部决策服务...")
+		fullDecision, err = decision.GetExternalDecision(ctx, at.externalProvider)
+	} else {
+		log.Printf("🤖 正在请求AI分析并决策... [模板: %s]", templateName)
+		fullDecision, err = decision.GetFullDecisionWithCustomPrompt(ctx, at.mcpClient, customPrompt, overrideBasePrompt, templateName)
+	}
+	if err == nil || mode != decision.DecisionModeAIRuleFallback || !errors.Is(err, decision.ErrAIUnavailable) {
+		return fullDecision, err
+	}
+
+	log.Printf("⚠️  %v，本周期回退到规则策略 %s", err, at.ruleStrategy.Name)
+	fallback, fallbackErr := decision.GetRuleBasedDecision(ctx, at.ruleStrategy)
+	if fallbackErr != nil {
+		return nil, fmt.Errorf("%v；规则策略回退失败: %w", err, fallbackErr)
+	}
+	fallback.Source = decision.DecisionSourceRuleFallback
+	return fallback, nil
+}
+
+// GetPromptExperiment 获取当前提示词实验配置
+func (at *AutoTrader) GetPromptExperiment() *decision.PromptExperiment {
+	return at.promptExperiment
+}
+
 // GetSystemPromptTemplate 获取当前系统提示词模板名称
 func (at *AutoTrader) GetSystemPromptTemplate() string {
 	return at.systemPromptTemplate
@@ -824,19 +1357,28 @@ func (at *AutoTrader) GetStatus() map[string]interface{} {
 	}
 
 	return map[string]interface{}{
-		"trader_id":       at.id,
-		"trader_name":     at.name,
-		"ai_model":        at.aiModel,
-		"exchange":        at.exchange,
-		"is_running":      at.isRunning,
-		"start_time":      at.startTime.Format(time.RFC3339),
-		"runtime_minutes": int(time.Since(at.startTime).Minutes()),
-		"call_count":      at.callCount,
-		"initial_balance": at.initialBalance,
-		"scan_interval":   at.config.ScanInterval.String(),
-		"stop_until":      at.stopUntil.Format(time.RFC3339),
-		"last_reset_time": at.lastResetTime.Format(time.RFC3339),
-		"ai_provider":     aiProvider,
+		"trader_id":         at.id,
+		"trader_name":       at.name,
+		"ai_model":          at.aiModel,
+		"exchange":          at.exchange,
+		"market_data":       at.marketProvider.Name(),
+		"block_stale_opens": at.config.BlockStaleOpens,
+		"max_slippage_pct":  at.config.MaxSlippagePct,
+		"is_running":        at.isRunning,
+		"start_time":        at.startTime.Format(time.RFC3339),
+		"runtime_minutes":   int(time.Since(at.startTime).Minutes()),
+		"call_count":        at.callCount,
+		"initial_balance":   at.initialBalance,
+		"scan_interval":     at.config.ScanInterval.String(),
+		"stop_until":        at.stopUntil.Format(time.RFC3339),
+		"last_reset_time":   at.lastResetTime.Format(time.RFC3339),
+		"ai_provider":       aiProvider,
+		"decision_mode":     at.GetDecisionMode(),
+		"locale":            at.GetLocale(),
+		"change_detector":   at.GetChangeDetectorConfig(),
+		"universe_filter":   at.GetUniverseFilter(),
+		"data_profile":      at.GetDataProfile(),
+		"alert_trigger":     at.GetAlertTrigger(),
 	}
 }
 